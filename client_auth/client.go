@@ -404,6 +404,9 @@ func (c *Client) ClearLockout(ctx context.Context, cookies []*http.Cookie) (Clea
 
 func (c *Client) InitiateRecovery(ctx context.Context, req InitiateRecoveryRequest) (InitiateRecoveryResponse, int, error) {
 	var resp InitiateRecoveryResponse
+	if err := req.Normalise(); err != nil {
+		return resp, 0, err
+	}
 	body, err := json.Marshal(req)
 	if err != nil {
 		return resp, 0, err
@@ -425,6 +428,9 @@ func (c *Client) InitiateRecovery(ctx context.Context, req InitiateRecoveryReque
 
 func (c *Client) VerifyRecoveryCode(ctx context.Context, req VerifyRecoveryCodeRequest) (VerifyRecoveryCodeResponse, int, error) {
 	var resp VerifyRecoveryCodeResponse
+	if err := req.Normalise(); err != nil {
+		return resp, 0, err
+	}
 	body, err := json.Marshal(req)
 	if err != nil {
 		return resp, 0, err
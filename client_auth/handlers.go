@@ -2,9 +2,11 @@ package client_auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/shared_email"
 )
 
 // ValidateSessionHandler proxies GET /api/session.
@@ -317,7 +319,11 @@ func InitiateRecoveryHandler(c *Client) http.HandlerFunc {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
+		// The client normalises the email and rejects invalid addresses.
 		resp, code, err := c.InitiateRecovery(r.Context(), req)
+		if errors.Is(err, shared_email.ErrInvalidAddress) {
+			code = http.StatusBadRequest
+		}
 		if err != nil {
 			http.Error(w, err.Error(), code)
 			return
@@ -337,6 +343,9 @@ func VerifyRecoveryCodeHandler(c *Client) http.HandlerFunc {
 			return
 		}
 		resp, code, err := c.VerifyRecoveryCode(r.Context(), req)
+		if errors.Is(err, shared_email.ErrInvalidAddress) {
+			code = http.StatusBadRequest
+		}
 		if err != nil {
 			http.Error(w, err.Error(), code)
 			return
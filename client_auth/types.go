@@ -1,5 +1,7 @@
 package client_auth
 
+import "github.com/hstles/go-sdk/shared_email"
+
 // SessionResponse is returned by GET /api/session
 type SessionResponse struct {
 	Valid    bool   `json:"valid"`
@@ -147,6 +149,16 @@ type InitiateRecoveryRequest struct {
 	Email string `json:"email"`
 }
 
+// Normalise validates Email and rewrites it in the form shared_email stores.
+func (r *InitiateRecoveryRequest) Normalise() error {
+	email, err := shared_email.Normalise(r.Email)
+	if err != nil {
+		return err
+	}
+	r.Email = email
+	return nil
+}
+
 // InitiateRecoveryResponse represents the response for initiating recovery
 type InitiateRecoveryResponse struct {
 	Success bool   `json:"success"`
@@ -160,6 +172,16 @@ type VerifyRecoveryCodeRequest struct {
 	RecoveryCode string `json:"recovery_code"`
 }
 
+// Normalise validates Email and rewrites it in the form shared_email stores.
+func (r *VerifyRecoveryCodeRequest) Normalise() error {
+	email, err := shared_email.Normalise(r.Email)
+	if err != nil {
+		return err
+	}
+	r.Email = email
+	return nil
+}
+
 // VerifyRecoveryCodeResponse represents the response for verifying recovery code
 type VerifyRecoveryCodeResponse struct {
 	Success     bool   `json:"success"`
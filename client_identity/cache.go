@@ -0,0 +1,471 @@
+package client_identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTTLs sets how long each kind of resource is served from cache.
+// A zero TTL disables caching for that resource.
+type CacheTTLs struct {
+	Plans         time.Duration
+	Users         time.Duration
+	Organisations time.Duration
+	Members       time.Duration
+
+	// StaleWhileRevalidate is how long past its TTL an entry may still be
+	// served while it is refreshed in the background.
+	StaleWhileRevalidate time.Duration
+}
+
+// DefaultCacheTTLs returns TTLs suited to rarely changing identity data.
+func DefaultCacheTTLs() CacheTTLs {
+	return CacheTTLs{
+		Plans:                10 * time.Minute,
+		Users:                time.Minute,
+		Organisations:        time.Minute,
+		Members:              30 * time.Second,
+		StaleWhileRevalidate: 30 * time.Second,
+	}
+}
+
+// CachedClient decorates Client with a read-through cache for plans, users,
+// organisations and members. Cached responses are revalidated with
+// If-None-Match when the service returns ETags, and writes made through the
+// CachedClient invalidate the affected entries. Methods not overridden here
+// go straight to the wrapped Client.
+//
+// Entries are swept once they are past their TTL and stale window, so
+// per-session copies do not outlive the sessions that fetched them.
+type CachedClient struct {
+	*Client
+	ttls CacheTTLs
+
+	mu         sync.Mutex
+	entries    map[string]map[string]*cacheEntry // resource -> caller variant -> entry
+	refreshing map[string]bool
+	// fetches tracks resources being fetched; invalidating one bumps its
+	// generation, and InvalidateAll and InvalidatePlans bump epoch, so a
+	// response that raced an invalidation is not stored.
+	fetches   map[string]*fetchState
+	epoch     uint64
+	nextSweep time.Time
+}
+
+type fetchState struct {
+	inFlight   int
+	generation uint64
+}
+
+type cacheEntry struct {
+	body    []byte
+	etag    string
+	fetched time.Time
+	ttl     time.Duration
+}
+
+// expired reports whether e can no longer be served, even stale.
+func (e *cacheEntry) expired(now time.Time, stale time.Duration) bool {
+	return now.Sub(e.fetched) >= e.ttl+stale
+}
+
+// cacheSweepInterval is how often expired entries are dropped.
+const cacheSweepInterval = time.Minute
+
+// NewCachedClient wraps c with a cache using ttls.
+func NewCachedClient(c *Client, ttls CacheTTLs) *CachedClient {
+	return &CachedClient{
+		Client:     c,
+		ttls:       ttls,
+		entries:    make(map[string]map[string]*cacheEntry),
+		refreshing: make(map[string]bool),
+		fetches:    make(map[string]*fetchState),
+	}
+}
+
+// generation identifies the invalidation state of a resource.
+type generation struct{ epoch, n uint64 }
+
+// beginFetch records a fetch of resource and returns its generation.
+func (cc *CachedClient) beginFetch(resource string) generation {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	f := cc.fetches[resource]
+	if f == nil {
+		f = &fetchState{}
+		cc.fetches[resource] = f
+	}
+	f.inFlight++
+	return generation{cc.epoch, f.generation}
+}
+
+// endFetchLocked ends a fetch started at gen and reports whether resource
+// was invalidated meanwhile. cc.mu must be held.
+func (cc *CachedClient) endFetchLocked(resource string, gen generation) (invalidated bool) {
+	f := cc.fetches[resource]
+	invalidated = generation{cc.epoch, f.generation} != gen
+	if f.inFlight--; f.inFlight == 0 {
+		delete(cc.fetches, resource)
+	}
+	return invalidated
+}
+
+// sweepLocked drops expired entries at most once per cacheSweepInterval.
+// cc.mu must be held.
+func (cc *CachedClient) sweepLocked(now time.Time) {
+	if now.Before(cc.nextSweep) {
+		return
+	}
+	for resource, variants := range cc.entries {
+		for variant, entry := range variants {
+			if entry.expired(now, cc.ttls.StaleWhileRevalidate) {
+				delete(variants, variant)
+			}
+		}
+		if len(variants) == 0 {
+			delete(cc.entries, resource)
+		}
+	}
+	cc.nextSweep = now.Add(cacheSweepInterval)
+}
+
+// Resource keys used for invalidation.
+func planKey(planID string) string   { return "plan:" + planID }
+func userKey(userID string) string   { return "user:" + userID }
+func orgKey(orgID string) string     { return "org:" + orgID }
+func membersKey(orgID string) string { return "members:" + orgID }
+
+const plansKey = "plans"
+
+// authFunc adds credentials to an outgoing request.
+type authFunc func(*http.Request)
+
+func withAPIKey(apiKey string) authFunc {
+	return func(r *http.Request) { r.Header.Set("X-API-Key", apiKey) }
+}
+
+func withCookies(cookies []*http.Cookie) authFunc {
+	return func(r *http.Request) {
+		for _, ck := range cookies {
+			r.AddCookie(ck)
+		}
+	}
+}
+
+// cookieVariant keys session-authorised entries by caller so one user's
+// cached view is never served to another.
+func cookieVariant(cookies []*http.Cookie) string {
+	h := sha256.New()
+	for _, ck := range cookies {
+		io.WriteString(h, ck.Name+"="+ck.Value+";")
+	}
+	return "session:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func variantKey(resource, variant string) string { return resource + "\x00" + variant }
+
+// cachedGet serves path from cache when fresh, serves it stale while
+// refreshing in the background within the stale window, and otherwise
+// revalidates it synchronously.
+func cachedGet[T any](cc *CachedClient, ctx context.Context, resource, variant string, ttl time.Duration, path string, auth authFunc) (T, int, error) {
+	var resp T
+	body, status, err := cc.cachedBody(ctx, resource, variant, ttl, path, auth)
+	if err != nil {
+		return resp, status, err
+	}
+	err = json.Unmarshal(body, &resp)
+	return resp, status, err
+}
+
+// cachedBody is cachedGet before decoding.
+func (cc *CachedClient) cachedBody(ctx context.Context, resource, variant string, ttl time.Duration, path string, auth authFunc) ([]byte, int, error) {
+	if ttl <= 0 {
+		body, _, status, err := cc.conditionalGet(ctx, path, auth, "")
+		return body, status, err
+	}
+
+	cc.mu.Lock()
+	entry := cc.entries[resource][variant]
+	cc.mu.Unlock()
+
+	if entry != nil {
+		age := time.Since(entry.fetched)
+		switch {
+		case age < ttl:
+			return entry.body, http.StatusOK, nil
+		case age < ttl+cc.ttls.StaleWhileRevalidate:
+			cc.refreshInBackground(resource, variant, ttl, path, auth, entry.etag)
+			return entry.body, http.StatusOK, nil
+		}
+	}
+
+	etag := ""
+	if entry != nil {
+		etag = entry.etag
+	}
+	return cc.revalidate(ctx, resource, variant, ttl, path, auth, etag)
+}
+
+// revalidate fetches path (conditionally when etag is set), updates the cache
+// and returns the current body. A response fetched across an invalidation
+// of resource is returned but not stored.
+func (cc *CachedClient) revalidate(ctx context.Context, resource, variant string, ttl time.Duration, path string, auth authFunc, etag string) ([]byte, int, error) {
+	gen := cc.beginFetch(resource)
+	body, newETag, status, err := cc.conditionalGet(ctx, path, auth, etag)
+	cc.mu.Lock()
+	invalidated := cc.endFetchLocked(resource, gen)
+	if err != nil {
+		cc.mu.Unlock()
+		return body, status, err
+	}
+	if invalidated {
+		cc.mu.Unlock()
+		if status == http.StatusNotModified {
+			return cc.revalidate(ctx, resource, variant, ttl, path, auth, "")
+		}
+		return body, status, nil
+	}
+	now := time.Now()
+	switch status {
+	case http.StatusNotModified:
+		entry := cc.entries[resource][variant]
+		if entry == nil {
+			// Swept while revalidating; fetch the full response instead.
+			cc.mu.Unlock()
+			return cc.revalidate(ctx, resource, variant, ttl, path, auth, "")
+		}
+		entry.fetched = now
+		cc.mu.Unlock()
+		return entry.body, http.StatusOK, nil
+	case http.StatusOK:
+		cc.sweepLocked(now)
+		if cc.entries[resource] == nil {
+			cc.entries[resource] = make(map[string]*cacheEntry)
+		}
+		cc.entries[resource][variant] = &cacheEntry{body: body, etag: newETag, fetched: now, ttl: ttl}
+	default:
+		// Do not keep serving an entry the service no longer returns.
+		delete(cc.entries[resource], variant)
+	}
+	cc.mu.Unlock()
+	return body, status, nil
+}
+
+func (cc *CachedClient) refreshInBackground(resource, variant string, ttl time.Duration, path string, auth authFunc, etag string) {
+	key := variantKey(resource, variant)
+	cc.mu.Lock()
+	if cc.refreshing[key] {
+		cc.mu.Unlock()
+		return
+	}
+	cc.refreshing[key] = true
+	cc.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cc.mu.Lock()
+			delete(cc.refreshing, key)
+			cc.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), cc.HTTPClient.Timeout)
+		defer cancel()
+		if _, _, err := cc.revalidate(ctx, resource, variant, ttl, path, auth, etag); err != nil {
+			log.Printf("client_identity: background refresh of %s failed: %v", path, err)
+		}
+	}()
+}
+
+func (cc *CachedClient) conditionalGet(ctx context.Context, path string, auth authFunc, etag string) ([]byte, string, int, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, cc.BaseURL+path, nil)
+	auth(req)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	r, err := cc.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", r.StatusCode, err
+	}
+	return body, r.Header.Get("ETag"), r.StatusCode, nil
+}
+
+// ============== Cached Reads ==============
+
+func (cc *CachedClient) ListPlans(ctx context.Context) ([]Plan, int, error) {
+	return cachedGet[[]Plan](cc, ctx, plansKey, "public", cc.ttls.Plans, "/api/plans", func(*http.Request) {})
+}
+
+func (cc *CachedClient) GetPlan(ctx context.Context, planID string) (Plan, int, error) {
+	return cachedGet[Plan](cc, ctx, planKey(planID), "public", cc.ttls.Plans, fmt.Sprintf("/api/plans/%s", planID), func(*http.Request) {})
+}
+
+func (cc *CachedClient) GetUserByID(ctx context.Context, apiKey, userID string) (User, int, error) {
+	return cachedGet[User](cc, ctx, userKey(userID), "service", cc.ttls.Users, fmt.Sprintf("/api/users/%s", userID), withAPIKey(apiKey))
+}
+
+func (cc *CachedClient) GetUser(ctx context.Context, cookies []*http.Cookie, userID string) (User, int, error) {
+	return cachedGet[User](cc, ctx, userKey(userID), cookieVariant(cookies), cc.ttls.Users, fmt.Sprintf("/api/users/%s", userID), withCookies(cookies))
+}
+
+func (cc *CachedClient) GetOrganisation(ctx context.Context, cookies []*http.Cookie, orgID string) (Organisation, int, error) {
+	return cachedGet[Organisation](cc, ctx, orgKey(orgID), cookieVariant(cookies), cc.ttls.Organisations, fmt.Sprintf("/api/organisations/%s", orgID), withCookies(cookies))
+}
+
+func (cc *CachedClient) ListMembers(ctx context.Context, cookies []*http.Cookie, orgID string) ([]Member, int, error) {
+	return cachedGet[[]Member](cc, ctx, membersKey(orgID), cookieVariant(cookies), cc.ttls.Members, fmt.Sprintf("/api/organisations/%s/members", orgID), withCookies(cookies))
+}
+
+// ListMembersPage caches each page of orgID's members; invalidating the
+// members drops every page.
+func (cc *CachedClient) ListMembersPage(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) (Page[Member], int, error) {
+	path := fmt.Sprintf("/api/organisations/%s/members", orgID)
+	query := opts.Values().Encode()
+	if query != "" {
+		path += "?" + query
+	}
+	body, status, err := cc.cachedBody(ctx, membersKey(orgID), cookieVariant(cookies)+"?"+query, cc.ttls.Members, path, withCookies(cookies))
+	if err != nil {
+		return Page[Member]{}, status, err
+	}
+	if status != http.StatusOK {
+		return Page[Member]{}, status, &StatusError{StatusCode: status}
+	}
+	page, err := decodePage[Member](body)
+	return page, status, err
+}
+
+// AllMembers iterates over every member of orgID matching opts, reading
+// pages through the cache.
+func (cc *CachedClient) AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) iter.Seq2[Member, error] {
+	return paginate(opts, func(o ListOptions) (Page[Member], int, error) {
+		return cc.ListMembersPage(ctx, cookies, orgID, o)
+	})
+}
+
+// ============== Invalidating Writes ==============
+
+func (cc *CachedClient) UpdateUser(ctx context.Context, cookies []*http.Cookie, userID string, req UpdateUserRequest) (User, int, error) {
+	resp, code, err := cc.Client.UpdateUser(ctx, cookies, userID, req)
+	cc.InvalidateUser(userID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) DeleteUser(ctx context.Context, cookies []*http.Cookie, userID string) (DeleteResponse, int, error) {
+	resp, code, err := cc.Client.DeleteUser(ctx, cookies, userID)
+	cc.InvalidateUser(userID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) UpdateOrganisation(ctx context.Context, cookies []*http.Cookie, orgID string, req UpdateOrganisationRequest) (Organisation, int, error) {
+	resp, code, err := cc.Client.UpdateOrganisation(ctx, cookies, orgID, req)
+	cc.InvalidateOrganisation(orgID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) DeleteOrganisation(ctx context.Context, cookies []*http.Cookie, orgID string) (DeleteResponse, int, error) {
+	resp, code, err := cc.Client.DeleteOrganisation(ctx, cookies, orgID)
+	cc.InvalidateOrganisation(orgID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) AddMember(ctx context.Context, cookies []*http.Cookie, orgID string, req AddMemberRequest) (Member, int, error) {
+	resp, code, err := cc.Client.AddMember(ctx, cookies, orgID, req)
+	cc.InvalidateMembers(orgID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) UpdateMemberStatus(ctx context.Context, cookies []*http.Cookie, orgID, userID string, req UpdateMemberStatusRequest) (Member, int, error) {
+	resp, code, err := cc.Client.UpdateMemberStatus(ctx, cookies, orgID, userID, req)
+	cc.InvalidateMembers(orgID)
+	return resp, code, err
+}
+
+func (cc *CachedClient) RemoveMember(ctx context.Context, cookies []*http.Cookie, orgID, userID string) (DeleteResponse, int, error) {
+	resp, code, err := cc.Client.RemoveMember(ctx, cookies, orgID, userID)
+	cc.InvalidateMembers(orgID)
+	return resp, code, err
+}
+
+// ============== Invalidation ==============
+
+func (cc *CachedClient) invalidate(resources ...string) {
+	cc.mu.Lock()
+	for _, resource := range resources {
+		delete(cc.entries, resource)
+		if f := cc.fetches[resource]; f != nil {
+			f.generation++
+		}
+	}
+	cc.mu.Unlock()
+}
+
+// InvalidateUser drops every cached copy of userID.
+func (cc *CachedClient) InvalidateUser(userID string) { cc.invalidate(userKey(userID)) }
+
+// InvalidateOrganisation drops every cached copy of orgID and its member list.
+func (cc *CachedClient) InvalidateOrganisation(orgID string) {
+	cc.invalidate(orgKey(orgID), membersKey(orgID))
+}
+
+// InvalidateMembers drops the cached member lists of orgID.
+func (cc *CachedClient) InvalidateMembers(orgID string) { cc.invalidate(membersKey(orgID)) }
+
+// InvalidatePlans drops the cached plan list and every cached plan.
+func (cc *CachedClient) InvalidatePlans() {
+	cc.mu.Lock()
+	for resource := range cc.entries {
+		if resource == plansKey || strings.HasPrefix(resource, "plan:") {
+			delete(cc.entries, resource)
+		}
+	}
+	// Plans being fetched have no entry yet; invalidating everything stops
+	// them being stored too.
+	cc.epoch++
+	cc.mu.Unlock()
+}
+
+// InvalidateAll empties the cache.
+func (cc *CachedClient) InvalidateAll() {
+	cc.mu.Lock()
+	cc.entries = make(map[string]map[string]*cacheEntry)
+	cc.epoch++
+	cc.mu.Unlock()
+}
+
+// InvalidateForEvent drops entries affected by an identity event, such as one
+// delivered by webhook. Event types are matched on their resource prefix
+// ("user.", "organisation.", "member.", "plan.").
+func (cc *CachedClient) InvalidateForEvent(eventType, userID, orgID string) {
+	resource, _, _ := strings.Cut(eventType, ".")
+	switch resource {
+	case "user":
+		cc.InvalidateUser(userID)
+	case "organisation":
+		cc.InvalidateOrganisation(orgID)
+	case "member":
+		cc.InvalidateMembers(orgID)
+	case "plan":
+		cc.InvalidatePlans()
+	default:
+		if userID != "" {
+			cc.InvalidateUser(userID)
+		}
+		if orgID != "" {
+			cc.InvalidateOrganisation(orgID)
+		}
+	}
+}
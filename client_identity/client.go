@@ -7,9 +7,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
+
+	"github.com/hstles/go-sdk/shared_email"
 )
 
+// MaxBatchSize is the most IDs sent in a single batch lookup.
+const MaxBatchSize = 100
+
 // Client wraps calls to your central identity service.
 type Client struct {
 	BaseURL    string
@@ -90,7 +96,22 @@ func (c *Client) GetPlan(ctx context.Context, planID string) (Plan, int, error)
 
 // ============== Users (Service API - require API key) ==============
 
+// GetUserByEmail looks up a user by email, normalised with shared_email.
+// Users created before emails were normalised may be stored with the address
+// as they typed it, so a 404 is retried with email exactly as given.
 func (c *Client) GetUserByEmail(ctx context.Context, apiKey, email string) (User, int, error) {
+	normalised, err := shared_email.Normalise(email)
+	if err != nil {
+		return User{}, 0, err
+	}
+	resp, status, err := c.getUserByEmail(ctx, apiKey, normalised)
+	if status == http.StatusNotFound && email != normalised {
+		return c.getUserByEmail(ctx, apiKey, email)
+	}
+	return resp, status, err
+}
+
+func (c *Client) getUserByEmail(ctx context.Context, apiKey, email string) (User, int, error) {
 	var resp User
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/users/email/%s", c.BaseURL, url.QueryEscape(email)), nil)
 	req.Header.Set("X-API-Key", apiKey)
@@ -122,8 +143,12 @@ func (c *Client) GetUserByID(ctx context.Context, apiKey, userID string) (User,
 	return resp, status, nil
 }
 
+// CreateUser creates a user; req.Email is normalised first.
 func (c *Client) CreateUser(ctx context.Context, apiKey string, req CreateUserRequest) (User, int, error) {
 	var resp User
+	if err := req.Normalise(); err != nil {
+		return resp, 0, err
+	}
 	body, err := json.Marshal(req)
 	if err != nil {
 		return resp, 0, err
@@ -143,10 +168,106 @@ func (c *Client) CreateUser(ctx context.Context, apiKey string, req CreateUserRe
 	return resp, status, nil
 }
 
+// GetUsersByIDs fetches many users in one request via POST /api/users/batch.
+// IDs are de-duplicated and sent in chunks of MaxBatchSize; unknown IDs are
+// simply absent from the result. Services without the batch endpoint are
+// queried with one GetUserByID call per ID instead.
+func (c *Client) GetUsersByIDs(ctx context.Context, apiKey string, ids []string) ([]User, int, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	var users []User
+	for start := 0; start < len(unique); start += MaxBatchSize {
+		chunk := unique[start:min(start+MaxBatchSize, len(unique))]
+		batch, code, err := c.getUsersBatch(ctx, apiKey, chunk)
+		if code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+			batch, code, err = c.getUsersOneByOne(ctx, apiKey, chunk)
+		}
+		if err != nil {
+			return users, code, err
+		}
+		if code != http.StatusOK {
+			return users, code, nil
+		}
+		users = append(users, batch...)
+	}
+	return users, http.StatusOK, nil
+}
+
+func (c *Client) getUsersBatch(ctx context.Context, apiKey string, ids []string) ([]User, int, error) {
+	var resp []User
+	body, err := json.Marshal(GetUsersByIDsRequest{IDs: ids})
+	if err != nil {
+		return resp, 0, err
+	}
+	httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/users/batch", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", apiKey)
+	r, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return resp, 0, err
+	}
+	defer r.Body.Close()
+	status := r.StatusCode
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		return resp, status, nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, status, err
+	}
+	return resp, status, nil
+}
+
+// getUsersOneByOne fetches ids concurrently with GetUserByID, skipping unknown users.
+func (c *Client) getUsersOneByOne(ctx context.Context, apiKey string, ids []string) ([]User, int, error) {
+	type result struct {
+		user User
+		code int
+		err  error
+	}
+	results := make([]result, len(ids))
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			u, code, err := c.GetUserByID(ctx, apiKey, id)
+			results[i] = result{u, code, err}
+		}(i, id)
+	}
+	wg.Wait()
+
+	users := make([]User, 0, len(ids))
+	for _, res := range results {
+		switch {
+		case res.code == http.StatusNotFound:
+			continue
+		case res.err != nil:
+			return users, res.code, res.err
+		case res.code != http.StatusOK:
+			return users, res.code, nil
+		}
+		users = append(users, res.user)
+	}
+	return users, http.StatusOK, nil
+}
+
 // ============== Events (Service API - require API key) ==============
 
 func (c *Client) CreateEvent(ctx context.Context, apiKey string, req CreateEventRequest) (Event, int, error) {
 	var resp Event
+	if err := req.Validate(); err != nil {
+		return resp, 0, err
+	}
 	body, err := json.Marshal(req)
 	if err != nil {
 		return resp, 0, err
@@ -166,6 +287,15 @@ func (c *Client) CreateEvent(ctx context.Context, apiKey string, req CreateEvent
 	return resp, status, nil
 }
 
+// CreateTypedEvent records an event whose type and metadata come from payload.
+func (c *Client) CreateTypedEvent(ctx context.Context, apiKey, userID, description string, payload EventPayload) (Event, int, error) {
+	req, err := NewEventRequest(userID, description, payload)
+	if err != nil {
+		return Event{}, 0, err
+	}
+	return c.CreateEvent(ctx, apiKey, req)
+}
+
 // ============== Users (Protected API - require session) ==============
 
 func (c *Client) ListUsers(ctx context.Context, cookies []*http.Cookie) ([]User, int, error) {
@@ -0,0 +1,361 @@
+package client_identity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============== Event Types ==============
+
+// Registered event types. Every event sent through CreateEvent must use one of
+// these or a type added with RegisterEventType.
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	EventUserLogin   = "user.login"
+	EventUserLogout  = "user.logout"
+
+	EventPasswordChanged = "auth.password_changed"
+	EventSessionRevoked  = "auth.session_revoked"
+	EventTwoFAEnabled    = "auth.2fa_enabled"
+	EventTwoFADisabled   = "auth.2fa_disabled"
+	EventUserLockedOut   = "auth.locked_out"
+
+	EventOrganisationCreated = "organisation.created"
+	EventOrganisationUpdated = "organisation.updated"
+	EventOrganisationDeleted = "organisation.deleted"
+
+	EventMemberAdded   = "member.added"
+	EventMemberUpdated = "member.updated"
+	EventMemberRemoved = "member.removed"
+
+	EventInviteCreated  = "invite.created"
+	EventInviteAccepted = "invite.accepted"
+	EventInviteRevoked  = "invite.revoked"
+
+	EventSubscriptionCreated   = "subscription.created"
+	EventSubscriptionUpdated   = "subscription.updated"
+	EventSubscriptionCancelled = "subscription.cancelled"
+	EventSubscriptionExpired   = "subscription.expired"
+
+	EventUsageThreshold = "usage.threshold_reached"
+)
+
+var (
+	ErrUnknownEventType = errors.New("unknown event type")
+	ErrInvalidMetadata  = errors.New("invalid event metadata")
+)
+
+// EventPayload is the typed Metadata of an event kind.
+type EventPayload interface {
+	EventType() string
+}
+
+// validator is implemented by payloads with required fields.
+type validator interface {
+	Validate() error
+}
+
+// ============== Payloads ==============
+
+// UserEventData is the metadata of user.* lifecycle events.
+type UserEventData struct {
+	// Kind is the event type sent: user.created, user.updated or user.deleted.
+	Kind          string   `json:"-"`
+	Email         string   `json:"email,omitempty"`
+	ChangedFields []string `json:"changed_fields,omitempty"`
+	ActorID       string   `json:"actor_id,omitempty"`
+}
+
+func (d UserEventData) EventType() string { return d.Kind }
+
+// LoginEventData is the metadata of user.login and user.logout.
+type LoginEventData struct {
+	// Kind is the event type sent: user.login or user.logout.
+	Kind      string `json:"-"`
+	Provider  string `json:"provider,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+func (d LoginEventData) EventType() string { return d.Kind }
+
+// SecurityEventData is the metadata of auth.* events.
+type SecurityEventData struct {
+	// Kind is the event type sent: one of the auth.* types.
+	Kind      string `json:"-"`
+	IPAddress string `json:"ip_address,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ActorID   string `json:"actor_id,omitempty"`
+}
+
+func (d SecurityEventData) EventType() string { return d.Kind }
+
+// OrganisationEventData is the metadata of organisation.* events.
+type OrganisationEventData struct {
+	// Kind is the event type sent: one of the organisation.* types.
+	Kind    string `json:"-"`
+	OrgID   string `json:"org_id"`
+	Name    string `json:"name,omitempty"`
+	ActorID string `json:"actor_id,omitempty"`
+}
+
+func (d OrganisationEventData) EventType() string { return d.Kind }
+
+func (d OrganisationEventData) Validate() error {
+	if d.OrgID == "" {
+		return errors.New("org_id is required")
+	}
+	return nil
+}
+
+// MemberEventData is the metadata of member.* events.
+type MemberEventData struct {
+	// Kind is the event type sent: one of the member.* types.
+	Kind         string `json:"-"`
+	OrgID        string `json:"org_id"`
+	MemberUserID string `json:"member_user_id"`
+	Role         string `json:"role,omitempty"`
+	Status       string `json:"status,omitempty"`
+	ActorID      string `json:"actor_id,omitempty"`
+}
+
+func (d MemberEventData) EventType() string { return d.Kind }
+
+func (d MemberEventData) Validate() error {
+	if d.OrgID == "" || d.MemberUserID == "" {
+		return errors.New("org_id and member_user_id are required")
+	}
+	return nil
+}
+
+// InviteEventData is the metadata of invite.* events.
+type InviteEventData struct {
+	// Kind is the event type sent: one of the invite.* types.
+	Kind     string `json:"-"`
+	OrgID    string `json:"org_id"`
+	InviteID string `json:"invite_id"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role,omitempty"`
+	ActorID  string `json:"actor_id,omitempty"`
+}
+
+func (d InviteEventData) EventType() string { return d.Kind }
+
+func (d InviteEventData) Validate() error {
+	if d.OrgID == "" || d.InviteID == "" {
+		return errors.New("org_id and invite_id are required")
+	}
+	return nil
+}
+
+// SubscriptionEventData is the metadata of subscription.* events.
+type SubscriptionEventData struct {
+	// Kind is the event type sent: one of the subscription.* types.
+	Kind           string     `json:"-"`
+	SubscriptionID string     `json:"subscription_id"`
+	PlanID         string     `json:"plan_id,omitempty"`
+	PreviousPlanID string     `json:"previous_plan_id,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	PeriodEnd      *time.Time `json:"period_end,omitempty"`
+}
+
+func (d SubscriptionEventData) EventType() string { return d.Kind }
+
+func (d SubscriptionEventData) Validate() error {
+	if d.SubscriptionID == "" {
+		return errors.New("subscription_id is required")
+	}
+	return nil
+}
+
+// UsageThresholdData is the metadata of usage.threshold_reached.
+type UsageThresholdData struct {
+	SubjectType string  `json:"subject_type"`
+	SubjectID   string  `json:"subject_id"`
+	Metric      string  `json:"metric"`
+	Period      string  `json:"period"`
+	Threshold   float64 `json:"threshold"`
+	Used        int64   `json:"used"`
+	Limit       int64   `json:"limit"`
+}
+
+func (UsageThresholdData) EventType() string { return EventUsageThreshold }
+
+func (d UsageThresholdData) Validate() error {
+	if d.SubjectID == "" || d.Metric == "" {
+		return errors.New("subject_id and metric are required")
+	}
+	return nil
+}
+
+// ============== Registry ==============
+
+var (
+	eventRegistryMu sync.RWMutex
+	eventRegistry   = map[string]func() EventPayload{}
+)
+
+func init() {
+	for _, t := range []string{EventUserCreated, EventUserUpdated, EventUserDeleted} {
+		registerKind(t, func(kind string) EventPayload { return &UserEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventUserLogin, EventUserLogout} {
+		registerKind(t, func(kind string) EventPayload { return &LoginEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventPasswordChanged, EventSessionRevoked, EventTwoFAEnabled, EventTwoFADisabled, EventUserLockedOut} {
+		registerKind(t, func(kind string) EventPayload { return &SecurityEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventOrganisationCreated, EventOrganisationUpdated, EventOrganisationDeleted} {
+		registerKind(t, func(kind string) EventPayload { return &OrganisationEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventMemberAdded, EventMemberUpdated, EventMemberRemoved} {
+		registerKind(t, func(kind string) EventPayload { return &MemberEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventInviteCreated, EventInviteAccepted, EventInviteRevoked} {
+		registerKind(t, func(kind string) EventPayload { return &InviteEventData{Kind: kind} })
+	}
+	for _, t := range []string{EventSubscriptionCreated, EventSubscriptionUpdated, EventSubscriptionCancelled, EventSubscriptionExpired} {
+		registerKind(t, func(kind string) EventPayload { return &SubscriptionEventData{Kind: kind} })
+	}
+	RegisterEventType(EventUsageThreshold, func() EventPayload { return &UsageThresholdData{} })
+}
+
+func registerKind(eventType string, factory func(kind string) EventPayload) {
+	RegisterEventType(eventType, func() EventPayload { return factory(eventType) })
+}
+
+// RegisterEventType adds an application-specific event type. newPayload must
+// return a pointer to a fresh payload struct. Registering an existing type replaces it.
+func RegisterEventType(eventType string, newPayload func() EventPayload) {
+	eventRegistryMu.Lock()
+	eventRegistry[eventType] = newPayload
+	eventRegistryMu.Unlock()
+}
+
+// EventTypes returns all registered event types, sorted.
+func EventTypes() []string {
+	eventRegistryMu.RLock()
+	defer eventRegistryMu.RUnlock()
+	types := make([]string, 0, len(eventRegistry))
+	for t := range eventRegistry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsKnownEventType reports whether eventType has been registered.
+func IsKnownEventType(eventType string) bool {
+	eventRegistryMu.RLock()
+	_, ok := eventRegistry[eventType]
+	eventRegistryMu.RUnlock()
+	return ok
+}
+
+func newPayload(eventType string) (EventPayload, error) {
+	eventRegistryMu.RLock()
+	factory, ok := eventRegistry[eventType]
+	eventRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, eventType)
+	}
+	return factory(), nil
+}
+
+// ============== Helpers ==============
+
+// NewEventRequest builds a CreateEventRequest whose Type and Metadata come from
+// payload. The payload is validated before it is encoded.
+func NewEventRequest(userID, description string, payload EventPayload) (CreateEventRequest, error) {
+	eventType := payload.EventType()
+	if !IsKnownEventType(eventType) {
+		return CreateEventRequest{}, fmt.Errorf("%w: %q", ErrUnknownEventType, eventType)
+	}
+	if v, ok := payload.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return CreateEventRequest{}, fmt.Errorf("%w for %s: %v", ErrInvalidMetadata, eventType, err)
+		}
+	}
+	metadata, err := json.Marshal(payload)
+	if err != nil {
+		return CreateEventRequest{}, fmt.Errorf("%w for %s: %v", ErrInvalidMetadata, eventType, err)
+	}
+	return CreateEventRequest{
+		UserID:      userID,
+		Type:        eventType,
+		Description: description,
+		Metadata:    string(metadata),
+	}, nil
+}
+
+// Validate rejects requests with an unregistered Type, or with Metadata that
+// does not decode strictly into the type's payload struct or fails its checks.
+func (r CreateEventRequest) Validate() error {
+	payload, err := newPayload(r.Type)
+	if err != nil {
+		return err
+	}
+	metadata := strings.TrimSpace(r.Metadata)
+	if metadata == "" {
+		metadata = "{}"
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(metadata)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(payload); err != nil {
+		return fmt.Errorf("%w for %s: %v", ErrInvalidMetadata, r.Type, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w for %s: trailing data", ErrInvalidMetadata, r.Type)
+	}
+	if v, ok := payload.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("%w for %s: %v", ErrInvalidMetadata, r.Type, err)
+		}
+	}
+	return nil
+}
+
+// Payload decodes Metadata into the payload struct registered for the event's
+// Type. Unknown fields are ignored so events written by newer services still decode.
+func (e Event) Payload() (EventPayload, error) {
+	payload, err := newPayload(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(e.Metadata) == "" {
+		return payload, nil
+	}
+	if err := json.Unmarshal([]byte(e.Metadata), payload); err != nil {
+		return nil, fmt.Errorf("%w for %s: %v", ErrInvalidMetadata, e.Type, err)
+	}
+	return payload, nil
+}
+
+// DecodeEventMetadata decodes an event's Metadata into T, which must be the
+// payload type registered for the event's Type, e.g.
+//
+//	data, err := DecodeEventMetadata[MemberEventData](event)
+func DecodeEventMetadata[T EventPayload](e Event) (T, error) {
+	var zero T
+	payload, err := e.Payload()
+	if err != nil {
+		return zero, err
+	}
+	if p, ok := any(payload).(*T); ok {
+		return *p, nil
+	}
+	if p, ok := payload.(T); ok {
+		return p, nil
+	}
+	return zero, fmt.Errorf("%w: %s metadata is %T, not %T", ErrInvalidMetadata, e.Type, payload, zero)
+}
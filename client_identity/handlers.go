@@ -2,9 +2,11 @@ package client_identity
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/shared_email"
 )
 
 // ============== Health & Heartbeat Handlers ==============
@@ -75,8 +77,15 @@ func GetPlanHandler(c *Client) http.HandlerFunc {
 func GetUserByEmailHandler(c *Client, apiKey string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
-		email := vars["email"]
-		resp, code, err := c.GetUserByEmail(r.Context(), apiKey, email)
+		// Pass the address as given: the client normalises it and retries
+		// the raw address for users stored before normalisation.
+		resp, code, err := c.GetUserByEmail(r.Context(), apiKey, vars["email"])
+		if errors.Is(err, shared_email.ErrInvalidAddress) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -96,6 +105,12 @@ func CreateUserHandler(c *Client, apiKey string) http.HandlerFunc {
 			return
 		}
 		resp, code, err := c.CreateUser(r.Context(), apiKey, req)
+		if errors.Is(err, shared_email.ErrInvalidAddress) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -132,6 +147,12 @@ func CreateEventHandler(c *Client, apiKey string) http.HandlerFunc {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
+		if err := req.Validate(); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+			return
+		}
 		resp, code, err := c.CreateEvent(r.Context(), apiKey, req)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -145,9 +166,21 @@ func CreateEventHandler(c *Client, apiKey string) http.HandlerFunc {
 
 // ============== User Handlers (Protected API) ==============
 
-// ListUsersHandler proxies GET /api/users.
+// ListUsersHandler proxies GET /api/users, forwarding list options from the query string.
 func ListUsersHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.ListUsersPage(r.Context(), r.Cookies(), opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.ListUsers(r.Context(), r.Cookies())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -214,9 +247,21 @@ func DeleteUserHandler(c *Client) http.HandlerFunc {
 
 // ============== Organisation Handlers ==============
 
-// ListOrganisationsHandler proxies GET /api/organisations.
+// ListOrganisationsHandler proxies GET /api/organisations, forwarding list options from the query string.
 func ListOrganisationsHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.ListOrganisationsPage(r.Context(), r.Cookies(), opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.ListOrganisations(r.Context(), r.Cookies())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -302,11 +347,23 @@ func DeleteOrganisationHandler(c *Client) http.HandlerFunc {
 
 // ============== Organisation Member Handlers ==============
 
-// ListMembersHandler proxies GET /api/organisations/{id}/members.
+// ListMembersHandler proxies GET /api/organisations/{id}/members, forwarding list options from the query string.
 func ListMembersHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		orgID := vars["id"]
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.ListMembersPage(r.Context(), r.Cookies(), orgID, opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.ListMembers(r.Context(), r.Cookies(), orgID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -488,11 +545,23 @@ func CancelSubscriptionHandler(c *Client) http.HandlerFunc {
 
 // ============== User Subscription Handlers ==============
 
-// GetUserSubscriptionsHandler proxies GET /api/users/{user_id}/subscriptions.
+// GetUserSubscriptionsHandler proxies GET /api/users/{user_id}/subscriptions, forwarding list options from the query string.
 func GetUserSubscriptionsHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		userID := vars["user_id"]
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.GetUserSubscriptionsPage(r.Context(), r.Cookies(), userID, opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.GetUserSubscriptions(r.Context(), r.Cookies(), userID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -522,9 +591,21 @@ func GetActiveSubscriptionHandler(c *Client) http.HandlerFunc {
 
 // ============== Event Handlers (Protected API) ==============
 
-// ListEventsHandler proxies GET /api/events.
+// ListEventsHandler proxies GET /api/events, forwarding list options from the query string.
 func ListEventsHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.ListEventsPage(r.Context(), r.Cookies(), opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.ListEvents(r.Context(), r.Cookies())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -536,11 +617,23 @@ func ListEventsHandler(c *Client) http.HandlerFunc {
 	}
 }
 
-// GetUserEventsHandler proxies GET /api/users/{user_id}/events.
+// GetUserEventsHandler proxies GET /api/users/{user_id}/events, forwarding list options from the query string.
 func GetUserEventsHandler(c *Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		userID := vars["user_id"]
+		// List parameters switch the response to a paged envelope.
+		if opts := ListOptionsFromQuery(r.URL.Query()); !opts.IsZero() {
+			resp, code, err := c.GetUserEventsPage(r.Context(), r.Cookies(), userID, opts)
+			if err != nil {
+				writePageError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		resp, code, err := c.GetUserEvents(r.Context(), r.Cookies(), userID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -551,3 +644,14 @@ func GetUserEventsHandler(c *Client) http.HandlerFunc {
 		json.NewEncoder(w).Encode(resp)
 	}
 }
+
+// writePageError forwards the status of a page request the identity service
+// refused, or answers 500 when the request itself failed.
+func writePageError(w http.ResponseWriter, err error) {
+	var status *StatusError
+	if errors.As(err, &status) {
+		http.Error(w, http.StatusText(status.StatusCode), status.StatusCode)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,47 @@
+package client_identity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetUserByEmailHandlerRetriesRawAddress(t *testing.T) {
+	// The identity service stores this user with the address as typed.
+	var lookups []string
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email := r.URL.Path[len("/api/users/email/"):]
+		lookups = append(lookups, email)
+		if email != "Ada@Example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(User{ID: "usr_1", Email: email})
+	}))
+	defer identity.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/email/{email}", GetUserByEmailHandler(NewClient(identity.URL), "key"))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/users/email/Ada@Example.com")
+	var got User
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK || got.ID != "usr_1" {
+		t.Fatalf("GET = %d, %+v, %v; want the user stored with the raw address", w.Code, got, err)
+	}
+	if len(lookups) != 2 || lookups[0] != "ada@example.com" {
+		t.Errorf("lookups = %v, want the normalised address then the raw one", lookups)
+	}
+
+	if w := serve("/users/email/not-an-address"); w.Code != http.StatusBadRequest {
+		t.Errorf("GET with an invalid address = %d, want 400", w.Code)
+	}
+}
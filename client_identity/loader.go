@@ -0,0 +1,208 @@
+package client_identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultLoaderWait is how long a UserLoader collects lookups before sending a batch.
+const DefaultLoaderWait = 2 * time.Millisecond
+
+var ErrUserNotFound = errors.New("client_identity: user not found")
+
+// UserLoader coalesces GetUserByID lookups made while handling one request.
+// Lookups arriving within Wait of each other are sent as a single
+// GetUsersByIDs call, concurrent lookups of the same ID share one result, and
+// results are cached for the loader's lifetime.
+type UserLoader struct {
+	ctx    context.Context
+	client *Client
+	apiKey string
+
+	// Wait is how long to collect lookups before dispatching a batch.
+	Wait time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*loadEntry
+	pending []string
+	timer   *time.Timer
+}
+
+type loadEntry struct {
+	done chan struct{}
+	user User
+	err  error
+}
+
+// NewUserLoader creates a loader whose batches run with ctx, normally the request context.
+func NewUserLoader(ctx context.Context, c *Client, apiKey string) *UserLoader {
+	return &UserLoader{
+		ctx:     ctx,
+		client:  c,
+		apiKey:  apiKey,
+		Wait:    DefaultLoaderWait,
+		entries: make(map[string]*loadEntry),
+	}
+}
+
+// Load returns the user with userID, or ErrUserNotFound.
+func (l *UserLoader) Load(ctx context.Context, userID string) (User, error) {
+	entry := l.enqueue(userID)
+	select {
+	case <-entry.done:
+		return entry.user, entry.err
+	case <-ctx.Done():
+		return User{}, ctx.Err()
+	}
+}
+
+// LoadMany returns the users for userIDs in the same order. Unknown IDs yield
+// zero Users and an ErrUserNotFound in the matching errs slot.
+func (l *UserLoader) LoadMany(ctx context.Context, userIDs []string) ([]User, []error) {
+	entries := make([]*loadEntry, len(userIDs))
+	for i, id := range userIDs {
+		entries[i] = l.enqueue(id)
+	}
+	users := make([]User, len(userIDs))
+	errs := make([]error, len(userIDs))
+	for i, entry := range entries {
+		select {
+		case <-entry.done:
+			users[i], errs[i] = entry.user, entry.err
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	return users, errs
+}
+
+// Prime stores a user fetched elsewhere so later loads do not request it.
+func (l *UserLoader) Prime(user User) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.entries[user.ID]; ok {
+		return
+	}
+	entry := &loadEntry{done: make(chan struct{}), user: user}
+	close(entry.done)
+	l.entries[user.ID] = entry
+}
+
+func (l *UserLoader) enqueue(userID string) *loadEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[userID]; ok {
+		return entry
+	}
+	entry := &loadEntry{done: make(chan struct{})}
+	l.entries[userID] = entry
+	l.pending = append(l.pending, userID)
+
+	if len(l.pending) >= MaxBatchSize {
+		l.stopTimerLocked()
+		go l.dispatch(l.takePendingLocked())
+	} else if l.timer == nil {
+		l.timer = time.AfterFunc(l.Wait, func() {
+			l.mu.Lock()
+			batch := l.takePendingLocked()
+			l.timer = nil
+			l.mu.Unlock()
+			l.dispatch(batch)
+		})
+	}
+	return entry
+}
+
+func (l *UserLoader) stopTimerLocked() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+func (l *UserLoader) takePendingLocked() []string {
+	batch := l.pending
+	l.pending = nil
+	return batch
+}
+
+func (l *UserLoader) dispatch(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	users, code, err := l.client.GetUsersByIDs(l.ctx, l.apiKey, ids)
+	if err == nil && code != http.StatusOK {
+		err = fmt.Errorf("client_identity: batch user lookup failed with status %d", code)
+	}
+
+	found := make(map[string]User, len(users))
+	for _, u := range users {
+		found[u.ID] = u
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		entry := l.entries[id]
+		switch u, ok := found[id]; {
+		case ok:
+			entry.user = u
+		case err != nil:
+			entry.err = err
+			// Forget failures so a later Load retries instead of reusing the error.
+			delete(l.entries, id)
+		default:
+			entry.err = ErrUserNotFound
+		}
+		close(entry.done)
+	}
+}
+
+// ============== Request Scope ==============
+
+type loaderContextKey struct{}
+
+// WithUserLoader stores loader in ctx.
+func WithUserLoader(ctx context.Context, loader *UserLoader) context.Context {
+	return context.WithValue(ctx, loaderContextKey{}, loader)
+}
+
+// UserLoaderFromContext returns the loader stored by WithUserLoader or UserLoaderMiddleware.
+func UserLoaderFromContext(ctx context.Context) (*UserLoader, bool) {
+	loader, ok := ctx.Value(loaderContextKey{}).(*UserLoader)
+	return loader, ok
+}
+
+// UserLoaderMiddleware gives every request its own UserLoader.
+func UserLoaderMiddleware(c *Client, apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loader := NewUserLoader(r.Context(), c, apiKey)
+			next.ServeHTTP(w, r.WithContext(WithUserLoader(r.Context(), loader)))
+		})
+	}
+}
+
+// LoadUser loads userID through the request's UserLoader, falling back to a
+// direct GetUserByID call when no loader is installed.
+func LoadUser(ctx context.Context, c *Client, apiKey, userID string) (User, error) {
+	if loader, ok := UserLoaderFromContext(ctx); ok {
+		return loader.Load(ctx, userID)
+	}
+	user, code, err := c.GetUserByID(ctx, apiKey, userID)
+	if code == http.StatusNotFound {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if code != http.StatusOK {
+		return User{}, fmt.Errorf("client_identity: user lookup failed with status %d", code)
+	}
+	return user, nil
+}
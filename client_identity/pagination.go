@@ -0,0 +1,269 @@
+package client_identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPageSize is the largest page size the identity service accepts.
+const MaxPageSize = 500
+
+// Sort directions
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ============== List Options ==============
+
+// ListOptions controls paging, sorting and filtering of list endpoints.
+// Zero values are omitted from the query string.
+type ListOptions struct {
+	PageSize      int        `json:"page_size,omitempty"`
+	Cursor        string     `json:"cursor,omitempty"`
+	Sort          string     `json:"sort,omitempty"`  // field name, e.g. created_at, email, name
+	Order         string     `json:"order,omitempty"` // asc or desc
+	Status        string     `json:"status,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	EventType     string     `json:"type,omitempty"`
+	Search        string     `json:"q,omitempty"`
+}
+
+// Values encodes the options as query parameters.
+func (o ListOptions) Values() url.Values {
+	q := url.Values{}
+	if o.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(min(o.PageSize, MaxPageSize)))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	if o.Order == SortAsc || o.Order == SortDesc {
+		q.Set("order", o.Order)
+	}
+	if o.Status != "" {
+		q.Set("status", o.Status)
+	}
+	if o.CreatedAfter != nil {
+		q.Set("created_after", o.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if o.CreatedBefore != nil {
+		q.Set("created_before", o.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	if o.EventType != "" {
+		q.Set("type", o.EventType)
+	}
+	if o.Search != "" {
+		q.Set("q", o.Search)
+	}
+	return q
+}
+
+// IsZero reports whether no option is set.
+func (o ListOptions) IsZero() bool {
+	return len(o.Values()) == 0
+}
+
+// ListOptionsFromQuery parses list options from query parameters.
+// Malformed values are ignored.
+func ListOptionsFromQuery(q url.Values) ListOptions {
+	o := ListOptions{
+		Cursor:    q.Get("cursor"),
+		Sort:      q.Get("sort"),
+		Order:     strings.ToLower(q.Get("order")),
+		Status:    q.Get("status"),
+		EventType: q.Get("type"),
+		Search:    q.Get("q"),
+	}
+	if n, err := strconv.Atoi(q.Get("page_size")); err == nil && n > 0 {
+		o.PageSize = min(n, MaxPageSize)
+	}
+	if t, err := time.Parse(time.RFC3339, q.Get("created_after")); err == nil {
+		o.CreatedAfter = &t
+	}
+	if t, err := time.Parse(time.RFC3339, q.Get("created_before")); err == nil {
+		o.CreatedBefore = &t
+	}
+	return o
+}
+
+// Page is one page of a list endpoint.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// ============== Paged Requests ==============
+
+// getPage fetches path with opts and decodes either a page envelope or, from
+// services that do not paginate yet, a bare JSON array as a single final page.
+// A status other than 200 is returned as a *StatusError without decoding the
+// body, which may be a plain-text error.
+func getPage[T any](c *Client, ctx context.Context, cookies []*http.Cookie, path string, opts ListOptions) (Page[T], int, error) {
+	var resp Page[T]
+	u := c.BaseURL + path
+	if q := opts.Values().Encode(); q != "" {
+		u += "?" + q
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	r, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return resp, 0, err
+	}
+	defer r.Body.Close()
+	status := r.StatusCode
+	if status != http.StatusOK {
+		return resp, status, &StatusError{StatusCode: status}
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return resp, status, err
+	}
+	resp, err = decodePage[T](body)
+	return resp, status, err
+}
+
+// decodePage decodes a page envelope or a bare JSON array.
+func decodePage[T any](body []byte) (Page[T], error) {
+	var resp Page[T]
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &resp.Items)
+		return resp, err
+	}
+	err := json.Unmarshal(body, &resp)
+	return resp, err
+}
+
+func (c *Client) ListUsersPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[User], int, error) {
+	return getPage[User](c, ctx, cookies, "/api/users", opts)
+}
+
+func (c *Client) ListOrganisationsPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[Organisation], int, error) {
+	return getPage[Organisation](c, ctx, cookies, "/api/organisations", opts)
+}
+
+func (c *Client) ListMembersPage(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) (Page[Member], int, error) {
+	return getPage[Member](c, ctx, cookies, fmt.Sprintf("/api/organisations/%s/members", orgID), opts)
+}
+
+func (c *Client) ListEventsPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[Event], int, error) {
+	return getPage[Event](c, ctx, cookies, "/api/events", opts)
+}
+
+func (c *Client) GetUserEventsPage(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) (Page[Event], int, error) {
+	return getPage[Event](c, ctx, cookies, fmt.Sprintf("/api/users/%s/events", userID), opts)
+}
+
+func (c *Client) GetUserSubscriptionsPage(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) (Page[Subscription], int, error) {
+	return getPage[Subscription](c, ctx, cookies, fmt.Sprintf("/api/users/%s/subscriptions", userID), opts)
+}
+
+// ============== Iterators ==============
+
+// StatusError is returned by the *Page requests, and yielded by the All*
+// iterators, when a page request answers with a status other than 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("client_identity: list request failed with status %d", e.StatusCode)
+}
+
+// paginate walks every page starting from opts, yielding items one by one.
+// A request failure or non-200 status is yielded once as the error and ends the walk.
+func paginate[T any](opts ListOptions, fetch func(ListOptions) (Page[T], int, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			page, code, err := fetch(opts)
+			if err == nil && code != http.StatusOK {
+				err = &StatusError{StatusCode: code}
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" || page.NextCursor == opts.Cursor || len(page.Items) == 0 {
+				return
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+// Collect gathers every item of an All* iterator, stopping at the first error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// AllUsers iterates over every user matching opts, fetching pages as needed.
+func (c *Client) AllUsers(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[User, error] {
+	return paginate(opts, func(o ListOptions) (Page[User], int, error) {
+		return c.ListUsersPage(ctx, cookies, o)
+	})
+}
+
+// AllOrganisations iterates over every organisation matching opts.
+func (c *Client) AllOrganisations(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[Organisation, error] {
+	return paginate(opts, func(o ListOptions) (Page[Organisation], int, error) {
+		return c.ListOrganisationsPage(ctx, cookies, o)
+	})
+}
+
+// AllMembers iterates over every member of orgID matching opts.
+func (c *Client) AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) iter.Seq2[Member, error] {
+	return paginate(opts, func(o ListOptions) (Page[Member], int, error) {
+		return c.ListMembersPage(ctx, cookies, orgID, o)
+	})
+}
+
+// AllEvents iterates over every event matching opts.
+func (c *Client) AllEvents(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[Event, error] {
+	return paginate(opts, func(o ListOptions) (Page[Event], int, error) {
+		return c.ListEventsPage(ctx, cookies, o)
+	})
+}
+
+// AllUserEvents iterates over every event of userID matching opts.
+func (c *Client) AllUserEvents(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) iter.Seq2[Event, error] {
+	return paginate(opts, func(o ListOptions) (Page[Event], int, error) {
+		return c.GetUserEventsPage(ctx, cookies, userID, o)
+	})
+}
+
+// AllUserSubscriptions iterates over every subscription of userID matching opts.
+func (c *Client) AllUserSubscriptions(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) iter.Seq2[Subscription, error] {
+	return paginate(opts, func(o ListOptions) (Page[Subscription], int, error) {
+		return c.GetUserSubscriptionsPage(ctx, cookies, userID, o)
+	})
+}
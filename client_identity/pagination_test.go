@@ -0,0 +1,35 @@
+package client_identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllMembersStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"items":[{"user_id":"usr_1","role":"owner","status":"active"}],"next_cursor":"c2"}`))
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+
+	members, err := Collect(c.AllMembers(context.Background(), nil, "org_1", ListOptions{}))
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusForbidden {
+		t.Fatalf("Collect = %v, want a *StatusError with 403 for the plain-text error page", err)
+	}
+	if len(members) != 1 || members[0].UserID != "usr_1" {
+		t.Errorf("members = %+v, want the first page", members)
+	}
+
+	if _, code, err := c.ListMembersPage(context.Background(), nil, "org_1", ListOptions{Cursor: "c2"}); code != http.StatusForbidden || !errors.As(err, &status) {
+		t.Errorf("ListMembersPage = %d, %v; want 403 and a *StatusError", code, err)
+	}
+}
@@ -0,0 +1,77 @@
+package client_identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+// planJSON is the wire form of Plan: the price as a decimal number in major
+// units alongside its currency code, as the identity service has always sent it.
+type planJSON struct {
+	planFields
+	Price    json.Number `json:"price"`
+	Currency string      `json:"currency"`
+}
+
+type planFields Plan
+
+// MarshalJSON writes Price as {"price": 19.99, "currency": "GBP"}. The
+// deprecated Currency field is used when Price has no currency.
+func (p Plan) MarshalJSON() ([]byte, error) {
+	currency := p.Price.Currency
+	if currency == "" {
+		currency = p.Currency
+	}
+	return json.Marshal(planJSON{
+		planFields: planFields(p),
+		Price:      json.Number(p.Price.Decimal()),
+		Currency:   currency,
+	})
+}
+
+// UnmarshalJSON reads the decimal price exactly, without passing through
+// float64. Prices written by older services as binary floats, such as
+// 19.990000000000002, are rounded to the currency's minor unit instead, and a
+// zero price may come without a currency.
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	var w planJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	*p = Plan(w.planFields)
+	price, err := parsePlanPrice(w.Price.String(), w.Currency)
+	if err != nil {
+		return fmt.Errorf("plan %s price: %w", p.ID, err)
+	}
+	p.Price = price
+	p.Currency = price.Currency
+	return nil
+}
+
+// maxFloatPrice bounds float prices that are rounded rather than rejected;
+// beyond it a float64 no longer holds whole minor units.
+const maxFloatPrice = 1e13
+
+func parsePlanPrice(amount, currency string) (shared_money.Money, error) {
+	if amount == "" {
+		return shared_money.Zero(currency), nil
+	}
+	if currency == "" {
+		if f, err := strconv.ParseFloat(amount, 64); err == nil && f == 0 {
+			return shared_money.Money{}, nil
+		}
+		return shared_money.Money{}, fmt.Errorf("%s has no currency", amount)
+	}
+	price, err := shared_money.Parse(amount, currency)
+	if errors.Is(err, shared_money.ErrInvalidAmount) {
+		if f, ferr := strconv.ParseFloat(amount, 64); ferr == nil && math.Abs(f) < maxFloatPrice {
+			return shared_money.FromMajor(f, currency), nil
+		}
+	}
+	return price, err
+}
@@ -1,6 +1,11 @@
 package client_identity
 
-import "time"
+import (
+	"time"
+
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_money"
+)
 
 // ============== Health & Heartbeat ==============
 
@@ -18,18 +23,21 @@ type HeartbeatResponse struct {
 
 // ============== Plans ==============
 
-// Plan represents a subscription plan
+// Plan represents a subscription plan. Price travels on the wire as the
+// decimal "price" and the ISO 4217 "currency" fields; see Plan.MarshalJSON.
 type Plan struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Currency    string    `json:"currency"`
-	Interval    string    `json:"interval"` // monthly, yearly, etc.
-	Features    []string  `json:"features,omitempty"`
-	Active      bool      `json:"active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Price       shared_money.Money `json:"-"`
+	// Deprecated: Currency mirrors Price.Currency for code written before
+	// Price carried its currency. Use Price.Currency.
+	Currency  string    `json:"-"`
+	Interval  string    `json:"interval"` // monthly, yearly, etc.
+	Features  []string  `json:"features,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // ============== Users ==============
@@ -52,6 +60,23 @@ type CreateUserRequest struct {
 	LastName  string `json:"last_name,omitempty"`
 }
 
+// Normalise validates Email and rewrites it in the form shared_email stores,
+// so the same mailbox never becomes two users. Errors wrap
+// shared_email.ErrInvalidAddress.
+func (r *CreateUserRequest) Normalise() error {
+	email, err := shared_email.Normalise(r.Email)
+	if err != nil {
+		return err
+	}
+	r.Email = email
+	return nil
+}
+
+// GetUsersByIDsRequest is the request body for POST /api/users/batch
+type GetUsersByIDsRequest struct {
+	IDs []string `json:"ids"`
+}
+
 // UpdateUserRequest is the request body for PUT /api/users/{id}
 type UpdateUserRequest struct {
 	FirstName *string `json:"first_name,omitempty"`
@@ -112,7 +137,7 @@ type Subscription struct {
 	UserID      string     `json:"user_id"`
 	PlanID      string     `json:"plan_id"`
 	Plan        *Plan      `json:"plan,omitempty"`
-	Status      string     `json:"status"` // active, inactive, cancelled, expired
+	Status      string     `json:"status"` // trialing, active, past_due, cancelled, expired (see shared_subscriptions)
 	StartDate   time.Time  `json:"start_date"`
 	EndDate     *time.Time `json:"end_date,omitempty"`
 	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
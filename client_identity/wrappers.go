@@ -3,6 +3,7 @@ package client_identity
 import (
 	"context"
 	"errors"
+	"iter"
 	"net/http"
 )
 
@@ -84,6 +85,14 @@ func CreateUser(ctx context.Context, apiKey string, req CreateUserRequest) (User
 	return Default.CreateUser(ctx, apiKey, req)
 }
 
+// GetUsersByIDs wraps Client.GetUsersByIDs on the default client.
+func GetUsersByIDs(ctx context.Context, apiKey string, ids []string) ([]User, int, error) {
+	if err := ensure(); err != nil {
+		return nil, 0, err
+	}
+	return Default.GetUsersByIDs(ctx, apiKey, ids)
+}
+
 // ============== Event Wrappers (Service API) ==============
 
 // CreateEvent wraps Client.CreateEvent on the default client.
@@ -94,6 +103,14 @@ func CreateEvent(ctx context.Context, apiKey string, req CreateEventRequest) (Ev
 	return Default.CreateEvent(ctx, apiKey, req)
 }
 
+// CreateTypedEvent wraps Client.CreateTypedEvent on the default client.
+func CreateTypedEvent(ctx context.Context, apiKey, userID, description string, payload EventPayload) (Event, int, error) {
+	if err := ensure(); err != nil {
+		return Event{}, 0, err
+	}
+	return Default.CreateTypedEvent(ctx, apiKey, userID, description, payload)
+}
+
 // ============== User Wrappers (Protected API) ==============
 
 // ListUsers wraps Client.ListUsers on the default client.
@@ -291,3 +308,111 @@ func GetUserEvents(ctx context.Context, cookies []*http.Cookie, userID string) (
 	}
 	return Default.GetUserEvents(ctx, cookies, userID)
 }
+
+// ============== Paged List Wrappers ==============
+
+// ListUsersPage wraps Client.ListUsersPage on the default client.
+func ListUsersPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[User], int, error) {
+	if err := ensure(); err != nil {
+		return Page[User]{}, 0, err
+	}
+	return Default.ListUsersPage(ctx, cookies, opts)
+}
+
+// ListOrganisationsPage wraps Client.ListOrganisationsPage on the default client.
+func ListOrganisationsPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[Organisation], int, error) {
+	if err := ensure(); err != nil {
+		return Page[Organisation]{}, 0, err
+	}
+	return Default.ListOrganisationsPage(ctx, cookies, opts)
+}
+
+// ListMembersPage wraps Client.ListMembersPage on the default client.
+func ListMembersPage(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) (Page[Member], int, error) {
+	if err := ensure(); err != nil {
+		return Page[Member]{}, 0, err
+	}
+	return Default.ListMembersPage(ctx, cookies, orgID, opts)
+}
+
+// ListEventsPage wraps Client.ListEventsPage on the default client.
+func ListEventsPage(ctx context.Context, cookies []*http.Cookie, opts ListOptions) (Page[Event], int, error) {
+	if err := ensure(); err != nil {
+		return Page[Event]{}, 0, err
+	}
+	return Default.ListEventsPage(ctx, cookies, opts)
+}
+
+// GetUserEventsPage wraps Client.GetUserEventsPage on the default client.
+func GetUserEventsPage(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) (Page[Event], int, error) {
+	if err := ensure(); err != nil {
+		return Page[Event]{}, 0, err
+	}
+	return Default.GetUserEventsPage(ctx, cookies, userID, opts)
+}
+
+// GetUserSubscriptionsPage wraps Client.GetUserSubscriptionsPage on the default client.
+func GetUserSubscriptionsPage(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) (Page[Subscription], int, error) {
+	if err := ensure(); err != nil {
+		return Page[Subscription]{}, 0, err
+	}
+	return Default.GetUserSubscriptionsPage(ctx, cookies, userID, opts)
+}
+
+// ============== Iterator Wrappers ==============
+
+// failed returns an iterator that yields err once.
+func failed[T any](err error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		yield(zero, err)
+	}
+}
+
+// AllUsers wraps Client.AllUsers on the default client.
+func AllUsers(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[User, error] {
+	if err := ensure(); err != nil {
+		return failed[User](err)
+	}
+	return Default.AllUsers(ctx, cookies, opts)
+}
+
+// AllOrganisations wraps Client.AllOrganisations on the default client.
+func AllOrganisations(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[Organisation, error] {
+	if err := ensure(); err != nil {
+		return failed[Organisation](err)
+	}
+	return Default.AllOrganisations(ctx, cookies, opts)
+}
+
+// AllMembers wraps Client.AllMembers on the default client.
+func AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts ListOptions) iter.Seq2[Member, error] {
+	if err := ensure(); err != nil {
+		return failed[Member](err)
+	}
+	return Default.AllMembers(ctx, cookies, orgID, opts)
+}
+
+// AllEvents wraps Client.AllEvents on the default client.
+func AllEvents(ctx context.Context, cookies []*http.Cookie, opts ListOptions) iter.Seq2[Event, error] {
+	if err := ensure(); err != nil {
+		return failed[Event](err)
+	}
+	return Default.AllEvents(ctx, cookies, opts)
+}
+
+// AllUserEvents wraps Client.AllUserEvents on the default client.
+func AllUserEvents(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) iter.Seq2[Event, error] {
+	if err := ensure(); err != nil {
+		return failed[Event](err)
+	}
+	return Default.AllUserEvents(ctx, cookies, userID, opts)
+}
+
+// AllUserSubscriptions wraps Client.AllUserSubscriptions on the default client.
+func AllUserSubscriptions(ctx context.Context, cookies []*http.Cookie, userID string, opts ListOptions) iter.Seq2[Subscription, error] {
+	if err := ensure(); err != nil {
+		return failed[Subscription](err)
+	}
+	return Default.AllUserSubscriptions(ctx, cookies, userID, opts)
+}
@@ -0,0 +1,135 @@
+package client_notify
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+const defaultBulkConcurrency = 8
+
+// MaxBulkFailures caps BulkReport.Failures; the counts stay exact.
+const MaxBulkFailures = 1000
+
+// BulkReport is the outcome of sending one message to many recipients.
+type BulkReport struct {
+	Total     int `json:"total"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	// Partial counts delivered recipients for whom at least one channel failed.
+	Partial int `json:"partial"`
+	// Skipped counts recipients who opted out of every selected channel;
+	// they are neither delivered nor failed.
+	Skipped  int           `json:"skipped"`
+	Failures []BulkFailure `json:"failures,omitempty"`
+	// Error is set when the recipients could not be read to the end or ctx
+	// was cancelled; recipients after that point were not attempted.
+	Error string `json:"error,omitempty"`
+}
+
+// BulkFailure is a recipient for whom some or all channels failed.
+type BulkFailure struct {
+	Recipient Recipient `json:"recipient"`
+	// Delivered is true when another channel succeeded.
+	Delivered bool      `json:"delivered"`
+	Channels  []Channel `json:"channels,omitempty"`
+	Error     string    `json:"error"`
+}
+
+// SendBulk sends msg to every recipient through n; see SendBulkSeq.
+func SendBulk(ctx context.Context, n Notifier, recipients []Recipient, msg Message, concurrency int) BulkReport {
+	return SendBulkSeq(ctx, n, func(yield func(Recipient, error) bool) {
+		for _, to := range recipients {
+			if !yield(to, nil) {
+				return
+			}
+		}
+	}, msg, concurrency)
+}
+
+// SendBulkSeq sends msg to every recipient yielded by recipients using
+// concurrency workers, eight by default. Failures are collected rather than
+// stopping the send. Throttle providers by registering drivers wrapped with
+// RateLimit on the Notifier.
+//
+// A non-empty msg.ID is shared by every copy, which lets in-app inboxes
+// recognise a repeated send; otherwise each recipient's copy gets its own.
+func SendBulkSeq(ctx context.Context, n Notifier, recipients iter.Seq2[Recipient, error], msg Message, concurrency int) BulkReport {
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	var (
+		report BulkReport
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		jobs   = make(chan Recipient)
+	)
+	record := func(to Recipient, res Result, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Total++
+		var failed []Channel
+		for _, d := range res.Deliveries {
+			if d.Err != nil {
+				failed = append(failed, d.Channel)
+			}
+		}
+		delivered := err == nil
+		switch {
+		case errors.Is(err, ErrOptedOut):
+			report.Skipped++
+			return
+		case !delivered:
+			report.Failed++
+		case len(failed) > 0:
+			report.Delivered++
+			report.Partial++
+			err = res.Err()
+		default:
+			report.Delivered++
+			return
+		}
+		if len(report.Failures) < MaxBulkFailures {
+			report.Failures = append(report.Failures, BulkFailure{
+				Recipient: to,
+				Delivered: delivered,
+				Channels:  failed,
+				Error:     err.Error(),
+			})
+		}
+	}
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for to := range jobs {
+				res, err := n.Notify(ctx, to, msg)
+				record(to, res, err)
+			}
+		}()
+	}
+
+	var stopErr error
+	for to, err := range recipients {
+		if err != nil {
+			stopErr = err
+			break
+		}
+		select {
+		case jobs <- to:
+			continue
+		case <-ctx.Done():
+			stopErr = ctx.Err()
+		}
+		break
+	}
+	close(jobs)
+	wg.Wait()
+
+	if stopErr != nil {
+		report.Error = stopErr.Error()
+	}
+	return report
+}
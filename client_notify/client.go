@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"path"
 	"strings"
 	"time"
+
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_helpers"
 )
 
 var (
@@ -16,6 +21,10 @@ var (
 	Default *EmailClient
 )
 
+// ErrServiceUnavailable means the notify service could not be reached or
+// failed with a 5xx status, as opposed to rejecting the request.
+var ErrServiceUnavailable = errors.New("notify service unavailable")
+
 // Init must be called exactly once (or whenever you want to point at a new URL).
 // baseURL must include scheme (“https://” or “http://”) or we’ll prepend “https://”.
 func Init(baseURL string) {
@@ -29,6 +38,22 @@ func Init(baseURL string) {
 type EmailClient struct {
 	baseURL    string
 	httpClient *http.Client
+
+	// Fallback, when set, sends emails directly over SMTP while the notify
+	// service is unavailable, rendering its templates locally. Emails with
+	// attachments have no fallback.
+	Fallback *SMTPDriver
+	// Suppressed optionally reports addresses that must not be emailed, such
+	// as ones that hard-bounced; every send to them fails with ErrSuppressed.
+	// Security code, recovery code and login link emails are checked as
+	// CategorySecurity, all others as CategoryAccount.
+	Suppressed func(ctx context.Context, address string, category Category) (bool, error)
+	// Tracker, when set, records every email sent through the Send methods,
+	// such as welcome emails, login links and recovery codes, without a user
+	// ID. Templated emails use their endpoint, e.g. "welcome", as subject.
+	// Notifications sent by an EmailDriver are left to Router.Tracker, so
+	// they are not recorded twice.
+	Tracker Tracker
 }
 
 func NewClient(baseURL string) *EmailClient {
@@ -40,8 +65,135 @@ func NewClient(baseURL string) *EmailClient {
 	}
 }
 
+// post sends payload to endpoint and reports the email to Tracker.
 func (c *EmailClient) post(ctx context.Context, endpoint string, payload interface{}) (*EmailResponse, error) {
-	fullURL := c.baseURL + path.Join("/api/email/", endpoint)
+	category := endpointCategory(endpoint)
+	resp, err := c.postAs(ctx, category, endpoint, payload)
+	if c.Tracker != nil && !errors.Is(err, ErrInvalidEmail) {
+		// postAs has normalised the address already.
+		to, _ := normaliseRecipient(payload)
+		subject := endpoint
+		if req, ok := payload.(*GenericEmailRequest); ok {
+			subject = req.Subject
+		}
+		c.track(ctx, Message{Category: category, Subject: subject}, resp, err, to)
+	}
+	return resp, err
+}
+
+// track reports an email sent outside a Router to Tracker, as one delivery
+// per address.
+func (c *EmailClient) track(ctx context.Context, msg Message, resp *EmailResponse, err error, addresses ...string) {
+	id, idErr := shared_helpers.GenerateCondensedUUID()
+	if idErr != nil {
+		log.Printf("client_notify: track email: GenerateCondensedUUID: %v", idErr)
+		return
+	}
+	msg.ID = id
+	msg.CreatedAt = time.Now().UTC()
+	d := Delivery{Channel: ChannelEmail, Err: err}
+	if err == nil && resp != nil {
+		d.ProviderID = resp.MessageID
+	}
+	for _, address := range addresses {
+		if trackErr := c.Tracker.Track(ctx, Recipient{Email: address}, msg, d); trackErr != nil {
+			log.Printf("client_notify: track email delivery of %s: %v", msg.ID, trackErr)
+		}
+	}
+}
+
+// postAs is post with the category the recipient is checked against the
+// suppression list as.
+func (c *EmailClient) postAs(ctx context.Context, category Category, endpoint string, payload interface{}) (*EmailResponse, error) {
+	to, err := normaliseRecipient(payload)
+	if err != nil {
+		return nil, err
+	}
+	if to != "" {
+		if err := c.checkSuppressed(ctx, category, to); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.postPath(ctx, path.Join("/api/email/", endpoint), endpoint, payload)
+	if err == nil || c.Fallback == nil || !errors.Is(err, ErrServiceUnavailable) || ctx.Err() != nil {
+		return resp, err
+	}
+	id, ferr := c.Fallback.sendRequest(ctx, payload)
+	if errors.Is(ferr, errNoFallback) {
+		return resp, err
+	}
+	return fallbackResponse(endpoint, err, id, ferr)
+}
+
+// endpointCategory is the category an email sent through a notify service
+// endpoint is checked against the suppression list as.
+func endpointCategory(endpoint string) Category {
+	switch endpoint {
+	case "security-code", "recovery-code", "login-link":
+		return CategorySecurity
+	}
+	return CategoryAccount
+}
+
+// checkSuppressed fails with ErrSuppressed if any of addresses must not be
+// sent emails of category.
+func (c *EmailClient) checkSuppressed(ctx context.Context, category Category, addresses ...string) error {
+	if c.Suppressed == nil {
+		return nil
+	}
+	for _, address := range addresses {
+		suppressed, err := c.Suppressed(ctx, address, category)
+		if err != nil {
+			return fmt.Errorf("check suppression: %w", err)
+		}
+		if suppressed {
+			return fmt.Errorf("%w: %s", ErrSuppressed, address)
+		}
+	}
+	return nil
+}
+
+// normaliseRecipient validates the To address of an email request body,
+// rewrites it in the form shared_email stores and returns it. Bodies without
+// a To address return "".
+func normaliseRecipient(payload interface{}) (string, error) {
+	var to *string
+	switch req := payload.(type) {
+	case *WelcomeEmailRequest:
+		to = &req.To
+	case *SecurityCodeEmailRequest:
+		to = &req.To
+	case *RecoveryCodeEmailRequest:
+		to = &req.To
+	case *ServiceAlertEmailRequest:
+		to = &req.To
+	case *LoginLinkEmailRequest:
+		to = &req.To
+	case *GenericEmailRequest:
+		to = &req.To
+	default:
+		return "", nil
+	}
+	email, err := shared_email.Normalise(*to)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEmail, err)
+	}
+	*to = email
+	return email, nil
+}
+
+// fallbackResponse reports the outcome of an SMTP fallback send made after
+// the notify service failed with err.
+func fallbackResponse(endpoint string, err error, messageID string, fallbackErr error) (*EmailResponse, error) {
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("SMTP fallback: %w", fallbackErr))
+	}
+	log.Printf("client_notify: sent %s email over SMTP fallback: %v", endpoint, err)
+	return &EmailResponse{Success: true, Message: "Sent over SMTP fallback", MessageID: messageID}, nil
+}
+
+func (c *EmailClient) postPath(ctx context.Context, urlPath, endpoint string, payload interface{}) (*EmailResponse, error) {
+	fullURL := c.baseURL + urlPath
 	b, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("marshal %s: %w", endpoint, err)
@@ -53,12 +205,25 @@ func (c *EmailClient) post(ctx context.Context, endpoint string, payload interfa
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("%s request failed: %w", endpoint, err)
+		return nil, fmt.Errorf("%s request failed: %w: %w", endpoint, ErrServiceUnavailable, err)
 	}
 	defer resp.Body.Close()
+	return decodeResponse(endpoint, resp)
+}
 
+// decodeResponse decodes an EmailResponse, failing with
+// ErrServiceUnavailable on 5xx statuses.
+func decodeResponse(endpoint string, resp *http.Response) (*EmailResponse, error) {
 	var er EmailResponse
-	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+	err := json.NewDecoder(resp.Body).Decode(&er)
+	if resp.StatusCode >= 500 {
+		reason := er.Error
+		if err != nil || reason == "" {
+			reason = resp.Status
+		}
+		return nil, fmt.Errorf("%s: %w: %s", endpoint, ErrServiceUnavailable, reason)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("%s decode: %w", endpoint, err)
 	}
 	if !er.Success {
@@ -119,3 +284,9 @@ func (c *EmailClient) SendGenericEmail(ctx context.Context, to, subject, message
 	req := GenericEmailRequest{To: to, Subject: subject, Message: message}
 	return c.post(ctx, "generic", &req)
 }
+
+// SendGenericEmailWithHeaders wraps POST /api/email/generic with custom headers.
+func (c *EmailClient) SendGenericEmailWithHeaders(ctx context.Context, to, subject, message string, headers map[string]string) (*EmailResponse, error) {
+	req := GenericEmailRequest{To: to, Subject: subject, Message: message, Headers: headers}
+	return c.post(ctx, "generic", &req)
+}
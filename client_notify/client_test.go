@@ -0,0 +1,123 @@
+package client_notify
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmailClientSuppressed(t *testing.T) {
+	ns := &notifyServer{}
+	srv := httptest.NewServer(ns)
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	var checked []string
+	c.Suppressed = func(_ context.Context, address string, category Category) (bool, error) {
+		checked = append(checked, address+" "+string(category))
+		// A complaint: only security emails may still be sent.
+		return address == "ada@example.com" && category != CategorySecurity, nil
+	}
+	ctx := context.Background()
+
+	if _, err := c.SendWelcomeEmail(ctx, "Ada@Example.com", "Ada"); !errors.Is(err, ErrSuppressed) {
+		t.Errorf("SendWelcomeEmail = %v, want ErrSuppressed", err)
+	}
+	if _, err := c.SendEmail(ctx, Email{To: []string{"bob@example.com"}, BCC: []string{"Ada <ada@example.com>"}, Subject: "Invoice", Text: "Attached"}); !errors.Is(err, ErrSuppressed) {
+		t.Errorf("SendEmail = %v, want ErrSuppressed", err)
+	}
+	if len(ns.paths) != 0 {
+		t.Fatalf("requests = %v, want none to suppressed addresses", ns.paths)
+	}
+
+	if _, err := c.SendSecurityCodeEmail(ctx, "ada@example.com", "123456"); err != nil {
+		t.Errorf("SendSecurityCodeEmail = %v, want it sent despite the complaint", err)
+	}
+	if _, err := NewEmailDriver(c).SendTracked(ctx, Recipient{Email: "ada@example.com"}, Message{Category: CategorySecurity, Subject: "New sign-in"}); err != nil {
+		t.Errorf("security notification = %v, want it sent despite the complaint", err)
+	}
+	if len(ns.paths) != 2 {
+		t.Errorf("requests = %v, want the security code and the notification", ns.paths)
+	}
+	want := []string{
+		"ada@example.com account",
+		"bob@example.com account", "ada@example.com account",
+		"ada@example.com security",
+		"ada@example.com security",
+	}
+	if len(checked) != len(want) {
+		t.Fatalf("checked %v, want %v", checked, want)
+	}
+	for i := range want {
+		if checked[i] != want[i] {
+			t.Errorf("check %d = %q, want %q", i, checked[i], want[i])
+		}
+	}
+}
+
+// trackerFunc adapts a function to Tracker.
+type trackerFunc func(ctx context.Context, to Recipient, msg Message, d Delivery) error
+
+func (f trackerFunc) Track(ctx context.Context, to Recipient, msg Message, d Delivery) error {
+	return f(ctx, to, msg, d)
+}
+
+func TestEmailClientTracksDirectSends(t *testing.T) {
+	ns := &notifyServer{}
+	srv := httptest.NewServer(ns)
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	type tracked struct {
+		to      string
+		subject string
+		d       Delivery
+	}
+	var got []tracked
+	c.Tracker = trackerFunc(func(_ context.Context, to Recipient, msg Message, d Delivery) error {
+		if msg.ID == "" {
+			t.Error("tracked message has no ID")
+		}
+		got = append(got, tracked{to.Email, msg.Subject, d})
+		return nil
+	})
+	c.Suppressed = func(_ context.Context, address string, _ Category) (bool, error) {
+		return address == "bounced@example.com", nil
+	}
+	ctx := context.Background()
+
+	if _, err := c.SendWelcomeEmail(ctx, "Ada@Example.com", "Ada"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendEmail(ctx, Email{To: []string{"bob@example.com"}, CC: []string{"Cy <cy@example.com>"}, Subject: "Invoice", Text: "Attached"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendGenericEmail(ctx, "bounced@example.com", "Hello", "Hi"); !errors.Is(err, ErrSuppressed) {
+		t.Fatalf("SendGenericEmail = %v, want ErrSuppressed", err)
+	}
+	if _, err := c.SendWelcomeEmail(ctx, "not an address", "Ada"); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("SendWelcomeEmail = %v, want ErrInvalidEmail", err)
+	}
+	// Driver sends are left to Router.Tracker.
+	if _, err := NewEmailDriver(c).SendTracked(ctx, Recipient{Email: "ada@example.com"}, Message{Subject: "News"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tracked{
+		{"ada@example.com", "welcome", Delivery{Channel: ChannelEmail, ProviderID: "msg_1"}},
+		{"bob@example.com", "Invoice", Delivery{Channel: ChannelEmail, ProviderID: "msg_1"}},
+		{"cy@example.com", "Invoice", Delivery{Channel: ChannelEmail, ProviderID: "msg_1"}},
+	}
+	if len(got) != len(want)+1 {
+		t.Fatalf("tracked %+v, want %d deliveries", got, len(want)+1)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("delivery %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+	if last := got[len(want)]; last.to != "bounced@example.com" || !errors.Is(last.d.Err, ErrSuppressed) {
+		t.Errorf("suppressed send tracked as %+v", last)
+	}
+}
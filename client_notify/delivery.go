@@ -0,0 +1,71 @@
+package client_notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DeliveryState is how far a sent message has got.
+type DeliveryState string
+
+const (
+	DeliveryQueued    DeliveryState = "queued"
+	DeliverySent      DeliveryState = "sent"
+	DeliveryDelivered DeliveryState = "delivered"
+	DeliveryBounced   DeliveryState = "bounced"
+	// DeliveryComplained means the recipient marked the email as spam.
+	DeliveryComplained DeliveryState = "complained"
+	DeliveryFailed     DeliveryState = "failed"
+	// DeliverySuppressed means the message was not sent because the
+	// address had bounced or complained before.
+	DeliverySuppressed DeliveryState = "suppressed"
+)
+
+// BounceType tells permanent bounces, after which an address must not be
+// emailed again, from temporary ones.
+type BounceType string
+
+const (
+	BounceHard BounceType = "hard"
+	BounceSoft BounceType = "soft"
+)
+
+// DeliveryStatus is the latest known state of a sent message.
+type DeliveryStatus struct {
+	MessageID string        `json:"messageId"`
+	State     DeliveryState `json:"state"`
+	Recipient string        `json:"recipient,omitempty"`
+	Bounce    BounceType    `json:"bounce,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// GetDeliveryStatus wraps GET /api/email/messages/{messageID} for an ID
+// from EmailResponse.MessageID.
+func (c *EmailClient) GetDeliveryStatus(ctx context.Context, messageID string) (*DeliveryStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/email/messages/"+url.PathEscape(messageID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new delivery status request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("delivery status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		EmailResponse
+		Status DeliveryStatus `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("delivery status decode: %w", err)
+	}
+	if !body.Success {
+		return nil, fmt.Errorf("delivery status backend error: %s", body.Error)
+	}
+	return &body.Status, nil
+}
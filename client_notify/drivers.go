@@ -0,0 +1,244 @@
+package client_notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_i18n"
+)
+
+// EmailDriver sends notifications as generic emails through the notify service.
+type EmailDriver struct {
+	Client *EmailClient
+	// Unsubscribe optionally returns a one-click unsubscribe URL for a
+	// message, which is linked in the body and sent as List-Unsubscribe
+	// headers. Security notifications never get one.
+	Unsubscribe func(to Recipient, msg Message) string
+	// Suppressed optionally reports addresses that must not be emailed, such
+	// as ones that hard-bounced; sends to them fail with ErrSuppressed. The
+	// client's own Suppressed is checked as well, with the message category.
+	Suppressed func(ctx context.Context, address string, category Category) (bool, error)
+}
+
+// NewEmailDriver creates an email driver; a nil client uses Default.
+func NewEmailDriver(client *EmailClient) *EmailDriver {
+	return &EmailDriver{Client: client}
+}
+
+func (d *EmailDriver) Channel() Channel { return ChannelEmail }
+
+func (d *EmailDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	_, err := d.SendTracked(ctx, to, msg)
+	return err
+}
+
+// SendTracked sends msg and returns the notify service's message ID.
+func (d *EmailDriver) SendTracked(ctx context.Context, to Recipient, msg Message) (string, error) {
+	if to.Email == "" {
+		return "", ErrNoAddress
+	}
+	if d.Suppressed != nil {
+		suppressed, err := d.Suppressed(ctx, to.Email, msg.Category)
+		if err != nil {
+			return "", fmt.Errorf("check suppression: %w", err)
+		}
+		if suppressed {
+			return "", ErrSuppressed
+		}
+	}
+	client := d.Client
+	if client == nil {
+		if err := ensure(); err != nil {
+			return "", err
+		}
+		client = Default
+	}
+	body := withLink(msg.Body, msg.Link)
+	var unsubscribeURL string
+	if d.Unsubscribe != nil && msg.Category != CategorySecurity {
+		unsubscribeURL = d.Unsubscribe(to, msg)
+	}
+	htmlBody := msg.HTML
+	var headers map[string]string
+	if unsubscribeURL != "" {
+		label := shared_i18n.Default.Localizer(to.Locale).T("email.unsubscribe")
+		body += "\n\n--\n" + label + ": " + unsubscribeURL
+		if htmlBody != "" {
+			htmlBody += fmt.Sprintf(`<p style="font-size:12px;color:#6b7280"><a href="%s">%s</a></p>`, html.EscapeString(unsubscribeURL), html.EscapeString(label))
+		}
+		headers = ListUnsubscribeHeaders(unsubscribeURL)
+	}
+	if htmlBody != "" {
+		// Only the free-form endpoint takes HTML; Body stays as the text part.
+		return messageID(client.sendEmail(ctx, msg.Category, Email{
+			To:      []string{to.Email},
+			Subject: msg.Subject,
+			Text:    body,
+			HTML:    htmlBody,
+			Headers: headers,
+		}))
+	}
+	req := GenericEmailRequest{To: to.Email, Subject: msg.Subject, Message: body, Headers: headers}
+	return messageID(client.postAs(ctx, msg.Category, "generic", &req))
+}
+
+func messageID(resp *EmailResponse, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return resp.MessageID, nil
+}
+
+// ListUnsubscribeHeaders returns the headers that let mail clients offer
+// one-click unsubscription (RFC 8058): a POST of
+// "List-Unsubscribe=One-Click" to url must unsubscribe without further input.
+func ListUnsubscribeHeaders(url string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + url + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// SMSSender sends a text message through an SMS provider, such as Twilio
+// or Vonage, and returns the provider's message ID.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) (string, error)
+}
+
+// SMSSenderFunc adapts a function to an SMSSender.
+type SMSSenderFunc func(ctx context.Context, to, body string) (string, error)
+
+func (f SMSSenderFunc) SendSMS(ctx context.Context, to, body string) (string, error) {
+	return f(ctx, to, body)
+}
+
+// SMSDriver sends the message body as a text message through Sender. The
+// notify service does not send SMS, so a provider must be plugged in.
+type SMSDriver struct {
+	Sender SMSSender
+	// MaxLength truncates long bodies; zero means 480 characters (three SMS segments).
+	MaxLength int
+}
+
+// NewSMSDriver creates an SMS driver sending through sender.
+func NewSMSDriver(sender SMSSender) *SMSDriver {
+	return &SMSDriver{Sender: sender}
+}
+
+func (d *SMSDriver) Channel() Channel { return ChannelSMS }
+
+func (d *SMSDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	_, err := d.SendTracked(ctx, to, msg)
+	return err
+}
+
+// SendTracked sends msg and returns the SMS provider's message ID.
+func (d *SMSDriver) SendTracked(ctx context.Context, to Recipient, msg Message) (string, error) {
+	if to.Phone == "" {
+		return "", ErrNoAddress
+	}
+	if d.Sender == nil {
+		return "", errors.New("sms driver has no sender")
+	}
+	body := msg.Body
+	if body == "" {
+		body = msg.Subject
+	}
+	limit := d.MaxLength
+	if limit <= 0 {
+		limit = 480
+	}
+	if runes := []rune(body); len(runes) > limit {
+		body = string(runes[:limit-1]) + "…"
+	}
+	return d.Sender.SendSMS(ctx, to.Phone, withLink(body, msg.Link))
+}
+
+// ChatDriver posts notifications to an incoming-webhook URL, such as a Slack
+// or Mattermost channel. The default payload is Slack's {"text": ...}.
+type ChatDriver struct {
+	// URL receives messages for recipients without their own ChatURL.
+	URL        string
+	HTTPClient *http.Client
+	// Payload builds the JSON body; nil uses {"text": "*subject*\nbody\nlink"}.
+	Payload func(msg Message) any
+}
+
+// NewChatDriver creates a chat driver posting to url by default.
+func NewChatDriver(url string) *ChatDriver {
+	return &ChatDriver{
+		URL:        url,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (d *ChatDriver) Channel() Channel { return ChannelChat }
+
+func (d *ChatDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	url := to.ChatURL
+	if url == "" {
+		url = d.URL
+	}
+	if url == "" {
+		return ErrNoAddress
+	}
+	var payload any = map[string]string{"text": chatText(msg)}
+	if d.Payload != nil {
+		payload = d.Payload(msg)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal chat message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("new chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := d.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chat request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("chat webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func chatText(msg Message) string {
+	var parts []string
+	if msg.Subject != "" {
+		parts = append(parts, "*"+msg.Subject+"*")
+	}
+	if msg.Body != "" {
+		parts = append(parts, msg.Body)
+	}
+	if msg.Link != "" {
+		parts = append(parts, msg.Link)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func withLink(body, link string) string {
+	if link == "" {
+		return body
+	}
+	if body == "" {
+		return link
+	}
+	return body + "\n\n" + link
+}
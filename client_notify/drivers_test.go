@@ -0,0 +1,120 @@
+package client_notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// notifyServer records the path and email of each request it receives.
+type notifyServer struct {
+	paths   []string
+	emails  []Email
+	generic []GenericEmailRequest
+}
+
+func (s *notifyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.paths = append(s.paths, r.URL.Path)
+	switch r.URL.Path {
+	case "/api/email/send":
+		var email Email
+		if err := json.Unmarshal([]byte(r.FormValue("email")), &email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.emails = append(s.emails, email)
+	case "/api/email/generic":
+		var req GenericEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.generic = append(s.generic, req)
+	}
+	json.NewEncoder(w).Encode(EmailResponse{Success: true, MessageID: "msg_1"})
+}
+
+func TestEmailDriverSendsHTML(t *testing.T) {
+	ns := &notifyServer{}
+	srv := httptest.NewServer(ns)
+	defer srv.Close()
+
+	d := NewEmailDriver(NewClient(srv.URL))
+	d.Unsubscribe = func(Recipient, Message) string { return "https://example.com/unsubscribe?t=a&b" }
+	msg := Message{Category: CategoryProduct, Subject: "News", Body: "Plain", HTML: "<p>Rich</p>", Link: "https://example.com"}
+
+	id, err := d.SendTracked(context.Background(), Recipient{Email: "ada@example.com"}, msg)
+	if err != nil || id != "msg_1" {
+		t.Fatalf("SendTracked = %q, %v", id, err)
+	}
+	if len(ns.emails) != 1 {
+		t.Fatalf("requests = %v, want one to /api/email/send", ns.paths)
+	}
+	email := ns.emails[0]
+	if email.Subject != "News" || len(email.To) != 1 || email.To[0] != "ada@example.com" {
+		t.Errorf("email = %+v", email)
+	}
+	if !strings.HasPrefix(email.HTML, "<p>Rich</p>") || !strings.Contains(email.HTML, `href="https://example.com/unsubscribe?t=a&amp;b"`) {
+		t.Errorf("HTML = %q, want the message HTML with an escaped unsubscribe link", email.HTML)
+	}
+	if !strings.HasPrefix(email.Text, "Plain\n\nhttps://example.com") || !strings.Contains(email.Text, "https://example.com/unsubscribe?t=a&b") {
+		t.Errorf("Text = %q, want the body, link and unsubscribe URL", email.Text)
+	}
+	if email.Headers["List-Unsubscribe"] != "<https://example.com/unsubscribe?t=a&b>" {
+		t.Errorf("Headers = %v", email.Headers)
+	}
+
+	msg.HTML = ""
+	if _, err := d.SendTracked(context.Background(), Recipient{Email: "ada@example.com"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(ns.generic) != 1 || ns.generic[0].Message == "" {
+		t.Errorf("text-only message sent to %v, want /api/email/generic", ns.paths)
+	}
+}
+
+func TestEmailDriverSuppressed(t *testing.T) {
+	ns := &notifyServer{}
+	srv := httptest.NewServer(ns)
+	defer srv.Close()
+
+	d := NewEmailDriver(NewClient(srv.URL))
+	d.Suppressed = func(context.Context, string, Category) (bool, error) { return true, nil }
+	if err := d.Send(context.Background(), Recipient{Email: "ada@example.com"}, Message{Subject: "Hi"}); !errors.Is(err, ErrSuppressed) {
+		t.Fatalf("Send = %v, want ErrSuppressed", err)
+	}
+	if len(ns.paths) != 0 {
+		t.Errorf("requests = %v, want none", ns.paths)
+	}
+}
+
+func TestSMSDriver(t *testing.T) {
+	var to, body string
+	d := NewSMSDriver(SMSSenderFunc(func(_ context.Context, phone, text string) (string, error) {
+		to, body = phone, text
+		return "SM1", nil
+	}))
+	d.MaxLength = 10
+
+	id, err := d.SendTracked(context.Background(), testRecipient, Message{Subject: "Ignored", Body: "Your code is 123456", Link: "https://x.io"})
+	if err != nil || id != "SM1" {
+		t.Fatalf("SendTracked = %q, %v", id, err)
+	}
+	if to != testRecipient.Phone {
+		t.Errorf("sent to %q, want %q", to, testRecipient.Phone)
+	}
+	if want := "Your code…\n\nhttps://x.io"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	if err := d.Send(context.Background(), Recipient{Email: "ada@example.com"}, Message{Body: "Hi"}); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Send without a phone = %v, want ErrNoAddress", err)
+	}
+	if err := (&SMSDriver{}).Send(context.Background(), testRecipient, Message{Body: "Hi"}); err == nil {
+		t.Error("Send without a sender succeeded")
+	}
+}
@@ -0,0 +1,316 @@
+package client_notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MaxRecipients is the most addresses an Email may have across To, CC and BCC.
+	MaxRecipients = 50
+	// MaxAttachmentSize is the largest single attachment accepted.
+	MaxAttachmentSize = 10 << 20
+	// MaxAttachmentsSize is the largest combined size of an Email's attachments.
+	MaxAttachmentsSize = 25 << 20
+)
+
+var (
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+)
+
+// reservedHeaders are set from Email's fields and cannot be overridden
+// through Email.Headers.
+var reservedHeaders = map[string]bool{
+	"From": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true,
+	"Subject": true, "Date": true, "Message-Id": true, "Mime-Version": true,
+	"Content-Type": true, "Content-Transfer-Encoding": true, "Content-Disposition": true,
+}
+
+// Email is a free-form email for POST /api/email/send. Addresses may
+// include a display name, e.g. "Alice <alice@example.com>".
+type Email struct {
+	To      []string `json:"to"`
+	CC      []string `json:"cc,omitempty"`
+	BCC     []string `json:"bcc,omitempty"`
+	ReplyTo []string `json:"replyTo,omitempty"`
+	Subject string   `json:"subject"`
+	// Text and HTML are the plain-text and HTML bodies; at least one is
+	// required and sending both produces a multipart/alternative email.
+	Text string `json:"text,omitempty"`
+	HTML string `json:"html,omitempty"`
+	// Headers are extra headers such as List-Unsubscribe or X-Entity-Ref-ID.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Attachments are streamed to the notify service, not held in memory.
+	Attachments []Attachment `json:"-"`
+}
+
+// Attachment is a file sent with an Email. Content is read once, when the
+// email is sent.
+type Attachment struct {
+	Filename string
+	// ContentType is sniffed from the content, or the file extension, when empty.
+	ContentType string
+	// ContentID makes the attachment inline, referenced from the HTML body
+	// as "cid:<ContentID>".
+	ContentID string
+	Content   io.Reader
+}
+
+// attachmentPart describes an attachment in the JSON part of the upload;
+// Part names the form file part holding its content.
+type attachmentPart struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	ContentID   string `json:"contentId,omitempty"`
+	Part        string `json:"part"`
+}
+
+// Validate checks addresses, bodies, headers and attachment names without
+// reading any attachment content.
+func (e *Email) Validate() error {
+	n := len(e.To) + len(e.CC) + len(e.BCC)
+	if n == 0 {
+		return fmt.Errorf("%w: no recipients", ErrInvalidEmail)
+	}
+	if n > MaxRecipients {
+		return fmt.Errorf("%w: %d recipients, at most %d allowed", ErrInvalidEmail, n, MaxRecipients)
+	}
+	for _, list := range [][]string{e.To, e.CC, e.BCC, e.ReplyTo} {
+		for _, addr := range list {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("%w: address %q: %v", ErrInvalidEmail, addr, err)
+			}
+		}
+	}
+	if strings.TrimSpace(e.Subject) == "" || strings.ContainsAny(e.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject is required and must be a single line", ErrInvalidEmail)
+	}
+	if e.Text == "" && e.HTML == "" {
+		return fmt.Errorf("%w: a text or HTML body is required", ErrInvalidEmail)
+	}
+	for name, value := range e.Headers {
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		if !validHeaderName(name) || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: header %q", ErrInvalidEmail, name)
+		}
+		if reservedHeaders[key] {
+			return fmt.Errorf("%w: header %q is set from the email's fields", ErrInvalidEmail, name)
+		}
+	}
+	for i, a := range e.Attachments {
+		if a.Content == nil {
+			return fmt.Errorf("%w: attachment %d has no content", ErrInvalidEmail, i)
+		}
+		if a.Filename == "" || strings.ContainsAny(a.Filename, "\r\n\"/\\") {
+			return fmt.Errorf("%w: attachment %d filename %q", ErrInvalidEmail, i, a.Filename)
+		}
+		if strings.ContainsAny(a.ContentID, "\r\n<> ") {
+			return fmt.Errorf("%w: attachment %d content ID %q", ErrInvalidEmail, i, a.ContentID)
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a header field name (RFC 5322 2.2).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if c <= ' ' || c >= 0x7f || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// SendEmail wraps POST /api/email/send. The email is uploaded as
+// multipart/form-data: an "email" part with the JSON fields, then one file
+// part per attachment. Attachments are streamed and fail the send with
+// ErrAttachmentTooLarge once they pass MaxAttachmentSize or, together,
+// MaxAttachmentsSize.
+func (c *EmailClient) SendEmail(ctx context.Context, email Email) (*EmailResponse, error) {
+	if err := email.Validate(); err != nil {
+		return nil, err
+	}
+	resp, err := c.sendEmail(ctx, CategoryAccount, email)
+	if c.Tracker != nil {
+		c.track(ctx, Message{Category: CategoryAccount, Subject: email.Subject}, resp, err, emailAddresses(email)...)
+	}
+	return resp, err
+}
+
+// emailAddresses returns the bare addresses of a validated email's
+// recipients.
+func emailAddresses(email Email) []string {
+	var addresses []string
+	for _, list := range [][]string{email.To, email.CC, email.BCC} {
+		for _, addr := range list {
+			// Validate has parsed every address already.
+			parsed, _ := mail.ParseAddress(addr)
+			addresses = append(addresses, parsed.Address)
+		}
+	}
+	return addresses
+}
+
+// sendEmail is SendEmail with the category the recipients are checked
+// against the suppression list as.
+func (c *EmailClient) sendEmail(ctx context.Context, category Category, email Email) (*EmailResponse, error) {
+	if err := email.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Suppressed != nil {
+		if err := c.checkSuppressed(ctx, category, emailAddresses(email)...); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.uploadEmail(ctx, email)
+	// Attachments were consumed by the failed upload, so only emails
+	// without them can be resent over SMTP.
+	if err != nil && c.Fallback != nil && len(email.Attachments) == 0 && errors.Is(err, ErrServiceUnavailable) && ctx.Err() == nil {
+		id, ferr := c.Fallback.Mailer.Send(ctx, email)
+		return fallbackResponse("send", err, id, ferr)
+	}
+	return resp, err
+}
+
+func (c *EmailClient) uploadEmail(ctx context.Context, email Email) (*EmailResponse, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeEmail(mw, email)
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/email/send", pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		<-writeErr
+		return nil, fmt.Errorf("new send request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := c.httpClient.Do(req)
+	// Unblock the writer if the request stopped reading the body early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	if werr := <-writeErr; werr != nil && !errors.Is(werr, io.ErrClosedPipe) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, werr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("send request failed: %w: %w", ErrServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+	return decodeResponse("send", resp)
+}
+
+// writeEmail writes the parts of email to mw, streaming attachments.
+func writeEmail(mw *multipart.Writer, email Email) error {
+	parts := make([]attachmentPart, len(email.Attachments))
+	readers := make([]io.Reader, len(email.Attachments))
+	for i, a := range email.Attachments {
+		contentType, r, err := openAttachment(a)
+		if err != nil {
+			return err
+		}
+		parts[i] = attachmentPart{
+			Filename:    a.Filename,
+			ContentType: contentType,
+			ContentID:   a.ContentID,
+			Part:        fmt.Sprintf("attachment%d", i),
+		}
+		readers[i] = r
+	}
+
+	payload := struct {
+		Email
+		Attachments []attachmentPart `json:"attachments,omitempty"`
+	}{email, parts}
+	fw, err := mw.CreateFormField("email")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(fw).Encode(payload); err != nil {
+		return fmt.Errorf("marshal email: %w", err)
+	}
+
+	var total int64
+	for i, p := range parts {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": p.Part, "filename": p.Filename}))
+		h.Set("Content-Type", p.ContentType)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if err := copyAttachment(w, readers[i], p.Filename, &total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openAttachment returns a's content type, sniffed from its first bytes
+// when not set, and a reader for its whole content.
+func openAttachment(a Attachment) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(a.Content, sniffLen)
+	if a.ContentType != "" {
+		return a.ContentType, br, nil
+	}
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, fmt.Errorf("read attachment %s: %w", a.Filename, err)
+	}
+	return detectContentType(a.Filename, head), br, nil
+}
+
+// copyAttachment streams an attachment to w, failing with
+// ErrAttachmentTooLarge past MaxAttachmentSize or, counting earlier
+// attachments in total, MaxAttachmentsSize.
+func copyAttachment(w io.Writer, r io.Reader, filename string, total *int64) error {
+	n, err := io.Copy(w, io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return fmt.Errorf("read attachment %s: %w", filename, err)
+	}
+	if n > MaxAttachmentSize {
+		return fmt.Errorf("%w: %s is over %d bytes", ErrAttachmentTooLarge, filename, MaxAttachmentSize)
+	}
+	if *total += n; *total > MaxAttachmentsSize {
+		return fmt.Errorf("%w: attachments are over %d bytes in total", ErrAttachmentTooLarge, MaxAttachmentsSize)
+	}
+	return nil
+}
+
+// sniffLen is how many bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// detectContentType sniffs the type of an attachment from its first bytes,
+// preferring the type of its extension when sniffing only finds generic
+// text or binary data (e.g. CSV, which sniffs as text/plain).
+func detectContentType(filename string, head []byte) string {
+	sniffed := http.DetectContentType(head)
+	generic := sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain")
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); generic && byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
@@ -0,0 +1,86 @@
+package client_notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Sent is a message captured by a fake.
+type Sent struct {
+	To      Recipient
+	Message Message
+}
+
+// FakeDriver records messages instead of delivering them. Set Err to make
+// every send fail.
+type FakeDriver struct {
+	channel Channel
+
+	mu   sync.Mutex
+	sent []Sent
+	Err  error
+}
+
+// NewFakeDriver creates a fake for channel.
+func NewFakeDriver(channel Channel) *FakeDriver {
+	return &FakeDriver{channel: channel}
+}
+
+func (f *FakeDriver) Channel() Channel { return f.channel }
+
+func (f *FakeDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.sent = append(f.sent, Sent{To: to, Message: msg})
+	return nil
+}
+
+// Sent returns a copy of the recorded messages.
+func (f *FakeDriver) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sent(nil), f.sent...)
+}
+
+// Reset forgets recorded messages.
+func (f *FakeDriver) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
+
+// FakeNotifier is a Notifier that records every call and reports each
+// message as delivered over its Channels, or email when none are set.
+type FakeNotifier struct {
+	mu   sync.Mutex
+	sent []Sent
+	Err  error
+}
+
+func (f *FakeNotifier) Notify(ctx context.Context, to Recipient, msg Message) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return Result{}, f.Err
+	}
+	f.sent = append(f.sent, Sent{To: to, Message: msg})
+	channels := msg.Channels
+	if len(channels) == 0 {
+		channels = []Channel{ChannelEmail}
+	}
+	res := Result{MessageID: msg.ID}
+	for _, ch := range channels {
+		res.Deliveries = append(res.Deliveries, Delivery{Channel: ch})
+	}
+	return res, nil
+}
+
+// Sent returns a copy of the recorded calls.
+func (f *FakeNotifier) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sent(nil), f.sent...)
+}
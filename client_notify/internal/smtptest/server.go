@@ -0,0 +1,370 @@
+// Package smtptest provides an in-process SMTP server for testing
+// client_notify's SMTPMailer.
+package smtptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Security is how clients connect to a Server.
+type Security int
+
+const (
+	Plaintext Security = iota
+	// StartTLS offers the STARTTLS extension on a plain connection.
+	StartTLS
+	// ImplicitTLS accepts TLS connections only.
+	ImplicitTLS
+)
+
+// Config configures a Server.
+type Config struct {
+	Security Security
+	// Users maps usernames to passwords; when set, AUTH is required.
+	Users map[string]string
+	// Mechanisms are the AUTH mechanisms offered; nil offers PLAIN and LOGIN.
+	Mechanisms []string
+}
+
+// Mail is a message received by a Server.
+type Mail struct {
+	From string
+	To   []string
+	Data []byte
+	// TLS reports whether the message arrived over an encrypted connection.
+	TLS bool
+	// Auth is the mechanism the client authenticated with, if any.
+	Auth string
+}
+
+// Message parses the received data.
+func (m Mail) Message() (*mail.Message, error) {
+	return mail.ReadMessage(strings.NewReader(string(m.Data)))
+}
+
+// Server is an in-process SMTP server. It speaks enough ESMTP for
+// client_notify.SMTPMailer: STARTTLS or implicit TLS with a self-signed
+// certificate, AUTH PLAIN and LOGIN, and several messages per connection.
+type Server struct {
+	cfg   Config
+	ln    net.Listener
+	tls   *tls.Config
+	roots *x509.CertPool
+
+	mu    sync.Mutex
+	mails []Mail
+	conns int
+	open  map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// NewServer starts a server on 127.0.0.1.
+func NewServer(cfg Config) (*Server, error) {
+	cert, roots, err := selfSignedCert()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Mechanisms == nil {
+		cfg.Mechanisms = []string{"PLAIN", "LOGIN"}
+	}
+	s := &Server{
+		cfg:   cfg,
+		tls:   &tls.Config{Certificates: []tls.Certificate{cert}},
+		roots: roots,
+		open:  make(map[net.Conn]bool),
+	}
+	if cfg.Security == ImplicitTLS {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tls)
+	} else {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host is the address the server listens on.
+func (s *Server) Host() string { return "127.0.0.1" }
+
+// Port is the port the server listens on.
+func (s *Server) Port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+// TLSConfig returns a client TLS configuration that trusts the server's
+// certificate.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.roots}
+}
+
+// Mails returns a copy of the messages received so far.
+func (s *Server) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+// Connections returns how many connections have been accepted.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// DropConnections closes every open connection, as a server timing out
+// idle clients would.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.open {
+		conn.Close()
+	}
+}
+
+// Close stops the server, dropping open connections.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.DropConnections()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.open[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(conn)
+			conn.Close()
+			s.mu.Lock()
+			delete(s.open, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// session serves one connection until QUIT or an error.
+func (s *Server) session(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	secure := s.cfg.Security == ImplicitTLS
+	var authed string
+	var from string
+	var to []string
+	reply := func(code int, msg string) bool {
+		return tp.PrintfLine("%d %s", code, msg) == nil
+	}
+	if !reply(220, "fake ESMTP ready") {
+		return
+	}
+	for {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			lines := []string{"fake"}
+			if s.cfg.Security == StartTLS && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			if len(s.cfg.Mechanisms) > 0 {
+				lines = append(lines, "AUTH "+strings.Join(s.cfg.Mechanisms, " "))
+			}
+			lines = append(lines, "8BITMIME")
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				if tp.PrintfLine("250%s%s", sep, l) != nil {
+					return
+				}
+			}
+		case "HELO":
+			reply(250, "fake")
+		case "STARTTLS":
+			if s.cfg.Security != StartTLS || secure {
+				reply(503, "5.5.1 TLS not available")
+				continue
+			}
+			reply(220, "2.0.0 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, tp, secure = tlsConn, textproto.NewConn(tlsConn), true
+			authed, from, to = "", "", nil
+		case "AUTH":
+			authed = s.auth(tp, arg)
+			if authed != "" {
+				reply(235, "2.7.0 Authentication successful")
+			} else {
+				reply(535, "5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL":
+			if len(s.cfg.Users) > 0 && authed == "" {
+				reply(530, "5.7.0 Authentication required")
+				continue
+			}
+			from, to = addrArg(arg), nil
+			reply(250, "2.1.0 OK")
+		case "RCPT":
+			if from == "" {
+				reply(503, "5.5.1 MAIL first")
+				continue
+			}
+			to = append(to, addrArg(arg))
+			reply(250, "2.1.5 OK")
+		case "DATA":
+			if len(to) == 0 {
+				reply(503, "5.5.1 RCPT first")
+				continue
+			}
+			reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, Mail{From: from, To: to, Data: data, TLS: secure, Auth: authed})
+			s.mu.Unlock()
+			from, to = "", nil
+			reply(250, "2.0.0 OK queued")
+		case "RSET":
+			from, to = "", nil
+			reply(250, "2.0.0 OK")
+		case "NOOP":
+			reply(250, "2.0.0 OK")
+		case "QUIT":
+			reply(221, "2.0.0 Bye")
+			return
+		default:
+			reply(502, "5.5.2 Command not recognised")
+		}
+	}
+}
+
+// auth runs an AUTH PLAIN or LOGIN exchange and returns the mechanism, or
+// "" if it is not offered or the credentials do not match.
+func (s *Server) auth(tp *textproto.Conn, arg string) string {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	mechanism = strings.ToUpper(mechanism)
+	if !slices.Contains(s.cfg.Mechanisms, mechanism) {
+		return ""
+	}
+	read := func(prompt string) (string, bool) {
+		if tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt))) != nil {
+			return "", false
+		}
+		line, err := tp.ReadLine()
+		if err != nil {
+			return "", false
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		return string(b), err == nil
+	}
+	var user, pass string
+	switch mechanism {
+	case "PLAIN":
+		resp := initial
+		if resp == "" {
+			if tp.PrintfLine("334 ") != nil {
+				return ""
+			}
+			resp, _ = tp.ReadLine()
+		}
+		b, err := base64.StdEncoding.DecodeString(resp)
+		if err != nil {
+			return ""
+		}
+		parts := strings.Split(string(b), "\x00")
+		if len(parts) != 3 {
+			return ""
+		}
+		user, pass = parts[1], parts[2]
+	case "LOGIN":
+		var ok bool
+		if user, ok = read("Username:"); !ok {
+			return ""
+		}
+		if pass, ok = read("Password:"); !ok {
+			return ""
+		}
+	default:
+		return ""
+	}
+	if want, ok := s.cfg.Users[user]; !ok || want != pass {
+		return ""
+	}
+	return mechanism
+}
+
+// addrArg extracts the address from "FROM:<a@b>" or "TO:<a@b> PARAM".
+func addrArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1 and localhost and a
+// pool trusting it.
+func selfSignedCert() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots, nil
+}
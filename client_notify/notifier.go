@@ -0,0 +1,305 @@
+package client_notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// Channel is a medium a notification can be delivered through.
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelChat  Channel = "chat"
+	ChannelInApp Channel = "in_app"
+)
+
+// Category classifies a notification for routing and user preferences.
+type Category string
+
+const (
+	CategorySecurity  Category = "security"
+	CategoryAccount   Category = "account"
+	CategoryBilling   Category = "billing"
+	CategoryProduct   Category = "product"
+	CategoryMarketing Category = "marketing"
+)
+
+var (
+	ErrNoChannels     = errors.New("no channels selected for notification")
+	ErrNoDriver       = errors.New("no driver registered for channel")
+	ErrNoAddress      = errors.New("recipient has no address for channel")
+	ErrInvalidMessage = errors.New("notification has no subject or body")
+	ErrOptedOut       = errors.New("recipient has opted out of this notification")
+	ErrSuppressed     = errors.New("recipient address is suppressed")
+)
+
+// Recipient is who a notification is for, with an address per channel.
+// Drivers skip recipients without an address for their channel.
+type Recipient struct {
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Phone  string `json:"phone,omitempty"` // E.164, e.g. +447700900000
+	// ChatURL overrides the chat driver's default incoming-webhook URL.
+	ChatURL string `json:"chat_url,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+}
+
+// Message is a notification independent of how it is delivered. Drivers
+// use the fields their channel supports: SMS sends only Body, chat and
+// in-app add Subject and Link, email uses everything.
+type Message struct {
+	// ID identifies the notification across channels; Router fills it in.
+	ID       string   `json:"id"`
+	Category Category `json:"category"`
+	Subject  string   `json:"subject"`
+	// Body is plain text.
+	Body string `json:"body"`
+	// HTML optionally replaces Body in channels that render HTML.
+	HTML string `json:"html,omitempty"`
+	// Link is an optional call to action.
+	Link string            `json:"link,omitempty"`
+	Data map[string]string `json:"data,omitempty"`
+	// Channels, when set, overrides routing.
+	Channels  []Channel `json:"channels,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifier sends a message to a recipient over one or more channels.
+type Notifier interface {
+	Notify(ctx context.Context, to Recipient, msg Message) (Result, error)
+}
+
+// Driver delivers messages over a single channel.
+type Driver interface {
+	Channel() Channel
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
+
+// TrackingDriver is a Driver that reports the provider's ID for each
+// message it sends, for delivery tracking.
+type TrackingDriver interface {
+	Driver
+	SendTracked(ctx context.Context, to Recipient, msg Message) (providerID string, err error)
+}
+
+// sendTracked sends through d, returning the provider's message ID when d
+// reports one.
+func sendTracked(ctx context.Context, d Driver, to Recipient, msg Message) (string, error) {
+	if td, ok := d.(TrackingDriver); ok {
+		return td.SendTracked(ctx, to, msg)
+	}
+	return "", d.Send(ctx, to, msg)
+}
+
+// Delivery is the outcome of sending over one channel.
+type Delivery struct {
+	Channel Channel `json:"channel"`
+	// ProviderID is the ID the channel's provider gave the message, when
+	// its driver is a TrackingDriver.
+	ProviderID string `json:"provider_id,omitempty"`
+	Err        error  `json:"-"`
+}
+
+// Result reports every channel a message was sent over.
+type Result struct {
+	MessageID  string     `json:"message_id"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Delivered reports whether at least one channel succeeded.
+func (r Result) Delivered() bool {
+	for _, d := range r.Deliveries {
+		if d.Err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Err joins the errors of the channels that failed.
+func (r Result) Err() error {
+	var errs []error
+	for _, d := range r.Deliveries {
+		if d.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Channel, d.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Preferences decides whether a recipient accepts a category of
+// notification on a channel, typically from settings they manage.
+type Preferences interface {
+	Allowed(ctx context.Context, to Recipient, category Category, channel Channel) (bool, error)
+}
+
+// Tracker records the outcome of every delivery, e.g. so that bounces
+// reported later can be matched to a user by Delivery.ProviderID.
+type Tracker interface {
+	Track(ctx context.Context, to Recipient, msg Message, d Delivery) error
+}
+
+// RouteFunc chooses channels for one recipient and message, typically from
+// the user's stored settings. Returning no channels defers to the category
+// defaults.
+type RouteFunc func(ctx context.Context, to Recipient, msg Message) ([]Channel, error)
+
+// Router is a Notifier that fans each message out to the drivers of the
+// channels chosen for it. Channels come from, in order: Message.Channels,
+// Route, Defaults for the message category, then Fallback. Channels the
+// recipient has opted out of in Preferences are then dropped, except for
+// security notifications, which are always sent. Every delivery is
+// reported to Tracker, when set.
+type Router struct {
+	drivers map[Channel]Driver
+
+	Route       RouteFunc
+	Defaults    map[Category][]Channel
+	Fallback    []Channel
+	Preferences Preferences
+	Tracker     Tracker
+}
+
+// NewRouter creates a Router over drivers that sends to email by default
+// and to email and in-app for security notifications.
+func NewRouter(drivers ...Driver) *Router {
+	r := &Router{
+		drivers: make(map[Channel]Driver),
+		Defaults: map[Category][]Channel{
+			CategorySecurity: {ChannelEmail, ChannelInApp},
+		},
+		Fallback: []Channel{ChannelEmail},
+	}
+	for _, d := range drivers {
+		r.Register(d)
+	}
+	return r
+}
+
+// Register adds or replaces the driver for its channel.
+func (r *Router) Register(d Driver) {
+	r.drivers[d.Channel()] = d
+}
+
+// Channels returns the channels msg would be sent over to to.
+func (r *Router) Channels(ctx context.Context, to Recipient, msg Message) ([]Channel, error) {
+	if len(msg.Channels) > 0 {
+		return dedupeChannels(msg.Channels), nil
+	}
+	if r.Route != nil {
+		channels, err := r.Route(ctx, to, msg)
+		if err != nil {
+			return nil, fmt.Errorf("route notification: %w", err)
+		}
+		if len(channels) > 0 {
+			return dedupeChannels(channels), nil
+		}
+	}
+	if channels := r.Defaults[msg.Category]; len(channels) > 0 {
+		return dedupeChannels(channels), nil
+	}
+	if len(r.Fallback) > 0 {
+		return dedupeChannels(r.Fallback), nil
+	}
+	return nil, ErrNoChannels
+}
+
+// Notify sends msg over every selected channel concurrently. It returns an
+// error only when no channel succeeded; inspect the Result for partial failures.
+func (r *Router) Notify(ctx context.Context, to Recipient, msg Message) (Result, error) {
+	if msg.Subject == "" && msg.Body == "" && msg.HTML == "" {
+		return Result{}, ErrInvalidMessage
+	}
+	if msg.ID == "" {
+		id, err := shared_helpers.GenerateCondensedUUID()
+		if err != nil {
+			return Result{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+		}
+		msg.ID = id
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now().UTC()
+	}
+	// Invalid addresses are left for the email driver to reject.
+	if email, err := shared_email.Normalise(to.Email); err == nil {
+		to.Email = email
+	}
+	channels, err := r.Channels(ctx, to, msg)
+	if err != nil {
+		return Result{}, err
+	}
+	if channels, err = r.allowed(ctx, to, msg, channels); err != nil {
+		return Result{MessageID: msg.ID}, err
+	}
+
+	res := Result{MessageID: msg.ID, Deliveries: make([]Delivery, len(channels))}
+	var wg sync.WaitGroup
+	for i, ch := range channels {
+		res.Deliveries[i].Channel = ch
+		d, ok := r.drivers[ch]
+		if !ok {
+			res.Deliveries[i].Err = ErrNoDriver
+			continue
+		}
+		wg.Add(1)
+		go func(i int, d Driver) {
+			defer wg.Done()
+			res.Deliveries[i].ProviderID, res.Deliveries[i].Err = sendTracked(ctx, d, to, msg)
+			if r.Tracker != nil {
+				if err := r.Tracker.Track(ctx, to, msg, res.Deliveries[i]); err != nil {
+					log.Printf("client_notify: track %s delivery of %s: %v", ch, msg.ID, err)
+				}
+			}
+		}(i, d)
+	}
+	wg.Wait()
+
+	if !res.Delivered() {
+		return res, res.Err()
+	}
+	return res, nil
+}
+
+// allowed drops the channels to has opted out of, failing with ErrOptedOut
+// when none are left.
+func (r *Router) allowed(ctx context.Context, to Recipient, msg Message, channels []Channel) ([]Channel, error) {
+	if r.Preferences == nil || msg.Category == CategorySecurity {
+		return channels, nil
+	}
+	out := channels[:0:0]
+	for _, ch := range channels {
+		ok, err := r.Preferences.Allowed(ctx, to, msg.Category, ch)
+		if err != nil {
+			return nil, fmt.Errorf("check notification preferences: %w", err)
+		}
+		if ok {
+			out = append(out, ch)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrOptedOut
+	}
+	return out, nil
+}
+
+func dedupeChannels(channels []Channel) []Channel {
+	seen := make(map[Channel]bool, len(channels))
+	out := make([]Channel, 0, len(channels))
+	for _, ch := range channels {
+		if !seen[ch] {
+			seen[ch] = true
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+package client_notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var testRecipient = Recipient{UserID: "usr_1", Email: "Ada@Example.com", Phone: "+447700900000"}
+
+func newTestRouter() (*Router, map[Channel]*FakeDriver) {
+	fakes := map[Channel]*FakeDriver{}
+	r := NewRouter()
+	for _, ch := range []Channel{ChannelEmail, ChannelSMS, ChannelChat, ChannelInApp} {
+		fakes[ch] = NewFakeDriver(ch)
+		r.Register(fakes[ch])
+	}
+	return r, fakes
+}
+
+// sentOver lists the channels whose fakes received a message, in a fixed order.
+func sentOver(fakes map[Channel]*FakeDriver) []Channel {
+	var out []Channel
+	for _, ch := range []Channel{ChannelEmail, ChannelSMS, ChannelChat, ChannelInApp} {
+		if len(fakes[ch].Sent()) > 0 {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+func TestRouterFansOut(t *testing.T) {
+	r, fakes := newTestRouter()
+	msg := Message{Subject: "Hello", Body: "Body", Channels: []Channel{ChannelEmail, ChannelSMS, ChannelInApp, ChannelEmail}}
+
+	res, err := r.Notify(context.Background(), testRecipient, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sentOver(fakes); !reflect.DeepEqual(got, []Channel{ChannelEmail, ChannelSMS, ChannelInApp}) {
+		t.Fatalf("sent over %v, want email, sms and in_app once each", got)
+	}
+	if len(res.Deliveries) != 3 || !res.Delivered() || res.Err() != nil || res.MessageID == "" {
+		t.Fatalf("Result = %+v", res)
+	}
+	// Every driver sees the same message, with the ID and address filled in.
+	for _, ch := range []Channel{ChannelEmail, ChannelSMS, ChannelInApp} {
+		sent := fakes[ch].Sent()[0]
+		if sent.Message.ID != res.MessageID || sent.Message.CreatedAt.IsZero() {
+			t.Errorf("%s got message %+v, want ID %s and CreatedAt set", ch, sent.Message, res.MessageID)
+		}
+		if sent.To.Email != "ada@example.com" {
+			t.Errorf("%s got email %q, want it normalised", ch, sent.To.Email)
+		}
+	}
+}
+
+func TestRouterChannelPrecedence(t *testing.T) {
+	route := func(channels ...Channel) RouteFunc {
+		return func(context.Context, Recipient, Message) ([]Channel, error) { return channels, nil }
+	}
+	tests := []struct {
+		name     string
+		channels []Channel
+		route    RouteFunc
+		category Category
+		want     []Channel
+	}{
+		{"message channels override routing", []Channel{ChannelChat}, route(ChannelSMS), CategorySecurity, []Channel{ChannelChat}},
+		{"route overrides category defaults", nil, route(ChannelSMS), CategorySecurity, []Channel{ChannelSMS}},
+		{"empty route defers to category defaults", nil, route(), CategorySecurity, []Channel{ChannelEmail, ChannelInApp}},
+		{"category defaults", nil, nil, CategorySecurity, []Channel{ChannelEmail, ChannelInApp}},
+		{"fallback", nil, nil, CategoryProduct, []Channel{ChannelEmail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fakes := newTestRouter()
+			r.Route = tt.route
+			msg := Message{Category: tt.category, Subject: "Hello", Channels: tt.channels}
+			if _, err := r.Notify(context.Background(), testRecipient, msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := sentOver(fakes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent over %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	r, _ := newTestRouter()
+	r.Fallback = nil
+	if _, err := r.Notify(context.Background(), testRecipient, Message{Category: CategoryProduct, Subject: "Hello"}); !errors.Is(err, ErrNoChannels) {
+		t.Errorf("Notify without any channels = %v, want ErrNoChannels", err)
+	}
+	r.Route = func(context.Context, Recipient, Message) ([]Channel, error) {
+		return nil, errors.New("settings unavailable")
+	}
+	if _, err := r.Notify(context.Background(), testRecipient, Message{Subject: "Hello"}); err == nil {
+		t.Error("Notify succeeded although Route failed")
+	}
+}
+
+func TestRouterPartialFailure(t *testing.T) {
+	r, fakes := newTestRouter()
+	failure := errors.New("provider down")
+	fakes[ChannelSMS].Err = failure
+	msg := Message{Subject: "Hello", Channels: []Channel{ChannelEmail, ChannelSMS}}
+
+	res, err := r.Notify(context.Background(), testRecipient, msg)
+	if err != nil {
+		t.Fatalf("Notify = %v, want nil when email was delivered", err)
+	}
+	if !res.Delivered() || !errors.Is(res.Err(), failure) {
+		t.Fatalf("Result = %+v, want delivered with the SMS failure reported", res)
+	}
+	for _, d := range res.Deliveries {
+		if (d.Channel == ChannelSMS) != (d.Err != nil) {
+			t.Errorf("delivery %s: err = %v", d.Channel, d.Err)
+		}
+	}
+
+	fakes[ChannelEmail].Err = failure
+	if _, err := r.Notify(context.Background(), testRecipient, msg); !errors.Is(err, failure) {
+		t.Errorf("Notify with every channel failing = %v, want %v", err, failure)
+	}
+
+	r = NewRouter(NewFakeDriver(ChannelEmail))
+	res, err = r.Notify(context.Background(), testRecipient, Message{Subject: "Hello", Channels: []Channel{ChannelEmail, ChannelChat}})
+	if err != nil || res.Deliveries[1].Channel != ChannelChat || !errors.Is(res.Deliveries[1].Err, ErrNoDriver) {
+		t.Errorf("Notify to an unregistered channel = %+v, %v; want ErrNoDriver for chat", res, err)
+	}
+}
+
+func TestRouterRejectsEmptyMessage(t *testing.T) {
+	r, fakes := newTestRouter()
+	if _, err := r.Notify(context.Background(), testRecipient, Message{}); !errors.Is(err, ErrInvalidMessage) {
+		t.Fatalf("Notify = %v, want ErrInvalidMessage", err)
+	}
+	if got := sentOver(fakes); len(got) != 0 {
+		t.Errorf("sent over %v, want nothing", got)
+	}
+}
+
+// fakePreferences opts every recipient out of the listed channels.
+type fakePreferences map[Channel]bool
+
+func (p fakePreferences) Allowed(_ context.Context, _ Recipient, _ Category, ch Channel) (bool, error) {
+	return !p[ch], nil
+}
+
+func TestRouterPreferences(t *testing.T) {
+	r, fakes := newTestRouter()
+	r.Preferences = fakePreferences{ChannelSMS: true}
+	msg := Message{Category: CategoryProduct, Subject: "Hello", Channels: []Channel{ChannelEmail, ChannelSMS}}
+
+	if _, err := r.Notify(context.Background(), testRecipient, msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentOver(fakes); !reflect.DeepEqual(got, []Channel{ChannelEmail}) {
+		t.Fatalf("sent over %v, want email only", got)
+	}
+
+	fakes[ChannelEmail].Reset()
+	msg.Channels = []Channel{ChannelSMS}
+	if _, err := r.Notify(context.Background(), testRecipient, msg); !errors.Is(err, ErrOptedOut) {
+		t.Fatalf("Notify = %v, want ErrOptedOut", err)
+	}
+
+	msg.Category = CategorySecurity
+	if _, err := r.Notify(context.Background(), testRecipient, msg); err != nil {
+		t.Fatalf("security notification: %v", err)
+	}
+	if got := sentOver(fakes); !reflect.DeepEqual(got, []Channel{ChannelSMS}) {
+		t.Errorf("security notification sent over %v, want sms despite the opt-out", got)
+	}
+}
+
+// trackingFake is a FakeDriver that reports provider IDs.
+type trackingFake struct{ *FakeDriver }
+
+func (f trackingFake) SendTracked(ctx context.Context, to Recipient, msg Message) (string, error) {
+	if err := f.Send(ctx, to, msg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d", f.Channel(), len(f.Sent())), nil
+}
+
+type recordingTracker struct {
+	mu         sync.Mutex
+	deliveries map[Channel]Delivery
+}
+
+func (rt *recordingTracker) Track(_ context.Context, _ Recipient, _ Message, d Delivery) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.deliveries[d.Channel] = d
+	return nil
+}
+
+func TestRouterTracksDeliveries(t *testing.T) {
+	failing := NewFakeDriver(ChannelChat)
+	failing.Err = errors.New("webhook gone")
+	tracker := &recordingTracker{deliveries: map[Channel]Delivery{}}
+	r := NewRouter(trackingFake{NewFakeDriver(ChannelEmail)}, failing)
+	r.Tracker = tracker
+
+	res, err := r.Notify(context.Background(), testRecipient, Message{Subject: "Hello", Channels: []Channel{ChannelEmail, ChannelChat}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deliveries[0].ProviderID != "email-1" {
+		t.Errorf("email ProviderID = %q, want email-1", res.Deliveries[0].ProviderID)
+	}
+	if d := tracker.deliveries[ChannelEmail]; d.ProviderID != "email-1" || d.Err != nil {
+		t.Errorf("tracked email delivery = %+v", d)
+	}
+	if d, ok := tracker.deliveries[ChannelChat]; !ok || d.Err == nil {
+		t.Errorf("tracked chat delivery = %+v, %v; want the failure", d, ok)
+	}
+}
+
+func TestFakeNotifier(t *testing.T) {
+	var n Notifier = &FakeNotifier{}
+	res, err := n.Notify(context.Background(), testRecipient, Message{ID: "m1", Subject: "Hello", Channels: []Channel{ChannelSMS}})
+	if err != nil || res.MessageID != "m1" || len(res.Deliveries) != 1 || res.Deliveries[0].Channel != ChannelSMS {
+		t.Fatalf("Notify = %+v, %v", res, err)
+	}
+	if sent := n.(*FakeNotifier).Sent(); len(sent) != 1 || sent[0].To.UserID != "usr_1" {
+		t.Errorf("Sent = %+v", sent)
+	}
+}
@@ -0,0 +1,78 @@
+package client_notify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit wraps d so that it starts at most perSecond sends per second on
+// average, allowing bursts of up to burst. The limit is shared by every
+// goroutine sending through the returned driver, so wrapping each provider's
+// driver once keeps a bulk send within that provider's API limits.
+func RateLimit(d Driver, perSecond float64, burst int) Driver {
+	return &rateLimitedDriver{Driver: d, bucket: newTokenBucket(perSecond, burst)}
+}
+
+type rateLimitedDriver struct {
+	Driver
+	bucket *tokenBucket
+}
+
+func (d *rateLimitedDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	if err := d.bucket.wait(ctx); err != nil {
+		return err
+	}
+	return d.Driver.Send(ctx, to, msg)
+}
+
+func (d *rateLimitedDriver) SendTracked(ctx context.Context, to Recipient, msg Message) (string, error) {
+	if err := d.bucket.wait(ctx); err != nil {
+		return "", err
+	}
+	return sendTracked(ctx, d.Driver, to, msg)
+}
+
+// tokenBucket hands out reservations: a caller takes a token even when
+// none is left and waits until the bucket would have refilled it, so
+// waiters are served in arrival order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	b := float64(max(burst, 1))
+	return &tokenBucket{rate: perSecond, burst: b, tokens: b, last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++ // hand the reservation back
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,366 @@
+package client_notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// SMTPSecurity is how an SMTP connection is encrypted.
+type SMTPSecurity string
+
+const (
+	// SMTPStartTLS upgrades a plain connection with STARTTLS, usually on port 587.
+	SMTPStartTLS SMTPSecurity = "starttls"
+	// SMTPImplicitTLS connects over TLS from the start, usually on port 465.
+	SMTPImplicitTLS SMTPSecurity = "tls"
+	// SMTPPlaintext does not encrypt; use it only for local relays and tests.
+	SMTPPlaintext SMTPSecurity = "none"
+)
+
+const (
+	defaultSMTPTimeout     = 10 * time.Second
+	defaultSMTPIdleTimeout = 30 * time.Second
+)
+
+// SMTPConfig configures an SMTPMailer.
+type SMTPConfig struct {
+	Host string
+	// Port defaults to 587, 465 or 25 depending on Security.
+	Port     int
+	Security SMTPSecurity // default SMTPStartTLS
+	// Username and Password enable authentication.
+	Username string
+	Password string
+	// Auth is "PLAIN", "LOGIN", or empty to use whichever the server offers,
+	// preferring PLAIN.
+	Auth string
+	// From is the sender, e.g. "Hstles <no-reply@hstles.com>".
+	From string
+	// HeloName is sent in EHLO; it defaults to "localhost".
+	HeloName string
+	// TLSConfig overrides the TLS settings; ServerName defaults to Host.
+	TLSConfig *tls.Config
+	// Timeout bounds dialling and each SMTP command.
+	Timeout time.Duration
+	// IdleTimeout closes a kept-alive connection unused for this long.
+	IdleTimeout time.Duration
+}
+
+// SMTPMailer sends Emails to an SMTP server, keeping one connection open
+// between sends. Sends are serialised over that connection, which suits a
+// fallback path; it is safe for concurrent use.
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	from *mail.Address
+
+	mu       sync.Mutex
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+	idle     *time.Timer
+}
+
+// NewSMTPMailer checks cfg and fills in its defaults. It does not connect
+// until the first send.
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("client_notify: SMTP host is required")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("client_notify: SMTP from address: %w", err)
+	}
+	if cfg.Security == "" {
+		cfg.Security = SMTPStartTLS
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case SMTPImplicitTLS:
+			cfg.Port = 465
+		case SMTPPlaintext:
+			cfg.Port = 25
+		default:
+			cfg.Port = 587
+		}
+	}
+	cfg.Auth = strings.ToUpper(cfg.Auth)
+	if cfg.Auth != "" && cfg.Auth != "PLAIN" && cfg.Auth != "LOGIN" {
+		return nil, fmt.Errorf("client_notify: unsupported SMTP auth %q", cfg.Auth)
+	}
+	if cfg.HeloName == "" {
+		cfg.HeloName = "localhost"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultSMTPTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultSMTPIdleTimeout
+	}
+	return &SMTPMailer{cfg: cfg, from: from}, nil
+}
+
+// From returns the configured sender.
+func (m *SMTPMailer) From() *mail.Address { return m.from }
+
+// Send delivers email and returns the Message-ID it was sent with. BCC
+// recipients get the email without appearing in its headers.
+func (m *SMTPMailer) Send(ctx context.Context, email Email) (string, error) {
+	if err := email.Validate(); err != nil {
+		return "", err
+	}
+	var rcpts []string
+	for _, list := range [][]string{email.To, email.CC, email.BCC} {
+		for _, addr := range list {
+			a, _ := mail.ParseAddress(addr)
+			if !slices.Contains(rcpts, a.Address) {
+				rcpts = append(rcpts, a.Address)
+			}
+		}
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return "", fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	messageID := id + "@" + domainOf(m.from.Address)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, err := m.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	conn := m.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+	if err := m.transaction(ctx, c, rcpts, email, messageID); err != nil {
+		m.closeLocked()
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("SMTP send: %w", err)
+	}
+	m.lastUsed = time.Now()
+	if m.idle == nil {
+		m.idle = time.AfterFunc(m.cfg.IdleTimeout, m.closeIdle)
+	} else {
+		m.idle.Reset(m.cfg.IdleTimeout)
+	}
+	return messageID, nil
+}
+
+func (m *SMTPMailer) transaction(ctx context.Context, c *smtp.Client, rcpts []string, email Email, messageID string) error {
+	m.extendDeadline()
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	dw := &deadlineWriter{w: w, ctx: ctx, extend: m.extendDeadline}
+	if err := writeMessage(dw, m.from, email, messageID, time.Now()); err != nil {
+		w.Close()
+		return err
+	}
+	m.extendDeadline()
+	return w.Close()
+}
+
+// connect returns the open connection if the server still answers on it,
+// or dials a new one. m.mu must be held.
+func (m *SMTPMailer) connect(ctx context.Context) (*smtp.Client, error) {
+	if m.client != nil {
+		m.extendDeadline()
+		if time.Since(m.lastUsed) < m.cfg.IdleTimeout && m.client.Reset() == nil {
+			return m.client, nil
+		}
+		m.closeLocked()
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	dialer := &net.Dialer{Timeout: m.cfg.Timeout}
+	tlsConfig := m.tlsConfig()
+	var conn net.Conn
+	var err error
+	if m.cfg.Security == SMTPImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SMTP dial %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(m.cfg.Timeout))
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMTP greeting: %w", err)
+	}
+	m.conn, m.client = conn, c
+	if err := m.handshake(c, tlsConfig); err != nil {
+		m.closeLocked()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake says hello, upgrades to TLS and authenticates.
+func (m *SMTPMailer) handshake(c *smtp.Client, tlsConfig *tls.Config) error {
+	if err := c.Hello(m.cfg.HeloName); err != nil {
+		return fmt.Errorf("SMTP EHLO: %w", err)
+	}
+	if m.cfg.Security == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("SMTP STARTTLS: %w", err)
+		}
+	}
+	if m.cfg.Username == "" {
+		return nil
+	}
+	ok, mechanisms := c.Extension("AUTH")
+	if !ok {
+		return errors.New("SMTP server does not support AUTH")
+	}
+	offered := strings.Fields(strings.ToUpper(mechanisms))
+	mechanism := m.cfg.Auth
+	if mechanism == "" {
+		mechanism = "PLAIN"
+		if !slices.Contains(offered, "PLAIN") && slices.Contains(offered, "LOGIN") {
+			mechanism = "LOGIN"
+		}
+	}
+	var auth smtp.Auth
+	if mechanism == "LOGIN" {
+		auth = &loginAuth{username: m.cfg.Username, password: m.cfg.Password, host: m.cfg.Host}
+	} else {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	if err := c.Auth(auth); err != nil {
+		return fmt.Errorf("SMTP AUTH %s: %w", mechanism, err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) tlsConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if m.cfg.TLSConfig != nil {
+		cfg = m.cfg.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = m.cfg.Host
+	}
+	return cfg
+}
+
+func (m *SMTPMailer) extendDeadline() {
+	m.conn.SetDeadline(time.Now().Add(m.cfg.Timeout))
+}
+
+// Close quits the open connection, if any.
+func (m *SMTPMailer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client == nil {
+		return nil
+	}
+	m.extendDeadline()
+	err := m.client.Quit()
+	m.closeLocked()
+	return err
+}
+
+func (m *SMTPMailer) closeIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil && time.Since(m.lastUsed) >= m.cfg.IdleTimeout {
+		m.extendDeadline()
+		m.client.Quit()
+		m.closeLocked()
+	}
+}
+
+func (m *SMTPMailer) closeLocked() {
+	if m.client != nil {
+		m.client.Close()
+	}
+	m.conn, m.client = nil, nil
+}
+
+// deadlineWriter extends the connection deadline as the message body is
+// written, so large emails are bounded per write rather than in total.
+type deadlineWriter struct {
+	w      io.Writer
+	ctx    context.Context
+	extend func()
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, err
+	}
+	d.extend()
+	return d.w.Write(p)
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks. Like
+// smtp.PlainAuth it refuses to send credentials unencrypted except to
+// localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "pass"):
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
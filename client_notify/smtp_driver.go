@@ -0,0 +1,103 @@
+package client_notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_templates"
+)
+
+// errNoFallback means a request has no local template to fall back to.
+var errNoFallback = errors.New("no SMTP fallback for request")
+
+// SMTPDriver sends email straight to an SMTP server, rendering the notify
+// service's templates locally with shared_templates. Use it as a Driver to
+// bypass the notify service, or as EmailClient.Fallback to use it only
+// while the service is down.
+type SMTPDriver struct {
+	Mailer    *SMTPMailer
+	Templates *shared_templates.Engine
+	// LoginLinkTTL and SecurityCodeTTL are the lifetimes shown in fallback
+	// login link and security code emails, whose requests do not carry them.
+	LoginLinkTTL    time.Duration
+	SecurityCodeTTL time.Duration
+}
+
+// NewSMTPDriver creates an SMTP driver rendering with templates.
+func NewSMTPDriver(mailer *SMTPMailer, templates *shared_templates.Engine) *SMTPDriver {
+	return &SMTPDriver{
+		Mailer:          mailer,
+		Templates:       templates,
+		LoginLinkTTL:    15 * time.Minute,
+		SecurityCodeTTL: 10 * time.Minute,
+	}
+}
+
+func (d *SMTPDriver) Channel() Channel { return ChannelEmail }
+
+func (d *SMTPDriver) Send(ctx context.Context, to Recipient, msg Message) error {
+	_, err := d.SendTracked(ctx, to, msg)
+	return err
+}
+
+// SendTracked renders msg with the generic template, unless it has its own
+// HTML, and returns the Message-ID it was sent with.
+func (d *SMTPDriver) SendTracked(ctx context.Context, to Recipient, msg Message) (string, error) {
+	if to.Email == "" {
+		return "", ErrNoAddress
+	}
+	rendered, err := d.Templates.RenderLocale(shared_templates.GenericData{
+		Subject: msg.Subject,
+		Message: withLink(msg.Body, msg.Link),
+	}, to.Locale)
+	if err != nil {
+		return "", err
+	}
+	if msg.HTML != "" {
+		rendered.HTML = msg.HTML
+	}
+	return d.Mailer.Send(ctx, Email{
+		To:      []string{(&mail.Address{Name: to.Name, Address: to.Email}).String()},
+		Subject: rendered.Subject,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+}
+
+// SendTemplate renders data in locale and sends it to the address to.
+func (d *SMTPDriver) SendTemplate(ctx context.Context, to, locale string, data shared_templates.Data, headers map[string]string) (string, error) {
+	rendered, err := d.Templates.RenderLocale(data, locale)
+	if err != nil {
+		return "", err
+	}
+	return d.Mailer.Send(ctx, Email{
+		To:      []string{to},
+		Subject: rendered.Subject,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+		Headers: headers,
+	})
+}
+
+// sendRequest sends a notify service request body with the matching
+// local template, or fails with errNoFallback.
+func (d *SMTPDriver) sendRequest(ctx context.Context, payload any) (string, error) {
+	switch req := payload.(type) {
+	case *WelcomeEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.WelcomeData{UserName: req.UserName}, nil)
+	case *SecurityCodeEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.SecurityCodeData{Code: req.Code, ExpiresIn: d.SecurityCodeTTL}, nil)
+	case *RecoveryCodeEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.RecoveryCodeData{UserName: req.UserName, Code: req.Code}, nil)
+	case *ServiceAlertEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.ServiceAlertData{Title: req.AlertTitle, Message: req.AlertMessage}, nil)
+	case *LoginLinkEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.LoginLinkData{UserName: req.UserName, Link: req.LoginLink, ExpiresIn: d.LoginLinkTTL}, nil)
+	case *GenericEmailRequest:
+		return d.SendTemplate(ctx, req.To, "", shared_templates.GenericData{Subject: req.Subject, Message: req.Message}, req.Headers)
+	}
+	return "", fmt.Errorf("%w: %T", errNoFallback, payload)
+}
@@ -0,0 +1,205 @@
+package client_notify
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// writeMessage writes email as an RFC 5322 message with MIME parts: a
+// single text or HTML part, multipart/alternative for both, wrapped in
+// multipart/mixed when there are attachments. Attachments are streamed.
+func writeMessage(w io.Writer, from *mail.Address, email Email, messageID string, date time.Time) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("From", from.String())
+	setAddressHeader(h, "To", email.To)
+	setAddressHeader(h, "Cc", email.CC)
+	setAddressHeader(h, "Reply-To", email.ReplyTo)
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	h.Set("Date", date.Format(time.RFC1123Z))
+	h.Set("Message-ID", "<"+messageID+">")
+	h.Set("MIME-Version", "1.0")
+	for name, value := range email.Headers {
+		h.Set(name, mime.QEncoding.Encode("utf-8", value))
+	}
+
+	bodyHeader, writeBody := bodyPart(email)
+	if len(email.Attachments) == 0 {
+		for k, v := range bodyHeader {
+			h[k] = v
+		}
+		if err := writeHeader(w, h); err != nil {
+			return err
+		}
+		return writeBody(w)
+	}
+
+	mw := multipart.NewWriter(w)
+	h.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+	pw, err := mw.CreatePart(bodyHeader)
+	if err != nil {
+		return err
+	}
+	if err := writeBody(pw); err != nil {
+		return err
+	}
+	var total int64
+	for _, a := range email.Attachments {
+		if err := writeAttachment(mw, a, &total); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// bodyPart returns the content headers of email's text and HTML bodies and
+// a function writing them: a single part, or multipart/alternative for both.
+func bodyPart(email Email) (textproto.MIMEHeader, func(io.Writer) error) {
+	if email.Text == "" || email.HTML == "" {
+		contentType, body := "text/plain; charset=utf-8", email.Text
+		if email.Text == "" {
+			contentType, body = "text/html; charset=utf-8", email.HTML
+		}
+		h := textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}
+		return h, func(w io.Writer) error { return writeQuotedPrintable(w, body) }
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	h := textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + boundary}}
+	return h, func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", email.Text},
+			{"text/html; charset=utf-8", email.HTML},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			if err := writeQuotedPrintable(pw, part.body); err != nil {
+				return err
+			}
+		}
+		return mw.Close()
+	}
+}
+
+func writeAttachment(mw *multipart.Writer, a Attachment, total *int64) error {
+	contentType, r, err := openAttachment(a)
+	if err != nil {
+		return err
+	}
+	disposition := "attachment"
+	h := make(textproto.MIMEHeader)
+	if a.ContentID != "" {
+		disposition = "inline"
+		h.Set("Content-ID", "<"+a.ContentID+">")
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	h.Set("Content-Transfer-Encoding", "base64")
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, &lineWrapper{w: pw, max: 76})
+	if err := copyAttachment(enc, r, a.Filename, total); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(pw, "\r\n")
+	return err
+}
+
+// writeHeader writes h in a stable order, then the blank line ending it.
+func writeHeader(w io.Writer, h textproto.MIMEHeader) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func setAddressHeader(h textproto.MIMEHeader, name string, addresses []string) {
+	if len(addresses) == 0 {
+		return
+	}
+	formatted := make([]string, len(addresses))
+	for i, addr := range addresses {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			formatted[i] = addr
+			continue
+		}
+		formatted[i] = a.String()
+	}
+	h.Set(name, strings.Join(formatted, ", "))
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// lineWrapper breaks base64 output into lines of at most max characters,
+// as RFC 2045 requires.
+type lineWrapper struct {
+	w   io.Writer
+	max int
+	n   int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.n == l.max {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.n = 0
+		}
+		chunk := p[:min(len(p), l.max-l.n)]
+		n, err := l.w.Write(chunk)
+		written += n
+		l.n += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
@@ -0,0 +1,234 @@
+package client_notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify/internal/smtptest"
+	"github.com/hstles/go-sdk/shared_templates"
+)
+
+var testSecurity = map[smtptest.Security]SMTPSecurity{
+	smtptest.Plaintext:   SMTPPlaintext,
+	smtptest.StartTLS:    SMTPStartTLS,
+	smtptest.ImplicitTLS: SMTPImplicitTLS,
+}
+
+func newSMTPServer(t *testing.T, cfg smtptest.Config) *smtptest.Server {
+	t.Helper()
+	srv, err := smtptest.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// newTestMailer returns a mailer for srv, applying edit to its config first.
+func newTestMailer(t *testing.T, srv *smtptest.Server, security smtptest.Security, edit func(*SMTPConfig)) *SMTPMailer {
+	t.Helper()
+	cfg := SMTPConfig{
+		Host:      srv.Host(),
+		Port:      srv.Port(),
+		Security:  testSecurity[security],
+		From:      "Test <no-reply@example.com>",
+		TLSConfig: srv.TLSConfig(),
+	}
+	if edit != nil {
+		edit(&cfg)
+	}
+	m, err := NewSMTPMailer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+var testEmail = Email{
+	To:      []string{"Ada <ada@example.com>"},
+	BCC:     []string{"audit@example.com"},
+	Subject: "Hello",
+	Text:    "Plain body",
+	HTML:    "<p>HTML body</p>",
+}
+
+func TestSMTPMailerSecurity(t *testing.T) {
+	for _, security := range []smtptest.Security{smtptest.Plaintext, smtptest.StartTLS, smtptest.ImplicitTLS} {
+		t.Run(string(testSecurity[security]), func(t *testing.T) {
+			srv := newSMTPServer(t, smtptest.Config{Security: security})
+			m := newTestMailer(t, srv, security, nil)
+
+			id, err := m.Send(context.Background(), testEmail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mails := srv.Mails()
+			if len(mails) != 1 {
+				t.Fatalf("received %d mails, want 1", len(mails))
+			}
+			got := mails[0]
+			if got.TLS != (security != smtptest.Plaintext) {
+				t.Errorf("TLS = %v", got.TLS)
+			}
+			if got.From != "no-reply@example.com" || strings.Join(got.To, ",") != "ada@example.com,audit@example.com" {
+				t.Errorf("envelope = %s -> %v", got.From, got.To)
+			}
+			msg, err := got.Message()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Header.Get("Subject") != "Hello" || msg.Header.Get("Message-Id") != "<"+id+">" || msg.Header.Get("Bcc") != "" {
+				t.Errorf("headers = %v, want the subject, Message-ID %s and no Bcc", msg.Header, id)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerRefusesUnverifiedTLS(t *testing.T) {
+	for _, security := range []smtptest.Security{smtptest.StartTLS, smtptest.ImplicitTLS} {
+		srv := newSMTPServer(t, smtptest.Config{Security: security})
+		m := newTestMailer(t, srv, security, func(cfg *SMTPConfig) { cfg.TLSConfig = nil })
+		if _, err := m.Send(context.Background(), testEmail); err == nil {
+			t.Errorf("%s: send to a self-signed certificate succeeded", testSecurity[security])
+		}
+	}
+
+	// A server that does not offer STARTTLS must not get the email in the clear.
+	srv := newSMTPServer(t, smtptest.Config{Security: smtptest.Plaintext})
+	m := newTestMailer(t, srv, smtptest.StartTLS, nil)
+	if _, err := m.Send(context.Background(), testEmail); err == nil || len(srv.Mails()) != 0 {
+		t.Errorf("send without STARTTLS = %v, %d mails; want an error and none", err, len(srv.Mails()))
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	users := map[string]string{"mailer": "s3cret"}
+	tests := []struct {
+		name       string
+		offered    []string
+		auth       string
+		password   string
+		wantAuth   string
+		wantFailed bool
+	}{
+		{"plain", nil, "PLAIN", "s3cret", "PLAIN", false},
+		{"login", nil, "LOGIN", "s3cret", "LOGIN", false},
+		{"prefers plain", nil, "", "s3cret", "PLAIN", false},
+		{"login when plain is not offered", []string{"LOGIN"}, "", "s3cret", "LOGIN", false},
+		{"wrong password", nil, "", "wrong", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPServer(t, smtptest.Config{Security: smtptest.StartTLS, Users: users, Mechanisms: tt.offered})
+			m := newTestMailer(t, srv, smtptest.StartTLS, func(cfg *SMTPConfig) {
+				cfg.Username, cfg.Password, cfg.Auth = "mailer", tt.password, tt.auth
+			})
+			_, err := m.Send(context.Background(), testEmail)
+			if tt.wantFailed {
+				if err == nil || len(srv.Mails()) != 0 {
+					t.Fatalf("Send = %v with %d mails, want an authentication error", err, len(srv.Mails()))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.Mails()[0].Auth; got != tt.wantAuth {
+				t.Errorf("authenticated with %q, want %q", got, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerReusesConnection(t *testing.T) {
+	srv := newSMTPServer(t, smtptest.Config{Security: smtptest.StartTLS})
+	m := newTestMailer(t, srv, smtptest.StartTLS, func(cfg *SMTPConfig) { cfg.IdleTimeout = 200 * time.Millisecond })
+	ctx := context.Background()
+	send := func() {
+		t.Helper()
+		if _, err := m.Send(ctx, testEmail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		send()
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("3 sends used %d connections, want 1", n)
+	}
+
+	// A connection the server dropped is replaced.
+	srv.DropConnections()
+	send()
+	if n := srv.Connections(); n != 2 {
+		t.Fatalf("send after the server dropped the connection used %d connections, want 2", n)
+	}
+
+	// So is one that sat idle past IdleTimeout.
+	time.Sleep(400 * time.Millisecond)
+	send()
+	if n := srv.Connections(); n != 3 {
+		t.Fatalf("send after IdleTimeout used %d connections, want 3", n)
+	}
+	if n := len(srv.Mails()); n != 5 {
+		t.Errorf("received %d mails, want 5", n)
+	}
+}
+
+// unavailableNotify answers every request with status and counts them.
+func unavailableNotify(t *testing.T, status int) (*EmailClient, *int) {
+	t.Helper()
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(EmailResponse{Error: http.StatusText(status)})
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL), &requests
+}
+
+func TestEmailClientFallsBackToSMTP(t *testing.T) {
+	ctx := context.Background()
+	smtpSrv := newSMTPServer(t, smtptest.Config{Security: smtptest.StartTLS})
+	driver := NewSMTPDriver(newTestMailer(t, smtpSrv, smtptest.StartTLS, nil), shared_templates.Default(shared_templates.Brand{Name: "Hstles"}))
+
+	c, requests := unavailableNotify(t, http.StatusServiceUnavailable)
+	c.Fallback = driver
+	resp, err := c.SendWelcomeEmail(ctx, "ada@example.com", "Ada")
+	if err != nil || !resp.Success || resp.MessageID == "" {
+		t.Fatalf("SendWelcomeEmail = %+v, %v; want it sent over SMTP", resp, err)
+	}
+	if _, err := c.SendEmail(ctx, Email{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Body"}); err != nil {
+		t.Fatalf("SendEmail = %v, want it sent over SMTP", err)
+	}
+	mails := smtpSrv.Mails()
+	if *requests != 2 || len(mails) != 2 || mails[0].To[0] != "ada@example.com" {
+		t.Fatalf("%d notify requests, SMTP mails %+v; want both emails sent over SMTP", *requests, mails)
+	}
+
+	// Attachments were consumed by the failed upload, so they are not retried.
+	attached := Email{To: []string{"ada@example.com"}, Subject: "Invoice", Text: "Attached",
+		Attachments: []Attachment{{Filename: "invoice.pdf", Content: strings.NewReader("%PDF-1.4")}}}
+	if _, err := c.SendEmail(ctx, attached); !errors.Is(err, ErrServiceUnavailable) {
+		t.Errorf("SendEmail with an attachment = %v, want ErrServiceUnavailable", err)
+	}
+
+	// Client errors are not outages and are not retried either.
+	c, _ = unavailableNotify(t, http.StatusBadRequest)
+	c.Fallback = driver
+	if _, err := c.SendWelcomeEmail(ctx, "ada@example.com", "Ada"); err == nil || errors.Is(err, ErrServiceUnavailable) {
+		t.Errorf("SendWelcomeEmail on 400 = %v, want a plain error", err)
+	}
+	if n := len(smtpSrv.Mails()); n != 2 {
+		t.Errorf("SMTP received %d mails, want still 2", n)
+	}
+}
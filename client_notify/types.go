@@ -5,6 +5,8 @@ type EmailResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Error   string `json:"error,omitempty"`
+	// MessageID identifies the sent email; pass it to GetDeliveryStatus.
+	MessageID string `json:"messageId,omitempty"`
 }
 
 // WelcomeEmailRequest for POST /api/email/welcome
@@ -45,4 +47,7 @@ type GenericEmailRequest struct {
 	To      string `json:"to"`
 	Subject string `json:"subject"`
 	Message string `json:"message"`
+	// Headers are added to the email, e.g. List-Unsubscribe, by notify
+	// service versions that accept custom headers; older ones ignore them.
+	Headers map[string]string `json:"headers,omitempty"`
 }
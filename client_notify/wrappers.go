@@ -53,3 +53,24 @@ func SendGenericEmail(ctx context.Context, to, subject, message string) (*EmailR
 	}
 	return Default.SendGenericEmail(ctx, to, subject, message)
 }
+
+func SendGenericEmailWithHeaders(ctx context.Context, to, subject, message string, headers map[string]string) (*EmailResponse, error) {
+	if err := ensure(); err != nil {
+		return nil, err
+	}
+	return Default.SendGenericEmailWithHeaders(ctx, to, subject, message, headers)
+}
+
+func SendEmail(ctx context.Context, email Email) (*EmailResponse, error) {
+	if err := ensure(); err != nil {
+		return nil, err
+	}
+	return Default.SendEmail(ctx, email)
+}
+
+func GetDeliveryStatus(ctx context.Context, messageID string) (*DeliveryStatus, error) {
+	if err := ensure(); err != nil {
+		return nil, err
+	}
+	return Default.GetDeliveryStatus(ctx, messageID)
+}
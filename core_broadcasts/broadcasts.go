@@ -0,0 +1,379 @@
+// Package core_broadcasts sends one notification to many recipients, now or
+// at a scheduled time. Scheduled broadcasts are stored in CoreDB, can be
+// cancelled until they start, and keep a report of who could not be reached.
+package core_broadcasts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// Broadcast statuses. Only scheduled broadcasts can be cancelled.
+const (
+	StatusScheduled = "scheduled"
+	StatusSending   = "sending"
+	StatusSent      = "sent"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+const defaultPageSize = 50
+
+var (
+	ErrBroadcastNotFound = errors.New("broadcast not found")
+	ErrNotCancellable    = errors.New("only scheduled broadcasts can be cancelled")
+	ErrEmptyAudience     = errors.New("broadcast has no recipients or organisation")
+	ErrNoMemberSource    = errors.New("broadcast selects an organisation but the service has no MemberSource")
+)
+
+// Audience selects who a broadcast goes to: the listed recipients, the
+// members of an organisation, or both. Recipients appearing twice, by user
+// ID or email, are sent to once.
+type Audience struct {
+	Recipients []client_notify.Recipient `json:"recipients,omitempty"`
+	// OrganisationID selects the organisation's members with MemberStatus,
+	// active by default, and one of Roles when set.
+	OrganisationID string   `json:"organisation_id,omitempty"`
+	MemberStatus   string   `json:"member_status,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+}
+
+// IsZero reports whether the audience selects nobody.
+func (a Audience) IsZero() bool {
+	return len(a.Recipients) == 0 && a.OrganisationID == ""
+}
+
+// Broadcast is a stored bulk send.
+type Broadcast struct {
+	ID         string                    `json:"id"`
+	Status     string                    `json:"status"`
+	Audience   Audience                  `json:"audience"`
+	Message    client_notify.Message     `json:"message"`
+	SendAt     time.Time                 `json:"send_at"`
+	Report     *client_notify.BulkReport `json:"report,omitempty"`
+	CreatedBy  string                    `json:"created_by,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+	StartedAt  *time.Time                `json:"started_at,omitempty"`
+	FinishedAt *time.Time                `json:"finished_at,omitempty"`
+
+	// lease is the lease_until value a Scheduler set when it claimed the
+	// broadcast; renew and finish match on it to tell whether it still holds it.
+	lease string
+}
+
+// ScheduleRequest is the body of POST /api/admin/broadcasts.
+type ScheduleRequest struct {
+	Audience Audience              `json:"audience"`
+	Message  client_notify.Message `json:"message"`
+	// SendAt is when to send; zero or past times send as soon as possible.
+	SendAt time.Time `json:"send_at,omitempty"`
+}
+
+// MemberSource lists the members of an organisation matching opts.
+type MemberSource func(ctx context.Context, orgID string, opts client_identity.ListOptions) iter.Seq2[client_identity.Member, error]
+
+// IdentityMembers lists members through the identity service with cookies,
+// which must stay valid until scheduled broadcasts run; use a service
+// session rather than a user's.
+func IdentityMembers(c *client_identity.Client, cookies []*http.Cookie) MemberSource {
+	return func(ctx context.Context, orgID string, opts client_identity.ListOptions) iter.Seq2[client_identity.Member, error] {
+		return c.AllMembers(ctx, cookies, orgID, opts)
+	}
+}
+
+// Service schedules and administers broadcasts.
+type Service struct {
+	db *sql.DB
+
+	// Members resolves organisation audiences. It is required only for
+	// broadcasts that set Audience.OrganisationID.
+	Members MemberSource
+}
+
+func NewService(db *sql.DB, members MemberSource) *Service {
+	return &Service{db: db, Members: members}
+}
+
+// Schedule stores a broadcast for a Scheduler to send at req.SendAt.
+// createdBy, which may be empty, records who scheduled it.
+func (s *Service) Schedule(ctx context.Context, req ScheduleRequest, createdBy string) (Broadcast, error) {
+	if err := s.validate(req.Audience, req.Message); err != nil {
+		return Broadcast{}, err
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return Broadcast{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	now := time.Now().UTC()
+	b := Broadcast{
+		ID:        id,
+		Status:    StatusScheduled,
+		Audience:  req.Audience,
+		Message:   req.Message,
+		SendAt:    req.SendAt.UTC(),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if b.SendAt.IsZero() || b.SendAt.Before(now) {
+		b.SendAt = now
+	}
+	// Every copy carries the broadcast ID, so a broadcast resumed after a
+	// crash does not add duplicates to in-app inboxes.
+	if b.Message.ID == "" {
+		b.Message.ID = id
+	}
+	if b.Message.CreatedAt.IsZero() {
+		b.Message.CreatedAt = b.SendAt
+	}
+
+	audience, err := json.Marshal(b.Audience)
+	if err != nil {
+		return Broadcast{}, fmt.Errorf("marshal audience: %w", err)
+	}
+	message, err := json.Marshal(b.Message)
+	if err != nil {
+		return Broadcast{}, fmt.Errorf("marshal message: %w", err)
+	}
+	ts := shared_helpers.FormatDBTime(now)
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO broadcasts (id, status, audience, message, send_at, created_by, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.Status, string(audience), string(message), shared_helpers.FormatDBTime(b.SendAt),
+		nullString(createdBy), ts, ts,
+	); err != nil {
+		return Broadcast{}, fmt.Errorf("insert broadcast: %w", err)
+	}
+	return b, nil
+}
+
+func (s *Service) validate(a Audience, msg client_notify.Message) error {
+	if a.IsZero() {
+		return ErrEmptyAudience
+	}
+	if a.OrganisationID != "" && s.Members == nil {
+		return ErrNoMemberSource
+	}
+	if msg.Subject == "" && msg.Body == "" && msg.HTML == "" {
+		return client_notify.ErrInvalidMessage
+	}
+	return nil
+}
+
+// Get returns a broadcast by ID.
+func (s *Service) Get(ctx context.Context, id string) (Broadcast, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+broadcastColumns+`
+           FROM broadcasts
+          WHERE id = ?`,
+		id,
+	)
+	b, err := scanBroadcast(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Broadcast{}, ErrBroadcastNotFound
+	}
+	return b, err
+}
+
+// List returns a page of broadcasts, newest first, optionally filtered by opts.Status.
+func (s *Service) List(ctx context.Context, opts client_identity.ListOptions) (client_identity.Page[Broadcast], error) {
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, client_identity.MaxPageSize)
+
+	query := `SELECT ` + broadcastColumns + `
+                FROM broadcasts
+               WHERE 1 = 1`
+	var args []any
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.Cursor != "" {
+		query += ` AND (created_at, id) < (SELECT created_at, id FROM broadcasts WHERE id = ?)`
+		args = append(args, opts.Cursor)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, size+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return client_identity.Page[Broadcast]{}, fmt.Errorf("query broadcasts: %w", err)
+	}
+	defer rows.Close()
+
+	page := client_identity.Page[Broadcast]{Items: []Broadcast{}}
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return client_identity.Page[Broadcast]{}, err
+		}
+		page.Items = append(page.Items, b)
+	}
+	if err := rows.Err(); err != nil {
+		return client_identity.Page[Broadcast]{}, err
+	}
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextCursor = page.Items[size-1].ID
+	}
+	return page, nil
+}
+
+// Cancel stops a scheduled broadcast from being sent. Broadcasts that have
+// started sending cannot be cancelled.
+func (s *Service) Cancel(ctx context.Context, id string) (Broadcast, error) {
+	now := shared_helpers.FormatDBTime(time.Now())
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE broadcasts
+            SET status = ?, updated_at = ?, finished_at = ?
+          WHERE id = ? AND status = ?`,
+		StatusCancelled, now, now, id, StatusScheduled,
+	)
+	if err != nil {
+		return Broadcast{}, fmt.Errorf("cancel broadcast: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return Broadcast{}, err
+		}
+		return Broadcast{}, ErrNotCancellable
+	}
+	return s.Get(ctx, id)
+}
+
+// Recipients yields everyone a selects, each once.
+func (s *Service) Recipients(ctx context.Context, a Audience) iter.Seq2[client_notify.Recipient, error] {
+	return func(yield func(client_notify.Recipient, error) bool) {
+		seen := make(map[string]bool)
+		first := func(to client_notify.Recipient) bool {
+			key := recipientKey(to)
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+			return true
+		}
+
+		for _, to := range a.Recipients {
+			if first(to) && !yield(to, nil) {
+				return
+			}
+		}
+		if a.OrganisationID == "" {
+			return
+		}
+		if s.Members == nil {
+			yield(client_notify.Recipient{}, ErrNoMemberSource)
+			return
+		}
+		status := a.MemberStatus
+		if status == "" {
+			status = "active"
+		}
+		opts := client_identity.ListOptions{Status: status, PageSize: client_identity.MaxPageSize}
+		for m, err := range s.Members(ctx, a.OrganisationID, opts) {
+			if err != nil {
+				yield(client_notify.Recipient{}, fmt.Errorf("list members of %s: %w", a.OrganisationID, err))
+				return
+			}
+			// The status filter is applied here too, for services that ignore it.
+			if m.Status != status || len(a.Roles) > 0 && !slices.Contains(a.Roles, m.Role) {
+				continue
+			}
+			to := memberRecipient(m)
+			if first(to) && !yield(to, nil) {
+				return
+			}
+		}
+	}
+}
+
+// recipientKey identifies to by user ID, or by normalised email for
+// recipients without one.
+func recipientKey(to client_notify.Recipient) string {
+	if to.UserID != "" {
+		return to.UserID
+	}
+	email, err := shared_email.Normalise(to.Email)
+	if err != nil {
+		email = strings.ToLower(to.Email)
+	}
+	return "email:" + email
+}
+
+// memberRecipient addresses a member by the user details the identity
+// service embeds; members without them can only be reached in-app.
+func memberRecipient(m client_identity.Member) client_notify.Recipient {
+	to := client_notify.Recipient{UserID: m.UserID}
+	if m.User != nil {
+		to.Email = m.User.Email
+		to.Name = strings.TrimSpace(m.User.FirstName + " " + m.User.LastName)
+	}
+	return to
+}
+
+const broadcastColumns = `id, status, audience, message, send_at, report, created_by, created_at, updated_at, started_at, finished_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBroadcast(row scanner) (Broadcast, error) {
+	var (
+		b                            Broadcast
+		audience, message            string
+		report, createdBy            sql.NullString
+		sendAt, createdAt, updatedAt string
+		startedAt, finishedAt        sql.NullString
+	)
+	if err := row.Scan(&b.ID, &b.Status, &audience, &message, &sendAt, &report, &createdBy,
+		&createdAt, &updatedAt, &startedAt, &finishedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Broadcast{}, err
+		}
+		return Broadcast{}, fmt.Errorf("scan broadcast: %w", err)
+	}
+	if err := json.Unmarshal([]byte(audience), &b.Audience); err != nil {
+		return Broadcast{}, fmt.Errorf("decode broadcast %s audience: %w", b.ID, err)
+	}
+	if err := json.Unmarshal([]byte(message), &b.Message); err != nil {
+		return Broadcast{}, fmt.Errorf("decode broadcast %s message: %w", b.ID, err)
+	}
+	if report.Valid {
+		b.Report = &client_notify.BulkReport{}
+		if err := json.Unmarshal([]byte(report.String), b.Report); err != nil {
+			return Broadcast{}, fmt.Errorf("decode broadcast %s report: %w", b.ID, err)
+		}
+	}
+	b.CreatedBy = createdBy.String
+	b.SendAt = shared_helpers.ParseDBTime(sendAt)
+	b.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	b.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	b.StartedAt = shared_helpers.ParseNullDBTime(startedAt)
+	b.FinishedAt = shared_helpers.ParseNullDBTime(finishedAt)
+	return b, nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
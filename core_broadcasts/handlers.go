@@ -0,0 +1,95 @@
+package core_broadcasts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// These are admin endpoints; mount them behind an administrator check.
+
+// ScheduleHandler serves POST /api/admin/broadcasts and wakes scheduler,
+// which may be nil, when the broadcast is due now.
+func ScheduleHandler(s *Service, scheduler *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, client_identity.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+		userID, _ := shared_utilities.RequireSessionUser(r)
+		b, err := s.Schedule(r.Context(), req, userID)
+		if err != nil {
+			writeBroadcastError(w, err)
+			return
+		}
+		if scheduler != nil && !b.SendAt.After(b.CreatedAt) {
+			scheduler.Kick()
+		}
+		writeJSON(w, http.StatusCreated, b)
+	}
+}
+
+// ListHandler serves GET /api/admin/broadcasts. It accepts the page_size,
+// cursor and status query parameters.
+func ListHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.List(r.Context(), client_identity.ListOptionsFromQuery(r.URL.Query()))
+		if err != nil {
+			writeBroadcastError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetHandler serves GET /api/admin/broadcasts/{broadcast_id}, including the
+// delivery report once the broadcast has been sent.
+func GetHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Get(r.Context(), mux.Vars(r)["broadcast_id"])
+		if err != nil {
+			writeBroadcastError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// CancelHandler serves POST /api/admin/broadcasts/{broadcast_id}/cancel.
+func CancelHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Cancel(r.Context(), mux.Vars(r)["broadcast_id"])
+		if err != nil {
+			writeBroadcastError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeBroadcastError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrBroadcastNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrNotCancellable):
+		status = http.StatusConflict
+	case errors.Is(err, ErrEmptyAudience), errors.Is(err, client_notify.ErrInvalidMessage):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrNoMemberSource):
+		status = http.StatusNotImplemented
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
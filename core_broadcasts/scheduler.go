@@ -0,0 +1,319 @@
+package core_broadcasts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// errLeaseLost means another scheduler claimed the broadcast after this
+// one's lease expired.
+var errLeaseLost = errors.New("lease expired and the broadcast was claimed again")
+
+const (
+	defaultPollInterval = 30 * time.Second
+	defaultBatchSize    = 5
+	defaultConcurrency  = 8
+	defaultLease        = 5 * time.Minute
+)
+
+// Scheduler sends due broadcasts through a Notifier. Per-provider rate
+// limits belong on the Notifier's drivers; see client_notify.RateLimit.
+//
+// Each recipient reached is recorded in broadcast_deliveries, so a broadcast
+// whose scheduler dies mid-send is resumed once its lease expires, skipping
+// recipients already delivered to. Only those sent to just before the crash
+// can get a second copy; copies share the broadcast's message ID so in-app
+// inboxes ignore the repeat.
+type Scheduler struct {
+	svc      *Service
+	notifier client_notify.Notifier
+
+	// PollInterval is how often Start looks for due broadcasts.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of broadcasts claimed per poll.
+	BatchSize int
+	// Concurrency is the number of recipients sent to in parallel per broadcast.
+	Concurrency int
+	// Lease is how long a claimed broadcast is hidden from other schedulers.
+	// It is renewed while sending, so it only needs to outlive a crash.
+	Lease time.Duration
+
+	kick chan struct{}
+}
+
+// NewScheduler creates a Scheduler for the broadcasts stored in svc.
+func NewScheduler(svc *Service, n client_notify.Notifier) *Scheduler {
+	return &Scheduler{
+		svc:          svc,
+		notifier:     n,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Concurrency:  defaultConcurrency,
+		Lease:        defaultLease,
+		kick:         make(chan struct{}, 1),
+	}
+}
+
+// Send sends msg to audience now without storing a broadcast and reports
+// who could not be reached.
+func (sc *Scheduler) Send(ctx context.Context, audience Audience, msg client_notify.Message) (client_notify.BulkReport, error) {
+	if err := sc.svc.validate(audience, msg); err != nil {
+		return client_notify.BulkReport{}, err
+	}
+	return client_notify.SendBulkSeq(ctx, sc.notifier, sc.svc.Recipients(ctx, audience), msg, sc.Concurrency), nil
+}
+
+// Kick wakes Start without waiting for the next poll, e.g. after scheduling
+// a broadcast for now.
+func (sc *Scheduler) Kick() {
+	select {
+	case sc.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start polls for due broadcasts every PollInterval until ctx is cancelled.
+func (sc *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(sc.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := sc.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("core_broadcasts: scheduler run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-sc.kick:
+		}
+	}
+}
+
+// RunOnce claims up to BatchSize due broadcasts and sends them one after
+// another. It returns the number of broadcasts sent.
+func (sc *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	due, err := sc.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range due {
+		if err := sc.run(ctx, b); err != nil {
+			log.Printf("core_broadcasts: broadcast %s: %v", b.ID, err)
+		}
+	}
+	return len(due), nil
+}
+
+// claim selects due broadcasts, and ones whose sender's lease expired, and
+// leases each with a conditional UPDATE so only one scheduler wins it.
+func (sc *Scheduler) claim(ctx context.Context) ([]Broadcast, error) {
+	now := time.Now().UTC()
+	ts := shared_helpers.FormatDBTime(now)
+	rows, err := sc.svc.db.QueryContext(ctx,
+		`SELECT `+broadcastColumns+`
+           FROM broadcasts
+          WHERE (status = ? AND send_at <= ?) OR (status = ? AND lease_until <= ?)
+          ORDER BY send_at
+          LIMIT ?`,
+		StatusScheduled, ts, StatusSending, ts, max(sc.BatchSize, 1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query due broadcasts: %w", err)
+	}
+	var candidates []Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query due broadcasts: %w", err)
+	}
+
+	lease := shared_helpers.FormatDBTime(now.Add(sc.Lease))
+	var claimed []Broadcast
+	for _, b := range candidates {
+		res, err := sc.svc.db.ExecContext(ctx,
+			`UPDATE broadcasts
+                SET status = ?, lease_until = ?, started_at = COALESCE(started_at, ?), updated_at = ?
+              WHERE id = ? AND status = ? AND updated_at = ?`,
+			StatusSending, lease, ts, ts, b.ID, b.Status, shared_helpers.FormatDBTime(b.UpdatedAt),
+		)
+		if err != nil {
+			return claimed, fmt.Errorf("lease broadcast %s: %w", b.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			b.lease = lease
+			claimed = append(claimed, b)
+		}
+	}
+	return claimed, nil
+}
+
+// run sends b to the recipients it has not reached yet while renewing its
+// lease, and stores the report.
+func (sc *Scheduler) run(ctx context.Context, b Broadcast) error {
+	delivered, err := sc.delivered(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+
+	sendCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	renewed := make(chan error, 1)
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(max(sc.Lease/3, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sendCtx.Done():
+				return
+			case <-ticker.C:
+				err := sc.renew(sendCtx, &b)
+				if errors.Is(err, errLeaseLost) {
+					// Another scheduler is sending now; stop rather than race it.
+					renewed <- err
+					stop()
+					return
+				}
+				if err != nil && sendCtx.Err() == nil {
+					log.Printf("core_broadcasts: renew lease of %s: %v", b.ID, err)
+				}
+			}
+		}
+	}()
+
+	remaining := sc.svc.Recipients(sendCtx, b.Audience)
+	report := client_notify.SendBulkSeq(sendCtx, progressNotifier{sc.notifier, sc.svc, b.ID},
+		skipDelivered(remaining, delivered), b.Message, sc.Concurrency)
+	stop()
+	if err := <-renewed; err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the lease to expire so another scheduler resumes it.
+		return ctx.Err()
+	}
+
+	// Recipients reached by an earlier attempt count towards this report.
+	report.Total += len(delivered)
+	report.Delivered += len(delivered)
+	status := StatusSent
+	if report.Delivered == 0 && (report.Total > 0 || report.Error != "") {
+		status = StatusFailed
+	}
+	return sc.finish(ctx, b, status, report)
+}
+
+// delivered returns the keys of the recipients an earlier attempt at the
+// broadcast reached.
+func (sc *Scheduler) delivered(ctx context.Context, id string) (map[string]bool, error) {
+	rows, err := sc.svc.db.QueryContext(ctx,
+		`SELECT recipient FROM broadcast_deliveries WHERE broadcast_id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query delivered recipients: %w", err)
+	}
+	defer rows.Close()
+	delivered := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("scan delivered recipient: %w", err)
+		}
+		delivered[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query delivered recipients: %w", err)
+	}
+	return delivered, nil
+}
+
+// skipDelivered yields the recipients whose keys are not in delivered.
+func skipDelivered(recipients iter.Seq2[client_notify.Recipient, error], delivered map[string]bool) iter.Seq2[client_notify.Recipient, error] {
+	return func(yield func(client_notify.Recipient, error) bool) {
+		for to, err := range recipients {
+			if err == nil && delivered[recipientKey(to)] {
+				continue
+			}
+			if !yield(to, err) {
+				return
+			}
+		}
+	}
+}
+
+// progressNotifier records each recipient a broadcast reaches, so a resumed
+// send can skip them.
+type progressNotifier struct {
+	client_notify.Notifier
+	svc         *Service
+	broadcastID string
+}
+
+func (p progressNotifier) Notify(ctx context.Context, to client_notify.Recipient, msg client_notify.Message) (client_notify.Result, error) {
+	res, err := p.Notifier.Notify(ctx, to, msg)
+	if err == nil || res.Delivered() {
+		// Record the delivery even when ctx was cancelled after the send.
+		if _, dbErr := p.svc.db.ExecContext(context.WithoutCancel(ctx),
+			`INSERT OR IGNORE INTO broadcast_deliveries (broadcast_id, recipient, delivered_at)
+             VALUES (?, ?, ?)`,
+			p.broadcastID, recipientKey(to), shared_helpers.FormatDBTime(time.Now()),
+		); dbErr != nil {
+			log.Printf("core_broadcasts: record delivery of %s: %v", p.broadcastID, dbErr)
+		}
+	}
+	return res, err
+}
+
+// renew extends b's lease if this scheduler still holds it.
+func (sc *Scheduler) renew(ctx context.Context, b *Broadcast) error {
+	lease := shared_helpers.FormatDBTime(time.Now().Add(sc.Lease))
+	res, err := sc.svc.db.ExecContext(ctx,
+		`UPDATE broadcasts SET lease_until = ? WHERE id = ? AND status = ? AND lease_until = ?`,
+		lease, b.ID, StatusSending, b.lease,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errLeaseLost
+	}
+	b.lease = lease
+	return nil
+}
+
+// finish stores b's outcome. Nothing is written when the lease expired and
+// another scheduler claimed the broadcast; that scheduler records its own.
+func (sc *Scheduler) finish(ctx context.Context, b Broadcast, status string, report client_notify.BulkReport) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	now := shared_helpers.FormatDBTime(time.Now())
+	res, err := sc.svc.db.ExecContext(ctx,
+		`UPDATE broadcasts
+            SET status = ?, report = ?, lease_until = NULL, updated_at = ?, finished_at = ?
+          WHERE id = ? AND lease_until = ?`,
+		status, string(raw), now, now, b.ID, b.lease,
+	)
+	if err != nil {
+		return fmt.Errorf("update broadcast: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		log.Printf("core_broadcasts: broadcast %s: lease expired before its %s report was recorded", b.ID, status)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package core_broadcasts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+func TestSkipDelivered(t *testing.T) {
+	recipients := []client_notify.Recipient{
+		{UserID: "usr_1"},
+		{Email: "Ada@Example.com"},
+		{UserID: "usr_2"},
+	}
+	seq := func(yield func(client_notify.Recipient, error) bool) {
+		for _, to := range recipients {
+			if !yield(to, nil) {
+				return
+			}
+		}
+	}
+	delivered := map[string]bool{"usr_1": true, recipientKey(client_notify.Recipient{Email: "ada@example.com"}): true}
+
+	var got []client_notify.Recipient
+	for to, err := range skipDelivered(seq, delivered) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, to)
+	}
+	if len(got) != 1 || got[0].UserID != "usr_2" {
+		t.Errorf("skipDelivered = %+v, want only usr_2", got)
+	}
+}
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// claimOne schedules a broadcast to recipients and claims it.
+func claimOne(t *testing.T, sc *Scheduler, recipients ...client_notify.Recipient) Broadcast {
+	t.Helper()
+	ctx := context.Background()
+	b, err := sc.svc.Schedule(ctx, ScheduleRequest{
+		Audience: Audience{Recipients: recipients},
+		Message:  client_notify.Message{Subject: "Maintenance", Body: "Tonight at ten."},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.BatchSize = 100
+	claimed, err := sc.claim(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range claimed {
+		if c.ID == b.ID {
+			return c
+		}
+	}
+	t.Fatal("the scheduled broadcast was not claimed")
+	return Broadcast{}
+}
+
+func TestResumeSkipsDeliveredRecipients(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(testDB(t), nil)
+	notifier := &client_notify.FakeNotifier{}
+	sc := NewScheduler(svc, notifier)
+	b := claimOne(t, sc, client_notify.Recipient{UserID: "usr_1"}, client_notify.Recipient{UserID: "usr_2"})
+
+	// An earlier attempt reached usr_1 before its scheduler died.
+	if _, err := svc.db.ExecContext(ctx,
+		`INSERT INTO broadcast_deliveries (broadcast_id, recipient, delivered_at) VALUES (?, ?, ?)`,
+		b.ID, "usr_1", shared_helpers.FormatDBTime(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.run(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if sent := notifier.Sent(); len(sent) != 1 || sent[0].To.UserID != "usr_2" {
+		t.Errorf("sent = %+v, want only usr_2", sent)
+	}
+	got, err := svc.Get(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusSent || got.Report == nil || got.Report.Total != 2 || got.Report.Delivered != 2 {
+		t.Errorf("broadcast = %+v, report %+v; want sent to both", got, got.Report)
+	}
+}
+
+func TestFinishRequiresLease(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(testDB(t), nil)
+	sc := NewScheduler(svc, &client_notify.FakeNotifier{})
+	b := claimOne(t, sc, client_notify.Recipient{UserID: "usr_1"})
+
+	// The lease expires and another scheduler claims the broadcast.
+	if _, err := svc.db.ExecContext(ctx, `UPDATE broadcasts SET lease_until = ? WHERE id = ?`,
+		shared_helpers.FormatDBTime(time.Now().Add(time.Hour)), b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.renew(ctx, &b); !errors.Is(err, errLeaseLost) {
+		t.Errorf("renew = %v, want errLeaseLost", err)
+	}
+	if err := sc.finish(ctx, b, StatusSent, client_notify.BulkReport{Total: 1, Delivered: 1}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Get(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusSending || got.Report != nil {
+		t.Errorf("broadcast = %+v, want it left to the scheduler holding the lease", got)
+	}
+}
@@ -0,0 +1,46 @@
+package core_broadcasts
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the broadcasts table, and the broadcast_deliveries
+// table that records who a broadcast reached, in CoreDB if they do not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"broadcasts", `CREATE TABLE IF NOT EXISTS broadcasts (
+             id          TEXT PRIMARY KEY,
+             status      TEXT NOT NULL,
+             audience    TEXT NOT NULL,
+             message     TEXT NOT NULL,
+             send_at     TIMESTAMP NOT NULL,
+             lease_until TIMESTAMP,
+             report      TEXT,
+             created_by  TEXT,
+             created_at  TIMESTAMP NOT NULL,
+             updated_at  TIMESTAMP NOT NULL,
+             started_at  TIMESTAMP,
+             finished_at TIMESTAMP
+         )`},
+		{"broadcasts due index", `CREATE INDEX IF NOT EXISTS idx_broadcasts_due
+             ON broadcasts (status, send_at)`},
+		{"broadcasts created index", `CREATE INDEX IF NOT EXISTS idx_broadcasts_created
+             ON broadcasts (created_at, id)`},
+		{"broadcast_deliveries", `CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+             broadcast_id TEXT NOT NULL,
+             recipient    TEXT NOT NULL,
+             delivered_at TIMESTAMP NOT NULL,
+             PRIMARY KEY (broadcast_id, recipient)
+         )`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// Package core_deliveries keeps a history of notification deliveries in
+// CoreDB, updates it from the notify service's delivery webhooks and stops
+// emails to addresses that hard-bounced or complained.
+package core_deliveries
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_webhooks"
+)
+
+const defaultPageSize = 20
+
+// Suppression reasons.
+const (
+	// SuppressionHardBounce stops every email to the address.
+	SuppressionHardBounce = "hard_bounce"
+	// SuppressionComplaint stops every email to the address except
+	// security notifications.
+	SuppressionComplaint = "complaint"
+)
+
+var (
+	ErrDeliveryNotFound    = errors.New("delivery not found")
+	ErrSuppressionNotFound = errors.New("address is not suppressed")
+)
+
+// Delivery is one notification sent over one channel.
+type Delivery struct {
+	ID string `json:"id"`
+	// MessageID is the notification's ID, shared by its deliveries over
+	// other channels.
+	MessageID string `json:"message_id"`
+	// ProviderID is the ID the notify service returned for the send.
+	ProviderID string                      `json:"provider_id,omitempty"`
+	UserID     string                      `json:"user_id,omitempty"`
+	Channel    client_notify.Channel       `json:"channel"`
+	Address    string                      `json:"address,omitempty"`
+	Category   client_notify.Category      `json:"category,omitempty"`
+	Subject    string                      `json:"subject,omitempty"`
+	Status     client_notify.DeliveryState `json:"status"`
+	Reason     string                      `json:"reason,omitempty"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+}
+
+// Service stores deliveries and suppressed addresses.
+//
+// Set it as client_notify.Router.Tracker and client_notify.EmailClient.Tracker
+// to record deliveries, as client_notify.EmailDriver.Suppressed to skip
+// suppressed addresses, and register HandleEvents on the webhook receiver for
+// the notify service.
+type Service struct {
+	db *sql.DB
+}
+
+// NewService creates a delivery tracking service.
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Track implements client_notify.Tracker. Emails sent directly through an
+// EmailClient are recorded without a user ID, so they are matched to bounces
+// and complaints by address but do not appear in History.
+func (s *Service) Track(ctx context.Context, to client_notify.Recipient, msg client_notify.Message, d client_notify.Delivery) error {
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	status, reason := client_notify.DeliverySent, ""
+	switch {
+	case errors.Is(d.Err, client_notify.ErrSuppressed):
+		status, reason = client_notify.DeliverySuppressed, d.Err.Error()
+	case d.Err != nil:
+		status, reason = client_notify.DeliveryFailed, d.Err.Error()
+	}
+	var address string
+	switch d.Channel {
+	case client_notify.ChannelEmail:
+		address = normaliseAddress(to.Email)
+	case client_notify.ChannelSMS:
+		address = to.Phone
+	}
+	now := shared_helpers.FormatDBTime(time.Now())
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO notification_deliveries
+             (id, message_id, provider_id, user_id, channel, address, category, subject, status, reason, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, msg.ID, nullString(d.ProviderID), nullString(to.UserID), string(d.Channel), address,
+		string(msg.Category), msg.Subject, string(status), reason, now, now,
+	); err != nil {
+		return fmt.Errorf("insert delivery: %w", err)
+	}
+	return nil
+}
+
+// Get returns a delivery by ID.
+func (s *Service) Get(ctx context.Context, id string) (Delivery, error) {
+	d, err := scanDelivery(s.db.QueryRowContext(ctx,
+		`SELECT `+deliveryColumns+` FROM notification_deliveries WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, err
+}
+
+// History lists userID's deliveries, newest first. opts.Status filters by
+// delivery status.
+func (s *Service) History(ctx context.Context, userID string, opts client_identity.ListOptions) (client_identity.Page[Delivery], error) {
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, client_identity.MaxPageSize)
+
+	query := `SELECT ` + deliveryColumns + `
+                FROM notification_deliveries
+               WHERE user_id = ?`
+	args := []any{userID}
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.Cursor != "" {
+		query += ` AND (created_at, id) < (SELECT created_at, id FROM notification_deliveries WHERE id = ? AND user_id = ?)`
+		args = append(args, opts.Cursor, userID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, size+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return client_identity.Page[Delivery]{}, fmt.Errorf("query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	page := client_identity.Page[Delivery]{Items: []Delivery{}}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return client_identity.Page[Delivery]{}, err
+		}
+		page.Items = append(page.Items, d)
+	}
+	if err := rows.Err(); err != nil {
+		return client_identity.Page[Delivery]{}, err
+	}
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextCursor = page.Items[size-1].ID
+	}
+	return page, nil
+}
+
+// RecordEvent applies a delivery event from the notify service to the
+// deliveries with its message ID, and suppresses the address after a hard
+// bounce or a complaint. Events for unknown messages still suppress.
+func (s *Service) RecordEvent(ctx context.Context, eventType string, ev shared_webhooks.EmailEvent) error {
+	var status client_notify.DeliveryState
+	switch eventType {
+	case shared_webhooks.EventEmailDelivered:
+		status = client_notify.DeliveryDelivered
+	case shared_webhooks.EventEmailBounced:
+		status = client_notify.DeliveryBounced
+	case shared_webhooks.EventEmailComplained:
+		status = client_notify.DeliveryComplained
+	default:
+		return fmt.Errorf("unknown email event type %q", eventType)
+	}
+
+	reason := ev.Reason
+	if ev.Bounce != "" {
+		reason = strings.TrimSpace(string(ev.Bounce) + " bounce: " + ev.Reason)
+	}
+	now := shared_helpers.FormatDBTime(time.Now())
+	// A late "delivered" must not hide an earlier bounce or complaint.
+	query := `UPDATE notification_deliveries SET status = ?, reason = ?, updated_at = ? WHERE provider_id = ?`
+	if status == client_notify.DeliveryDelivered {
+		query += ` AND status NOT IN ('bounced', 'complained')`
+	}
+	if _, err := s.db.ExecContext(ctx, query, string(status), reason, now, ev.MessageID); err != nil {
+		return fmt.Errorf("update delivery: %w", err)
+	}
+
+	switch {
+	case status == client_notify.DeliveryBounced && ev.Bounce == client_notify.BounceHard:
+		return s.suppress(ctx, ev.Recipient, SuppressionHardBounce, ev)
+	case status == client_notify.DeliveryComplained:
+		return s.suppress(ctx, ev.Recipient, SuppressionComplaint, ev)
+	}
+	return nil
+}
+
+// HandleEvents registers RecordEvent for the notify service's email events
+// on rc.
+func (s *Service) HandleEvents(rc *shared_webhooks.Receiver) {
+	for _, eventType := range []string{
+		shared_webhooks.EventEmailDelivered,
+		shared_webhooks.EventEmailBounced,
+		shared_webhooks.EventEmailComplained,
+	} {
+		rc.OnEmail(eventType, func(ctx context.Context, env shared_webhooks.Envelope, ev shared_webhooks.EmailEvent) error {
+			return s.RecordEvent(ctx, env.Type, ev)
+		})
+	}
+}
+
+// suppress adds address to the suppression list. A hard bounce is never
+// downgraded to a complaint.
+func (s *Service) suppress(ctx context.Context, address, reason string, ev shared_webhooks.EmailEvent) error {
+	address = normaliseAddress(address)
+	if address == "" {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO email_suppressions (address, reason, provider_id, detail, created_at)
+         VALUES (?, ?, ?, ?, ?)
+         ON CONFLICT (address) DO UPDATE SET
+             reason = CASE WHEN email_suppressions.reason = ? THEN email_suppressions.reason ELSE excluded.reason END,
+             provider_id = excluded.provider_id,
+             detail = excluded.detail`,
+		address, reason, nullString(ev.MessageID), ev.Reason, shared_helpers.FormatDBTime(time.Now()),
+		SuppressionHardBounce,
+	); err != nil {
+		return fmt.Errorf("suppress address: %w", err)
+	}
+	return nil
+}
+
+// Suppressed reports whether emails of category must not be sent to
+// address. It fits client_notify.EmailClient.Suppressed and
+// client_notify.EmailDriver.Suppressed.
+func (s *Service) Suppressed(ctx context.Context, address string, category client_notify.Category) (bool, error) {
+	var reason string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT reason FROM email_suppressions WHERE address = ?`, normaliseAddress(address),
+	).Scan(&reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("query suppression: %w", err)
+	}
+	if reason == SuppressionComplaint && category == client_notify.CategorySecurity {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Unsuppress removes address from the suppression list, e.g. once the
+// user has fixed their mailbox.
+func (s *Service) Unsuppress(ctx context.Context, address string) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM email_suppressions WHERE address = ?`, normaliseAddress(address))
+	if err != nil {
+		return fmt.Errorf("delete suppression: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// normaliseAddress returns address as shared_email stores it. Addresses it
+// rejects are still matched case-insensitively, so bounces reported for
+// them can be suppressed.
+func normaliseAddress(address string) string {
+	if email, err := shared_email.Normalise(address); err == nil {
+		return email
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+const deliveryColumns = `id, message_id, provider_id, user_id, channel, address, category, subject, status, reason, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDelivery(row scanner) (Delivery, error) {
+	var (
+		d                         Delivery
+		providerID, userID        sql.NullString
+		channel, category, status string
+		createdAt, updatedAt      string
+	)
+	if err := row.Scan(&d.ID, &d.MessageID, &providerID, &userID, &channel, &d.Address, &category,
+		&d.Subject, &status, &d.Reason, &createdAt, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Delivery{}, err
+		}
+		return Delivery{}, fmt.Errorf("scan delivery: %w", err)
+	}
+	d.ProviderID = providerID.String
+	d.UserID = userID.String
+	d.Channel = client_notify.Channel(channel)
+	d.Category = client_notify.Category(category)
+	d.Status = client_notify.DeliveryState(status)
+	d.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	d.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	return d, nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
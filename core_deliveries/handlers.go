@@ -0,0 +1,69 @@
+package core_deliveries
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// HistoryHandler serves GET /api/notifications/deliveries, the session
+// user's delivery history. It accepts the page_size, cursor and status
+// query parameters.
+func HistoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resp, err := s.History(r.Context(), userID, client_identity.ListOptionsFromQuery(r.URL.Query()))
+		if err != nil {
+			writeDeliveryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// These are admin endpoints; mount them behind an administrator check.
+
+// UserHistoryHandler serves GET /api/admin/users/{user_id}/deliveries.
+func UserHistoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.History(r.Context(), mux.Vars(r)["user_id"], client_identity.ListOptionsFromQuery(r.URL.Query()))
+		if err != nil {
+			writeDeliveryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// UnsuppressHandler serves DELETE /api/admin/email-suppressions/{address}.
+func UnsuppressHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Unsuppress(r.Context(), mux.Vars(r)["address"]); err != nil {
+			writeDeliveryError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, client_identity.DeleteResponse{Success: true, Message: "Address unsuppressed"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeDeliveryError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrDeliveryNotFound) || errors.Is(err, ErrSuppressionNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,47 @@
+package core_deliveries
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the notification_deliveries and email_suppressions
+// tables in CoreDB if they do not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"notification_deliveries", `CREATE TABLE IF NOT EXISTS notification_deliveries (
+             id          TEXT PRIMARY KEY,
+             message_id  TEXT NOT NULL,
+             provider_id TEXT,
+             user_id     TEXT,
+             channel     TEXT NOT NULL,
+             address     TEXT NOT NULL DEFAULT '',
+             category    TEXT NOT NULL DEFAULT '',
+             subject     TEXT NOT NULL DEFAULT '',
+             status      TEXT NOT NULL,
+             reason      TEXT NOT NULL DEFAULT '',
+             created_at  TIMESTAMP NOT NULL,
+             updated_at  TIMESTAMP NOT NULL
+         )`},
+		{"notification_deliveries user index", `CREATE INDEX IF NOT EXISTS idx_notification_deliveries_user
+             ON notification_deliveries (user_id, created_at, id)`},
+		{"notification_deliveries provider index", `CREATE INDEX IF NOT EXISTS idx_notification_deliveries_provider
+             ON notification_deliveries (provider_id)`},
+		{"email_suppressions", `CREATE TABLE IF NOT EXISTS email_suppressions (
+             address     TEXT PRIMARY KEY,
+             reason      TEXT NOT NULL,
+             provider_id TEXT,
+             detail      TEXT NOT NULL DEFAULT '',
+             created_at  TIMESTAMP NOT NULL
+         )`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package core_inbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// All handlers act on the session user's own inbox.
+
+// ListHandler serves GET /api/inbox. It accepts the page_size, cursor and
+// status (unread or read) query parameters.
+func ListHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resp, err := s.List(r.Context(), userID, client_identity.ListOptionsFromQuery(r.URL.Query()))
+		if err != nil {
+			writeInboxError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// UnreadCountResponse is returned by GET /api/inbox/unread-count.
+type UnreadCountResponse struct {
+	Unread int `json:"unread"`
+}
+
+// UnreadCountHandler serves GET /api/inbox/unread-count.
+func UnreadCountHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		n, err := s.UnreadCount(r.Context(), userID)
+		if err != nil {
+			writeInboxError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, UnreadCountResponse{Unread: n})
+	}
+}
+
+// MarkReadHandler serves POST /api/inbox/{notification_id}/read.
+func MarkReadHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := s.MarkRead(r.Context(), userID, mux.Vars(r)["notification_id"]); err != nil {
+			writeInboxError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MarkAllReadHandler serves POST /api/inbox/read.
+func MarkAllReadHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if _, err := s.MarkAllRead(r.Context(), userID); err != nil {
+			writeInboxError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteHandler serves DELETE /api/inbox/{notification_id}.
+func DeleteHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := s.Delete(r.Context(), userID, mux.Vars(r)["notification_id"]); err != nil {
+			writeInboxError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, client_identity.DeleteResponse{Success: true, Message: "notification deleted"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeInboxError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotificationNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
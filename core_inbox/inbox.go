@@ -0,0 +1,217 @@
+// Package core_inbox stores in-app notifications in CoreDB. Its Service is
+// the client_notify driver for the in-app channel.
+package core_inbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// Status filters for List.
+const (
+	StatusUnread = "unread"
+	StatusRead   = "read"
+)
+
+const defaultPageSize = 20
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// Notification is a message in a user's inbox.
+type Notification struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	MessageID string            `json:"message_id"`
+	Category  string            `json:"category,omitempty"`
+	Subject   string            `json:"subject"`
+	Body      string            `json:"body"`
+	Link      string            `json:"link,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Service reads and writes inbox notifications.
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Channel makes Service a client_notify.Driver for the in-app channel.
+func (s *Service) Channel() client_notify.Channel { return client_notify.ChannelInApp }
+
+// Send stores msg in the recipient's inbox. Sending the same message ID to
+// the same user twice stores it once.
+func (s *Service) Send(ctx context.Context, to client_notify.Recipient, msg client_notify.Message) error {
+	if to.UserID == "" {
+		return client_notify.ErrNoAddress
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	var data any
+	if len(msg.Data) > 0 {
+		b, err := json.Marshal(msg.Data)
+		if err != nil {
+			return fmt.Errorf("marshal notification data: %w", err)
+		}
+		data = string(b)
+	}
+	createdAt := msg.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO inbox_notifications (id, user_id, message_id, category, subject, body, link, data, created_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+         ON CONFLICT (user_id, message_id) DO NOTHING`,
+		id, to.UserID, msg.ID, string(msg.Category), msg.Subject, msg.Body, msg.Link, data,
+		shared_helpers.FormatDBTime(createdAt),
+	); err != nil {
+		return fmt.Errorf("insert inbox notification: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of userID's notifications, newest first. opts.Status
+// may be StatusUnread or StatusRead.
+func (s *Service) List(ctx context.Context, userID string, opts client_identity.ListOptions) (client_identity.Page[Notification], error) {
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, client_identity.MaxPageSize)
+
+	query := `SELECT ` + notificationColumns + `
+                FROM inbox_notifications
+               WHERE user_id = ?`
+	args := []any{userID}
+	switch opts.Status {
+	case StatusUnread:
+		query += ` AND read_at IS NULL`
+	case StatusRead:
+		query += ` AND read_at IS NOT NULL`
+	}
+	if opts.Cursor != "" {
+		query += ` AND (created_at, id) < (SELECT created_at, id FROM inbox_notifications WHERE id = ? AND user_id = ?)`
+		args = append(args, opts.Cursor, userID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, size+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return client_identity.Page[Notification]{}, fmt.Errorf("query inbox notifications: %w", err)
+	}
+	defer rows.Close()
+
+	page := client_identity.Page[Notification]{Items: []Notification{}}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return client_identity.Page[Notification]{}, err
+		}
+		page.Items = append(page.Items, n)
+	}
+	if err := rows.Err(); err != nil {
+		return client_identity.Page[Notification]{}, err
+	}
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextCursor = page.Items[size-1].ID
+	}
+	return page, nil
+}
+
+// UnreadCount returns how many of userID's notifications are unread.
+func (s *Service) UnreadCount(ctx context.Context, userID string) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM inbox_notifications WHERE user_id = ? AND read_at IS NULL`,
+		userID,
+	).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count unread notifications: %w", err)
+	}
+	return n, nil
+}
+
+// MarkRead marks one of userID's notifications as read. Marking a read
+// notification again is not an error.
+func (s *Service) MarkRead(ctx context.Context, userID, notificationID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE inbox_notifications SET read_at = COALESCE(read_at, ?) WHERE id = ? AND user_id = ?`,
+		shared_helpers.FormatDBTime(time.Now()), notificationID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("mark notification read: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of userID as read and returns how many changed.
+func (s *Service) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE inbox_notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`,
+		shared_helpers.FormatDBTime(time.Now()), userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("mark notifications read: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Delete removes one of userID's notifications.
+func (s *Service) Delete(ctx context.Context, userID, notificationID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM inbox_notifications WHERE id = ? AND user_id = ?`,
+		notificationID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("delete notification: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+const notificationColumns = `id, user_id, message_id, category, subject, body, link, data, read_at, created_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNotification(row scanner) (Notification, error) {
+	var (
+		n            Notification
+		data, readAt sql.NullString
+		createdAt    string
+	)
+	if err := row.Scan(&n.ID, &n.UserID, &n.MessageID, &n.Category, &n.Subject, &n.Body, &n.Link,
+		&data, &readAt, &createdAt); err != nil {
+		return Notification{}, fmt.Errorf("scan inbox notification: %w", err)
+	}
+	if data.Valid && data.String != "" {
+		if err := json.Unmarshal([]byte(data.String), &n.Data); err != nil {
+			return Notification{}, fmt.Errorf("decode notification data: %w", err)
+		}
+	}
+	n.ReadAt = shared_helpers.ParseNullDBTime(readAt)
+	n.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	return n, nil
+}
@@ -0,0 +1,36 @@
+package core_inbox
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the inbox_notifications table in CoreDB if it does not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"inbox_notifications", `CREATE TABLE IF NOT EXISTS inbox_notifications (
+             id         TEXT PRIMARY KEY,
+             user_id    TEXT NOT NULL,
+             message_id TEXT NOT NULL,
+             category   TEXT NOT NULL DEFAULT '',
+             subject    TEXT NOT NULL DEFAULT '',
+             body       TEXT NOT NULL DEFAULT '',
+             link       TEXT NOT NULL DEFAULT '',
+             data       TEXT,
+             read_at    TIMESTAMP,
+             created_at TIMESTAMP NOT NULL,
+             UNIQUE (user_id, message_id)
+         )`},
+		{"inbox_notifications user index", `CREATE INDEX IF NOT EXISTS idx_inbox_notifications_user
+             ON inbox_notifications (user_id, created_at, id)`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package core_invitations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_seats"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// Mount these handlers behind shared_rbac.RequireOrgPermission(shared_rbac.PermMembersInvite),
+// except AcceptInviteHandler which only needs the invite token.
+
+// CreateInviteHandler serves POST /api/organisations/{id}/invites.
+func CreateInviteHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req CreateInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.Create(r.Context(), r.Cookies(), orgID, userID, req)
+		if err != nil {
+			writeInviteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ListInvitesHandler serves GET /api/organisations/{id}/invites.
+func ListInvitesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		resp, err := s.ListPending(r.Context(), orgID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if resp == nil {
+			resp = []Invite{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ResendInviteHandler serves POST /api/organisations/{id}/invites/{invite_id}/resend.
+func ResendInviteHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		inviteID := vars["invite_id"]
+		resp, err := s.Resend(r.Context(), r.Cookies(), orgID, inviteID)
+		if err != nil {
+			writeInviteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// RevokeInviteHandler serves DELETE /api/organisations/{id}/invites/{invite_id}.
+func RevokeInviteHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		inviteID := vars["invite_id"]
+		if err := s.Revoke(r.Context(), orgID, inviteID); err != nil {
+			writeInviteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(client_identity.DeleteResponse{Success: true, Message: "invite revoked"})
+	}
+}
+
+// AcceptInviteHandler serves POST /api/invites/accept.
+func AcceptInviteHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AcceptInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.Accept(r.Context(), req)
+		if err != nil {
+			writeInviteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func writeInviteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidEmail), errors.Is(err, ErrInvalidRole), errors.Is(err, ErrInvalidToken):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrInviteNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlreadyInvited), errors.Is(err, ErrInviteNotPending):
+		status = http.StatusConflict
+	case errors.Is(err, ErrTokenExpired):
+		status = http.StatusGone
+	case errors.Is(err, shared_seats.ErrSeatLimitReached):
+		status = http.StatusPaymentRequired
+	case errors.Is(err, client_notify.ErrSuppressed):
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(client_identity.ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,488 @@
+package core_invitations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_email"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_rbac"
+	"github.com/hstles/go-sdk/shared_seats"
+	"github.com/hstles/go-sdk/shared_templates"
+)
+
+// DefaultInviteTTL is how long an invite token stays valid.
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+// Invite statuses
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRevoked  = "revoked"
+	// StatusExpired marks a pending invite that lapsed and was replaced by a new one.
+	StatusExpired = "expired"
+)
+
+var (
+	ErrInviteNotFound   = errors.New("invite not found")
+	ErrInviteNotPending = errors.New("invite is no longer pending")
+	ErrAlreadyInvited   = errors.New("email already has a pending invite to this organisation")
+	ErrInvalidRole      = errors.New("invalid invite role")
+	ErrInvalidEmail     = errors.New("invalid invite email")
+)
+
+// Invite is an invitation for an email address to join an organisation.
+type Invite struct {
+	ID             string     `json:"id"`
+	OrgID          string     `json:"org_id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"`
+	InvitedBy      string     `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedUserID string     `json:"accepted_user_id,omitempty"`
+}
+
+// Expired reports whether the invite is pending past its expiry.
+func (i Invite) Expired() bool {
+	return i.Status == StatusPending && time.Now().After(i.ExpiresAt)
+}
+
+// CreateInviteRequest is the request body for POST /api/organisations/{id}/invites
+type CreateInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AcceptInviteRequest is the request body for POST /api/invites/accept
+type AcceptInviteRequest struct {
+	Token     string `json:"token"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// AcceptInviteResponse is returned after an invite is accepted.
+type AcceptInviteResponse struct {
+	Invite Invite                 `json:"invite"`
+	User   client_identity.User   `json:"user"`
+	Member client_identity.Member `json:"member"`
+}
+
+// Identity is the part of the identity client the Service uses. It is
+// satisfied by *client_identity.Client and *client_identity.CachedClient.
+type Identity interface {
+	GetUserByEmail(ctx context.Context, apiKey, email string) (client_identity.User, int, error)
+	GetUserByID(ctx context.Context, apiKey, userID string) (client_identity.User, int, error)
+	CreateUser(ctx context.Context, apiKey string, req client_identity.CreateUserRequest) (client_identity.User, int, error)
+	GetOrganisation(ctx context.Context, cookies []*http.Cookie, orgID string) (client_identity.Organisation, int, error)
+	AddMember(ctx context.Context, cookies []*http.Cookie, orgID string, req client_identity.AddMemberRequest) (client_identity.Member, int, error)
+}
+
+// Service manages organisation invites stored in CoreDB.
+type Service struct {
+	db       *sql.DB
+	identity Identity
+	notifier *client_notify.EmailClient
+	apiKey   string
+	secret   []byte
+
+	// AcceptURL is the page that accepts invites; the token is added as ?token=.
+	AcceptURL string
+	// TTL is how long new and resent invites stay valid.
+	TTL time.Duration
+	// Seats, when set, enforces plan seat limits on acceptance.
+	Seats *shared_seats.Enforcer
+	// Session returns the session cookies of the service account that adds
+	// accepted invitees to their organisation; the invitee is not an admin
+	// of it and may not be signed in yet.
+	Session func(ctx context.Context) ([]*http.Cookie, error)
+	// Templates renders invite emails, which are sent through the notify
+	// service's generic endpoint.
+	Templates *shared_templates.Engine
+}
+
+// NewService creates an invite service. templates renders the invite email
+// with the application's brand; apiKey is the identity service API key used to
+// look up and create users; secret signs invite tokens; session returns the
+// service account's cookies for membership changes.
+func NewService(db *sql.DB, identity Identity, notifier *client_notify.EmailClient, templates *shared_templates.Engine, apiKey string, secret []byte, acceptURL string, session func(ctx context.Context) ([]*http.Cookie, error)) *Service {
+	return &Service{
+		db:        db,
+		identity:  identity,
+		notifier:  notifier,
+		apiKey:    apiKey,
+		secret:    secret,
+		AcceptURL: acceptURL,
+		TTL:       DefaultInviteTTL,
+		Session:   session,
+		Templates: templates,
+	}
+}
+
+// Create records a pending invite and emails the invite link. cookies are the
+// inviter's session cookies, used to read the organisation name. If the email
+// cannot be sent the invite is deleted, so creating it again is not refused
+// with ErrAlreadyInvited.
+func (s *Service) Create(ctx context.Context, cookies []*http.Cookie, orgID, invitedBy string, req CreateInviteRequest) (Invite, error) {
+	email, err := shared_email.Normalise(req.Email)
+	if err != nil {
+		return Invite{}, fmt.Errorf("%w: %v", ErrInvalidEmail, err)
+	}
+	role, ok := shared_rbac.ParseRole(req.Role)
+	if !ok || role == shared_rbac.RoleOwner {
+		return Invite{}, ErrInvalidRole
+	}
+
+	// A lapsed invite no longer blocks a new one.
+	cutoff := shared_helpers.FormatDBTime(time.Now())
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE organisation_invites
+            SET status = ?, updated_at = ?
+          WHERE org_id = ? AND email = ? AND status = ? AND expires_at <= ?`,
+		StatusExpired, cutoff, orgID, email, StatusPending, cutoff,
+	); err != nil {
+		return Invite{}, fmt.Errorf("expire lapsed invites: %w", err)
+	}
+
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return Invite{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return Invite{}, fmt.Errorf("generate invite nonce: %w", err)
+	}
+
+	now := time.Now().UTC()
+	invite := Invite{
+		ID:        id,
+		OrgID:     orgID,
+		Email:     email,
+		Role:      string(role),
+		Status:    StatusPending,
+		InvitedBy: invitedBy,
+		ExpiresAt: now.Add(s.TTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO organisation_invites (id, org_id, email, role, status, nonce_hash, invited_by, expires_at, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		invite.ID, invite.OrgID, invite.Email, invite.Role, invite.Status, hashNonce(nonce), invite.InvitedBy,
+		shared_helpers.FormatDBTime(invite.ExpiresAt), shared_helpers.FormatDBTime(now), shared_helpers.FormatDBTime(now),
+	); err != nil {
+		// The unique index on pending invites refuses a second one, even when
+		// two are created concurrently.
+		if shared_helpers.IsUniqueViolation(err) {
+			return Invite{}, ErrAlreadyInvited
+		}
+		return Invite{}, fmt.Errorf("insert invite: %w", err)
+	}
+
+	if err := s.send(ctx, cookies, invite, nonce); err != nil {
+		if _, delErr := s.db.ExecContext(ctx,
+			`DELETE FROM organisation_invites WHERE id = ? AND status = ?`, invite.ID, StatusPending,
+		); delErr != nil {
+			log.Printf("core_invitations: delete unsent invite %s: %v", invite.ID, delErr)
+		}
+		return Invite{}, err
+	}
+	return invite, nil
+}
+
+// Resend issues a fresh token for a pending invite, extends its expiry and
+// emails it again. Previously sent links stop working, unless the email cannot
+// be sent, in which case the invite is left as it was.
+func (s *Service) Resend(ctx context.Context, cookies []*http.Cookie, orgID, inviteID string) (Invite, error) {
+	invite, err := s.Get(ctx, orgID, inviteID)
+	if err != nil {
+		return Invite{}, err
+	}
+	if invite.Status != StatusPending {
+		return invite, ErrInviteNotPending
+	}
+	var oldHash string
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT nonce_hash FROM organisation_invites WHERE id = ?`, inviteID,
+	).Scan(&oldHash); err != nil {
+		return Invite{}, fmt.Errorf("query invite nonce: %w", err)
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return Invite{}, fmt.Errorf("generate invite nonce: %w", err)
+	}
+	oldExpiresAt, oldUpdatedAt := invite.ExpiresAt, invite.UpdatedAt
+	now := time.Now().UTC()
+	invite.ExpiresAt = now.Add(s.TTL)
+	invite.UpdatedAt = now
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE organisation_invites
+            SET nonce_hash = ?, expires_at = ?, updated_at = ?
+          WHERE id = ? AND org_id = ? AND status = ? AND nonce_hash = ?`,
+		hashNonce(nonce), shared_helpers.FormatDBTime(invite.ExpiresAt), shared_helpers.FormatDBTime(now),
+		inviteID, orgID, StatusPending, oldHash,
+	)
+	if err != nil {
+		return Invite{}, fmt.Errorf("update invite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return invite, ErrInviteNotPending
+	}
+
+	if err := s.send(ctx, cookies, invite, nonce); err != nil {
+		// Restore the previous token so the link already sent keeps working.
+		if _, rbErr := s.db.ExecContext(ctx,
+			`UPDATE organisation_invites
+                SET nonce_hash = ?, expires_at = ?, updated_at = ?
+              WHERE id = ? AND nonce_hash = ?`,
+			oldHash, shared_helpers.FormatDBTime(oldExpiresAt), shared_helpers.FormatDBTime(oldUpdatedAt),
+			inviteID, hashNonce(nonce),
+		); rbErr != nil {
+			log.Printf("core_invitations: restore token of unsent invite %s: %v", inviteID, rbErr)
+		}
+		invite.ExpiresAt, invite.UpdatedAt = oldExpiresAt, oldUpdatedAt
+		return invite, err
+	}
+	return invite, nil
+}
+
+// Revoke cancels a pending invite so its token can no longer be accepted.
+func (s *Service) Revoke(ctx context.Context, orgID, inviteID string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE organisation_invites
+            SET status = ?, updated_at = ?
+          WHERE id = ? AND org_id = ? AND status = ?`,
+		StatusRevoked, shared_helpers.FormatDBTime(time.Now()), inviteID, orgID, StatusPending,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke invite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.Get(ctx, orgID, inviteID); err != nil {
+			return err
+		}
+		return ErrInviteNotPending
+	}
+	return nil
+}
+
+// Accept redeems an invite token. The invited user is looked up by email and
+// created through client_identity if needed, then added to the organisation
+// with the invited role using the service account's Session.
+func (s *Service) Accept(ctx context.Context, req AcceptInviteRequest) (AcceptInviteResponse, error) {
+	claims, err := parseToken(s.secret, req.Token, time.Now())
+	if err != nil {
+		return AcceptInviteResponse{}, err
+	}
+
+	// Claim the invite first so a token can only be redeemed once, even concurrently.
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE organisation_invites
+            SET status = ?, accepted_at = ?, updated_at = ?
+          WHERE id = ? AND nonce_hash = ? AND status = ? AND expires_at > ?`,
+		StatusAccepted, shared_helpers.FormatDBTime(now), shared_helpers.FormatDBTime(now),
+		claims.InviteID, hashNonce(claims.Nonce), StatusPending, shared_helpers.FormatDBTime(now),
+	)
+	if err != nil {
+		return AcceptInviteResponse{}, fmt.Errorf("claim invite: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return AcceptInviteResponse{}, ErrInviteNotPending
+	}
+
+	invite, err := s.getByID(ctx, claims.InviteID)
+	if err != nil {
+		return AcceptInviteResponse{}, err
+	}
+
+	user, member, err := s.join(ctx, invite, req)
+	if err != nil {
+		// Put the invite back so it can be retried with the same link.
+		if _, rbErr := s.db.ExecContext(ctx,
+			`UPDATE organisation_invites
+                SET status = ?, accepted_at = NULL, updated_at = ?
+              WHERE id = ?`,
+			StatusPending, shared_helpers.FormatDBTime(time.Now()), invite.ID,
+		); rbErr != nil {
+			log.Printf("core_invitations: release invite %s: %v", invite.ID, rbErr)
+		}
+		return AcceptInviteResponse{}, err
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE organisation_invites SET accepted_user_id = ? WHERE id = ?`,
+		user.ID, invite.ID,
+	); err != nil {
+		log.Printf("core_invitations: record accepting user for %s: %v", invite.ID, err)
+	}
+	invite.Status = StatusAccepted
+	invite.AcceptedAt = &now
+	invite.AcceptedUserID = user.ID
+	return AcceptInviteResponse{Invite: invite, User: user, Member: member}, nil
+}
+
+func (s *Service) join(ctx context.Context, invite Invite, req AcceptInviteRequest) (client_identity.User, client_identity.Member, error) {
+	user, code, err := s.identity.GetUserByEmail(ctx, s.apiKey, invite.Email)
+	switch {
+	case code == http.StatusNotFound:
+		user, code, err = s.identity.CreateUser(ctx, s.apiKey, client_identity.CreateUserRequest{
+			Email:     invite.Email,
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+		})
+		if err != nil || code >= 300 {
+			return user, client_identity.Member{}, fmt.Errorf("create user %s: status=%d err=%v", invite.Email, code, err)
+		}
+	case err != nil || code != http.StatusOK:
+		return user, client_identity.Member{}, fmt.Errorf("get user %s: status=%d err=%v", invite.Email, code, err)
+	}
+
+	cookies, err := s.Session(ctx)
+	if err != nil {
+		return user, client_identity.Member{}, fmt.Errorf("service session: %w", err)
+	}
+	add := client_identity.AddMemberRequest{UserID: user.ID, Role: invite.Role}
+	var member client_identity.Member
+	if s.Seats != nil {
+		member, code, err = s.Seats.AddMember(ctx, cookies, invite.OrgID, add)
+	} else {
+		member, code, err = s.identity.AddMember(ctx, cookies, invite.OrgID, add)
+	}
+	if err != nil {
+		return user, member, err
+	}
+	if code >= 300 {
+		return user, member, fmt.Errorf("add member to %s: unexpected status %d", invite.OrgID, code)
+	}
+	return user, member, nil
+}
+
+// Get returns a single invite belonging to orgID.
+func (s *Service) Get(ctx context.Context, orgID, inviteID string) (Invite, error) {
+	invite, err := s.getByID(ctx, inviteID)
+	if err != nil {
+		return Invite{}, err
+	}
+	if invite.OrgID != orgID {
+		return Invite{}, ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+// ListPending returns the unexpired pending invites of orgID, newest first.
+func (s *Service) ListPending(ctx context.Context, orgID string) ([]Invite, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+inviteColumns+`
+           FROM organisation_invites
+          WHERE org_id = ? AND status = ? AND expires_at > ?
+          ORDER BY created_at DESC`,
+		orgID, StatusPending, shared_helpers.FormatDBTime(time.Now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pending invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (s *Service) getByID(ctx context.Context, inviteID string) (Invite, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+inviteColumns+`
+           FROM organisation_invites
+          WHERE id = ?`,
+		inviteID,
+	)
+	invite, err := scanInvite(row)
+	if err == sql.ErrNoRows {
+		return Invite{}, ErrInviteNotFound
+	}
+	return invite, err
+}
+
+const inviteColumns = `id, org_id, email, role, status, invited_by, expires_at, created_at, updated_at, accepted_at, accepted_user_id`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvite(row scanner) (Invite, error) {
+	var (
+		invite                          Invite
+		expiresAt, createdAt, updatedAt string
+		acceptedAt, acceptedUserID      sql.NullString
+	)
+	if err := row.Scan(&invite.ID, &invite.OrgID, &invite.Email, &invite.Role, &invite.Status, &invite.InvitedBy,
+		&expiresAt, &createdAt, &updatedAt, &acceptedAt, &acceptedUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return Invite{}, err
+		}
+		return Invite{}, fmt.Errorf("scan invite: %w", err)
+	}
+	invite.ExpiresAt = shared_helpers.ParseDBTime(expiresAt)
+	invite.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	invite.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	invite.AcceptedAt = shared_helpers.ParseNullDBTime(acceptedAt)
+	invite.AcceptedUserID = acceptedUserID.String
+	return invite, nil
+}
+
+// InviteLink returns the accept URL carrying token.
+func (s *Service) InviteLink(token string) string {
+	u, err := url.Parse(s.AcceptURL)
+	if err != nil {
+		return s.AcceptURL + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (s *Service) send(ctx context.Context, cookies []*http.Cookie, invite Invite, nonce string) error {
+	orgName := invite.OrgID
+	if org, code, err := s.identity.GetOrganisation(ctx, cookies, invite.OrgID); err == nil && code == http.StatusOK {
+		orgName = org.Name
+	}
+	inviterName := ""
+	if inviter, code, err := s.identity.GetUserByID(ctx, s.apiKey, invite.InvitedBy); err == nil && code == http.StatusOK {
+		inviterName = strings.TrimSpace(inviter.FirstName + " " + inviter.LastName)
+	}
+
+	token := signToken(s.secret, tokenClaims{InviteID: invite.ID, ExpiresAt: invite.ExpiresAt, Nonce: nonce})
+	rendered, err := s.Templates.Render(shared_templates.InviteData{
+		OrganisationName: orgName,
+		InviterName:      inviterName,
+		Role:             invite.Role,
+		Link:             s.InviteLink(token),
+	})
+	if err != nil {
+		return fmt.Errorf("render invite email: %w", err)
+	}
+	if _, err := s.notifier.SendGenericEmail(ctx, invite.Email, rendered.Subject, rendered.Text); err != nil {
+		return fmt.Errorf("send invite email to %s: %w", invite.Email, err)
+	}
+	return nil
+}
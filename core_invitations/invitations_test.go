@@ -0,0 +1,238 @@
+package core_invitations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_templates"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestToken(t *testing.T) {
+	now := time.Now()
+	claims := tokenClaims{InviteID: "inv_1", ExpiresAt: now.Add(time.Hour).Truncate(time.Second).UTC(), Nonce: "n0nce"}
+	token := signToken(testSecret, claims)
+
+	got, err := parseToken(testSecret, token, now)
+	if err != nil || got != claims {
+		t.Fatalf("parseToken = %+v, %v; want %+v", got, err, claims)
+	}
+	if _, err := parseToken([]byte("another secret"), token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("parseToken with another secret = %v, want ErrInvalidToken", err)
+	}
+	if _, err := parseToken(testSecret, token+"x", now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("parseToken with a tampered signature = %v, want ErrInvalidToken", err)
+	}
+	if _, err := parseToken(testSecret, token, now.Add(2*time.Hour)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("parseToken after expiry = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestInviteLink(t *testing.T) {
+	s := &Service{AcceptURL: "https://app.example.com/invites/accept?lang=en"}
+	u, err := url.Parse(s.InviteLink("a.b+c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("token") != "a.b+c" || u.Query().Get("lang") != "en" {
+		t.Errorf("InviteLink = %s, want the token added to the existing query", u)
+	}
+}
+
+// fakeIdentity knows no users and adds every member it is asked to.
+type fakeIdentity struct {
+	mu      sync.Mutex
+	members []client_identity.AddMemberRequest
+}
+
+func (f *fakeIdentity) GetUserByEmail(context.Context, string, string) (client_identity.User, int, error) {
+	return client_identity.User{}, http.StatusNotFound, errors.New("not found")
+}
+
+func (f *fakeIdentity) GetUserByID(context.Context, string, string) (client_identity.User, int, error) {
+	return client_identity.User{ID: "usr_admin", FirstName: "Grace", LastName: "Hopper"}, http.StatusOK, nil
+}
+
+func (f *fakeIdentity) CreateUser(_ context.Context, _ string, req client_identity.CreateUserRequest) (client_identity.User, int, error) {
+	return client_identity.User{ID: "usr_new", Email: req.Email}, http.StatusCreated, nil
+}
+
+func (f *fakeIdentity) GetOrganisation(_ context.Context, _ []*http.Cookie, orgID string) (client_identity.Organisation, int, error) {
+	return client_identity.Organisation{ID: orgID, Name: "Acme"}, http.StatusOK, nil
+}
+
+func (f *fakeIdentity) AddMember(_ context.Context, _ []*http.Cookie, _ string, req client_identity.AddMemberRequest) (client_identity.Member, int, error) {
+	f.mu.Lock()
+	f.members = append(f.members, req)
+	f.mu.Unlock()
+	return client_identity.Member{UserID: req.UserID, Role: req.Role, Status: "active"}, http.StatusCreated, nil
+}
+
+// notifyServer records invite emails, or fails them with 503 while down.
+type notifyServer struct {
+	mu     sync.Mutex
+	down   bool
+	emails []client_notify.GenericEmailRequest
+}
+
+func (n *notifyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(client_notify.EmailResponse{Error: "unavailable"})
+		return
+	}
+	var req client_notify.GenericEmailRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	n.emails = append(n.emails, req)
+	json.NewEncoder(w).Encode(client_notify.EmailResponse{Success: true, MessageID: "msg_1"})
+}
+
+var tokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_\-.%]+)`)
+
+// lastToken returns the token in the most recent invite email.
+func (n *notifyServer) lastToken(t *testing.T) string {
+	t.Helper()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.emails) == 0 {
+		t.Fatal("no invite email was sent")
+	}
+	m := tokenPattern.FindStringSubmatch(n.emails[len(n.emails)-1].Message)
+	if m == nil {
+		t.Fatalf("no token in %q", n.emails[len(n.emails)-1].Message)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestService(t *testing.T) (*Service, *notifyServer, string) {
+	t.Helper()
+	ns := &notifyServer{}
+	srv := httptest.NewServer(ns)
+	t.Cleanup(srv.Close)
+	session := func(context.Context) ([]*http.Cookie, error) {
+		return []*http.Cookie{{Name: "session", Value: "service"}}, nil
+	}
+	s := NewService(testDB(t), &fakeIdentity{}, client_notify.NewClient(srv.URL),
+		shared_templates.Default(shared_templates.Brand{Name: "Hstles"}), "key", testSecret,
+		"https://app.example.com/invites/accept", session)
+	// Each test uses its own organisation, so runs against a shared database do not collide.
+	return s, ns, "org_" + time.Now().Format("150405.000000000")
+}
+
+func TestCreateRefusesSecondPendingInvite(t *testing.T) {
+	ctx := context.Background()
+	s, _, orgID := newTestService(t)
+	req := CreateInviteRequest{Email: "ada@example.com", Role: "member"}
+
+	first, err := s.Create(ctx, nil, orgID, "usr_admin", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, nil, orgID, "usr_admin", CreateInviteRequest{Email: "Ada@Example.com", Role: "admin"}); !errors.Is(err, ErrAlreadyInvited) {
+		t.Fatalf("second Create = %v, want ErrAlreadyInvited", err)
+	}
+
+	// The database refuses a duplicate even when the pre-check is skipped.
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO organisation_invites (id, org_id, email, role, status, nonce_hash, invited_by, expires_at)
+         VALUES ('dup_`+orgID+`', ?, 'ada@example.com', 'member', 'pending', 'x', 'usr_admin', ?)`,
+		orgID, first.ExpiresAt.Format(time.RFC3339Nano))
+	if err == nil {
+		t.Fatal("inserting a second pending invite succeeded")
+	}
+
+	// Once the invite lapses, the address can be invited again.
+	if _, err := s.db.ExecContext(ctx, `UPDATE organisation_invites SET expires_at = ? WHERE id = ?`, "2000-01-01T00:00:00.000000000Z", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, nil, orgID, "usr_admin", req); err != nil {
+		t.Fatalf("Create after the invite lapsed = %v", err)
+	}
+	if got, err := s.Get(ctx, orgID, first.ID); err != nil || got.Status != StatusExpired {
+		t.Errorf("lapsed invite = %+v, %v; want it expired", got, err)
+	}
+}
+
+func TestResendKeepsTokenWhenSendFails(t *testing.T) {
+	ctx := context.Background()
+	s, ns, orgID := newTestService(t)
+	invite, err := s.Create(ctx, nil, orgID, "usr_admin", CreateInviteRequest{Email: "ada@example.com", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := ns.lastToken(t)
+
+	ns.mu.Lock()
+	ns.down = true
+	ns.mu.Unlock()
+	if _, err := s.Resend(ctx, nil, orgID, invite.ID); err == nil {
+		t.Fatal("Resend succeeded while notify was down")
+	}
+	if got, _ := s.Get(ctx, orgID, invite.ID); !got.ExpiresAt.Equal(invite.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v after a failed resend, want %v", got.ExpiresAt, invite.ExpiresAt)
+	}
+
+	res, err := s.Accept(ctx, AcceptInviteRequest{Token: token})
+	if err != nil {
+		t.Fatalf("Accept with the original link after a failed resend = %v", err)
+	}
+	if res.Invite.Status != StatusAccepted || res.Member.Role != "member" {
+		t.Errorf("Accept = %+v", res)
+	}
+}
+
+func TestResendReplacesToken(t *testing.T) {
+	ctx := context.Background()
+	s, ns, orgID := newTestService(t)
+	invite, err := s.Create(ctx, nil, orgID, "usr_admin", CreateInviteRequest{Email: "ada@example.com", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := ns.lastToken(t)
+	if _, err := s.Resend(ctx, nil, orgID, invite.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Accept(ctx, AcceptInviteRequest{Token: old}); !errors.Is(err, ErrInviteNotPending) {
+		t.Errorf("Accept with the replaced link = %v, want ErrInviteNotPending", err)
+	}
+	if _, err := s.Accept(ctx, AcceptInviteRequest{Token: ns.lastToken(t)}); err != nil {
+		t.Errorf("Accept with the resent link = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package core_invitations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the organisation_invites table in CoreDB if it does not exist.
+func EnsureSchema(db *sql.DB) error {
+	if _, err := db.Exec(
+		`CREATE TABLE IF NOT EXISTS organisation_invites (
+             id               TEXT PRIMARY KEY,
+             org_id           TEXT NOT NULL,
+             email            TEXT NOT NULL,
+             role             TEXT NOT NULL,
+             status           TEXT NOT NULL,
+             nonce_hash       TEXT NOT NULL,
+             invited_by       TEXT NOT NULL,
+             expires_at       TIMESTAMP NOT NULL,
+             created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             accepted_at      TIMESTAMP,
+             accepted_user_id TEXT
+         )`,
+	); err != nil {
+		return fmt.Errorf("create organisation_invites: %w", err)
+	}
+	if _, err := db.Exec(
+		`CREATE INDEX IF NOT EXISTS idx_organisation_invites_org_status
+             ON organisation_invites (org_id, status)`,
+	); err != nil {
+		return fmt.Errorf("create organisation_invites index: %w", err)
+	}
+	// Only one invite per address may be pending in an organisation. Tables
+	// created before the unique index may hold duplicates; all but the newest
+	// are revoked so it can be built.
+	if _, err := db.Exec(
+		`UPDATE organisation_invites
+            SET status = 'revoked', updated_at = CURRENT_TIMESTAMP
+          WHERE status = 'pending'
+            AND EXISTS (SELECT 1
+                          FROM organisation_invites newer
+                         WHERE newer.org_id = organisation_invites.org_id
+                           AND newer.email = organisation_invites.email
+                           AND newer.status = 'pending'
+                           AND (newer.created_at > organisation_invites.created_at
+                                OR (newer.created_at = organisation_invites.created_at AND newer.id > organisation_invites.id)))`,
+	); err != nil {
+		return fmt.Errorf("revoke duplicate pending invites: %w", err)
+	}
+	if _, err := db.Exec(
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organisation_invites_pending_email
+             ON organisation_invites (org_id, email) WHERE status = 'pending'`,
+	); err != nil {
+		return fmt.Errorf("create organisation_invites pending index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package core_invitations
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid invite token")
+	ErrTokenExpired = errors.New("invite token has expired")
+)
+
+// tokenClaims is the signed content of an invite token.
+type tokenClaims struct {
+	InviteID  string
+	ExpiresAt time.Time
+	Nonce     string
+}
+
+// newNonce returns a random nonce. Only its hash is stored, so a token can be
+// used once and is invalidated when the invite is resent.
+func newNonce() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// signToken encodes claims as "<payload>.<signature>", both base64url.
+func signToken(secret []byte, c tokenClaims) string {
+	payload := c.InviteID + "|" + strconv.FormatInt(c.ExpiresAt.Unix(), 10) + "|" + c.Nonce
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(secret, encoded))
+}
+
+// parseToken verifies the signature and expiry of token and returns its claims.
+func parseToken(secret []byte, token string, now time.Time) (tokenClaims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, sign(secret, encoded)) {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	c := tokenClaims{InviteID: parts[0], ExpiresAt: time.Unix(exp, 0).UTC(), Nonce: parts[2]}
+	if now.After(c.ExpiresAt) {
+		return c, ErrTokenExpired
+	}
+	return c, nil
+}
+
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package core_invoicing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// ListInvoicesHandler serves GET /api/invoices for the session user.
+func ListInvoicesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resp, err := s.ListForUser(r.Context(), userID)
+		if err != nil {
+			writeInvoiceError(w, err)
+			return
+		}
+		if resp == nil {
+			resp = []Invoice{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// GetInvoiceHandler serves GET /api/invoices/{invoice_id}. The query
+// parameter format selects json (default), html or pdf. Invoices of other
+// users are reported as not found.
+func GetInvoiceHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		inv, err := s.Get(r.Context(), mux.Vars(r)["invoice_id"])
+		if err == nil && inv.UserID != userID {
+			err = ErrInvoiceNotFound
+		}
+		if err != nil {
+			writeInvoiceError(w, err)
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "pdf":
+			pdf, err := s.RenderPDF(inv)
+			if err != nil {
+				writeInvoiceError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+inv.Number+`.pdf"`)
+			w.WriteHeader(http.StatusOK)
+			w.Write(pdf)
+		case "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := s.RenderHTML(w, inv); err != nil {
+				writeInvoiceError(w, err)
+			}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(inv)
+		}
+	}
+}
+
+func writeInvoiceError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvoiceNotOpen), errors.Is(err, ErrAlreadyInvoiced):
+		status = http.StatusConflict
+	case errors.Is(err, ErrNoCurrency), errors.Is(err, ErrEmptyInvoice):
+		status = http.StatusBadRequest
+	case errors.Is(err, client_notify.ErrSuppressed):
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(client_identity.ErrorResponse{Error: err.Error()})
+}
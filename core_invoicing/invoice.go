@@ -0,0 +1,198 @@
+package core_invoicing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_money"
+	"github.com/hstles/go-sdk/shared_subscriptions"
+)
+
+// Invoice statuses
+const (
+	StatusDraft = "draft"
+	StatusOpen  = "open"
+	StatusPaid  = "paid"
+	StatusVoid  = "void"
+)
+
+// Line kinds
+const (
+	LineCharge = "charge"
+	LineCredit = "credit"
+	LineTax    = "tax"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	ErrInvoiceNotOpen  = errors.New("invoice is no longer open")
+	ErrNoCurrency      = errors.New("plan has no currency")
+	ErrEmptyInvoice    = errors.New("invoice has no lines")
+	ErrAlreadyInvoiced = errors.New("subscription period is already invoiced")
+)
+
+// LineItem is one row of an invoice, in the invoice currency.
+type LineItem struct {
+	Kind        string             `json:"kind"`
+	Description string             `json:"description"`
+	Quantity    int64              `json:"quantity"`
+	UnitAmount  shared_money.Money `json:"unit_amount"`
+	Amount      shared_money.Money `json:"amount"`
+}
+
+// TaxRate is applied to the sum of charge and credit lines.
+type TaxRate struct {
+	Name string `json:"name"` // e.g. "VAT"
+	// BasisPoints is the rate in hundredths of a percent, e.g. 2000 for 20%
+	// or 550 for 5.5%, so rates are exact.
+	BasisPoints int64 `json:"basis_points"`
+}
+
+// Invoice is an issued or draft invoice. All amounts are in Currency.
+type Invoice struct {
+	ID             string             `json:"id"`
+	Number         string             `json:"number,omitempty"`
+	UserID         string             `json:"user_id"`
+	SubscriptionID string             `json:"subscription_id,omitempty"`
+	PlanID         string             `json:"plan_id,omitempty"`
+	CustomerName   string             `json:"customer_name,omitempty"`
+	CustomerEmail  string             `json:"customer_email,omitempty"`
+	Currency       string             `json:"currency"`
+	Lines          []LineItem         `json:"lines"`
+	Subtotal       shared_money.Money `json:"subtotal"`
+	Tax            shared_money.Money `json:"tax"`
+	Total          shared_money.Money `json:"total"`
+	Status         string             `json:"status"`
+	PeriodStart    *time.Time         `json:"period_start,omitempty"`
+	PeriodEnd      *time.Time         `json:"period_end,omitempty"`
+	IssuedAt       time.Time          `json:"issued_at"`
+	PaidAt         *time.Time         `json:"paid_at,omitempty"`
+}
+
+// BuildOptions supplies what a Subscription and Plan do not carry.
+type BuildOptions struct {
+	Customer client_identity.User
+	// Period is the billing period invoiced; zero values omit it.
+	Period shared_subscriptions.Period
+	// Proration, when set, replaces the plan charge with a credit for the
+	// old plan and a prorated charge for the new one.
+	Proration *shared_subscriptions.Proration
+	// PreviousPlanName labels the proration credit line.
+	PreviousPlanName string
+	Taxes            []TaxRate
+	// Extra lines appended after the plan charge, before tax.
+	Extra []LineItem
+}
+
+// Build creates a draft invoice for one period of sub on plan. The currency
+// comes from the plan price; taxes are computed on the subtotal. Extra lines
+// must be in the same currency.
+func Build(sub client_identity.Subscription, plan client_identity.Plan, opts BuildOptions) (Invoice, error) {
+	currency := plan.Price.Currency
+	if currency == "" {
+		return Invoice{}, ErrNoCurrency
+	}
+	inv := Invoice{
+		UserID:         sub.UserID,
+		SubscriptionID: sub.ID,
+		PlanID:         plan.ID,
+		CustomerName:   strings.TrimSpace(opts.Customer.FirstName + " " + opts.Customer.LastName),
+		CustomerEmail:  opts.Customer.Email,
+		Currency:       currency,
+		Status:         StatusDraft,
+	}
+	if !opts.Period.Start.IsZero() {
+		start, end := opts.Period.Start, opts.Period.End
+		inv.PeriodStart, inv.PeriodEnd = &start, &end
+	}
+
+	description := plan.Name
+	if inv.PeriodStart != nil {
+		description = fmt.Sprintf("%s (%s – %s)", plan.Name,
+			inv.PeriodStart.Format("2 Jan 2006"), inv.PeriodEnd.Format("2 Jan 2006"))
+	}
+	charge := plan.Price
+	if p := opts.Proration; p != nil {
+		if p.Credit.IsPositive() {
+			label := "Unused time on previous plan"
+			if opts.PreviousPlanName != "" {
+				label = "Unused time on " + opts.PreviousPlanName
+			}
+			credit := p.Credit.Neg()
+			inv.Lines = append(inv.Lines, LineItem{Kind: LineCredit, Description: label, Quantity: 1, UnitAmount: credit, Amount: credit})
+		}
+		if !p.ResetPeriod {
+			description = fmt.Sprintf("Remaining time on %s", plan.Name)
+		}
+		charge = p.Charge
+	}
+	inv.Lines = append(inv.Lines, LineItem{Kind: LineCharge, Description: description, Quantity: 1, UnitAmount: charge, Amount: charge})
+	for _, line := range opts.Extra {
+		if line.Kind == "" {
+			line.Kind = LineCharge
+		}
+		if line.Quantity == 0 {
+			line.Quantity = 1
+		}
+		if line.Amount.IsZero() {
+			amount, err := line.UnitAmount.Mul(line.Quantity)
+			if err != nil {
+				return Invoice{}, fmt.Errorf("line %q: %w", line.Description, err)
+			}
+			line.Amount = amount
+		}
+		inv.Lines = append(inv.Lines, line)
+	}
+	if err := inv.applyTaxes(opts.Taxes); err != nil {
+		return Invoice{}, err
+	}
+	return inv, nil
+}
+
+// applyTaxes recomputes the subtotal, appends a tax line per rate and sets
+// the total. Tax is rounded half up in the minor unit.
+func (inv *Invoice) applyTaxes(rates []TaxRate) error {
+	inv.Subtotal = shared_money.Zero(inv.Currency)
+	for _, line := range inv.Lines {
+		if line.Kind == LineTax {
+			continue
+		}
+		var err error
+		if inv.Subtotal, err = inv.Subtotal.Add(line.Amount); err != nil {
+			return fmt.Errorf("line %q: %w", line.Description, err)
+		}
+	}
+	inv.Tax = shared_money.Zero(inv.Currency)
+	for _, rate := range rates {
+		amount, err := inv.Subtotal.Percent(rate.BasisPoints, shared_money.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		inv.Lines = append(inv.Lines, LineItem{
+			Kind:        LineTax,
+			Description: fmt.Sprintf("%s (%s%%)", rate.Name, formatBasisPoints(rate.BasisPoints)),
+			Quantity:    1,
+			UnitAmount:  amount,
+			Amount:      amount,
+		})
+		if inv.Tax, err = inv.Tax.Add(amount); err != nil {
+			return err
+		}
+	}
+	var err error
+	inv.Total, err = inv.Subtotal.Add(inv.Tax)
+	return err
+}
+
+// formatBasisPoints writes a rate as a percentage without trailing zeros,
+// e.g. 2000 as "20" and 550 as "5.5".
+func formatBasisPoints(bp int64) string {
+	sign := ""
+	if bp < 0 {
+		sign, bp = "-", -bp
+	}
+	return sign + strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%d.%02d", bp/100, bp%100), "0"), ".")
+}
@@ -0,0 +1,99 @@
+package core_invoicing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_money"
+	"github.com/hstles/go-sdk/shared_subscriptions"
+)
+
+var (
+	testSub  = client_identity.Subscription{ID: "sub_1", UserID: "usr_1", PlanID: "pro"}
+	testPlan = client_identity.Plan{ID: "pro", Name: "Pro", Price: shared_money.New(1999, "GBP"), Interval: "monthly"}
+)
+
+func TestBuildTaxRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    int64
+		rates    []TaxRate
+		wantTax  []int64
+		wantDesc []string
+	}{
+		// 19.99 × 20% = 3.998, rounded to 4.00.
+		{"vat", 1999, []TaxRate{{Name: "VAT", BasisPoints: 2000}}, []int64{400}, []string{"VAT (20%)"}},
+		// 10.10 × 5.5% = 0.5555 rounds to 0.56; 10.10 × 0.25% = 0.02525 to 0.03.
+		{"fractional rates", 1010, []TaxRate{{Name: "GST", BasisPoints: 550}, {Name: "Levy", BasisPoints: 25}}, []int64{56, 3}, []string{"GST (5.5%)", "Levy (0.25%)"}},
+		// Exactly half a penny rounds up: 0.10 × 5% = 0.005.
+		{"half up", 10, []TaxRate{{Name: "Tax", BasisPoints: 500}}, []int64{1}, []string{"Tax (5%)"}},
+		{"no tax", 1999, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := testPlan
+			plan.Price = shared_money.New(tt.price, "GBP")
+			inv, err := Build(testSub, plan, BuildOptions{Taxes: tt.rates})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var taxes []LineItem
+			var sum int64
+			for _, line := range inv.Lines {
+				if line.Kind == LineTax {
+					taxes = append(taxes, line)
+					sum += line.Amount.Amount
+				}
+			}
+			if len(taxes) != len(tt.wantTax) {
+				t.Fatalf("tax lines = %+v, want %d", taxes, len(tt.wantTax))
+			}
+			for i, line := range taxes {
+				if line.Amount.Amount != tt.wantTax[i] || line.Description != tt.wantDesc[i] {
+					t.Errorf("tax line %d = %q %d, want %q %d", i, line.Description, line.Amount.Amount, tt.wantDesc[i], tt.wantTax[i])
+				}
+			}
+			if inv.Subtotal.Amount != tt.price || inv.Tax.Amount != sum || inv.Total.Amount != tt.price+sum {
+				t.Errorf("subtotal %d, tax %d, total %d; want %d, %d, %d", inv.Subtotal.Amount, inv.Tax.Amount, inv.Total.Amount, tt.price, sum, tt.price+sum)
+			}
+		})
+	}
+}
+
+func TestBuildProration(t *testing.T) {
+	start := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+	period := shared_subscriptions.Period{Start: start, End: start.AddDate(0, 1, 0)}
+	proration := &shared_subscriptions.Proration{Credit: shared_money.New(500, "GBP"), Charge: shared_money.New(1000, "GBP")}
+	inv, err := Build(testSub, testPlan, BuildOptions{
+		Period:           period,
+		Proration:        proration,
+		PreviousPlanName: "Starter",
+		Taxes:            []TaxRate{{Name: "VAT", BasisPoints: 2000}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Lines[0].Kind != LineCredit || inv.Lines[0].Amount.Amount != -500 || inv.Lines[0].Description != "Unused time on Starter" {
+		t.Errorf("credit line = %+v", inv.Lines[0])
+	}
+	if inv.Subtotal.Amount != 500 || inv.Tax.Amount != 100 || inv.Total.Amount != 600 {
+		t.Errorf("subtotal %d, tax %d, total %d; want 500, 100, 600", inv.Subtotal.Amount, inv.Tax.Amount, inv.Total.Amount)
+	}
+	if inv.PeriodStart == nil || !inv.PeriodStart.Equal(start) {
+		t.Errorf("PeriodStart = %v, want %v", inv.PeriodStart, start)
+	}
+
+	if _, err := Build(testSub, client_identity.Plan{ID: "free"}, BuildOptions{}); !errors.Is(err, ErrNoCurrency) {
+		t.Errorf("Build without a currency = %v, want ErrNoCurrency", err)
+	}
+}
+
+func TestFormatBasisPoints(t *testing.T) {
+	for bp, want := range map[int64]string{2000: "20", 550: "5.5", 25: "0.25", 1234: "12.34", 0: "0", -150: "-1.5"} {
+		if got := formatBasisPoints(bp); got != want {
+			t.Errorf("formatBasisPoints(%d) = %q, want %q", bp, got, want)
+		}
+	}
+}
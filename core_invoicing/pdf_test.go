@@ -0,0 +1,62 @@
+package core_invoicing
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRenderPDF(t *testing.T) {
+	s := NewService(nil, nil, Issuer{Name: "Hstles (UK) Ltd", TaxID: "GB123"})
+	inv, err := Build(testSub, testPlan, BuildOptions{Taxes: []TaxRate{{Name: "VAT", BasisPoints: 2000}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Number = "INV-000042"
+	inv.IssuedAt = time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	pdf, err := s.RenderPDF(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q…", pdf[:min(len(pdf), 20)])
+	}
+	for _, want := range []string{"(INV-000042)", `(Hstles \(UK\) Ltd)`, "(Tax ID: GB123)", "(VAT \\(20%\\))", "\\24323.99"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF does not contain %s", want)
+		}
+	}
+
+	// The xref table points at every object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Errorf("startxref %d does not point at the xref table", xref)
+	}
+	for _, off := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf, -1) {
+		n, _ := strconv.Atoi(string(off[1]))
+		if !regexp.MustCompile(`^\d+ 0 obj\n`).Match(pdf[n:]) {
+			t.Errorf("xref offset %d does not start an object", n)
+		}
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	tests := map[string]string{
+		`a (b) \c`: `a \(b\) \\c`,
+		"€5 – ok":  `\2005 \226 ok`,
+		"café":     `caf\351`,
+		"日本":       "??",
+	}
+	for in, want := range tests {
+		if got := pdfEscape(in); got != want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
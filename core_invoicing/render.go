@@ -0,0 +1,161 @@
+package core_invoicing
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+//go:embed templates/invoice.html
+var invoiceHTML string
+
+// invoiceView is the data passed to the invoice template.
+type invoiceView struct {
+	Invoice Invoice
+	Issuer  Issuer
+}
+
+// RenderHTML writes the invoice as a standalone HTML document.
+func (s *Service) RenderHTML(w io.Writer, inv Invoice) error {
+	tmpl, err := template.New("invoice").Funcs(template.FuncMap{
+		"money": func(m shared_money.Money) string { return m.Format(s.Locale) },
+		"date":  formatDate,
+	}).Parse(invoiceHTML)
+	if err != nil {
+		return fmt.Errorf("parse invoice template: %w", err)
+	}
+	if err := tmpl.Execute(w, invoiceView{Invoice: inv, Issuer: s.Issuer}); err != nil {
+		return fmt.Errorf("render invoice %s: %w", inv.Number, err)
+	}
+	return nil
+}
+
+func formatDate(v any) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format("2 January 2006")
+	case *time.Time:
+		if t != nil {
+			return t.Format("2 January 2006")
+		}
+	}
+	return ""
+}
+
+// RenderPDF lays the invoice out on A4 pages using the standard Helvetica
+// fonts, so no font files or external services are needed.
+func (s *Service) RenderPDF(inv Invoice) ([]byte, error) {
+	const (
+		left   = 50.0
+		right  = 545.0
+		top    = 790.0
+		bottom = 70.0
+	)
+	money := func(m shared_money.Money) string { return m.Format(s.Locale) }
+
+	doc := &pdfDoc{}
+	page := doc.addPage()
+	y := top
+
+	page.text(left, y, 16, true, s.Issuer.Name)
+	page.textRight(right, y, 20, true, "INVOICE")
+	y -= 22
+	page.textRight(right, y, 10, false, inv.Number)
+	y -= 14
+	page.textRight(right, y, 10, false, "Issued "+formatDate(inv.IssuedAt))
+	y -= 14
+	page.textRight(right, y, 10, false, "Status: "+strings.ToUpper(inv.Status))
+
+	y = top - 22
+	for _, line := range s.Issuer.Address {
+		page.text(left, y, 10, false, line)
+		y -= 14
+	}
+	if s.Issuer.Email != "" {
+		page.text(left, y, 10, false, s.Issuer.Email)
+		y -= 14
+	}
+	if s.Issuer.TaxID != "" {
+		page.text(left, y, 10, false, "Tax ID: "+s.Issuer.TaxID)
+		y -= 14
+	}
+
+	y = min(y, top-70) - 20
+	page.text(left, y, 10, true, "Bill to")
+	y -= 14
+	if inv.CustomerName != "" {
+		page.text(left, y, 10, false, inv.CustomerName)
+		y -= 14
+	}
+	page.text(left, y, 10, false, inv.CustomerEmail)
+	y -= 14
+	if inv.PeriodStart != nil {
+		page.text(left, y, 10, false, fmt.Sprintf("Period %s – %s", formatDate(inv.PeriodStart), formatDate(inv.PeriodEnd)))
+		y -= 14
+	}
+
+	header := func() {
+		y -= 16
+		page.text(left, y, 10, true, "Description")
+		page.textRight(360, y, 10, true, "Qty")
+		page.textRight(450, y, 10, true, "Unit price")
+		page.textRight(right, y, 10, true, "Amount")
+		y -= 6
+		page.rule(left, right, y, 1)
+		y -= 14
+	}
+	header()
+
+	for _, line := range inv.Lines {
+		if line.Kind == LineTax {
+			continue
+		}
+		if y < bottom {
+			page = doc.addPage()
+			y = top
+			header()
+		}
+		page.text(left, y, 10, false, truncate(line.Description, 250, 10))
+		page.textRight(360, y, 10, false, fmt.Sprint(line.Quantity))
+		page.textRight(450, y, 10, false, money(line.UnitAmount))
+		page.textRight(right, y, 10, false, money(line.Amount))
+		y -= 6
+		page.rule(left, right, y, 0.5)
+		y -= 14
+	}
+
+	if y < bottom+60 {
+		page = doc.addPage()
+		y = top
+	}
+	y -= 4
+	page.textRight(450, y, 10, false, "Subtotal")
+	page.textRight(right, y, 10, false, money(inv.Subtotal))
+	y -= 16
+	for _, line := range inv.Lines {
+		if line.Kind != LineTax {
+			continue
+		}
+		page.textRight(450, y, 10, false, line.Description)
+		page.textRight(right, y, 10, false, money(line.Amount))
+		y -= 16
+	}
+	page.rule(350, right, y+10, 1)
+	y -= 4
+	page.textRight(450, y, 11, true, "Total")
+	page.textRight(right, y, 11, true, money(inv.Total))
+	if inv.PaidAt != nil {
+		y -= 16
+		page.textRight(right, y, 10, false, "Paid "+formatDate(inv.PaidAt))
+	}
+
+	if s.Issuer.Footnote != "" {
+		page.text(left, bottom-30, 8, false, truncate(s.Issuer.Footnote, right-left, 8))
+	}
+	return doc.bytes(), nil
+}
@@ -0,0 +1,50 @@
+package core_invoicing
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the invoices and invoice_sequences tables in CoreDB if they do not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"invoices", `CREATE TABLE IF NOT EXISTS invoices (
+             id              TEXT PRIMARY KEY,
+             number          TEXT NOT NULL UNIQUE,
+             user_id         TEXT NOT NULL,
+             subscription_id TEXT,
+             plan_id         TEXT,
+             customer_name   TEXT NOT NULL DEFAULT '',
+             customer_email  TEXT NOT NULL DEFAULT '',
+             currency        TEXT NOT NULL,
+             subtotal        INTEGER NOT NULL,
+             tax             INTEGER NOT NULL,
+             total           INTEGER NOT NULL,
+             status          TEXT NOT NULL,
+             lines           TEXT NOT NULL,
+             period_start    TIMESTAMP,
+             period_end      TIMESTAMP,
+             issued_at       TIMESTAMP NOT NULL,
+             paid_at         TIMESTAMP
+         )`},
+		{"invoices user index", `CREATE INDEX IF NOT EXISTS idx_invoices_user
+             ON invoices (user_id, issued_at)`},
+		// A subscription period is invoiced once; a voided invoice can be reissued.
+		{"invoices period index", `CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_subscription_period
+             ON invoices (subscription_id, period_start)
+             WHERE subscription_id IS NOT NULL AND period_start IS NOT NULL AND status != 'void'`},
+		{"invoice_sequences", `CREATE TABLE IF NOT EXISTS invoice_sequences (
+             name       TEXT PRIMARY KEY,
+             next_value INTEGER NOT NULL
+         )`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package core_invoicing
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+// DefaultNumberPrefix starts every invoice number.
+const DefaultNumberPrefix = "INV-"
+
+// Issuer is the business printed at the top of every invoice.
+type Issuer struct {
+	Name     string   `json:"name"`
+	Address  []string `json:"address,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	TaxID    string   `json:"tax_id,omitempty"` // e.g. VAT registration number
+	LogoURL  string   `json:"logo_url,omitempty"`
+	Footnote string   `json:"footnote,omitempty"`
+}
+
+// Service issues, stores, renders and emails invoices.
+type Service struct {
+	db       *sql.DB
+	notifier *client_notify.EmailClient
+
+	Issuer Issuer
+	// NumberPrefix is prepended to the sequence; numbers restart per prefix.
+	NumberPrefix string
+	// NumberDigits zero-pads the sequence, e.g. INV-000042.
+	NumberDigits int
+	// Locale formats amounts on rendered invoices, e.g. "de-DE".
+	Locale string
+}
+
+// NewService creates an invoicing service. notifier may be nil to disable email.
+func NewService(db *sql.DB, notifier *client_notify.EmailClient, issuer Issuer) *Service {
+	return &Service{
+		db:           db,
+		notifier:     notifier,
+		Issuer:       issuer,
+		NumberPrefix: DefaultNumberPrefix,
+		NumberDigits: 6,
+		Locale:       "en-GB",
+	}
+}
+
+// Issue assigns the next sequential number to a draft invoice and stores it
+// as open. Numbers are allocated in the same transaction as the insert, so
+// there are no gaps from failed inserts. A subscription period that already
+// has an invoice that is not void returns that invoice and ErrAlreadyInvoiced,
+// so retried issues do not bill twice.
+func (s *Service) Issue(ctx context.Context, inv Invoice) (Invoice, error) {
+	if len(inv.Lines) == 0 {
+		return Invoice{}, ErrEmptyInvoice
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return Invoice{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	lines, err := json.Marshal(inv.Lines)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("marshal invoice lines: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO invoice_sequences (name, next_value) VALUES (?, 2)
+         ON CONFLICT (name) DO UPDATE SET next_value = next_value + 1
+         RETURNING next_value - 1`,
+		s.NumberPrefix,
+	).Scan(&seq); err != nil {
+		return Invoice{}, fmt.Errorf("allocate invoice number: %w", err)
+	}
+
+	inv.ID = id
+	inv.Number = fmt.Sprintf("%s%0*d", s.NumberPrefix, s.NumberDigits, seq)
+	inv.Status = StatusOpen
+	inv.IssuedAt = time.Now().UTC()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO invoices (id, number, user_id, subscription_id, plan_id, customer_name, customer_email, currency,
+                               subtotal, tax, total, status, lines, period_start, period_end, issued_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.ID, inv.Number, inv.UserID, inv.SubscriptionID, inv.PlanID, inv.CustomerName, inv.CustomerEmail, inv.Currency,
+		inv.Subtotal.Amount, inv.Tax.Amount, inv.Total.Amount, inv.Status, string(lines),
+		nullTime(inv.PeriodStart), nullTime(inv.PeriodEnd), shared_helpers.FormatDBTime(inv.IssuedAt),
+	); err != nil {
+		if shared_helpers.IsUniqueViolation(err) && inv.SubscriptionID != "" && inv.PeriodStart != nil {
+			tx.Rollback()
+			return s.existingForPeriod(ctx, inv.SubscriptionID, *inv.PeriodStart)
+		}
+		return Invoice{}, fmt.Errorf("insert invoice: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Invoice{}, fmt.Errorf("commit invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// existingForPeriod returns the invoice that is not void for a subscription
+// period, with ErrAlreadyInvoiced.
+func (s *Service) existingForPeriod(ctx context.Context, subscriptionID string, periodStart time.Time) (Invoice, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+`
+           FROM invoices
+          WHERE subscription_id = ? AND period_start = ? AND status != ?`,
+		subscriptionID, shared_helpers.FormatDBTime(periodStart), StatusVoid,
+	)
+	inv, err := scanInvoice(row)
+	if err != nil {
+		return Invoice{}, fmt.Errorf("query invoice for subscription %s: %w", subscriptionID, err)
+	}
+	return inv, ErrAlreadyInvoiced
+}
+
+// MarkPaid records payment of an open invoice.
+func (s *Service) MarkPaid(ctx context.Context, invoiceID string, paidAt time.Time) error {
+	return s.setStatus(ctx, invoiceID, StatusPaid, &paidAt)
+}
+
+// Void cancels an open invoice. Its number stays allocated.
+func (s *Service) Void(ctx context.Context, invoiceID string) error {
+	return s.setStatus(ctx, invoiceID, StatusVoid, nil)
+}
+
+func (s *Service) setStatus(ctx context.Context, invoiceID, status string, paidAt *time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE invoices SET status = ?, paid_at = COALESCE(?, paid_at) WHERE id = ? AND status = ?`,
+		status, nullTime(paidAt), invoiceID, StatusOpen,
+	)
+	if err != nil {
+		return fmt.Errorf("update invoice: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var current string
+		err := s.db.QueryRowContext(ctx, `SELECT status FROM invoices WHERE id = ?`, invoiceID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrInvoiceNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("query invoice status: %w", err)
+		}
+		return fmt.Errorf("%w: invoice is %s", ErrInvoiceNotOpen, current)
+	}
+	return nil
+}
+
+// Get returns an invoice by ID.
+func (s *Service) Get(ctx context.Context, invoiceID string) (Invoice, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+`
+           FROM invoices
+          WHERE id = ?`,
+		invoiceID,
+	)
+	inv, err := scanInvoice(row)
+	if err == sql.ErrNoRows {
+		return Invoice{}, ErrInvoiceNotFound
+	}
+	return inv, err
+}
+
+// ListForUser returns the invoices of userID, newest first.
+func (s *Service) ListForUser(ctx context.Context, userID string) ([]Invoice, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+invoiceColumns+`
+           FROM invoices
+          WHERE user_id = ?
+          ORDER BY issued_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, rows.Err()
+}
+
+// Email sends the invoice to its customer with the PDF attached. link is an
+// optional URL where the invoice can be viewed online.
+func (s *Service) Email(ctx context.Context, inv Invoice, link string) error {
+	if s.notifier == nil {
+		return fmt.Errorf("core_invoicing: no notifier configured")
+	}
+	if inv.CustomerEmail == "" {
+		return fmt.Errorf("invoice %s has no customer email", inv.Number)
+	}
+	var html bytes.Buffer
+	if err := s.RenderHTML(&html, inv); err != nil {
+		return err
+	}
+	pdf, err := s.RenderPDF(inv)
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("Invoice %s for %s is attached.", inv.Number, inv.Total.Format(s.Locale))
+	if link != "" {
+		text += "\n\nView it online: " + link
+	}
+	subject := "Invoice " + inv.Number
+	if s.Issuer.Name != "" {
+		subject += " from " + s.Issuer.Name
+	}
+	_, err = s.notifier.SendEmail(ctx, client_notify.Email{
+		To:      []string{inv.CustomerEmail},
+		Subject: subject,
+		Text:    text,
+		HTML:    html.String(),
+		Attachments: []client_notify.Attachment{{
+			Filename:    inv.Number + ".pdf",
+			ContentType: "application/pdf",
+			Content:     bytes.NewReader(pdf),
+		}},
+	})
+	return err
+}
+
+const invoiceColumns = `id, number, user_id, subscription_id, plan_id, customer_name, customer_email, currency,
+       subtotal, tax, total, status, lines, period_start, period_end, issued_at, paid_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvoice(row scanner) (Invoice, error) {
+	var (
+		inv                            Invoice
+		subscriptionID, planID         sql.NullString
+		subtotal, tax, total           int64
+		lines, issuedAt                string
+		periodStart, periodEnd, paidAt sql.NullString
+	)
+	if err := row.Scan(&inv.ID, &inv.Number, &inv.UserID, &subscriptionID, &planID, &inv.CustomerName, &inv.CustomerEmail,
+		&inv.Currency, &subtotal, &tax, &total, &inv.Status, &lines, &periodStart, &periodEnd,
+		&issuedAt, &paidAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Invoice{}, err
+		}
+		return Invoice{}, fmt.Errorf("scan invoice: %w", err)
+	}
+	if err := json.Unmarshal([]byte(lines), &inv.Lines); err != nil {
+		return Invoice{}, fmt.Errorf("decode invoice lines: %w", err)
+	}
+	inv.Subtotal = shared_money.New(subtotal, inv.Currency)
+	inv.Tax = shared_money.New(tax, inv.Currency)
+	inv.Total = shared_money.New(total, inv.Currency)
+	inv.SubscriptionID = subscriptionID.String
+	inv.PlanID = planID.String
+	inv.PeriodStart = shared_helpers.ParseNullDBTime(periodStart)
+	inv.PeriodEnd = shared_helpers.ParseNullDBTime(periodEnd)
+	inv.IssuedAt = shared_helpers.ParseDBTime(issuedAt)
+	inv.PaidAt = shared_helpers.ParseNullDBTime(paidAt)
+	return inv, nil
+}
+
+func nullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return shared_helpers.FormatDBTime(*t)
+}
@@ -0,0 +1,87 @@
+package core_invoicing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_subscriptions"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestIssueNumbering(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(testDB(t), nil, Issuer{Name: "Hstles"})
+	// A prefix per run starts a fresh sequence.
+	s.NumberPrefix = "T" + time.Now().Format("150405.000000") + "-"
+	s.NumberDigits = 4
+
+	draft, err := Build(testSub, testPlan, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"0001", "0002", "0003"} {
+		inv, err := s.Issue(ctx, draft)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Number != s.NumberPrefix+want || inv.Status != StatusOpen {
+			t.Errorf("invoice %d = %s %s, want %s%s open", i, inv.Number, inv.Status, s.NumberPrefix, want)
+		}
+	}
+
+	draft.Lines = nil
+	if _, err := s.Issue(ctx, draft); !errors.Is(err, ErrEmptyInvoice) {
+		t.Errorf("Issue without lines = %v, want ErrEmptyInvoice", err)
+	}
+}
+
+func TestIssueOncePerPeriod(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(testDB(t), nil, Issuer{Name: "Hstles"})
+	sub := testSub
+	sub.ID = "sub_" + time.Now().Format("150405.000000000")
+	start := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+	draft, err := Build(sub, testPlan, BuildOptions{Period: shared_subscriptions.Period{Start: start, End: start.AddDate(0, 1, 0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.Issue(ctx, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Issue(ctx, draft)
+	if !errors.Is(err, ErrAlreadyInvoiced) || again.ID != first.ID {
+		t.Fatalf("second Issue = %s, %v; want %s and ErrAlreadyInvoiced", again.ID, err, first.ID)
+	}
+
+	// Once voided, the period can be invoiced again.
+	if err := s.Void(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	reissued, err := s.Issue(ctx, draft)
+	if err != nil || reissued.ID == first.ID {
+		t.Fatalf("Issue after voiding = %s, %v; want a new invoice", reissued.ID, err)
+	}
+}
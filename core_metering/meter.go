@@ -0,0 +1,393 @@
+package core_metering
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_entitlements"
+)
+
+// Thresholds are the fractions of a limit at which a usage event is emitted.
+var Thresholds = []float64{0.8, 1.0}
+
+// EventTypeUsageThreshold is the client_identity event type emitted when a threshold is crossed.
+const EventTypeUsageThreshold = client_identity.EventUsageThreshold
+
+const (
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxPending    = 500
+	// limitTTL is how long a known limit is trusted before it is resolved again.
+	limitTTL = time.Hour
+)
+
+type counterKey struct {
+	subjectType string
+	subjectID   string
+	metric      string
+	period      string
+}
+
+// knownLimit is a subject's limit for a counter, kept for threshold events.
+type knownLimit struct {
+	limit   int64
+	userID  string // who threshold events are recorded against
+	expires time.Time
+}
+
+// Meter buffers usage increments in memory and writes them to CoreDB in batches.
+type Meter struct {
+	db       *sql.DB
+	identity *client_identity.Client
+	apiKey   string
+
+	// FlushInterval is how often Start writes buffered increments.
+	FlushInterval time.Duration
+	// MaxPending triggers an early flush once this many distinct counters are buffered.
+	MaxPending int
+	// Entitlements resolves a subject's limits when a flush has to check a
+	// counter that no Quota call has seen recently, so metrics that are only
+	// Added still emit threshold events. Optional; see EntitlementsFrom.
+	Entitlements func(ctx context.Context, subject Subject) (shared_entitlements.Entitlements, error)
+
+	mu      sync.Mutex
+	pending map[counterKey]int64
+	limits  map[counterKey]knownLimit // used for threshold events
+	flushMu sync.Mutex
+	kick    chan struct{}
+}
+
+// NewMeter creates a Meter writing to db. identity and apiKey are used to emit
+// threshold events; pass a nil identity client to disable them.
+func NewMeter(db *sql.DB, identity *client_identity.Client, apiKey string) *Meter {
+	return &Meter{
+		db:            db,
+		identity:      identity,
+		apiKey:        apiKey,
+		FlushInterval: defaultFlushInterval,
+		MaxPending:    defaultMaxPending,
+		pending:       make(map[counterKey]int64),
+		limits:        make(map[counterKey]knownLimit),
+		kick:          make(chan struct{}, 1),
+	}
+}
+
+// Add records n units of metric against subject in the current period.
+// The increment is buffered until the next flush.
+func (m *Meter) Add(subject Subject, metric Metric, n int64) {
+	if n == 0 {
+		return
+	}
+	key := counterKey{subject.Type, subject.ID, metric.Name, metric.Period(time.Now())}
+	m.mu.Lock()
+	m.pending[key] += n
+	full := len(m.pending) >= m.MaxPending
+	m.mu.Unlock()
+
+	if full {
+		select {
+		case m.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start flushes buffered increments every FlushInterval until ctx is cancelled,
+// then performs a final flush.
+func (m *Meter) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Use a fresh context so the final flush is not cancelled with the loop.
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := m.Flush(flushCtx); err != nil {
+				log.Printf("core_metering: final flush failed: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		case <-m.kick:
+		}
+		if err := m.Flush(ctx); err != nil {
+			log.Printf("core_metering: flush failed: %v", err)
+		}
+	}
+}
+
+// Flush writes all buffered increments in a single transaction. On failure the
+// increments are returned to the buffer so they are retried on the next flush.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	batch := m.pending
+	m.pending = make(map[counterKey]int64)
+	m.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	totals, err := m.writeBatch(ctx, batch)
+	if err != nil {
+		m.mu.Lock()
+		for key, n := range batch {
+			m.pending[key] += n
+		}
+		m.mu.Unlock()
+		return err
+	}
+
+	if m.identity == nil {
+		return nil
+	}
+	resolved := make(map[Subject]*shared_entitlements.Entitlements)
+	for key, after := range totals {
+		m.checkThresholds(ctx, key, after-batch[key], after, resolved)
+	}
+	m.mu.Lock()
+	m.pruneLimitsLocked(time.Now())
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Meter) writeBatch(ctx context.Context, batch map[counterKey]int64) (map[counterKey]int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin usage flush: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO usage_counters (subject_type, subject_id, metric, period, value, updated_at)
+         VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+         ON CONFLICT (subject_type, subject_id, metric, period)
+         DO UPDATE SET value = usage_counters.value + excluded.value,
+                       updated_at = CURRENT_TIMESTAMP
+         RETURNING value`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("prepare usage upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	totals := make(map[counterKey]int64, len(batch))
+	for key, n := range batch {
+		var total int64
+		if err := stmt.QueryRowContext(ctx, key.subjectType, key.subjectID, key.metric, key.period, n).Scan(&total); err != nil {
+			return nil, fmt.Errorf("upsert usage %s/%s: %w", key.subjectID, key.metric, err)
+		}
+		totals[key] = total
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit usage flush: %w", err)
+	}
+	return totals, nil
+}
+
+// Usage returns the recorded usage of metric for subject in the current period,
+// including increments that have not been flushed yet.
+func (m *Meter) Usage(ctx context.Context, subject Subject, metric Metric) (int64, error) {
+	key := counterKey{subject.Type, subject.ID, metric.Name, metric.Period(time.Now())}
+
+	var stored int64
+	err := m.db.QueryRowContext(ctx,
+		`SELECT value
+           FROM usage_counters
+          WHERE subject_type = ? AND subject_id = ? AND metric = ? AND period = ?`,
+		key.subjectType, key.subjectID, key.metric, key.period,
+	).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("query usage %s/%s: %w", subject.ID, metric.Name, err)
+	}
+
+	m.mu.Lock()
+	stored += m.pending[key]
+	m.mu.Unlock()
+	return stored, nil
+}
+
+// Quota describes usage of a metric against its plan limit.
+type Quota struct {
+	Metric    string    `json:"metric"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	Unlimited bool      `json:"unlimited"`
+	ResetsAt  time.Time `json:"resets_at,omitempty"`
+}
+
+// Exceeded reports whether no quota remains.
+func (q Quota) Exceeded() bool {
+	return !q.Unlimited && q.Remaining <= 0
+}
+
+// Quota computes the remaining quota of metric for subject against e.
+// Metrics the plan declares no limit for are unlimited, unless e is not
+// backed by a subscription that grants access, in which case the limit is zero.
+func (m *Meter) Quota(ctx context.Context, subject Subject, metric Metric, e shared_entitlements.Entitlements) (Quota, error) {
+	used, err := m.Usage(ctx, subject, metric)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	now := time.Now()
+	limit := limitOf(e, metric.Name)
+	q := Quota{
+		Metric:    metric.Name,
+		Used:      used,
+		Limit:     limit,
+		Unlimited: limit == shared_entitlements.Unlimited,
+		ResetsAt:  metric.PeriodEnd(now),
+	}
+	if !q.Unlimited {
+		q.Remaining = max(limit-used, 0)
+	}
+
+	// Remember the limit so flushes can tell when a threshold is crossed.
+	key := counterKey{subject.Type, subject.ID, metric.Name, metric.Period(now)}
+	m.mu.Lock()
+	m.limits[key] = newKnownLimit(key, limit, e, now)
+	m.mu.Unlock()
+	return q, nil
+}
+
+// limitOf returns e's limit for metric, see Quota.
+func limitOf(e shared_entitlements.Entitlements, metric string) int64 {
+	limit, ok := e.Limit(metric)
+	switch {
+	case ok:
+		return limit
+	case e.Active:
+		return shared_entitlements.Unlimited
+	default:
+		return 0
+	}
+}
+
+func newKnownLimit(key counterKey, limit int64, e shared_entitlements.Entitlements, now time.Time) knownLimit {
+	userID := e.OwnerID
+	if key.subjectType == SubjectUser {
+		userID = key.subjectID
+	}
+	expires := now.Add(limitTTL)
+	if end := periodEnd(key.period); !end.IsZero() && end.Before(expires) {
+		expires = end
+	}
+	return knownLimit{limit: limit, userID: userID, expires: expires}
+}
+
+// periodEnd returns when the counter period key ends, or the zero time for
+// lifetime counters.
+func periodEnd(period string) time.Time {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// pruneLimitsLocked drops limits that expired or belong to a past period.
+// m.mu must be held.
+func (m *Meter) pruneLimitsLocked(now time.Time) {
+	for key, known := range m.limits {
+		if !now.Before(known.expires) {
+			delete(m.limits, key)
+		}
+	}
+}
+
+// limitFor returns the limit of key, from an earlier Quota call or resolved
+// through Entitlements. resolved holds the entitlements looked up during the
+// current flush, so each subject is resolved once.
+func (m *Meter) limitFor(ctx context.Context, key counterKey, resolved map[Subject]*shared_entitlements.Entitlements) (knownLimit, bool) {
+	now := time.Now()
+	m.mu.Lock()
+	known, ok := m.limits[key]
+	m.mu.Unlock()
+	if ok && now.Before(known.expires) {
+		return known, true
+	}
+	if m.Entitlements == nil {
+		return knownLimit{}, false
+	}
+
+	subject := Subject{Type: key.subjectType, ID: key.subjectID}
+	e, seen := resolved[subject]
+	if !seen {
+		got, err := m.Entitlements(ctx, subject)
+		if err != nil {
+			log.Printf("core_metering: resolve entitlements for %s %s: %v", subject.Type, subject.ID, err)
+		} else {
+			e = &got
+		}
+		resolved[subject] = e
+	}
+	if e == nil {
+		return knownLimit{}, false
+	}
+	known = newKnownLimit(key, limitOf(*e, key.metric), *e, now)
+	m.mu.Lock()
+	m.limits[key] = known
+	m.mu.Unlock()
+	return known, true
+}
+
+func (m *Meter) checkThresholds(ctx context.Context, key counterKey, before, after int64, resolved map[Subject]*shared_entitlements.Entitlements) {
+	if after <= before {
+		return
+	}
+	known, ok := m.limitFor(ctx, key, resolved)
+	if !ok || known.limit <= 0 {
+		return
+	}
+
+	for _, t := range Thresholds {
+		mark := int64(float64(known.limit) * t)
+		if before < mark && after >= mark {
+			m.emitThreshold(ctx, key, known, t, after)
+		}
+	}
+}
+
+func (m *Meter) emitThreshold(ctx context.Context, key counterKey, known knownLimit, threshold float64, used int64) {
+	limit := known.limit
+	description := fmt.Sprintf("%s usage reached %d%% of limit (%d/%d)", key.metric, int(threshold*100), used, limit)
+	payload := client_identity.UsageThresholdData{
+		SubjectType: key.subjectType,
+		SubjectID:   key.subjectID,
+		Metric:      key.metric,
+		Period:      key.period,
+		Threshold:   threshold,
+		Used:        used,
+		Limit:       limit,
+	}
+	if _, code, err := m.identity.CreateTypedEvent(ctx, m.apiKey, known.userID, description, payload); err != nil || code >= 300 {
+		log.Printf("core_metering: emit threshold event for %s/%s failed: status=%d err=%v", key.subjectID, key.metric, code, err)
+	}
+}
+
+// EntitlementsFrom returns a Meter.Entitlements func that resolves users and
+// organisations with resolver, signed in as its Session service account.
+func EntitlementsFrom(resolver *shared_entitlements.Resolver) func(ctx context.Context, subject Subject) (shared_entitlements.Entitlements, error) {
+	return func(ctx context.Context, subject Subject) (shared_entitlements.Entitlements, error) {
+		var cookies []*http.Cookie
+		if resolver.Session != nil {
+			var err error
+			if cookies, err = resolver.Session(ctx); err != nil {
+				return shared_entitlements.Entitlements{}, fmt.Errorf("service session: %w", err)
+			}
+		}
+		if subject.Type == SubjectOrganisation {
+			return resolver.ForOrganisation(ctx, cookies, subject.ID)
+		}
+		return resolver.ForUser(ctx, cookies, subject.ID)
+	}
+}
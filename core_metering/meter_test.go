@@ -0,0 +1,249 @@
+package core_metering
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_entitlements"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+// eventRecorder is an identity service that records the events it is sent.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []client_identity.CreateEventRequest
+}
+
+func (rec *eventRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req client_identity.CreateEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec.mu.Lock()
+	rec.events = append(rec.events, req)
+	rec.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(client_identity.Event{ID: "evt_1", UserID: req.UserID, Type: req.Type})
+}
+
+func (rec *eventRecorder) thresholds(t *testing.T) []client_identity.UsageThresholdData {
+	t.Helper()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var out []client_identity.UsageThresholdData
+	for _, ev := range rec.events {
+		var d client_identity.UsageThresholdData
+		if err := json.Unmarshal([]byte(ev.Metadata), &d); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func newTestMeter(t *testing.T, db *sql.DB) (*Meter, *eventRecorder) {
+	t.Helper()
+	rec := &eventRecorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	return NewMeter(db, client_identity.NewClient(srv.URL), "key"), rec
+}
+
+func plan(features ...string) shared_entitlements.Entitlements {
+	e := shared_entitlements.FromPlan("usr_1", client_identity.Plan{ID: "pro", Features: features})
+	e.Active = true
+	return e
+}
+
+func TestLimitOf(t *testing.T) {
+	tests := []struct {
+		name string
+		e    shared_entitlements.Entitlements
+		want int64
+	}{
+		{"declared", plan("api_calls_monthly=100"), 100},
+		{"unlimited", plan("api_calls_monthly=unlimited"), shared_entitlements.Unlimited},
+		{"undeclared", plan("sso"), shared_entitlements.Unlimited},
+		{"no subscription", shared_entitlements.Entitlements{}, 0},
+	}
+	for _, tt := range tests {
+		if got := limitOf(tt.e, MetricAPICalls.Name); got != tt.want {
+			t.Errorf("%s: limit = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMetricPeriods(t *testing.T) {
+	at := time.Date(2026, time.December, 31, 23, 0, 0, 0, time.UTC)
+	if got := MetricAPICalls.Period(at); got != "2026-12" {
+		t.Errorf("Period = %q", got)
+	}
+	if got, want := MetricAPICalls.PeriodEnd(at), time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("PeriodEnd = %v, want %v", got, want)
+	}
+	if !periodEnd("2026-12").Equal(MetricAPICalls.PeriodEnd(at)) {
+		t.Errorf("periodEnd(2026-12) = %v", periodEnd("2026-12"))
+	}
+	if MetricStorageBytes.Period(at) != PeriodLifetime || !periodEnd(PeriodLifetime).IsZero() {
+		t.Error("lifetime metrics have a period end")
+	}
+}
+
+func TestThresholdsForAddOnlyMetrics(t *testing.T) {
+	m, rec := newTestMeter(t, nil)
+	var lookups int
+	m.Entitlements = func(_ context.Context, subject Subject) (shared_entitlements.Entitlements, error) {
+		lookups++
+		e := plan("storage_bytes=100")
+		e.SubjectID, e.OwnerID = subject.ID, "usr_owner"
+		return e, nil
+	}
+	ctx := context.Background()
+	resolved := map[Subject]*shared_entitlements.Entitlements{}
+	key := counterKey{SubjectOrganisation, "org_1", MetricStorageBytes.Name, PeriodLifetime}
+
+	m.checkThresholds(ctx, key, 50, 85, resolved)
+	m.checkThresholds(ctx, counterKey{SubjectOrganisation, "org_1", "seats", PeriodLifetime}, 0, 1, resolved)
+	if lookups != 1 {
+		t.Errorf("resolved entitlements %d times in one flush, want once", lookups)
+	}
+	m.checkThresholds(ctx, key, 85, 120, map[Subject]*shared_entitlements.Entitlements{})
+	if lookups != 1 {
+		t.Errorf("resolved entitlements %d times, want the limit reused", lookups)
+	}
+
+	got := rec.thresholds(t)
+	if len(got) != 2 || got[0].Threshold != 0.8 || got[1].Threshold != 1.0 || got[1].Limit != 100 {
+		t.Fatalf("threshold events = %+v, want 80%% then 100%% of 100", got)
+	}
+	for _, ev := range rec.events {
+		if ev.UserID != "usr_owner" {
+			t.Errorf("organisation event recorded against %q, want the owner", ev.UserID)
+		}
+	}
+}
+
+func TestPruneLimits(t *testing.T) {
+	m, _ := newTestMeter(t, nil)
+	now := time.Date(2026, time.March, 31, 23, 30, 0, 0, time.UTC)
+	e := plan("api_calls_monthly=10")
+	march := counterKey{SubjectUser, "usr_1", MetricAPICalls.Name, "2026-03"}
+	lifetime := counterKey{SubjectUser, "usr_1", MetricStorageBytes.Name, PeriodLifetime}
+	m.limits[march] = newKnownLimit(march, 10, e, now)
+	m.limits[lifetime] = newKnownLimit(lifetime, 10, e, now)
+
+	m.pruneLimitsLocked(now.Add(time.Minute))
+	if len(m.limits) != 2 {
+		t.Fatalf("pruned %v too early", m.limits)
+	}
+	m.pruneLimitsLocked(now.Add(31 * time.Minute))
+	if _, ok := m.limits[march]; ok {
+		t.Error("limit for a past period was kept")
+	}
+	m.pruneLimitsLocked(now.Add(limitTTL + time.Minute))
+	if len(m.limits) != 0 {
+		t.Errorf("limits = %v, want all expired", m.limits)
+	}
+}
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQuotaAndFlush(t *testing.T) {
+	ctx := context.Background()
+	m, rec := newTestMeter(t, testDB(t))
+	subject := User("usr_quota_" + time.Now().Format("150405.000000"))
+	e := plan("api_calls_monthly=10")
+
+	q, err := m.Quota(ctx, subject, MetricAPICalls, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Limit != 10 || q.Remaining != 10 || q.Exceeded() {
+		t.Fatalf("Quota = %+v", q)
+	}
+	m.Add(subject, MetricAPICalls, 8)
+	if err := m.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.thresholds(t); len(got) != 1 || got[0].Threshold != 0.8 || rec.events[0].UserID != subject.ID {
+		t.Errorf("threshold events = %+v", got)
+	}
+	m.Add(subject, MetricAPICalls, 2)
+	if q, err = m.Quota(ctx, subject, MetricAPICalls, e); err != nil || !q.Exceeded() {
+		t.Errorf("Quota with pending usage = %+v, %v; want exceeded", q, err)
+	}
+	if q, err = m.Quota(ctx, subject, MetricAPICalls, plan("sso")); err != nil || !q.Unlimited || q.Exceeded() {
+		t.Errorf("Quota without a declared limit = %+v, %v; want unlimited", q, err)
+	}
+}
+
+// orgIdentity serves one organisation owned by usr_owner, whose plan allows
+// one API call a month.
+type orgIdentity struct{}
+
+func (orgIdentity) GetActiveSubscription(context.Context, []*http.Cookie, string) (client_identity.Subscription, int, error) {
+	plan := &client_identity.Plan{ID: "team", Interval: "monthly", Features: []string{"api_calls_monthly=1"}}
+	return client_identity.Subscription{ID: "sub_1", PlanID: "team", Plan: plan, Status: "active", StartDate: time.Now().Add(-time.Hour)}, http.StatusOK, nil
+}
+
+func (orgIdentity) GetPlan(context.Context, string) (client_identity.Plan, int, error) {
+	return client_identity.Plan{}, http.StatusNotFound, nil
+}
+
+func (orgIdentity) AllMembers(context.Context, []*http.Cookie, string, client_identity.ListOptions) iter.Seq2[client_identity.Member, error] {
+	return func(yield func(client_identity.Member, error) bool) {
+		yield(client_identity.Member{UserID: "usr_owner", Role: "owner", Status: "active"}, nil)
+	}
+}
+
+func TestEnforceOrgQuota(t *testing.T) {
+	resolver := shared_entitlements.NewResolver(orgIdentity{}, 0)
+	serve := func(m *Meter, orgIDVar, path string) int {
+		router := mux.NewRouter()
+		router.Handle("/orgs/{org_id}", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		router.Use(m.EnforceOrgQuota(MetricAPICalls, 1, resolver, orgIDVar))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	m, _ := newTestMeter(t, nil)
+	if code := serve(m, "missing", "/orgs/org_1"); code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 without the organisation route variable", code)
+	}
+
+	m, _ = newTestMeter(t, testDB(t))
+	path := "/orgs/org_" + time.Now().Format("150405.000000")
+	if code := serve(m, "org_id", path); code != http.StatusOK {
+		t.Fatalf("first call = %d, want 200", code)
+	}
+	if code := serve(m, "org_id", path); code != http.StatusTooManyRequests {
+		t.Errorf("second call = %d, want 429 once the organisation's quota is used", code)
+	}
+}
@@ -0,0 +1,58 @@
+package core_metering
+
+import (
+	"time"
+
+	"github.com/hstles/go-sdk/shared_entitlements"
+)
+
+// PeriodLifetime is the period key for counters that never reset.
+const PeriodLifetime = "lifetime"
+
+// Subject types
+const (
+	SubjectUser         = "user"
+	SubjectOrganisation = "organisation"
+)
+
+// Subject identifies who usage is recorded against.
+type Subject struct {
+	Type string
+	ID   string
+}
+
+// User returns the Subject for a user ID.
+func User(userID string) Subject { return Subject{Type: SubjectUser, ID: userID} }
+
+// Organisation returns the Subject for an organisation ID.
+func Organisation(orgID string) Subject { return Subject{Type: SubjectOrganisation, ID: orgID} }
+
+// Metric is a metered quantity. Its Name matches the plan limit it is checked against.
+type Metric struct {
+	Name    string
+	Monthly bool // counters reset at the start of each calendar month (UTC)
+}
+
+var (
+	// MetricStorageBytes is a gauge of bytes stored; record negative amounts on delete.
+	MetricStorageBytes = Metric{Name: shared_entitlements.LimitStorageBytes}
+	// MetricAPICalls counts API calls per calendar month.
+	MetricAPICalls = Metric{Name: shared_entitlements.LimitAPICallsMonthly, Monthly: true}
+)
+
+// Period returns the counter period key containing t.
+func (m Metric) Period(t time.Time) string {
+	if !m.Monthly {
+		return PeriodLifetime
+	}
+	return t.UTC().Format("2006-01")
+}
+
+// PeriodEnd returns when the period containing t ends, or the zero time for lifetime metrics.
+func (m Metric) PeriodEnd(t time.Time) time.Time {
+	if !m.Monthly {
+		return time.Time{}
+	}
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,98 @@
+package core_metering
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/shared_entitlements"
+	"github.com/hstles/go-sdk/shared_i18n"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// EnforceQuota returns middleware that rejects session users who have used up
+// their quota of metric with 429 Too Many Requests, and records cost units of
+// usage for every request that is let through.
+func (m *Meter) EnforceQuota(metric Metric, cost int64, resolver *shared_entitlements.Resolver) mux.MiddlewareFunc {
+	return m.enforce(metric, cost, func(r *http.Request) (Subject, shared_entitlements.Entitlements, int, error) {
+		userID, ok := shared_utilities.GetUserIDFromContext(r)
+		if !ok {
+			return Subject{}, shared_entitlements.Entitlements{}, http.StatusUnauthorized, nil
+		}
+		e, err := resolver.ForUser(r.Context(), r.Cookies(), userID)
+		return User(userID), e, http.StatusOK, err
+	})
+}
+
+// EnforceOrgQuota is EnforceQuota for the organisation named by the orgIDVar
+// route variable, whose usage is shared by all of its members.
+func (m *Meter) EnforceOrgQuota(metric Metric, cost int64, resolver *shared_entitlements.Resolver, orgIDVar string) mux.MiddlewareFunc {
+	return m.enforce(metric, cost, func(r *http.Request) (Subject, shared_entitlements.Entitlements, int, error) {
+		orgID := mux.Vars(r)[orgIDVar]
+		if orgID == "" {
+			return Subject{}, shared_entitlements.Entitlements{}, http.StatusBadRequest, nil
+		}
+		e, err := resolver.ForOrganisation(r.Context(), r.Cookies(), orgID)
+		return Organisation(orgID), e, http.StatusOK, err
+	})
+}
+
+// subjectResolver picks the subject a request's usage is recorded against.
+type subjectResolver func(r *http.Request) (Subject, shared_entitlements.Entitlements, int, error)
+
+func (m *Meter) enforce(metric Metric, cost int64, resolve subjectResolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject, e, code, err := resolve(r)
+			if err != nil {
+				log.Printf("EnforceQuota: resolve entitlements: %v", err)
+				shared_i18n.Error(w, r, "errors.quota_check_failed", http.StatusBadGateway)
+				return
+			}
+			switch code {
+			case http.StatusUnauthorized:
+				shared_i18n.Error(w, r, "errors.unauthorized", http.StatusUnauthorized)
+				return
+			case http.StatusBadRequest:
+				shared_i18n.Error(w, r, "errors.org_id_required", http.StatusBadRequest)
+				return
+			}
+
+			q, err := m.Quota(r.Context(), subject, metric, e)
+			if err != nil {
+				log.Printf("EnforceQuota: %v", err)
+				shared_i18n.Error(w, r, "errors.quota_check_failed", http.StatusInternalServerError)
+				return
+			}
+			if !q.Unlimited && q.Remaining < cost {
+				writeQuotaExceeded(w, r, q)
+				return
+			}
+
+			m.Add(subject, metric, cost)
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(q.Limit, 10))
+			if !q.Unlimited {
+				w.Header().Set("X-Quota-Remaining", strconv.FormatInt(q.Remaining-cost, 10))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeQuotaExceeded(w http.ResponseWriter, r *http.Request, q Quota) {
+	if !q.ResetsAt.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(q.ResetsAt).Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Quota Quota  `json:"quota"`
+	}{
+		Error: shared_i18n.FromRequest(r).T("errors.quota_exceeded"),
+		Quota: q,
+	})
+}
@@ -0,0 +1,24 @@
+package core_metering
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the usage_counters table in CoreDB if it does not exist.
+func EnsureSchema(db *sql.DB) error {
+	if _, err := db.Exec(
+		`CREATE TABLE IF NOT EXISTS usage_counters (
+             subject_type TEXT    NOT NULL,
+             subject_id   TEXT    NOT NULL,
+             metric       TEXT    NOT NULL,
+             period       TEXT    NOT NULL,
+             value        INTEGER NOT NULL DEFAULT 0,
+             updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             PRIMARY KEY (subject_type, subject_id, metric, period)
+         )`,
+	); err != nil {
+		return fmt.Errorf("create usage_counters: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package core_models
+
+import (
+	"fmt"
+
+	"github.com/hstles/go-sdk/core_datastore"
+	"github.com/hstles/go-sdk/core_outbox"
+)
+
+// EnsureSchema creates the tables core_models writes to alongside users. Call
+// it at startup, before CreateUser queues its first welcome email.
+func EnsureSchema(mgr *core_datastore.Manager) error {
+	if err := core_outbox.EnsureSchema(mgr.CoreDB); err != nil {
+		return fmt.Errorf("ensure outbox schema: %w", err)
+	}
+	return nil
+}
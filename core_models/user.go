@@ -1,12 +1,15 @@
 package core_models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 
 	"github.com/hstles/go-sdk/core_datastore"
+	"github.com/hstles/go-sdk/core_outbox"
+	"github.com/hstles/go-sdk/shared_email"
 	"github.com/hstles/go-sdk/shared_helpers"
 )
 
@@ -16,7 +19,10 @@ type User struct {
 	Email        string
 }
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("a user with this email already exists")
+)
 
 // GetUserByID retrieves a user from CoreDB based on their hstles_user_id.
 func GetUserByID(mgr *core_datastore.Manager, userID string) (*User, error) {
@@ -40,14 +46,22 @@ func GetUserByID(mgr *core_datastore.Manager, userID string) (*User, error) {
 	return user, nil
 }
 
-// GetUserByEmail retrieves a user from CoreDB based on their email address.
+// GetUserByEmail retrieves a user from CoreDB based on their email address,
+// which is normalised with shared_email first. Invalid addresses fail with an
+// error wrapping shared_email.ErrInvalidAddress.
 func GetUserByEmail(mgr *core_datastore.Manager, email string) (*User, error) {
 	log.Printf("Entering GetUserByEmail with email: %s", email)
 
+	email, err := shared_email.Normalise(email)
+	if err != nil {
+		return nil, err
+	}
+
+	// COLLATE NOCASE also finds users stored before emails were normalised.
 	row := mgr.CoreDB.QueryRow(
 		`SELECT hstles_user_id, name, email
            FROM users
-          WHERE email = ?`,
+          WHERE email = ? COLLATE NOCASE`,
 		email,
 	)
 
@@ -62,9 +76,19 @@ func GetUserByEmail(mgr *core_datastore.Manager, email string) (*User, error) {
 	return user, nil
 }
 
-// CreateUser creates a new user in CoreDB and sends a welcome email.
+// CreateUser creates a new user in CoreDB and queues a welcome email in the
+// core_outbox within the same transaction, so the email is sent if and only
+// if the user is created. The email is stored normalised with shared_email;
+// if any user already has it, in any letter case, CreateUser fails with
+// ErrUserExists.
 func CreateUser(mgr *core_datastore.Manager, name, email string) (*User, error) {
 	log.Printf("Entering CreateUser with name: %s, email: %s", name, email)
+	ctx := context.Background()
+
+	email, err := shared_email.Normalise(email)
+	if err != nil {
+		return nil, err
+	}
 
 	// 1) Generate a new condensed UUID for the user
 	hstlesUserID, err := shared_helpers.GenerateCondensedUUID()
@@ -72,8 +96,31 @@ func CreateUser(mgr *core_datastore.Manager, name, email string) (*User, error)
 		return nil, fmt.Errorf("GenerateCondensedUUID: %w", err)
 	}
 
-	// 2) Insert the new user into the core users table
-	_, err = mgr.CoreDB.Exec(
+	tx, err := mgr.CoreDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 2) Refuse duplicates; COLLATE NOCASE also finds users stored before
+	// emails were normalised, which a unique index on email would miss.
+	var existingID string
+	err = tx.QueryRowContext(ctx,
+		`SELECT hstles_user_id
+           FROM users
+          WHERE email = ? COLLATE NOCASE
+          LIMIT 1`,
+		email,
+	).Scan(&existingID)
+	if err == nil {
+		return nil, ErrUserExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("query existing user: %w", err)
+	}
+
+	// 3) Insert the new user into the core users table
+	_, err = tx.ExecContext(ctx,
 		`INSERT INTO users (hstles_user_id, name, email)
                  VALUES (?,             ?,    ?)`,
 		hstlesUserID, name, email,
@@ -82,39 +129,72 @@ func CreateUser(mgr *core_datastore.Manager, name, email string) (*User, error)
 		return nil, fmt.Errorf("insert new user: %w", err)
 	}
 
-	// 3) Build the User object to return
+	// 4) Queue the welcome email; the core_outbox dispatcher sends it after commit
+	if _, err := core_outbox.EnqueueWelcomeEmailTx(ctx, tx, email, name); err != nil {
+		return nil, fmt.Errorf("queue welcome email: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit new user: %w", err)
+	}
+
+	// 5) Build the User object to return
 	newUser := &User{
 		HstlesUserID: hstlesUserID,
 		Name:         name,
 		Email:        email,
 	}
 
-	// 4) Send a welcome email (failure to send is non‐fatal)
-	// TODO: Implement email sending functionality
-	// smtpCfg := config.LoadSMTPConfig()
-	// mailer := services_email.NewMailer(smtpCfg)
-	// if mailErr := mailer.SendWelcomeHTMLEmail(email, name); mailErr != nil {
-	//     log.Printf("⚠️  warning: failed to send welcome email to %s: %v", email, mailErr)
-	// }
-	log.Printf("Welcome email sending not yet implemented for user: %s", email)
-
 	return newUser, nil
 }
 
-// UpdateUser updates an existing user's information in the core database.
+// UpdateUser updates an existing user's information in the core database,
+// storing the email normalised with shared_email. It returns ErrUserExists if
+// another user already has the email.
 func UpdateUser(mgr *core_datastore.Manager, user User) error {
 	log.Printf("Updating user: %s", user.HstlesUserID)
+	ctx := context.Background()
+
+	email, err := shared_email.Normalise(user.Email)
+	if err != nil {
+		return err
+	}
+	user.Email = email
+
+	tx, err := mgr.CoreDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Refuse another user's email, matching it the way CreateUser does.
+	var existingID string
+	err = tx.QueryRowContext(ctx,
+		`SELECT hstles_user_id
+           FROM users
+          WHERE email = ? COLLATE NOCASE AND hstles_user_id != ?
+          LIMIT 1`,
+		user.Email, user.HstlesUserID,
+	).Scan(&existingID)
+	if err == nil {
+		return ErrUserExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("query existing user: %w", err)
+	}
 
 	query := `
 		UPDATE users 
 		SET name = ?, email = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE hstles_user_id = ?
 	`
-	_, err := mgr.CoreDB.Exec(query, user.Name, user.Email, user.HstlesUserID)
+	_, err = tx.ExecContext(ctx, query, user.Name, user.Email, user.HstlesUserID)
 	if err != nil {
 		log.Printf("Error updating user: %v", err)
 		return fmt.Errorf("failed to update user: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user update: %w", err)
+	}
 
 	log.Printf("User successfully updated: %s", user.HstlesUserID)
 	return nil
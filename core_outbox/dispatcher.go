@@ -0,0 +1,318 @@
+package core_outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 50
+	defaultConcurrency  = 8
+	defaultMaxAttempts  = 10
+	defaultBaseBackoff  = 15 * time.Second
+	defaultMaxBackoff   = 2 * time.Hour
+	defaultLease        = 2 * time.Minute
+)
+
+// Handler delivers the payload of one kind of message.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the Dispatcher dead-letters the message at once
+// instead of retrying it, e.g. for a payload that cannot be decoded.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Dispatcher delivers due outbox messages through the handler registered
+// for their kind, retrying failures with exponential backoff and marking
+// them dead after MaxAttempts.
+type Dispatcher struct {
+	svc      *Service
+	handlers map[string]Handler
+
+	// PollInterval is how often Start looks for due messages.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of messages claimed per poll.
+	BatchSize int
+	// Concurrency is the number of messages delivered in parallel.
+	Concurrency int
+	// MaxAttempts is the number of failed attempts after which a message is dead.
+	MaxAttempts int
+	// BaseBackoff and MaxBackoff bound the delay before retry n: BaseBackoff*2^(n-1).
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed message is hidden from other dispatchers while it is delivered.
+	Lease time.Duration
+
+	kick chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher for the messages queued in svc. Register
+// handlers with Handle, HandleNotifications and HandleEmail before Start.
+func NewDispatcher(svc *Service) *Dispatcher {
+	return &Dispatcher{
+		svc:          svc,
+		handlers:     make(map[string]Handler),
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Concurrency:  defaultConcurrency,
+		MaxAttempts:  defaultMaxAttempts,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		Lease:        defaultLease,
+		kick:         make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler for kind, replacing any previous one.
+func (d *Dispatcher) Handle(kind string, h Handler) {
+	d.handlers[kind] = h
+}
+
+// HandleNotifications delivers KindNotification messages through n. A
+// message whose recipient has opted out of all its channels is marked sent;
+// one that failed on every channel because the address is suppressed or
+// missing is dead-lettered.
+func (d *Dispatcher) HandleNotifications(n client_notify.Notifier) {
+	d.Handle(KindNotification, func(ctx context.Context, payload json.RawMessage) error {
+		var p NotificationPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(fmt.Errorf("decode notification: %w", err))
+		}
+		res, err := n.Notify(ctx, p.To, p.Message)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, client_notify.ErrOptedOut):
+			// Nothing to deliver: the recipient turned off every channel.
+			return nil
+		case errors.Is(err, client_notify.ErrInvalidMessage) || errors.Is(err, client_notify.ErrNoChannels), undeliverable(res):
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// undeliverable reports whether every channel of res failed in a way that
+// retrying cannot fix: a suppressed address or none at all.
+func undeliverable(res client_notify.Result) bool {
+	for _, d := range res.Deliveries {
+		if !errors.Is(d.Err, client_notify.ErrSuppressed) && !errors.Is(d.Err, client_notify.ErrNoAddress) {
+			return false
+		}
+	}
+	return len(res.Deliveries) > 0
+}
+
+// HandleEmail delivers the notify service email kinds through c. Emails to
+// addresses c.Suppressed reports are dead-lettered without retrying.
+func (d *Dispatcher) HandleEmail(c *client_notify.EmailClient) {
+	d.Handle(KindWelcomeEmail, func(ctx context.Context, payload json.RawMessage) error {
+		var p WelcomeEmailPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(fmt.Errorf("decode welcome email: %w", err))
+		}
+		_, err := c.SendWelcomeEmail(ctx, p.To, p.UserName)
+		if errors.Is(err, client_notify.ErrSuppressed) || errors.Is(err, client_notify.ErrInvalidEmail) {
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// Kick wakes Start without waiting for the next poll, e.g. after a commit.
+func (d *Dispatcher) Kick() {
+	select {
+	case d.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start polls for due messages every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("core_outbox: dispatch run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.kick:
+		}
+	}
+}
+
+// RunOnce claims up to BatchSize due messages, delivers them and records
+// the outcome. It returns the number of messages attempted.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	due, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sem := make(chan struct{}, max(d.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, m := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(m Message) {
+			defer func() { <-sem; wg.Done() }()
+			if err := d.dispatch(ctx, m); err != nil {
+				log.Printf("core_outbox: message %s: %v", m.ID, err)
+			}
+		}(m)
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// claim selects due messages and leases each one by pushing its
+// next_attempt_at forward. The conditional UPDATE means only one dispatcher wins a message.
+func (d *Dispatcher) claim(ctx context.Context) ([]Message, error) {
+	now := time.Now().UTC()
+	rows, err := d.svc.db.QueryContext(ctx,
+		`SELECT `+messageColumns+`
+           FROM outbox_messages
+          WHERE status IN (?, ?) AND next_attempt_at <= ?
+          ORDER BY next_attempt_at
+          LIMIT ?`,
+		StatusPending, StatusFailed, shared_helpers.FormatDBTime(now), max(d.BatchSize, 1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query due outbox messages: %w", err)
+	}
+	var candidates []Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query due outbox messages: %w", err)
+	}
+
+	leaseUntil := now.Add(d.Lease)
+	lease := shared_helpers.FormatDBTime(leaseUntil)
+	var claimed []Message
+	for _, m := range candidates {
+		res, err := d.svc.db.ExecContext(ctx,
+			`UPDATE outbox_messages
+                SET next_attempt_at = ?
+              WHERE id = ? AND status = ? AND next_attempt_at = ?`,
+			lease, m.ID, m.Status, shared_helpers.FormatDBTime(m.NextAttemptAt),
+		)
+		if err != nil {
+			return claimed, fmt.Errorf("lease outbox message %s: %w", m.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			// finish matches on the lease to tell whether it still holds it.
+			m.NextAttemptAt = leaseUntil
+			claimed = append(claimed, m)
+		}
+	}
+	return claimed, nil
+}
+
+// Backoff returns the delay before the retry following failed attempt n (1-based), with up to 10% jitter.
+func (d *Dispatcher) Backoff(n int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < n && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.MaxBackoff)
+	if delay > 0 {
+		delay += time.Duration(rand.Int64N(int64(delay)/10 + 1))
+	}
+	return delay
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, m Message) error {
+	m.Attempts++
+	h, ok := d.handlers[m.Kind]
+	if !ok {
+		return d.finish(ctx, m, StatusDead, fmt.Sprintf("no handler for kind %q", m.Kind), time.Time{})
+	}
+
+	err := safeHandle(ctx, h, m.Payload)
+	var permanent permanentError
+	switch {
+	case err == nil:
+		return d.finish(ctx, m, StatusSent, "", time.Time{})
+	case errors.As(err, &permanent), m.Attempts >= d.MaxAttempts:
+		return d.finish(ctx, m, StatusDead, err.Error(), time.Time{})
+	default:
+		return d.finish(ctx, m, StatusFailed, err.Error(), time.Now().Add(d.Backoff(m.Attempts)))
+	}
+}
+
+func safeHandle(ctx context.Context, h Handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(ctx, payload)
+}
+
+// finish records the outcome of an attempt. next is only used for failed
+// messages. The update only applies while m's lease is held: if delivery
+// outlived Lease and another dispatcher claimed the message, that
+// dispatcher's outcome wins.
+func (d *Dispatcher) finish(ctx context.Context, m Message, status, errText string, next time.Time) error {
+	now := time.Now().UTC()
+	if next.IsZero() {
+		next = now
+	}
+	var sentAt any
+	if status == StatusSent {
+		sentAt = shared_helpers.FormatDBTime(now)
+	}
+	res, err := d.svc.db.ExecContext(ctx,
+		`UPDATE outbox_messages
+            SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?,
+                sent_at = COALESCE(?, sent_at)
+          WHERE id = ? AND next_attempt_at = ?`,
+		status, m.Attempts, nullString(errText), shared_helpers.FormatDBTime(next),
+		shared_helpers.FormatDBTime(now), sentAt, m.ID, shared_helpers.FormatDBTime(m.NextAttemptAt),
+	)
+	if err != nil {
+		return fmt.Errorf("update outbox message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		log.Printf("core_outbox: message %s: lease expired before its %s outcome was recorded", m.ID, status)
+	}
+	return nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
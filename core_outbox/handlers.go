@@ -0,0 +1,90 @@
+package core_outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// These are admin endpoints; mount them behind an administrator check.
+
+// ListHandler serves GET /api/admin/outbox. It accepts the page_size,
+// cursor, status and type (message kind) query parameters.
+func ListHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.List(r.Context(), client_identity.ListOptionsFromQuery(r.URL.Query()))
+		if err != nil {
+			writeOutboxError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetHandler serves GET /api/admin/outbox/{message_id}.
+func GetHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Get(r.Context(), mux.Vars(r)["message_id"])
+		if err != nil {
+			writeOutboxError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ReplayHandler serves POST /api/admin/outbox/{message_id}/replay and wakes
+// dispatcher, which may be nil, to deliver it straight away.
+func ReplayHandler(s *Service, dispatcher *Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Replay(r.Context(), mux.Vars(r)["message_id"])
+		if err != nil {
+			writeOutboxError(w, err)
+			return
+		}
+		if dispatcher != nil {
+			dispatcher.Kick()
+		}
+		writeJSON(w, http.StatusAccepted, resp)
+	}
+}
+
+// ReplayAllResponse is returned by POST /api/admin/outbox/replay.
+type ReplayAllResponse struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// ReplayAllHandler serves POST /api/admin/outbox/replay?status=dead|failed.
+func ReplayAllHandler(s *Service, dispatcher *Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := s.ReplayAll(r.Context(), r.URL.Query().Get("status"))
+		if err != nil {
+			writeOutboxError(w, err)
+			return
+		}
+		if dispatcher != nil && n > 0 {
+			dispatcher.Kick()
+		}
+		writeJSON(w, http.StatusAccepted, ReplayAllResponse{Replayed: n})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeOutboxError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrNotReplayable):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
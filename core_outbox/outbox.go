@@ -0,0 +1,283 @@
+// Package core_outbox is a transactional outbox for notifications: messages
+// are written to CoreDB in the same transaction as the change that causes
+// them and delivered afterwards by a Dispatcher, so they are neither lost
+// when sending fails nor sent for changes that roll back.
+package core_outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// Message statuses. Failed messages are retried; dead ones wait for a replay.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusDead    = "dead"
+)
+
+// Built-in message kinds, delivered by the handlers Dispatcher registers for them.
+const (
+	KindNotification = "notification"
+	KindWelcomeEmail = "email.welcome"
+)
+
+const defaultPageSize = 50
+
+var (
+	ErrMessageNotFound = errors.New("outbox message not found")
+	ErrNotReplayable   = errors.New("only failed or dead outbox messages can be replayed")
+)
+
+// Message is a queued outbox entry.
+type Message struct {
+	ID            string          `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+}
+
+// NotificationPayload is the payload of KindNotification.
+type NotificationPayload struct {
+	To      client_notify.Recipient `json:"to"`
+	Message client_notify.Message   `json:"message"`
+}
+
+// WelcomeEmailPayload is the payload of KindWelcomeEmail.
+type WelcomeEmailPayload = client_notify.WelcomeEmailRequest
+
+// Service enqueues and administers outbox messages.
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Enqueue queues a message in its own transaction. Prefer EnqueueTx when
+// the message accompanies a database change.
+func (s *Service) Enqueue(ctx context.Context, kind string, payload any) (Message, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Message{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m, err := EnqueueTx(ctx, tx, kind, payload)
+	if err != nil {
+		return m, err
+	}
+	if err := tx.Commit(); err != nil {
+		return m, fmt.Errorf("commit outbox message: %w", err)
+	}
+	return m, nil
+}
+
+// EnqueueTx queues a message within the caller's transaction, so it is only
+// delivered if the transaction commits.
+func EnqueueTx(ctx context.Context, tx *sql.Tx, kind string, payload any) (Message, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("marshal %s payload: %w", kind, err)
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return Message{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	now := time.Now().UTC()
+	m := Message{
+		ID:            id,
+		Kind:          kind,
+		Payload:       raw,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	ts := shared_helpers.FormatDBTime(now)
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox_messages (id, kind, payload, status, attempts, next_attempt_at, created_at, updated_at)
+         VALUES (?, ?, ?, ?, 0, ?, ?, ?)`,
+		m.ID, m.Kind, string(raw), m.Status, ts, ts, ts,
+	); err != nil {
+		return Message{}, fmt.Errorf("insert outbox message: %w", err)
+	}
+	return m, nil
+}
+
+// EnqueueNotificationTx queues a client_notify message. Its ID is fixed now
+// so that retries are recognisable as the same notification.
+func EnqueueNotificationTx(ctx context.Context, tx *sql.Tx, to client_notify.Recipient, msg client_notify.Message) (Message, error) {
+	if msg.ID == "" {
+		id, err := shared_helpers.GenerateCondensedUUID()
+		if err != nil {
+			return Message{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+		}
+		msg.ID = id
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now().UTC()
+	}
+	return EnqueueTx(ctx, tx, KindNotification, NotificationPayload{To: to, Message: msg})
+}
+
+// EnqueueWelcomeEmailTx queues the notify service's welcome email.
+func EnqueueWelcomeEmailTx(ctx context.Context, tx *sql.Tx, to, userName string) (Message, error) {
+	return EnqueueTx(ctx, tx, KindWelcomeEmail, WelcomeEmailPayload{To: to, UserName: userName})
+}
+
+// Get returns a message by ID.
+func (s *Service) Get(ctx context.Context, id string) (Message, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+`
+           FROM outbox_messages
+          WHERE id = ?`,
+		id,
+	)
+	m, err := scanMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Message{}, ErrMessageNotFound
+	}
+	return m, err
+}
+
+// List returns a page of messages, newest first, optionally filtered by
+// opts.Status and by kind in opts.EventType.
+func (s *Service) List(ctx context.Context, opts client_identity.ListOptions) (client_identity.Page[Message], error) {
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	size = min(size, client_identity.MaxPageSize)
+
+	query := `SELECT ` + messageColumns + `
+                FROM outbox_messages
+               WHERE 1 = 1`
+	var args []any
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.EventType != "" {
+		query += ` AND kind = ?`
+		args = append(args, opts.EventType)
+	}
+	if opts.Cursor != "" {
+		query += ` AND (created_at, id) < (SELECT created_at, id FROM outbox_messages WHERE id = ?)`
+		args = append(args, opts.Cursor)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, size+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return client_identity.Page[Message]{}, fmt.Errorf("query outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	page := client_identity.Page[Message]{Items: []Message{}}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return client_identity.Page[Message]{}, err
+		}
+		page.Items = append(page.Items, m)
+	}
+	if err := rows.Err(); err != nil {
+		return client_identity.Page[Message]{}, err
+	}
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextCursor = page.Items[size-1].ID
+	}
+	return page, nil
+}
+
+// Replay requeues a failed or dead message for immediate delivery with a
+// fresh attempt budget.
+func (s *Service) Replay(ctx context.Context, id string) (Message, error) {
+	now := shared_helpers.FormatDBTime(time.Now())
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE outbox_messages
+            SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+          WHERE id = ? AND status IN (?, ?)`,
+		StatusPending, now, now, id, StatusFailed, StatusDead,
+	)
+	if err != nil {
+		return Message{}, fmt.Errorf("replay outbox message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return Message{}, err
+		}
+		return Message{}, ErrNotReplayable
+	}
+	return s.Get(ctx, id)
+}
+
+// ReplayAll requeues every message with status, StatusDead by default or
+// StatusFailed, and returns how many were requeued.
+func (s *Service) ReplayAll(ctx context.Context, status string) (int64, error) {
+	if status == "" {
+		status = StatusDead
+	}
+	if status != StatusDead && status != StatusFailed {
+		return 0, ErrNotReplayable
+	}
+	now := shared_helpers.FormatDBTime(time.Now())
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE outbox_messages
+            SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+          WHERE status = ?`,
+		StatusPending, now, now, status,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("replay outbox messages: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+const messageColumns = `id, kind, payload, status, attempts, last_error, next_attempt_at, created_at, updated_at, sent_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (Message, error) {
+	var (
+		m                                   Message
+		payload                             string
+		lastError, sentAt                   sql.NullString
+		nextAttemptAt, createdAt, updatedAt string
+	)
+	if err := row.Scan(&m.ID, &m.Kind, &payload, &m.Status, &m.Attempts, &lastError,
+		&nextAttemptAt, &createdAt, &updatedAt, &sentAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Message{}, err
+		}
+		return Message{}, fmt.Errorf("scan outbox message: %w", err)
+	}
+	m.Payload = json.RawMessage(payload)
+	m.LastError = lastError.String
+	m.NextAttemptAt = shared_helpers.ParseDBTime(nextAttemptAt)
+	m.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	m.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	m.SentAt = shared_helpers.ParseNullDBTime(sentAt)
+	return m, nil
+}
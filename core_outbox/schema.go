@@ -0,0 +1,37 @@
+package core_outbox
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the outbox_messages table in CoreDB if it does not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"outbox_messages", `CREATE TABLE IF NOT EXISTS outbox_messages (
+             id              TEXT PRIMARY KEY,
+             kind            TEXT NOT NULL,
+             payload         TEXT NOT NULL,
+             status          TEXT NOT NULL,
+             attempts        INTEGER NOT NULL DEFAULT 0,
+             last_error      TEXT,
+             next_attempt_at TIMESTAMP NOT NULL,
+             created_at      TIMESTAMP NOT NULL,
+             updated_at      TIMESTAMP NOT NULL,
+             sent_at         TIMESTAMP
+         )`},
+		{"outbox_messages due index", `CREATE INDEX IF NOT EXISTS idx_outbox_messages_due
+             ON outbox_messages (status, next_attempt_at)`},
+		{"outbox_messages created index", `CREATE INDEX IF NOT EXISTS idx_outbox_messages_created
+             ON outbox_messages (created_at, id)`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
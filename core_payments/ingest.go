@@ -0,0 +1,480 @@
+package core_payments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_subscriptions"
+)
+
+// Payment event processing statuses
+const (
+	statusProcessing = "processing"
+	statusProcessed  = "processed"
+	statusFailed     = "failed"
+)
+
+// staleClaim is how long a "processing" claim is honoured before a retry may take it over.
+const staleClaim = 5 * time.Minute
+
+const maxWebhookBody = 1 << 20
+
+var (
+	ErrUnknownPrice    = errors.New("provider price is not mapped to a plan")
+	ErrUnknownCustomer = errors.New("provider customer is not linked to a user")
+	// ErrProvisioning is returned while another event is creating the
+	// client_identity subscription for the same provider subscription; the
+	// webhook fails so the provider retries once it exists.
+	ErrProvisioning = errors.New("subscription is being provisioned by another event")
+)
+
+// Identity is the part of the identity client the Ingestor uses. It is
+// satisfied by *client_identity.Client and *client_identity.CachedClient.
+type Identity interface {
+	CreateSubscription(ctx context.Context, cookies []*http.Cookie, req client_identity.CreateSubscriptionRequest) (client_identity.Subscription, int, error)
+	UpdateSubscription(ctx context.Context, cookies []*http.Cookie, subscriptionID string, req client_identity.UpdateSubscriptionRequest) (client_identity.Subscription, int, error)
+	CancelSubscription(ctx context.Context, cookies []*http.Cookie, subscriptionID string) (client_identity.Subscription, int, error)
+}
+
+// Ingestor verifies provider webhooks and applies them to client_identity
+// subscriptions exactly once per provider event ID.
+type Ingestor struct {
+	db       *sql.DB
+	provider Provider
+	identity Identity
+
+	// Prices maps provider price IDs to client_identity Plan IDs.
+	Prices map[string]string
+	// Session returns the session cookies of the service account used for
+	// subscription calls, which are protected endpoints.
+	Session func(ctx context.Context) ([]*http.Cookie, error)
+}
+
+// NewIngestor creates an Ingestor for provider. prices maps provider price IDs to Plan IDs.
+func NewIngestor(db *sql.DB, provider Provider, identity Identity, prices map[string]string, session func(ctx context.Context) ([]*http.Cookie, error)) *Ingestor {
+	return &Ingestor{
+		db:       db,
+		provider: provider,
+		identity: identity,
+		Prices:   prices,
+		Session:  session,
+	}
+}
+
+// ServeHTTP verifies and processes one provider webhook. Failures answer 500
+// so the provider retries; replays of processed events answer 200.
+func (in *Ingestor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := in.provider.VerifySignature(r.Header, body); err != nil {
+		log.Printf("core_payments: %s webhook rejected: %v", in.provider.Name(), err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	ev, err := in.provider.ParseEvent(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := in.Process(r.Context(), ev); err != nil {
+		log.Printf("core_payments: %s event %s (%s) failed: %v", in.provider.Name(), ev.ID, ev.ProviderType, err)
+		http.Error(w, "Webhook processing failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Process applies ev unless it has already been processed. It is safe to call
+// with the same event concurrently; only one call does the work.
+func (in *Ingestor) Process(ctx context.Context, ev Event) error {
+	claimed, err := in.claim(ctx, ev)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	if err := in.apply(ctx, ev); err != nil {
+		if _, dbErr := in.db.ExecContext(ctx,
+			`UPDATE payment_events SET status = ?, error = ? WHERE provider = ? AND event_id = ?`,
+			statusFailed, err.Error(), in.provider.Name(), ev.ID,
+		); dbErr != nil {
+			log.Printf("core_payments: record failure of %s: %v", ev.ID, dbErr)
+		}
+		return err
+	}
+	if _, err := in.db.ExecContext(ctx,
+		`UPDATE payment_events SET status = ?, error = NULL, processed_at = ? WHERE provider = ? AND event_id = ?`,
+		statusProcessed, shared_helpers.FormatDBTime(time.Now()), in.provider.Name(), ev.ID,
+	); err != nil {
+		return fmt.Errorf("mark payment event processed: %w", err)
+	}
+	return nil
+}
+
+// claim records ev as processing. Events that failed, or whose claim went
+// stale, can be claimed again; processed events cannot.
+func (in *Ingestor) claim(ctx context.Context, ev Event) (bool, error) {
+	now := time.Now()
+	res, err := in.db.ExecContext(ctx,
+		`INSERT INTO payment_events (provider, event_id, event_type, status, received_at)
+         VALUES (?, ?, ?, ?, ?)
+         ON CONFLICT (provider, event_id) DO UPDATE
+            SET status = excluded.status, error = NULL, received_at = excluded.received_at
+          WHERE payment_events.status = ?
+             OR (payment_events.status = ? AND payment_events.received_at < ?)`,
+		in.provider.Name(), ev.ID, ev.ProviderType, statusProcessing, shared_helpers.FormatDBTime(now),
+		statusFailed, statusProcessing, shared_helpers.FormatDBTime(now.Add(-staleClaim)),
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim payment event: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (in *Ingestor) apply(ctx context.Context, ev Event) error {
+	if ev.Type == EventIgnored {
+		return nil
+	}
+	if ev.CustomerID != "" && ev.UserID != "" {
+		if err := in.linkCustomer(ctx, ev.CustomerID, ev.UserID); err != nil {
+			return err
+		}
+	}
+	if ev.SubscriptionID == "" {
+		// One-off payments and checkouts without a subscription have nothing to map.
+		return nil
+	}
+
+	m, ok, err := in.mapping(ctx, ev.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ok && m.Deleted:
+		// Deleted provider subscriptions cannot come back, so nothing may revive them.
+		return nil
+	case ok && !ev.CreatedAt.IsZero() && ev.CreatedAt.Before(m.LastEventAt):
+		// Providers do not deliver in order; an older event would undo a newer one.
+		log.Printf("core_payments: %s event %s for %s is older than one already applied; ignoring", in.provider.Name(), ev.ID, ev.SubscriptionID)
+		return nil
+	case !ok && (ev.Type == EventSubscriptionDeleted || ev.Status == ProviderStatusPending):
+		// Nothing was provisioned, and nothing is until the first payment succeeds.
+		return nil
+	}
+
+	cookies, err := in.Session(ctx)
+	if err != nil {
+		return fmt.Errorf("service session: %w", err)
+	}
+
+	if ev.Type == EventSubscriptionDeleted {
+		if m.SubscriptionID != "" {
+			if err := in.cancel(ctx, cookies, m.SubscriptionID); err != nil {
+				return err
+			}
+			if err := in.update(ctx, cookies, m.SubscriptionID, string(shared_subscriptions.StateExpired), ev.PeriodEnd); err != nil {
+				return err
+			}
+		}
+		return in.recordEvent(ctx, ev, true)
+	}
+
+	if m, err = in.ensureSubscription(ctx, cookies, ev, m, ok); err != nil {
+		return err
+	}
+
+	status := ""
+	switch ev.Status {
+	case ProviderStatusTrialing:
+		status = string(shared_subscriptions.StateTrialing)
+	case ProviderStatusActive:
+		status = string(shared_subscriptions.StateActive)
+	case ProviderStatusPastDue:
+		status = string(shared_subscriptions.StatePastDue)
+	case ProviderStatusCanceled:
+		status = string(shared_subscriptions.StateExpired)
+	}
+	if ev.CancelAtPeriodEnd && status != string(shared_subscriptions.StateExpired) {
+		status = string(shared_subscriptions.StateCancelled)
+	}
+	if status != "" || ev.PeriodEnd != nil {
+		if err := in.update(ctx, cookies, m.SubscriptionID, status, ev.PeriodEnd); err != nil {
+			return err
+		}
+	}
+	return in.recordEvent(ctx, ev, false)
+}
+
+type subscriptionMapping struct {
+	// SubscriptionID is empty while the row is claimed and the
+	// client_identity subscription not yet created.
+	SubscriptionID string
+	UserID         string
+	PlanID         string
+	// LastEventAt is the provider creation time of the newest event applied.
+	LastEventAt time.Time
+	Deleted     bool
+}
+
+// ensureSubscription returns the client_identity subscription for the
+// provider subscription, creating it on first sight and replacing it when the
+// provider moved it to a price that maps to a different plan. m and ok are
+// the current mapping, as returned by mapping.
+//
+// The mapping row is claimed before the subscription is created and only
+// filled in afterwards, so concurrent events for the same provider
+// subscription (checkout.session.completed and invoice.paid usually arrive
+// together) cannot both create one.
+func (in *Ingestor) ensureSubscription(ctx context.Context, cookies []*http.Cookie, ev Event, m subscriptionMapping, ok bool) (subscriptionMapping, error) {
+	planID := ""
+	if ev.PriceID != "" {
+		var err error
+		if planID, err = in.planFor(ev.PriceID); err != nil {
+			return m, err
+		}
+	}
+	if ok && m.SubscriptionID != "" && (planID == "" || planID == m.PlanID) {
+		return m, nil
+	}
+	if ok && planID == "" {
+		planID = m.PlanID
+	}
+	if planID == "" {
+		return m, fmt.Errorf("%w: no price on %s for unseen subscription %s", ErrUnknownPrice, ev.ProviderType, ev.SubscriptionID)
+	}
+
+	userID := ev.UserID
+	if ok {
+		userID = m.UserID
+	} else if userID == "" {
+		var err error
+		if userID, err = in.customerUser(ctx, ev.CustomerID); err != nil {
+			return m, err
+		}
+	}
+
+	previous := m
+	if err := in.claimMapping(ctx, ev.SubscriptionID, previous, ok, userID, planID); err != nil {
+		return m, err
+	}
+	if previous.SubscriptionID != "" {
+		// Plans cannot be changed in place, so end the old subscription and start a new one.
+		if err := in.cancel(ctx, cookies, previous.SubscriptionID); err != nil {
+			in.restoreMapping(ctx, ev.SubscriptionID, previous)
+			return m, err
+		}
+	}
+	sub, code, err := in.identity.CreateSubscription(ctx, cookies, client_identity.CreateSubscriptionRequest{UserID: userID, PlanID: planID})
+	if err == nil && code >= 300 {
+		err = fmt.Errorf("unexpected status %d", code)
+	}
+	if err != nil {
+		in.releaseMapping(ctx, ev.SubscriptionID)
+		return m, fmt.Errorf("create subscription for %s: %w", userID, err)
+	}
+
+	if _, err := in.db.ExecContext(ctx,
+		`UPDATE payment_subscriptions
+            SET subscription_id = ?, updated_at = ?
+          WHERE provider = ? AND provider_subscription_id = ? AND subscription_id IS NULL`,
+		sub.ID, shared_helpers.FormatDBTime(time.Now()), in.provider.Name(), ev.SubscriptionID,
+	); err != nil {
+		return m, fmt.Errorf("record subscription mapping: %w", err)
+	}
+	m.SubscriptionID, m.UserID, m.PlanID = sub.ID, userID, planID
+	return m, nil
+}
+
+// claimMapping reserves the mapping row of a provider subscription for the
+// caller: it inserts a pending row for an unseen subscription, takes over a
+// pending row whose claim went stale, or empties the subscription ID of a
+// row whose plan is changing. It fails with ErrProvisioning when another
+// event holds the row.
+func (in *Ingestor) claimMapping(ctx context.Context, providerSubscriptionID string, m subscriptionMapping, ok bool, userID, planID string) error {
+	now := time.Now()
+	ts := shared_helpers.FormatDBTime(now)
+	var (
+		res sql.Result
+		err error
+	)
+	switch {
+	case !ok:
+		res, err = in.db.ExecContext(ctx,
+			`INSERT INTO payment_subscriptions (provider, provider_subscription_id, user_id, plan_id, created_at, updated_at)
+             VALUES (?, ?, ?, ?, ?, ?)
+             ON CONFLICT (provider, provider_subscription_id) DO NOTHING`,
+			in.provider.Name(), providerSubscriptionID, userID, planID, ts, ts,
+		)
+	case m.SubscriptionID == "":
+		res, err = in.db.ExecContext(ctx,
+			`UPDATE payment_subscriptions
+                SET plan_id = ?, updated_at = ?
+              WHERE provider = ? AND provider_subscription_id = ?
+                AND subscription_id IS NULL AND updated_at < ?`,
+			planID, ts, in.provider.Name(), providerSubscriptionID, shared_helpers.FormatDBTime(now.Add(-staleClaim)),
+		)
+	default:
+		res, err = in.db.ExecContext(ctx,
+			`UPDATE payment_subscriptions
+                SET subscription_id = NULL, plan_id = ?, updated_at = ?
+              WHERE provider = ? AND provider_subscription_id = ? AND subscription_id = ?`,
+			planID, ts, in.provider.Name(), providerSubscriptionID, m.SubscriptionID,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("claim subscription mapping: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrProvisioning, providerSubscriptionID)
+	}
+	return nil
+}
+
+// releaseMapping makes a pending claim stale straight away, so the
+// provider's retry can take it over without waiting.
+func (in *Ingestor) releaseMapping(ctx context.Context, providerSubscriptionID string) {
+	if _, err := in.db.ExecContext(ctx,
+		`UPDATE payment_subscriptions
+            SET updated_at = ?
+          WHERE provider = ? AND provider_subscription_id = ? AND subscription_id IS NULL`,
+		shared_helpers.FormatDBTime(time.Unix(0, 0)), in.provider.Name(), providerSubscriptionID,
+	); err != nil {
+		log.Printf("core_payments: release subscription mapping %s: %v", providerSubscriptionID, err)
+	}
+}
+
+// restoreMapping undoes the claim of a plan change that could not cancel the old subscription.
+func (in *Ingestor) restoreMapping(ctx context.Context, providerSubscriptionID string, m subscriptionMapping) {
+	if _, err := in.db.ExecContext(ctx,
+		`UPDATE payment_subscriptions
+            SET subscription_id = ?, plan_id = ?, updated_at = ?
+          WHERE provider = ? AND provider_subscription_id = ? AND subscription_id IS NULL`,
+		m.SubscriptionID, m.PlanID, shared_helpers.FormatDBTime(time.Now()), in.provider.Name(), providerSubscriptionID,
+	); err != nil {
+		log.Printf("core_payments: restore subscription mapping %s: %v", providerSubscriptionID, err)
+	}
+}
+
+// recordEvent advances the mapping's last_event_at to ev.CreatedAt, and marks
+// it deleted when ev ended the provider subscription.
+func (in *Ingestor) recordEvent(ctx context.Context, ev Event, deleted bool) error {
+	now := shared_helpers.FormatDBTime(time.Now())
+	var deletedAt any
+	if deleted {
+		deletedAt = now
+	}
+	created := shared_helpers.FormatDBTime(ev.CreatedAt)
+	if _, err := in.db.ExecContext(ctx,
+		`UPDATE payment_subscriptions
+            SET last_event_at = CASE WHEN last_event_at IS NULL OR last_event_at < ? THEN ? ELSE last_event_at END,
+                deleted_at = COALESCE(deleted_at, ?), updated_at = ?
+          WHERE provider = ? AND provider_subscription_id = ?`,
+		created, created, deletedAt, now, in.provider.Name(), ev.SubscriptionID,
+	); err != nil {
+		return fmt.Errorf("record subscription event: %w", err)
+	}
+	return nil
+}
+
+func (in *Ingestor) planFor(priceID string) (string, error) {
+	planID, ok := in.Prices[priceID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPrice, priceID)
+	}
+	return planID, nil
+}
+
+func (in *Ingestor) mapping(ctx context.Context, providerSubscriptionID string) (subscriptionMapping, bool, error) {
+	var (
+		m                                    subscriptionMapping
+		subscriptionID, lastEventAt, deleted sql.NullString
+	)
+	err := in.db.QueryRowContext(ctx,
+		`SELECT subscription_id, user_id, plan_id, last_event_at, deleted_at
+           FROM payment_subscriptions
+          WHERE provider = ? AND provider_subscription_id = ?`,
+		in.provider.Name(), providerSubscriptionID,
+	).Scan(&subscriptionID, &m.UserID, &m.PlanID, &lastEventAt, &deleted)
+	if err == sql.ErrNoRows {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, fmt.Errorf("query subscription mapping: %w", err)
+	}
+	m.SubscriptionID = subscriptionID.String
+	m.LastEventAt = shared_helpers.ParseDBTime(lastEventAt.String)
+	m.Deleted = deleted.Valid
+	return m, true, nil
+}
+
+func (in *Ingestor) linkCustomer(ctx context.Context, customerID, userID string) error {
+	if _, err := in.db.ExecContext(ctx,
+		`INSERT INTO payment_customers (provider, customer_id, user_id, created_at)
+         VALUES (?, ?, ?, ?)
+         ON CONFLICT (provider, customer_id) DO UPDATE SET user_id = excluded.user_id`,
+		in.provider.Name(), customerID, userID, shared_helpers.FormatDBTime(time.Now()),
+	); err != nil {
+		return fmt.Errorf("link payment customer: %w", err)
+	}
+	return nil
+}
+
+func (in *Ingestor) customerUser(ctx context.Context, customerID string) (string, error) {
+	var userID string
+	err := in.db.QueryRowContext(ctx,
+		`SELECT user_id FROM payment_customers WHERE provider = ? AND customer_id = ?`,
+		in.provider.Name(), customerID,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCustomer, customerID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("query payment customer: %w", err)
+	}
+	return userID, nil
+}
+
+func (in *Ingestor) update(ctx context.Context, cookies []*http.Cookie, subscriptionID, status string, end *time.Time) error {
+	req := client_identity.UpdateSubscriptionRequest{EndDate: end}
+	if status != "" {
+		req.Status = &status
+	}
+	_, code, err := in.identity.UpdateSubscription(ctx, cookies, subscriptionID, req)
+	if err != nil {
+		return fmt.Errorf("update subscription %s: %w", subscriptionID, err)
+	}
+	if code >= 300 {
+		return fmt.Errorf("update subscription %s: unexpected status %d", subscriptionID, code)
+	}
+	return nil
+}
+
+func (in *Ingestor) cancel(ctx context.Context, cookies []*http.Cookie, subscriptionID string) error {
+	_, code, err := in.identity.CancelSubscription(ctx, cookies, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("cancel subscription %s: %w", subscriptionID, err)
+	}
+	// Already cancelled or gone is fine: the provider may send deletions more than once.
+	if code >= 300 && code != http.StatusNotFound && code != http.StatusConflict {
+		return fmt.Errorf("cancel subscription %s: unexpected status %d", subscriptionID, code)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package core_payments
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_helpers"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+// fakeIdentity records the subscription calls made by the Ingestor.
+type fakeIdentity struct {
+	mu        sync.Mutex
+	created   []client_identity.CreateSubscriptionRequest
+	statuses  []string
+	cancelled []string
+	failNext  bool
+}
+
+func (f *fakeIdentity) CreateSubscription(_ context.Context, _ []*http.Cookie, req client_identity.CreateSubscriptionRequest) (client_identity.Subscription, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNext {
+		f.failNext = false
+		return client_identity.Subscription{}, 0, errors.New("identity service unavailable")
+	}
+	f.created = append(f.created, req)
+	id := fmt.Sprintf("sub-%d", len(f.created))
+	return client_identity.Subscription{ID: id, UserID: req.UserID, PlanID: req.PlanID, Status: "active"}, http.StatusCreated, nil
+}
+
+func (f *fakeIdentity) UpdateSubscription(_ context.Context, _ []*http.Cookie, subscriptionID string, req client_identity.UpdateSubscriptionRequest) (client_identity.Subscription, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req.Status != nil {
+		f.statuses = append(f.statuses, subscriptionID+":"+*req.Status)
+	}
+	return client_identity.Subscription{ID: subscriptionID}, http.StatusOK, nil
+}
+
+func (f *fakeIdentity) CancelSubscription(_ context.Context, _ []*http.Cookie, subscriptionID string) (client_identity.Subscription, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, subscriptionID)
+	return client_identity.Subscription{ID: subscriptionID}, http.StatusOK, nil
+}
+
+// testDB opens the libsql database named by CORE_TEST_DATABASE_URL, e.g. a
+// local sqld at http://127.0.0.1:8080.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// fixtureEvent parses a recorded Stripe payload, renaming its event,
+// customer and subscription IDs with suffix so runs against a shared
+// database do not see each other's rows.
+func fixtureEvent(t *testing.T, name, suffix string) Event {
+	t.Helper()
+	ev, err := NewStripeProvider(testSecret).ParseEvent(readFixture(t, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.ID += suffix
+	ev.CustomerID += suffix
+	ev.SubscriptionID += suffix
+	return ev
+}
+
+func newTestIngestor(t *testing.T) (*Ingestor, *fakeIdentity, string) {
+	t.Helper()
+	identity := &fakeIdentity{}
+	in := NewIngestor(testDB(t), NewStripeProvider(testSecret), identity,
+		map[string]string{"price_pro_monthly": "plan_pro"},
+		func(context.Context) ([]*http.Cookie, error) { return nil, nil },
+	)
+	suffix, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in, identity, "_" + suffix
+}
+
+func TestIngestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	in, identity, suffix := newTestIngestor(t)
+
+	for _, name := range []string{
+		"checkout_session_completed",
+		"invoice_paid",
+		"invoice_payment_failed",
+		"customer_subscription_updated",
+		"customer_subscription_deleted",
+	} {
+		if err := in.Process(ctx, fixtureEvent(t, name, suffix)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	// Late redeliveries: a replayed event and an update sent before the
+	// deletion but received after it must not revive the subscription.
+	if err := in.Process(ctx, fixtureEvent(t, "invoice_paid", suffix)); err != nil {
+		t.Fatal(err)
+	}
+	late := fixtureEvent(t, "customer_subscription_updated", suffix)
+	late.ID += "_late"
+	late.CancelAtPeriodEnd = false
+	if err := in.Process(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identity.created) != 1 || identity.created[0] != (client_identity.CreateSubscriptionRequest{UserID: "usr_7Hk2pQ", PlanID: "plan_pro"}) {
+		t.Fatalf("created = %+v, want one plan_pro subscription for usr_7Hk2pQ", identity.created)
+	}
+	want := []string{"sub-1:active", "sub-1:past_due", "sub-1:cancelled", "sub-1:expired"}
+	if fmt.Sprint(identity.statuses) != fmt.Sprint(want) {
+		t.Errorf("status updates = %v, want %v", identity.statuses, want)
+	}
+	if fmt.Sprint(identity.cancelled) != "[sub-1]" {
+		t.Errorf("cancelled = %v, want [sub-1]", identity.cancelled)
+	}
+}
+
+func TestIngestIgnoresOlderEvents(t *testing.T) {
+	ctx := context.Background()
+	in, identity, suffix := newTestIngestor(t)
+
+	for _, name := range []string{"checkout_session_completed", "invoice_payment_failed", "invoice_paid"} {
+		if err := in.Process(ctx, fixtureEvent(t, name, suffix)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	// invoice.paid was created before invoice.payment_failed, so it arrived late.
+	if fmt.Sprint(identity.statuses) != "[sub-1:past_due]" {
+		t.Errorf("status updates = %v, want [sub-1:past_due]", identity.statuses)
+	}
+}
+
+func TestIngestSkipsPendingSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	in, identity, suffix := newTestIngestor(t)
+
+	ev := fixtureEvent(t, "checkout_session_completed", suffix)
+	ev.Status = ProviderStatusPending
+	if err := in.Process(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(identity.created) != 0 {
+		t.Fatalf("created = %+v for an unpaid checkout, want none", identity.created)
+	}
+
+	// The customer was still linked, so the first paid invoice provisions it.
+	if err := in.Process(ctx, fixtureEvent(t, "invoice_paid", suffix)); err != nil {
+		t.Fatal(err)
+	}
+	if len(identity.created) != 1 || identity.created[0].UserID != "usr_7Hk2pQ" {
+		t.Fatalf("created = %+v, want one subscription for usr_7Hk2pQ", identity.created)
+	}
+}
+
+func TestIngestRetriesAfterFailedCreate(t *testing.T) {
+	ctx := context.Background()
+	in, identity, suffix := newTestIngestor(t)
+
+	identity.failNext = true
+	ev := fixtureEvent(t, "checkout_session_completed", suffix)
+	if err := in.Process(ctx, ev); err == nil {
+		t.Fatal("Process succeeded with the identity service down")
+	}
+	// The provider retries the same event; the released claim lets it through.
+	if err := in.Process(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Process(ctx, fixtureEvent(t, "invoice_paid", suffix)); err != nil {
+		t.Fatal(err)
+	}
+	if len(identity.created) != 1 {
+		t.Fatalf("created = %+v, want exactly one subscription", identity.created)
+	}
+}
+
+func TestIngestConcurrentEventsCreateOneSubscription(t *testing.T) {
+	ctx := context.Background()
+	in, identity, suffix := newTestIngestor(t)
+
+	checkout := fixtureEvent(t, "checkout_session_completed", suffix)
+	if err := in.linkCustomer(ctx, checkout.CustomerID, checkout.UserID); err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{checkout, fixtureEvent(t, "invoice_paid", suffix)}
+	var wg sync.WaitGroup
+	for _, ev := range events {
+		wg.Add(1)
+		go func(ev Event) {
+			defer wg.Done()
+			// Losing the claim fails with ErrProvisioning, which the provider retries.
+			if err := in.Process(ctx, ev); err != nil && !errors.Is(err, ErrProvisioning) {
+				t.Error(err)
+			}
+		}(ev)
+	}
+	wg.Wait()
+	if len(identity.created) != 1 {
+		t.Fatalf("created = %+v, want exactly one subscription", identity.created)
+	}
+}
+
+func TestIngestorServeHTTP(t *testing.T) {
+	in, identity, _ := newTestIngestor(t)
+	body := readFixture(t, "checkout_session_completed")
+
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/stripe", bytes.NewReader(body))
+		req.Header.Set(StripeSignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		in.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("t=1,v1=00"); code != http.StatusUnauthorized {
+		t.Fatalf("bad signature answered %d, want 401", code)
+	}
+
+	// The fixture IDs are fixed, so clear anything a previous run left behind.
+	for _, stmt := range []string{
+		`DELETE FROM payment_events WHERE provider = 'stripe' AND event_id = 'evt_1PqCheckout00001'`,
+		`DELETE FROM payment_subscriptions WHERE provider = 'stripe' AND provider_subscription_id = 'sub_1PqSub00001'`,
+	} {
+		if _, err := in.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signature := NewStripeProvider(testSecret).SignPayload(body, time.Now())
+	for i := 0; i < 2; i++ {
+		if code := post(signature); code != http.StatusOK {
+			t.Fatalf("delivery %d answered %d, want 200", i+1, code)
+		}
+	}
+	if len(identity.created) != 1 {
+		t.Fatalf("created = %+v after a redelivery, want exactly one subscription", identity.created)
+	}
+}
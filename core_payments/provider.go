@@ -0,0 +1,59 @@
+package core_payments
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Normalised payment event types. Provider adapters translate their own
+// event names into these; anything else is EventIgnored.
+const (
+	EventCheckoutCompleted   = "checkout.completed"
+	EventInvoicePaid         = "invoice.paid"
+	EventPaymentFailed       = "payment.failed"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+	EventIgnored             = "ignored"
+)
+
+// Provider subscription statuses, normalised.
+const (
+	ProviderStatusTrialing = "trialing"
+	ProviderStatusActive   = "active"
+	ProviderStatusPastDue  = "past_due"
+	ProviderStatusCanceled = "canceled"
+	ProviderStatusPending  = "pending" // awaiting first payment; not yet provisioned
+)
+
+// Event is a provider webhook translated into the fields the ingestor needs.
+type Event struct {
+	ID string `json:"id"`
+	// Type is one of the Event constants.
+	Type string `json:"type"`
+	// ProviderType is the provider's own event name, kept for logs.
+	ProviderType string `json:"provider_type"`
+	// CreatedAt is when the provider created the event. Events older than
+	// one already applied to the same subscription are ignored.
+	CreatedAt      time.Time `json:"created_at"`
+	CustomerID     string    `json:"customer_id,omitempty"`
+	SubscriptionID string    `json:"subscription_id,omitempty"`
+	// UserID is the application user, when the provider echoes it back
+	// (client_reference_id or metadata.user_id).
+	UserID            string          `json:"user_id,omitempty"`
+	PriceID           string          `json:"price_id,omitempty"`
+	Status            string          `json:"status,omitempty"`
+	PeriodEnd         *time.Time      `json:"period_end,omitempty"`
+	CancelAtPeriodEnd bool            `json:"cancel_at_period_end,omitempty"`
+	Raw               json.RawMessage `json:"-"`
+}
+
+// Provider adapts a payment processor's webhooks.
+type Provider interface {
+	// Name identifies the provider in CoreDB, e.g. "stripe".
+	Name() string
+	// VerifySignature authenticates a webhook request body.
+	VerifySignature(header http.Header, body []byte) error
+	// ParseEvent translates a verified body into an Event.
+	ParseEvent(body []byte) (Event, error)
+}
@@ -0,0 +1,50 @@
+package core_payments
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the payment event, customer and subscription mapping tables in CoreDB if they do not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"payment_events", `CREATE TABLE IF NOT EXISTS payment_events (
+             provider     TEXT NOT NULL,
+             event_id     TEXT NOT NULL,
+             event_type   TEXT NOT NULL,
+             status       TEXT NOT NULL,
+             error        TEXT,
+             received_at  TIMESTAMP NOT NULL,
+             processed_at TIMESTAMP,
+             PRIMARY KEY (provider, event_id)
+         )`},
+		{"payment_customers", `CREATE TABLE IF NOT EXISTS payment_customers (
+             provider    TEXT NOT NULL,
+             customer_id TEXT NOT NULL,
+             user_id     TEXT NOT NULL,
+             created_at  TIMESTAMP NOT NULL,
+             PRIMARY KEY (provider, customer_id)
+         )`},
+		{"payment_subscriptions", `CREATE TABLE IF NOT EXISTS payment_subscriptions (
+             provider                 TEXT NOT NULL,
+             provider_subscription_id TEXT NOT NULL,
+             subscription_id          TEXT,
+             user_id                  TEXT NOT NULL,
+             plan_id                  TEXT NOT NULL,
+             last_event_at            TIMESTAMP,
+             deleted_at               TIMESTAMP,
+             created_at               TIMESTAMP NOT NULL,
+             updated_at               TIMESTAMP NOT NULL,
+             PRIMARY KEY (provider, provider_subscription_id)
+         )`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package core_payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_webhooks"
+)
+
+// StripeSignatureHeader carries Stripe's "t=<unix>,v1=<hex>" webhook signature.
+const StripeSignatureHeader = "Stripe-Signature"
+
+// StripeProvider adapts Stripe (and Stripe-compatible) webhooks.
+type StripeProvider struct {
+	secrets [][]byte
+	// Tolerance is the maximum age of a signature timestamp.
+	Tolerance time.Duration
+}
+
+// NewStripeProvider creates an adapter verifying against the endpoint's
+// signing secrets ("whsec_..."). Several secrets may be given during rotation.
+func NewStripeProvider(secrets ...string) *StripeProvider {
+	p := &StripeProvider{Tolerance: shared_webhooks.DefaultTolerance}
+	for _, s := range secrets {
+		p.secrets = append(p.secrets, []byte(s))
+	}
+	return p
+}
+
+func (p *StripeProvider) Name() string { return "stripe" }
+
+// VerifySignature checks the Stripe-Signature header. Stripe signs
+// "<t>.<body>" with HMAC-SHA256, the same scheme as shared_webhooks.
+func (p *StripeProvider) VerifySignature(header http.Header, body []byte) error {
+	return shared_webhooks.Verify(p.secrets, header.Get(StripeSignatureHeader), body, p.Tolerance, time.Now())
+}
+
+// SignPayload returns a Stripe-Signature value for body, for replaying
+// recorded fixture payloads against a handler.
+func (p *StripeProvider) SignPayload(body []byte, t time.Time) string {
+	if len(p.secrets) == 0 {
+		return ""
+	}
+	return shared_webhooks.Sign(p.secrets[0], t, body)
+}
+
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+type stripePrice struct {
+	ID string `json:"id"`
+}
+
+type stripeLineItems struct {
+	Data []struct {
+		Price  *stripePrice `json:"price"`
+		Period *struct {
+			End int64 `json:"end"`
+		} `json:"period"`
+	} `json:"data"`
+}
+
+type stripeCheckoutSession struct {
+	ClientReferenceID string            `json:"client_reference_id"`
+	PaymentStatus     string            `json:"payment_status"`
+	Customer          string            `json:"customer"`
+	Subscription      string            `json:"subscription"`
+	Metadata          map[string]string `json:"metadata"`
+	LineItems         *stripeLineItems  `json:"line_items"`
+}
+
+type stripeInvoice struct {
+	Customer     string            `json:"customer"`
+	Subscription string            `json:"subscription"`
+	Metadata     map[string]string `json:"metadata"`
+	Lines        stripeLineItems   `json:"lines"`
+}
+
+type stripeSubscription struct {
+	ID                string            `json:"id"`
+	Customer          string            `json:"customer"`
+	Status            string            `json:"status"`
+	CurrentPeriodEnd  int64             `json:"current_period_end"`
+	CancelAtPeriodEnd bool              `json:"cancel_at_period_end"`
+	Metadata          map[string]string `json:"metadata"`
+	Items             stripeLineItems   `json:"items"`
+}
+
+// ParseEvent translates the Stripe event types the ingestor acts on:
+// checkout.session.completed, invoice.paid, invoice.payment_succeeded,
+// invoice.payment_failed and customer.subscription.created/updated/deleted.
+func (p *StripeProvider) ParseEvent(body []byte) (Event, error) {
+	var se stripeEvent
+	if err := json.Unmarshal(body, &se); err != nil {
+		return Event{}, fmt.Errorf("decode stripe event: %w", err)
+	}
+	if se.ID == "" || se.Type == "" {
+		return Event{}, fmt.Errorf("decode stripe event: missing id or type")
+	}
+	ev := Event{
+		ID:           se.ID,
+		ProviderType: se.Type,
+		Type:         EventIgnored,
+		CreatedAt:    time.Unix(se.Created, 0).UTC(),
+		Raw:          body,
+	}
+
+	switch se.Type {
+	case "checkout.session.completed":
+		var obj stripeCheckoutSession
+		if err := json.Unmarshal(se.Data.Object, &obj); err != nil {
+			return ev, fmt.Errorf("decode %s: %w", se.Type, err)
+		}
+		ev.Type = EventCheckoutCompleted
+		ev.CustomerID = obj.Customer
+		ev.SubscriptionID = obj.Subscription
+		ev.UserID = firstNonEmpty(obj.ClientReferenceID, obj.Metadata["user_id"])
+		if obj.PaymentStatus == "unpaid" {
+			// Delayed payment methods complete the checkout before the money arrives.
+			ev.Status = ProviderStatusPending
+		}
+		ev.PriceID = obj.Metadata["price_id"]
+		if obj.LineItems != nil {
+			ev.PriceID = firstNonEmpty(firstPrice(*obj.LineItems), ev.PriceID)
+		}
+
+	case "invoice.paid", "invoice.payment_succeeded", "invoice.payment_failed":
+		var obj stripeInvoice
+		if err := json.Unmarshal(se.Data.Object, &obj); err != nil {
+			return ev, fmt.Errorf("decode %s: %w", se.Type, err)
+		}
+		ev.Type = EventInvoicePaid
+		ev.Status = ProviderStatusActive
+		if se.Type == "invoice.payment_failed" {
+			ev.Type = EventPaymentFailed
+			ev.Status = ProviderStatusPastDue
+		}
+		ev.CustomerID = obj.Customer
+		ev.SubscriptionID = obj.Subscription
+		ev.UserID = obj.Metadata["user_id"]
+		ev.PriceID = firstPrice(obj.Lines)
+		for _, line := range obj.Lines.Data {
+			if line.Period != nil && line.Period.End > 0 {
+				end := time.Unix(line.Period.End, 0).UTC()
+				ev.PeriodEnd = &end
+				break
+			}
+		}
+
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var obj stripeSubscription
+		if err := json.Unmarshal(se.Data.Object, &obj); err != nil {
+			return ev, fmt.Errorf("decode %s: %w", se.Type, err)
+		}
+		ev.Type = EventSubscriptionUpdated
+		if se.Type == "customer.subscription.deleted" {
+			ev.Type = EventSubscriptionDeleted
+		}
+		ev.CustomerID = obj.Customer
+		ev.SubscriptionID = obj.ID
+		ev.UserID = obj.Metadata["user_id"]
+		ev.PriceID = firstPrice(obj.Items)
+		ev.Status = stripeStatus(obj.Status)
+		ev.CancelAtPeriodEnd = obj.CancelAtPeriodEnd
+		if obj.CurrentPeriodEnd > 0 {
+			end := time.Unix(obj.CurrentPeriodEnd, 0).UTC()
+			ev.PeriodEnd = &end
+		}
+	}
+	return ev, nil
+}
+
+func stripeStatus(status string) string {
+	switch status {
+	case "trialing":
+		return ProviderStatusTrialing
+	case "active":
+		return ProviderStatusActive
+	case "past_due", "unpaid":
+		return ProviderStatusPastDue
+	case "canceled", "incomplete_expired":
+		return ProviderStatusCanceled
+	default: // incomplete, paused
+		return ProviderStatusPending
+	}
+}
+
+func firstPrice(items stripeLineItems) string {
+	for _, item := range items.Data {
+		if item.Price != nil && item.Price.ID != "" {
+			return item.Price.ID
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
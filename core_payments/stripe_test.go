@@ -0,0 +1,123 @@
+package core_payments
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test"
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "stripe", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestStripeParseEvent(t *testing.T) {
+	periodEnd := func(unix int64) *time.Time {
+		end := time.Unix(unix, 0).UTC()
+		return &end
+	}
+	tests := []struct {
+		fixture string
+		want    Event
+	}{
+		{"checkout_session_completed", Event{
+			ID: "evt_1PqCheckout00001", Type: EventCheckoutCompleted, ProviderType: "checkout.session.completed",
+			CustomerID: "cus_QhT0yFJ1", SubscriptionID: "sub_1PqSub00001", UserID: "usr_7Hk2pQ", PriceID: "price_pro_monthly",
+		}},
+		{"invoice_paid", Event{
+			ID: "evt_1PqInvoice00002", Type: EventInvoicePaid, ProviderType: "invoice.paid",
+			CustomerID: "cus_QhT0yFJ1", SubscriptionID: "sub_1PqSub00001", PriceID: "price_pro_monthly",
+			Status: ProviderStatusActive, PeriodEnd: periodEnd(1725788400),
+		}},
+		{"invoice_payment_failed", Event{
+			ID: "evt_1PrInvoiceFail03", Type: EventPaymentFailed, ProviderType: "invoice.payment_failed",
+			CustomerID: "cus_QhT0yFJ1", SubscriptionID: "sub_1PqSub00001", PriceID: "price_pro_monthly",
+			Status: ProviderStatusPastDue, PeriodEnd: periodEnd(1728380400),
+		}},
+		{"customer_subscription_updated", Event{
+			ID: "evt_1PsSubUpdated04", Type: EventSubscriptionUpdated, ProviderType: "customer.subscription.updated",
+			CustomerID: "cus_QhT0yFJ1", SubscriptionID: "sub_1PqSub00001", PriceID: "price_pro_monthly",
+			Status: ProviderStatusActive, PeriodEnd: periodEnd(1728380400), CancelAtPeriodEnd: true,
+		}},
+		{"customer_subscription_deleted", Event{
+			ID: "evt_1PtSubDeleted05", Type: EventSubscriptionDeleted, ProviderType: "customer.subscription.deleted",
+			CustomerID: "cus_QhT0yFJ1", SubscriptionID: "sub_1PqSub00001", PriceID: "price_pro_monthly",
+			Status: ProviderStatusCanceled, PeriodEnd: periodEnd(1728380400),
+		}},
+	}
+	p := NewStripeProvider(testSecret)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := p.ParseEvent(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.CreatedAt.IsZero() {
+				t.Error("CreatedAt is zero")
+			}
+			got.CreatedAt, got.Raw = time.Time{}, nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEvent =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripeParseEventUnpaidCheckoutIsPending(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"checkout.session.completed","created":1723110000,
+		"data":{"object":{"payment_status":"unpaid","customer":"cus_1","subscription":"sub_1"}}}`)
+	ev, err := NewStripeProvider(testSecret).ParseEvent(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Status != ProviderStatusPending {
+		t.Errorf("Status = %q, want %q", ev.Status, ProviderStatusPending)
+	}
+}
+
+func TestStripeParseEventIgnoresOtherTypes(t *testing.T) {
+	ev, err := NewStripeProvider(testSecret).ParseEvent([]byte(`{"id":"evt_1","type":"charge.refunded","data":{"object":{}}}`))
+	if err != nil || ev.Type != EventIgnored {
+		t.Fatalf("ParseEvent = %+v, %v; want ignored", ev, err)
+	}
+	if _, err := NewStripeProvider(testSecret).ParseEvent([]byte(`{"type":"invoice.paid"}`)); err == nil {
+		t.Fatal("ParseEvent without an id succeeded")
+	}
+}
+
+func TestStripeVerifySignature(t *testing.T) {
+	p := NewStripeProvider("whsec_old", testSecret)
+	signer := NewStripeProvider(testSecret)
+	body := readFixture(t, "invoice_paid")
+
+	header := http.Header{}
+	header.Set(StripeSignatureHeader, signer.SignPayload(body, time.Now()))
+	if err := p.VerifySignature(header, body); err != nil {
+		t.Fatalf("VerifySignature = %v", err)
+	}
+
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-2] = ' '
+	if err := p.VerifySignature(header, tampered); err == nil {
+		t.Error("tampered body verified")
+	}
+
+	header.Set(StripeSignatureHeader, signer.SignPayload(body, time.Now().Add(-time.Hour)))
+	if err := p.VerifySignature(header, body); err == nil {
+		t.Error("stale signature verified")
+	}
+
+	header.Set(StripeSignatureHeader, NewStripeProvider("whsec_other").SignPayload(body, time.Now()))
+	if err := p.VerifySignature(header, body); err == nil {
+		t.Error("signature with an unknown secret verified")
+	}
+}
@@ -0,0 +1,130 @@
+package core_preferences
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_i18n"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// ListHandler serves GET /api/notification-preferences for the session user.
+func ListHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, client_identity.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+		resp, err := s.List(r.Context(), userID)
+		if err != nil {
+			writePreferenceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// UpdateHandler serves PUT /api/notification-preferences for the session
+// user and returns all of their preferences afterwards.
+func UpdateHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := shared_utilities.RequireSessionUser(r)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, client_identity.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+		var req UpdatePreferencesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, client_identity.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+		for _, p := range req.Preferences {
+			if _, err := s.Set(r.Context(), userID, p); err != nil {
+				writePreferenceError(w, err)
+				return
+			}
+		}
+		resp, err := s.List(r.Context(), userID)
+		if err != nil {
+			writePreferenceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body style="font-family:sans-serif;max-width:32rem;margin:4rem auto;padding:0 1rem">
+<p>{{.Text}}</p>
+{{if .Token}}<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">{{.Title}}</button></form>{{end}}
+</body></html>`))
+
+// UnsubscribeHandler serves the page behind Service.UnsubscribeURL. GET asks
+// for confirmation, so link scanners that follow URLs in emails do not
+// unsubscribe anyone; POST, from that page or from a mail client's one-click
+// button (RFC 8058), turns the preference off. No session is needed: the
+// signed token identifies the user.
+func UnsubscribeHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := shared_i18n.FromRequest(r)
+		page := struct{ Lang, Title, Text, Token string }{Lang: l.Lang(), Title: l.T("email.unsubscribe")}
+
+		token := r.URL.Query().Get("token")
+		if r.Method == http.MethodPost {
+			if err := r.ParseForm(); err == nil && r.PostForm.Get("token") != "" {
+				token = r.PostForm.Get("token")
+			}
+		}
+		c, err := parseToken(s.secret, token)
+		if err != nil {
+			page.Text = l.T("unsubscribe.invalid")
+			writePage(w, http.StatusBadRequest, page)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			page.Text = l.T("unsubscribe.confirm", "category", string(c.Category))
+			page.Token = token
+			writePage(w, http.StatusOK, page)
+		case http.MethodPost:
+			if _, err := s.Unsubscribe(r.Context(), token); err != nil {
+				writePreferenceError(w, err)
+				return
+			}
+			page.Text = l.T("unsubscribe.done", "category", string(c.Category))
+			writePage(w, http.StatusOK, page)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func writePage(w http.ResponseWriter, status int, page any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	unsubscribePage.Execute(w, page)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writePreferenceError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrSecurityRequired), errors.Is(err, ErrInvalidCategory), errors.Is(err, ErrInvalidChannel):
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
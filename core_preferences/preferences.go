@@ -0,0 +1,203 @@
+// Package core_preferences stores which notifications each user wants, by
+// category and channel, in CoreDB. Its Service plugs into
+// client_notify.Router as Preferences and into client_notify.EmailDriver as
+// the source of signed one-click unsubscribe links.
+package core_preferences
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// AllChannels is the channel of a preference that applies to every channel
+// of its category. A preference for a specific channel takes precedence.
+const AllChannels client_notify.Channel = ""
+
+var (
+	ErrSecurityRequired = errors.New("security notifications cannot be turned off")
+	ErrInvalidCategory  = errors.New("unknown notification category")
+	ErrInvalidChannel   = errors.New("unknown notification channel")
+	ErrSecretTooShort   = fmt.Errorf("unsubscribe secret must be at least %d bytes", MinSecretLength)
+)
+
+// MinSecretLength is the shortest secret NewService accepts for signing
+// unsubscribe tokens, which never expire.
+const MinSecretLength = 32
+
+// categories are the categories users may set preferences for.
+var categories = map[client_notify.Category]bool{
+	client_notify.CategorySecurity:  true,
+	client_notify.CategoryAccount:   true,
+	client_notify.CategoryBilling:   true,
+	client_notify.CategoryProduct:   true,
+	client_notify.CategoryMarketing: true,
+}
+
+// channels are the channels a preference may name.
+var channels = map[client_notify.Channel]bool{
+	AllChannels:                true,
+	client_notify.ChannelEmail: true,
+	client_notify.ChannelSMS:   true,
+	client_notify.ChannelChat:  true,
+	client_notify.ChannelInApp: true,
+}
+
+// Preference turns a category of notification on or off for one channel,
+// or for all of them when Channel is AllChannels.
+type Preference struct {
+	Category  client_notify.Category `json:"category"`
+	Channel   client_notify.Channel  `json:"channel,omitempty"`
+	Enabled   bool                   `json:"enabled"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// UpdatePreferencesRequest is the body of PUT /api/notification-preferences.
+type UpdatePreferencesRequest struct {
+	Preferences []Preference `json:"preferences"`
+}
+
+// Service reads and writes preferences and issues unsubscribe links.
+// Notifications a user has no preference for are sent.
+type Service struct {
+	db     *sql.DB
+	secret []byte
+
+	// UnsubscribeURL is the page serving UnsubscribeHandler; the token is
+	// added as ?token=.
+	UnsubscribeURL string
+}
+
+// NewService creates a preference service; secret signs unsubscribe tokens
+// and must be at least MinSecretLength random bytes.
+func NewService(db *sql.DB, secret []byte, unsubscribeURL string) (*Service, error) {
+	if len(secret) < MinSecretLength {
+		return nil, ErrSecretTooShort
+	}
+	return &Service{db: db, secret: secret, UnsubscribeURL: unsubscribeURL}, nil
+}
+
+// List returns the preferences userID has set.
+func (s *Service) List(ctx context.Context, userID string) ([]Preference, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT category, channel, enabled, updated_at
+           FROM notification_preferences
+          WHERE user_id = ?
+          ORDER BY category, channel`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := []Preference{}
+	for rows.Next() {
+		var (
+			p                 Preference
+			category, channel string
+			enabled           int
+			updatedAt         string
+		)
+		if err := rows.Scan(&category, &channel, &enabled, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification preference: %w", err)
+		}
+		p.Category = client_notify.Category(category)
+		p.Channel = client_notify.Channel(channel)
+		p.Enabled = enabled == 1
+		p.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// Set stores p for userID. Security notifications cannot be turned off.
+func (s *Service) Set(ctx context.Context, userID string, p Preference) (Preference, error) {
+	if !categories[p.Category] {
+		return Preference{}, fmt.Errorf("%w: %q", ErrInvalidCategory, p.Category)
+	}
+	if !channels[p.Channel] {
+		return Preference{}, fmt.Errorf("%w: %q", ErrInvalidChannel, p.Channel)
+	}
+	if p.Category == client_notify.CategorySecurity && !p.Enabled {
+		return Preference{}, ErrSecurityRequired
+	}
+	p.UpdatedAt = time.Now().UTC()
+	enabled := 0
+	if p.Enabled {
+		enabled = 1
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO notification_preferences (user_id, category, channel, enabled, updated_at)
+         VALUES (?, ?, ?, ?, ?)
+         ON CONFLICT (user_id, category, channel)
+         DO UPDATE SET enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		userID, string(p.Category), string(p.Channel), enabled, shared_helpers.FormatDBTime(p.UpdatedAt),
+	); err != nil {
+		return Preference{}, fmt.Errorf("upsert notification preference: %w", err)
+	}
+	return p, nil
+}
+
+// Enabled reports whether userID accepts category on channel. Security
+// notifications are always accepted.
+func (s *Service) Enabled(ctx context.Context, userID string, category client_notify.Category, channel client_notify.Channel) (bool, error) {
+	if category == client_notify.CategorySecurity {
+		return true, nil
+	}
+	var enabled int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT enabled
+           FROM notification_preferences
+          WHERE user_id = ? AND category = ? AND channel IN (?, ?)
+          ORDER BY channel DESC
+          LIMIT 1`,
+		userID, string(category), string(channel), string(AllChannels),
+	).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("query notification preference: %w", err)
+	}
+	return enabled == 1, nil
+}
+
+// Allowed implements client_notify.Preferences. Recipients without a user
+// ID have no preferences and receive everything.
+func (s *Service) Allowed(ctx context.Context, to client_notify.Recipient, category client_notify.Category, channel client_notify.Channel) (bool, error) {
+	if to.UserID == "" {
+		return true, nil
+	}
+	return s.Enabled(ctx, to.UserID, category, channel)
+}
+
+// UnsubscribeLink returns the one-click unsubscribe URL for msg's category
+// by email. It fits client_notify.EmailDriver.Unsubscribe and returns ""
+// for recipients without a user ID and for security notifications.
+func (s *Service) UnsubscribeLink(to client_notify.Recipient, msg client_notify.Message) string {
+	if to.UserID == "" || msg.Category == "" || msg.Category == client_notify.CategorySecurity || s.UnsubscribeURL == "" {
+		return ""
+	}
+	token := signToken(s.secret, tokenClaims{
+		UserID:   to.UserID,
+		Category: msg.Category,
+		Channel:  client_notify.ChannelEmail,
+	})
+	return s.UnsubscribeURL + "?token=" + url.QueryEscape(token)
+}
+
+// Unsubscribe turns off the preference named by a token from UnsubscribeLink.
+func (s *Service) Unsubscribe(ctx context.Context, token string) (Preference, error) {
+	c, err := parseToken(s.secret, token)
+	if err != nil {
+		return Preference{}, err
+	}
+	return s.Set(ctx, c.UserID, Preference{Category: c.Category, Channel: c.Channel, Enabled: false})
+}
@@ -0,0 +1,15 @@
+package core_preferences
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewServiceRejectsShortSecret(t *testing.T) {
+	if _, err := NewService(nil, []byte("too short"), ""); !errors.Is(err, ErrSecretTooShort) {
+		t.Errorf("NewService with a 9-byte secret = %v, want ErrSecretTooShort", err)
+	}
+	if _, err := NewService(nil, make([]byte, MinSecretLength), ""); err != nil {
+		t.Errorf("NewService with a %d-byte secret = %v", MinSecretLength, err)
+	}
+}
@@ -0,0 +1,29 @@
+package core_preferences
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the notification_preferences table in CoreDB if it does not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"notification_preferences", `CREATE TABLE IF NOT EXISTS notification_preferences (
+             user_id    TEXT NOT NULL,
+             category   TEXT NOT NULL,
+             channel    TEXT NOT NULL,
+             enabled    INTEGER NOT NULL,
+             updated_at TIMESTAMP NOT NULL,
+             PRIMARY KEY (user_id, category, channel)
+         )`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
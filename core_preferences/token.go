@@ -0,0 +1,61 @@
+package core_preferences
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/hstles/go-sdk/client_notify"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// tokenClaims is the signed content of an unsubscribe token. Tokens do not
+// expire: unsubscribe links must keep working for as long as the email is
+// kept, and using one twice is harmless.
+type tokenClaims struct {
+	UserID   string
+	Category client_notify.Category
+	Channel  client_notify.Channel
+}
+
+// signToken encodes claims as "<payload>.<signature>", both base64url.
+func signToken(secret []byte, c tokenClaims) string {
+	payload := c.UserID + "|" + string(c.Category) + "|" + string(c.Channel)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(secret, encoded))
+}
+
+// parseToken verifies the signature of token and returns its claims.
+func parseToken(secret []byte, token string) (tokenClaims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, sign(secret, encoded)) {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || parts[0] == "" {
+		return tokenClaims{}, ErrInvalidToken
+	}
+	return tokenClaims{
+		UserID:   parts[0],
+		Category: client_notify.Category(parts[1]),
+		Channel:  client_notify.Channel(parts[2]),
+	}, nil
+}
+
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("unsubscribe:"))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
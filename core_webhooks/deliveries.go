@@ -0,0 +1,334 @@
+package core_webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_webhooks"
+)
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+const defaultDeliveryPageSize = 50
+
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Delivery is one event queued for one endpoint.
+type Delivery struct {
+	ID             string     `json:"id"`
+	EndpointID     string     `json:"endpoint_id"`
+	OrgID          string     `json:"org_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Attempt is the log entry of a single HTTP request made for a delivery.
+type Attempt struct {
+	ID           string    `json:"id"`
+	DeliveryID   string    `json:"delivery_id"`
+	EndpointID   string    `json:"endpoint_id"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Publish fans eventType out to every active endpoint of orgID subscribed to
+// it. data becomes the envelope's data field. The deliveries are picked up by
+// a Worker; the returned envelope carries the event ID shared by all of them.
+func (s *Service) Publish(ctx context.Context, orgID, eventType string, data any) (shared_webhooks.Envelope, error) {
+	return s.inTx(ctx, func(tx *sql.Tx) (shared_webhooks.Envelope, error) {
+		return s.PublishTx(ctx, tx, orgID, eventType, data)
+	})
+}
+
+// PublishTx is Publish within the caller's transaction, so deliveries are only
+// queued if the business change that caused them commits.
+func (s *Service) PublishTx(ctx context.Context, tx *sql.Tx, orgID, eventType string, data any) (shared_webhooks.Envelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return shared_webhooks.Envelope{}, fmt.Errorf("marshal %s payload: %w", eventType, err)
+	}
+	return s.enqueue(ctx, tx, orgID, shared_webhooks.Envelope{Type: eventType, Data: raw})
+}
+
+// PublishEvent publishes an identity event about userID with its registered
+// client_identity payload, which is validated as client_identity.NewEventRequest
+// does. Receivers decode it with shared_webhooks.DecodePayload.
+func (s *Service) PublishEvent(ctx context.Context, orgID, userID string, payload client_identity.EventPayload) (shared_webhooks.Envelope, error) {
+	return s.inTx(ctx, func(tx *sql.Tx) (shared_webhooks.Envelope, error) {
+		return s.PublishEventTx(ctx, tx, orgID, userID, payload)
+	})
+}
+
+// PublishEventTx is PublishEvent within the caller's transaction.
+func (s *Service) PublishEventTx(ctx context.Context, tx *sql.Tx, orgID, userID string, payload client_identity.EventPayload) (shared_webhooks.Envelope, error) {
+	req, err := client_identity.NewEventRequest(userID, "", payload)
+	if err != nil {
+		return shared_webhooks.Envelope{}, err
+	}
+	return s.enqueue(ctx, tx, orgID, shared_webhooks.Envelope{
+		Type:   req.Type,
+		UserID: userID,
+		Data:   json.RawMessage(req.Metadata),
+	})
+}
+
+func (s *Service) inTx(ctx context.Context, fn func(tx *sql.Tx) (shared_webhooks.Envelope, error)) (shared_webhooks.Envelope, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return shared_webhooks.Envelope{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	env, err := fn(tx)
+	if err != nil {
+		return env, err
+	}
+	if err := tx.Commit(); err != nil {
+		return env, fmt.Errorf("commit webhook deliveries: %w", err)
+	}
+	return env, nil
+}
+
+// enqueue stamps env with a new event ID and queues a delivery of it for
+// every active endpoint of orgID subscribed to its type.
+func (s *Service) enqueue(ctx context.Context, tx *sql.Tx, orgID string, env shared_webhooks.Envelope) (shared_webhooks.Envelope, error) {
+	eventID, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return env, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	now := time.Now().UTC()
+	env.ID = eventID
+	env.CreatedAt = now
+	eventType := env.Type
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return env, fmt.Errorf("marshal envelope: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+endpointColumns+`
+           FROM webhook_endpoints
+          WHERE org_id = ? AND active = 1`,
+		orgID,
+	)
+	if err != nil {
+		return env, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+	var targets []Endpoint
+	for rows.Next() {
+		ep, err := scanEndpoint(rows)
+		if err != nil {
+			rows.Close()
+			return env, err
+		}
+		if ep.Subscribed(eventType) {
+			targets = append(targets, ep)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return env, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+
+	ts := shared_helpers.FormatDBTime(now)
+	for _, ep := range targets {
+		id, err := shared_helpers.GenerateCondensedUUID()
+		if err != nil {
+			return env, fmt.Errorf("GenerateCondensedUUID: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO webhook_deliveries (id, endpoint_id, org_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+             VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?)`,
+			id, ep.ID, orgID, eventID, eventType, string(payload), DeliveryPending, ts, ts, ts,
+		); err != nil {
+			return env, fmt.Errorf("insert webhook delivery: %w", err)
+		}
+	}
+	return env, nil
+}
+
+// GetDelivery returns a delivery belonging to orgID.
+func (s *Service) GetDelivery(ctx context.Context, orgID, deliveryID string) (Delivery, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+deliveryColumns+`
+           FROM webhook_deliveries
+          WHERE id = ? AND org_id = ?`,
+		deliveryID, orgID,
+	)
+	d, err := scanDelivery(row)
+	if err == sql.ErrNoRows {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, err
+}
+
+// ListDeliveries returns the deliveries of an endpoint, newest first. opts
+// supports PageSize, Cursor (the NextCursor of the previous page) and Status.
+func (s *Service) ListDeliveries(ctx context.Context, orgID, endpointID string, opts client_identity.ListOptions) (client_identity.Page[Delivery], error) {
+	if _, err := s.GetEndpoint(ctx, orgID, endpointID); err != nil {
+		return client_identity.Page[Delivery]{}, err
+	}
+	size := opts.PageSize
+	if size <= 0 {
+		size = defaultDeliveryPageSize
+	}
+	size = min(size, client_identity.MaxPageSize)
+
+	query := `SELECT ` + deliveryColumns + `
+                FROM webhook_deliveries
+               WHERE endpoint_id = ?`
+	args := []any{endpointID}
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.Cursor != "" {
+		// Keyset pagination on (created_at, id) from the cursor delivery.
+		query += ` AND (created_at, id) < (SELECT created_at, id FROM webhook_deliveries WHERE id = ?)`
+		args = append(args, opts.Cursor)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, size+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return client_identity.Page[Delivery]{}, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	page := client_identity.Page[Delivery]{Items: []Delivery{}}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return client_identity.Page[Delivery]{}, err
+		}
+		page.Items = append(page.Items, d)
+	}
+	if err := rows.Err(); err != nil {
+		return client_identity.Page[Delivery]{}, err
+	}
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextCursor = page.Items[size-1].ID
+	}
+	return page, nil
+}
+
+// ListAttempts returns the request log of a delivery, in attempt order.
+func (s *Service) ListAttempts(ctx context.Context, orgID, deliveryID string) ([]Attempt, error) {
+	if _, err := s.GetDelivery(ctx, orgID, deliveryID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, delivery_id, endpoint_id, attempt, status_code, error, response_body, duration_ms, created_at
+           FROM webhook_delivery_attempts
+          WHERE delivery_id = ?
+          ORDER BY attempt`,
+		deliveryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		var (
+			a                 Attempt
+			statusCode        sql.NullInt64
+			errText, respBody sql.NullString
+			createdAt         string
+		)
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.EndpointID, &a.Attempt, &statusCode, &errText, &respBody,
+			&a.DurationMS, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan webhook attempt: %w", err)
+		}
+		a.StatusCode = int(statusCode.Int64)
+		a.Error = errText.String
+		a.ResponseBody = respBody.String
+		a.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// Redeliver queues a delivery to be sent again straight away, whatever its
+// current status. The attempt counter restarts so it gets a full set of retries.
+func (s *Service) Redeliver(ctx context.Context, orgID, deliveryID string) (Delivery, error) {
+	d, err := s.GetDelivery(ctx, orgID, deliveryID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	ep, err := s.GetEndpoint(ctx, orgID, d.EndpointID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !ep.Active {
+		return Delivery{}, ErrEndpointDisabled
+	}
+
+	now := time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+            SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+          WHERE id = ?`,
+		DeliveryPending, shared_helpers.FormatDBTime(now), shared_helpers.FormatDBTime(now), deliveryID,
+	); err != nil {
+		return Delivery{}, fmt.Errorf("requeue webhook delivery: %w", err)
+	}
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.UpdatedAt = now
+	return d, nil
+}
+
+const deliveryColumns = `id, endpoint_id, org_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+       last_status_code, last_error, created_at, updated_at, delivered_at`
+
+func scanDelivery(row scanner) (Delivery, error) {
+	var (
+		d                                   Delivery
+		nextAttemptAt, createdAt, updatedAt string
+		lastStatusCode                      sql.NullInt64
+		lastError, deliveredAt              sql.NullString
+	)
+	if err := row.Scan(&d.ID, &d.EndpointID, &d.OrgID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&nextAttemptAt, &lastStatusCode, &lastError, &createdAt, &updatedAt, &deliveredAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Delivery{}, err
+		}
+		return Delivery{}, fmt.Errorf("scan webhook delivery: %w", err)
+	}
+	d.NextAttemptAt = shared_helpers.ParseDBTime(nextAttemptAt)
+	d.LastStatusCode = int(lastStatusCode.Int64)
+	d.LastError = lastError.String
+	d.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	d.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	d.DeliveredAt = shared_helpers.ParseNullDBTime(deliveredAt)
+	return d, nil
+}
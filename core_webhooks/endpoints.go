@@ -0,0 +1,306 @@
+package core_webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_helpers"
+)
+
+// SecretPrefix marks endpoint signing secrets so they are recognisable in logs and config.
+const SecretPrefix = "whsec_"
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrEndpointDisabled = errors.New("webhook endpoint is disabled")
+)
+
+// Endpoint is a customer URL subscribed to events of one organisation.
+type Endpoint struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Subscribed reports whether the endpoint wants eventType. An empty
+// EventTypes list subscribes to everything; "user.*" matches a whole family.
+func (e Endpoint) Subscribed(eventType string) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == "*" || t == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateEndpointRequest is the request body for POST /api/organisations/{id}/webhooks
+type CreateEndpointRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// UpdateEndpointRequest is the request body for PUT /api/organisations/{id}/webhooks/{endpoint_id}
+type UpdateEndpointRequest struct {
+	URL         *string   `json:"url,omitempty"`
+	EventTypes  *[]string `json:"event_types,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// Service manages webhook endpoints and deliveries stored in CoreDB.
+type Service struct {
+	db *sql.DB
+
+	// AllowPrivateNetworks lets endpoints point at loopback and other private
+	// addresses. It is for tests and local development only; set it before
+	// creating a Worker, whose client it also configures.
+	AllowPrivateNetworks bool
+}
+
+// NewService creates a webhook service backed by db.
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SecretPrefix + hex.EncodeToString(b), nil
+}
+
+func cleanEventTypes(types []string) []string {
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// CreateEndpoint registers a new endpoint for orgID with a freshly generated
+// secret. The secret is only returned here and from RotateSecret.
+func (s *Service) CreateEndpoint(ctx context.Context, orgID string, req CreateEndpointRequest) (Endpoint, error) {
+	if err := s.validateURL(req.URL); err != nil {
+		return Endpoint{}, err
+	}
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("generate webhook secret: %w", err)
+	}
+
+	now := time.Now().UTC()
+	ep := Endpoint{
+		ID:          id,
+		OrgID:       orgID,
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  cleanEventTypes(req.EventTypes),
+		Description: req.Description,
+		Active:      true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_endpoints (id, org_id, url, secret, event_types, description, active, created_at, updated_at)
+         VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+		ep.ID, ep.OrgID, ep.URL, ep.Secret, strings.Join(ep.EventTypes, ","), ep.Description,
+		shared_helpers.FormatDBTime(now), shared_helpers.FormatDBTime(now),
+	); err != nil {
+		return Endpoint{}, fmt.Errorf("insert webhook endpoint: %w", err)
+	}
+	return ep, nil
+}
+
+// GetEndpoint returns an endpoint of orgID without its secret.
+func (s *Service) GetEndpoint(ctx context.Context, orgID, endpointID string) (Endpoint, error) {
+	ep, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if ep.OrgID != orgID {
+		return Endpoint{}, ErrEndpointNotFound
+	}
+	ep.Secret = ""
+	return ep, nil
+}
+
+// ListEndpoints returns the endpoints of orgID without their secrets, oldest first.
+func (s *Service) ListEndpoints(ctx context.Context, orgID string) ([]Endpoint, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+endpointColumns+`
+           FROM webhook_endpoints
+          WHERE org_id = ?
+          ORDER BY created_at`,
+		orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []Endpoint
+	for rows.Next() {
+		ep, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		ep.Secret = ""
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, rows.Err()
+}
+
+// UpdateEndpoint changes the URL, subscriptions, description or active flag of an endpoint.
+func (s *Service) UpdateEndpoint(ctx context.Context, orgID, endpointID string, req UpdateEndpointRequest) (Endpoint, error) {
+	ep, err := s.GetEndpoint(ctx, orgID, endpointID)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if req.URL != nil {
+		if err := s.validateURL(*req.URL); err != nil {
+			return Endpoint{}, err
+		}
+		ep.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		ep.EventTypes = cleanEventTypes(*req.EventTypes)
+	}
+	if req.Description != nil {
+		ep.Description = *req.Description
+	}
+	if req.Active != nil {
+		ep.Active = *req.Active
+	}
+	ep.UpdatedAt = time.Now().UTC()
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_endpoints
+            SET url = ?, event_types = ?, description = ?, active = ?, updated_at = ?
+          WHERE id = ? AND org_id = ?`,
+		ep.URL, strings.Join(ep.EventTypes, ","), ep.Description, ep.Active, shared_helpers.FormatDBTime(ep.UpdatedAt),
+		endpointID, orgID,
+	); err != nil {
+		return Endpoint{}, fmt.Errorf("update webhook endpoint: %w", err)
+	}
+	return ep, nil
+}
+
+// RotateSecret replaces the signing secret of an endpoint and returns the endpoint with the new secret.
+func (s *Service) RotateSecret(ctx context.Context, orgID, endpointID string) (Endpoint, error) {
+	ep, err := s.GetEndpoint(ctx, orgID, endpointID)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("generate webhook secret: %w", err)
+	}
+	ep.Secret = secret
+	ep.UpdatedAt = time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_endpoints SET secret = ?, updated_at = ? WHERE id = ? AND org_id = ?`,
+		secret, shared_helpers.FormatDBTime(ep.UpdatedAt), endpointID, orgID,
+	); err != nil {
+		return Endpoint{}, fmt.Errorf("rotate webhook secret: %w", err)
+	}
+	return ep, nil
+}
+
+// DeleteEndpoint removes an endpoint. Its pending deliveries are dead-lettered.
+func (s *Service) DeleteEndpoint(ctx context.Context, orgID, endpointID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM webhook_endpoints WHERE id = ? AND org_id = ?`,
+		endpointID, orgID,
+	)
+	if err != nil {
+		return fmt.Errorf("delete webhook endpoint: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEndpointNotFound
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+            SET status = ?, last_error = ?, updated_at = ?
+          WHERE endpoint_id = ? AND status = ?`,
+		DeliveryDead, "endpoint deleted", shared_helpers.FormatDBTime(time.Now()), endpointID, DeliveryPending,
+	); err != nil {
+		return fmt.Errorf("dead-letter webhook deliveries: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *Service) getEndpoint(ctx context.Context, endpointID string) (Endpoint, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+endpointColumns+`
+           FROM webhook_endpoints
+          WHERE id = ?`,
+		endpointID,
+	)
+	ep, err := scanEndpoint(row)
+	if err == sql.ErrNoRows {
+		return Endpoint{}, ErrEndpointNotFound
+	}
+	return ep, err
+}
+
+const endpointColumns = `id, org_id, url, secret, event_types, description, active, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEndpoint(row scanner) (Endpoint, error) {
+	var (
+		ep                   Endpoint
+		eventTypes           string
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&ep.ID, &ep.OrgID, &ep.URL, &ep.Secret, &eventTypes, &ep.Description, &ep.Active,
+		&createdAt, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Endpoint{}, err
+		}
+		return Endpoint{}, fmt.Errorf("scan webhook endpoint: %w", err)
+	}
+	ep.EventTypes = []string{}
+	if eventTypes != "" {
+		ep.EventTypes = strings.Split(eventTypes, ",")
+	}
+	ep.CreatedAt = shared_helpers.ParseDBTime(createdAt)
+	ep.UpdatedAt = shared_helpers.ParseDBTime(updatedAt)
+	return ep, nil
+}
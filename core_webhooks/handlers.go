@@ -0,0 +1,166 @@
+package core_webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// Mount these handlers behind shared_rbac.RequireOrgPermission(shared_rbac.PermOrgUpdate).
+
+// CreateEndpointHandler serves POST /api/organisations/{id}/webhooks.
+func CreateEndpointHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID := mux.Vars(r)["id"]
+		var req CreateEndpointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.CreateEndpoint(r.Context(), orgID, req)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
+
+// ListEndpointsHandler serves GET /api/organisations/{id}/webhooks.
+func ListEndpointsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID := mux.Vars(r)["id"]
+		resp, err := s.ListEndpoints(r.Context(), orgID)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		if resp == nil {
+			resp = []Endpoint{}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// GetEndpointHandler serves GET /api/organisations/{id}/webhooks/{endpoint_id}.
+func GetEndpointHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		resp, err := s.GetEndpoint(r.Context(), vars["id"], vars["endpoint_id"])
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// UpdateEndpointHandler serves PUT /api/organisations/{id}/webhooks/{endpoint_id}.
+func UpdateEndpointHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		var req UpdateEndpointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.UpdateEndpoint(r.Context(), vars["id"], vars["endpoint_id"], req)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// RotateSecretHandler serves POST /api/organisations/{id}/webhooks/{endpoint_id}/rotate-secret.
+func RotateSecretHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		resp, err := s.RotateSecret(r.Context(), vars["id"], vars["endpoint_id"])
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// DeleteEndpointHandler serves DELETE /api/organisations/{id}/webhooks/{endpoint_id}.
+func DeleteEndpointHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if err := s.DeleteEndpoint(r.Context(), vars["id"], vars["endpoint_id"]); err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, client_identity.DeleteResponse{Success: true, Message: "webhook endpoint deleted"})
+	}
+}
+
+// ListDeliveriesHandler serves GET /api/organisations/{id}/webhooks/{endpoint_id}/deliveries.
+// It accepts the page_size, cursor and status query parameters.
+func ListDeliveriesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		opts := client_identity.ListOptionsFromQuery(r.URL.Query())
+		resp, err := s.ListDeliveries(r.Context(), vars["id"], vars["endpoint_id"], opts)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ListAttemptsHandler serves GET /api/organisations/{id}/webhooks/deliveries/{delivery_id}/attempts.
+func ListAttemptsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		resp, err := s.ListAttempts(r.Context(), vars["id"], vars["delivery_id"])
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// RedeliverHandler serves POST /api/organisations/{id}/webhooks/deliveries/{delivery_id}/redeliver.
+// worker may be nil; when set it is woken so the delivery is sent immediately.
+func RedeliverHandler(s *Service, worker *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		resp, err := s.Redeliver(r.Context(), vars["id"], vars["delivery_id"])
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		if worker != nil {
+			worker.Kick()
+		}
+		writeJSON(w, http.StatusAccepted, resp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrPrivateAddress):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrEndpointNotFound), errors.Is(err, ErrDeliveryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrEndpointDisabled):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, client_identity.ErrorResponse{Error: err.Error()})
+}
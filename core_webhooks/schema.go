@@ -0,0 +1,67 @@
+package core_webhooks
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EnsureSchema creates the webhook endpoint, delivery and attempt tables in CoreDB if they do not exist.
+func EnsureSchema(db *sql.DB) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"webhook_endpoints", `CREATE TABLE IF NOT EXISTS webhook_endpoints (
+             id          TEXT PRIMARY KEY,
+             org_id      TEXT NOT NULL,
+             url         TEXT NOT NULL,
+             secret      TEXT NOT NULL,
+             event_types TEXT NOT NULL DEFAULT '',
+             description TEXT NOT NULL DEFAULT '',
+             active      INTEGER NOT NULL DEFAULT 1,
+             created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+         )`},
+		{"webhook_endpoints index", `CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_org
+             ON webhook_endpoints (org_id)`},
+		{"webhook_deliveries", `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+             id               TEXT PRIMARY KEY,
+             endpoint_id      TEXT NOT NULL,
+             org_id           TEXT NOT NULL,
+             event_id         TEXT NOT NULL,
+             event_type       TEXT NOT NULL,
+             payload          TEXT NOT NULL,
+             status           TEXT NOT NULL,
+             attempts         INTEGER NOT NULL DEFAULT 0,
+             next_attempt_at  TIMESTAMP NOT NULL,
+             last_status_code INTEGER,
+             last_error       TEXT,
+             created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+             delivered_at     TIMESTAMP
+         )`},
+		{"webhook_deliveries due index", `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+             ON webhook_deliveries (status, next_attempt_at)`},
+		{"webhook_deliveries endpoint index", `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint
+             ON webhook_deliveries (endpoint_id, created_at)`},
+		{"webhook_delivery_attempts", `CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+             id            TEXT PRIMARY KEY,
+             delivery_id   TEXT NOT NULL,
+             endpoint_id   TEXT NOT NULL,
+             attempt       INTEGER NOT NULL,
+             status_code   INTEGER,
+             error         TEXT,
+             response_body TEXT,
+             duration_ms   INTEGER NOT NULL,
+             created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+         )`},
+		{"webhook_delivery_attempts index", `CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery
+             ON webhook_delivery_attempts (delivery_id, attempt)`},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.sql); err != nil {
+			return fmt.Errorf("create %s: %w", stmt.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package core_webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when an endpoint URL names, or resolves to,
+// an address inside our own network rather than on the public internet.
+var ErrPrivateAddress = errors.New("webhook URL must not point at a private or reserved address")
+
+const defaultDialTimeout = 10 * time.Second
+
+// reservedPrefixes are ranges not covered by the netip predicates used in
+// blockedAddr that must still never be reached from a delivery.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which can embed any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, likewise
+}
+
+// blockedAddr reports whether a delivery must not connect to addr: loopback,
+// RFC 1918 and unique-local ranges, link-local (which holds the cloud
+// metadata service at 169.254.169.254), multicast, unspecified and other
+// reserved addresses.
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDial is a net.Dialer Control hook. It runs after name resolution, on
+// the address actually being connected to, so a public host name that
+// resolves to a private address is refused as well.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if blockedAddr(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ap.Addr())
+	}
+	return nil
+}
+
+// NewHTTPClient returns the client a Worker sends deliveries with. Unless
+// allowPrivate is set, it refuses to connect to private and reserved
+// addresses and ignores proxy settings, which would otherwise connect on its
+// behalf. Redirects are never followed: the 3xx response is recorded as a
+// failed attempt, so a receiver cannot bounce a delivery to another host.
+//
+// allowPrivate exists for tests and local development, which deliver to
+// servers on the loopback interface.
+func NewHTTPClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = checkDial
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   defaultClientTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (s *Service) validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidURL
+	}
+	if s.AllowPrivateNetworks {
+		return nil
+	}
+	// Host names are only checked when the worker connects, as DNS can change
+	// after registration; literal addresses and localhost are refused up front.
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && blockedAddr(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
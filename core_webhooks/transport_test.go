@@ -0,0 +1,94 @@
+package core_webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestBlockedAddr(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "100.64.0.1", "255.255.255.255", "224.0.0.1",
+		"::1", "::", "fe80::1", "fd00:ec2::254", "::ffff:127.0.0.1", "64:ff9b::a9fe:a9fe",
+	}
+	for _, s := range blocked {
+		if !blockedAddr(netip.MustParseAddr(s)) {
+			t.Errorf("blockedAddr(%s) = false, want true", s)
+		}
+	}
+	allowed := []string{"93.184.216.34", "1.1.1.1", "2606:4700:4700::1111"}
+	for _, s := range allowed {
+		if blockedAddr(netip.MustParseAddr(s)) {
+			t.Errorf("blockedAddr(%s) = true, want false", s)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	svc := NewService(nil)
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://example.com/hooks", nil},
+		{"http://203.0.113.7:8080/hooks", nil},
+		{"ftp://example.com/hooks", ErrInvalidURL},
+		{"/hooks", ErrInvalidURL},
+		{"http://localhost:8080/hooks", ErrPrivateAddress},
+		{"http://127.0.0.1/hooks", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"http://[::1]/hooks", ErrPrivateAddress},
+		{"http://10.0.0.5/hooks", ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		if err := svc.validateURL(tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("validateURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+
+	svc.AllowPrivateNetworks = true
+	if err := svc.validateURL("http://127.0.0.1/hooks"); err != nil {
+		t.Errorf("validateURL with AllowPrivateNetworks = %v, want nil", err)
+	}
+}
+
+func TestHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewHTTPClient(false).Get(srv.URL)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("Get(%s) = %v, want ErrPrivateAddress", srv.URL, err)
+	}
+
+	resp, err := NewHTTPClient(true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get with private networks allowed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHTTPClientDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := NewHTTPClient(true).Get(srv.URL + "/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || followed {
+		t.Fatalf("status = %d, followed = %v; want 302 and not followed", resp.StatusCode, followed)
+	}
+}
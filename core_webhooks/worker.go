@@ -0,0 +1,333 @@
+package core_webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_webhooks"
+)
+
+// Headers sent with every delivery alongside shared_webhooks.SignatureHeader.
+const (
+	HeaderEventID    = "X-Hstles-Event-ID"
+	HeaderEventType  = "X-Hstles-Event-Type"
+	HeaderDeliveryID = "X-Hstles-Delivery-ID"
+)
+
+const (
+	defaultPollInterval  = 5 * time.Second
+	defaultBatchSize     = 50
+	defaultConcurrency   = 8
+	defaultMaxAttempts   = 8
+	defaultBaseBackoff   = 30 * time.Second
+	defaultMaxBackoff    = 6 * time.Hour
+	defaultLease         = 2 * time.Minute
+	defaultClientTimeout = 15 * time.Second
+	maxLoggedResponse    = 4 << 10
+)
+
+// Worker sends due deliveries, retrying failures with exponential backoff
+// and dead-lettering them after MaxAttempts.
+type Worker struct {
+	svc *Service
+
+	// Client sends the deliveries. Its Timeout bounds each attempt. The
+	// default from NewHTTPClient refuses private addresses and redirects.
+	Client *http.Client
+	// PollInterval is how often Start looks for due deliveries.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of deliveries claimed per poll.
+	BatchSize int
+	// Concurrency is the number of deliveries sent in parallel.
+	Concurrency int
+	// MaxAttempts is the number of failed attempts after which a delivery is dead.
+	MaxAttempts int
+	// BaseBackoff and MaxBackoff bound the delay before retry n: BaseBackoff*2^(n-1).
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed delivery is hidden from other workers while it is sent.
+	Lease time.Duration
+
+	kick chan struct{}
+}
+
+// NewWorker creates a Worker sending the deliveries queued in svc.
+func NewWorker(svc *Service) *Worker {
+	return &Worker{
+		svc:          svc,
+		Client:       NewHTTPClient(svc.AllowPrivateNetworks),
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Concurrency:  defaultConcurrency,
+		MaxAttempts:  defaultMaxAttempts,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		Lease:        defaultLease,
+		kick:         make(chan struct{}, 1),
+	}
+}
+
+// Kick wakes Start without waiting for the next poll, e.g. after Publish.
+func (w *Worker) Kick() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start polls for due deliveries every PollInterval until ctx is cancelled.
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("core_webhooks: delivery run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+	}
+}
+
+// RunOnce claims up to BatchSize due deliveries, sends them and records the
+// outcome. It returns the number of deliveries attempted.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	due, err := w.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sem := make(chan struct{}, max(w.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, d := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(d Delivery) {
+			defer func() { <-sem; wg.Done() }()
+			if err := w.deliver(ctx, d); err != nil {
+				log.Printf("core_webhooks: delivery %s: %v", d.ID, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// claim selects due deliveries and leases each one by pushing its
+// next_attempt_at forward. The conditional UPDATE means only one worker wins a delivery.
+func (w *Worker) claim(ctx context.Context) ([]Delivery, error) {
+	now := time.Now().UTC()
+	rows, err := w.svc.db.QueryContext(ctx,
+		`SELECT `+deliveryColumns+`
+           FROM webhook_deliveries
+          WHERE status = ? AND next_attempt_at <= ?
+          ORDER BY next_attempt_at
+          LIMIT ?`,
+		DeliveryPending, shared_helpers.FormatDBTime(now), max(w.BatchSize, 1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query due deliveries: %w", err)
+	}
+	var candidates []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query due deliveries: %w", err)
+	}
+
+	leaseUntil := now.Add(w.Lease)
+	lease := shared_helpers.FormatDBTime(leaseUntil)
+	var claimed []Delivery
+	for _, d := range candidates {
+		res, err := w.svc.db.ExecContext(ctx,
+			`UPDATE webhook_deliveries
+                SET next_attempt_at = ?
+              WHERE id = ? AND status = ? AND next_attempt_at = ?`,
+			lease, d.ID, DeliveryPending, shared_helpers.FormatDBTime(d.NextAttemptAt),
+		)
+		if err != nil {
+			return claimed, fmt.Errorf("lease delivery %s: %w", d.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			// finish matches on the lease to tell whether it still holds it.
+			d.NextAttemptAt = leaseUntil
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+// Backoff returns the delay before the retry following failed attempt n (1-based),
+// with up to 10% jitter so endpoints recovering from an outage are not stampeded.
+func (w *Worker) Backoff(n int) time.Duration {
+	d := w.BaseBackoff
+	for i := 1; i < n && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, w.MaxBackoff)
+	if d > 0 {
+		d += time.Duration(rand.Int64N(int64(d)/10 + 1))
+	}
+	return d
+}
+
+func (w *Worker) deliver(ctx context.Context, d Delivery) error {
+	ep, err := w.svc.getEndpoint(ctx, d.EndpointID)
+	if errors.Is(err, ErrEndpointNotFound) {
+		return w.finish(ctx, d, DeliveryDead, 0, "endpoint deleted", time.Time{})
+	}
+	if err != nil {
+		return err
+	}
+	if !ep.Active {
+		return w.finish(ctx, d, DeliveryDead, 0, ErrEndpointDisabled.Error(), time.Time{})
+	}
+
+	attempt := d.Attempts + 1
+	start := time.Now()
+	statusCode, respBody, sendErr := w.send(ctx, ep, d)
+	duration := time.Since(start)
+
+	errText := ""
+	if sendErr != nil {
+		errText = sendErr.Error()
+	} else if statusCode < 200 || statusCode >= 300 {
+		errText = fmt.Sprintf("unexpected status %d", statusCode)
+	}
+	if err := w.logAttempt(ctx, d, attempt, statusCode, errText, respBody, duration); err != nil {
+		log.Printf("core_webhooks: log attempt for %s: %v", d.ID, err)
+	}
+
+	d.Attempts = attempt
+	out := w.outcome(attempt, statusCode, errText)
+	if out.disable {
+		// The receiver told us the endpoint no longer exists; stop sending to it.
+		if _, err := w.svc.db.ExecContext(ctx,
+			`UPDATE webhook_endpoints SET active = 0, updated_at = ? WHERE id = ?`,
+			shared_helpers.FormatDBTime(time.Now()), ep.ID,
+		); err != nil {
+			log.Printf("core_webhooks: disable endpoint %s: %v", ep.ID, err)
+		}
+	}
+	return w.finish(ctx, d, out.status, statusCode, errText, out.next)
+}
+
+// attemptOutcome is what an attempt leaves the delivery as.
+type attemptOutcome struct {
+	status  string
+	next    time.Time
+	disable bool
+}
+
+// outcome decides the delivery status after attempt n (1-based) ended with
+// statusCode and errText, which is empty for a 2xx response.
+func (w *Worker) outcome(attempt, statusCode int, errText string) attemptOutcome {
+	switch {
+	case errText == "":
+		return attemptOutcome{status: DeliverySucceeded}
+	case statusCode == http.StatusGone:
+		return attemptOutcome{status: DeliveryDead, disable: true}
+	case attempt >= w.MaxAttempts:
+		return attemptOutcome{status: DeliveryDead}
+	default:
+		return attemptOutcome{status: DeliveryPending, next: time.Now().Add(w.Backoff(attempt))}
+	}
+}
+
+func (w *Worker) send(ctx context.Context, ep Endpoint, d Delivery) (int, string, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hstles-webhooks/1.0")
+	req.Header.Set(shared_webhooks.SignatureHeader, shared_webhooks.Sign([]byte(ep.Secret), time.Now(), body))
+	req.Header.Set(HeaderEventID, d.EventID)
+	req.Header.Set(HeaderEventType, d.EventType)
+	req.Header.Set(HeaderDeliveryID, d.ID)
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	return resp.StatusCode, string(respBody), nil
+}
+
+func (w *Worker) logAttempt(ctx context.Context, d Delivery, attempt, statusCode int, errText, respBody string, duration time.Duration) error {
+	id, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		return fmt.Errorf("GenerateCondensedUUID: %w", err)
+	}
+	_, err = w.svc.db.ExecContext(ctx,
+		`INSERT INTO webhook_delivery_attempts (id, delivery_id, endpoint_id, attempt, status_code, error, response_body, duration_ms, created_at)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, d.ID, d.EndpointID, attempt, nullInt(statusCode), nullString(errText), nullString(respBody),
+		duration.Milliseconds(), shared_helpers.FormatDBTime(time.Now()),
+	)
+	return err
+}
+
+// finish records the outcome of an attempt. next is only used for pending
+// deliveries. Nothing is written when the lease expired and another worker
+// claimed the delivery in the meantime.
+func (w *Worker) finish(ctx context.Context, d Delivery, status string, statusCode int, errText string, next time.Time) error {
+	now := time.Now().UTC()
+	if next.IsZero() {
+		next = now
+	}
+	var deliveredAt any
+	if status == DeliverySucceeded {
+		deliveredAt = shared_helpers.FormatDBTime(now)
+	}
+	res, err := w.svc.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+            SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?,
+                updated_at = ?, delivered_at = COALESCE(?, delivered_at)
+          WHERE id = ? AND next_attempt_at = ?`,
+		status, d.Attempts, shared_helpers.FormatDBTime(next), nullInt(statusCode), nullString(errText),
+		shared_helpers.FormatDBTime(now), deliveredAt, d.ID, shared_helpers.FormatDBTime(d.NextAttemptAt),
+	)
+	if err != nil {
+		return fmt.Errorf("update delivery: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		log.Printf("core_webhooks: delivery %s: lease expired before its %s outcome was recorded", d.ID, status)
+	}
+	return nil
+}
+
+func nullInt(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
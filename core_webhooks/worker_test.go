@@ -0,0 +1,300 @@
+package core_webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_helpers"
+	"github.com/hstles/go-sdk/shared_webhooks"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+// receiver is a local endpoint that answers with the queued status codes,
+// then 200, and records what it was sent.
+type receiver struct {
+	t      *testing.T
+	secret []byte
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := shared_webhooks.Verify([][]byte{rc.secret}, r.Header.Get(shared_webhooks.SignatureHeader), body, shared_webhooks.DefaultTolerance, time.Now()); err != nil {
+		rc.t.Errorf("signature does not verify: %v", err)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) respond(statuses ...int) {
+	rc.mu.Lock()
+	rc.statuses = append(rc.statuses, statuses...)
+	rc.mu.Unlock()
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	rc := &receiver{t: t, secret: []byte("whsec_test")}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	svc := NewService(nil)
+	svc.AllowPrivateNetworks = true
+	w := NewWorker(svc)
+	ep := Endpoint{ID: "ep1", URL: srv.URL, Secret: string(rc.secret)}
+	d := Delivery{ID: "dl1", EventID: "ev1", EventType: "user.created", Payload: `{"id":"ev1"}`}
+
+	status, _, err := w.send(context.Background(), ep, d)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send = %d, %v; want 200", status, err)
+	}
+	r := rc.requests[0]
+	for header, want := range map[string]string{
+		HeaderEventID:    "ev1",
+		HeaderEventType:  "user.created",
+		HeaderDeliveryID: "dl1",
+		"Content-Type":   "application/json",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if string(rc.bodies[0]) != d.Payload {
+		t.Errorf("body = %s, want %s", rc.bodies[0], d.Payload)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	w := NewWorker(NewService(nil))
+	w.MaxAttempts = 3
+	w.BaseBackoff = time.Minute
+	w.MaxBackoff = time.Hour
+
+	tests := []struct {
+		name       string
+		attempt    int
+		statusCode int
+		errText    string
+		want       string
+		disable    bool
+		retry      bool
+	}{
+		{"success", 1, 204, "", DeliverySucceeded, false, false},
+		{"server error retries", 1, 500, "unexpected status 500", DeliveryPending, false, true},
+		{"network error retries", 2, 0, "connection refused", DeliveryPending, false, true},
+		{"dead after max attempts", 3, 500, "unexpected status 500", DeliveryDead, false, false},
+		{"gone disables endpoint", 1, 410, "unexpected status 410", DeliveryDead, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			out := w.outcome(tt.attempt, tt.statusCode, tt.errText)
+			if out.status != tt.want || out.disable != tt.disable {
+				t.Fatalf("outcome = %+v, want status %s, disable %v", out, tt.want, tt.disable)
+			}
+			if tt.retry {
+				delay := out.next.Sub(before)
+				base := w.BaseBackoff << (tt.attempt - 1)
+				if delay < base || delay > base+base/10+time.Second {
+					t.Errorf("retry after %v, want about %v", delay, base)
+				}
+			} else if !out.next.IsZero() {
+				t.Errorf("next = %v, want zero", out.next)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := NewWorker(NewService(nil))
+	w.BaseBackoff = time.Second
+	w.MaxBackoff = 10 * time.Second
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 20: 10 * time.Second} {
+		if got := w.Backoff(n); got < want || got > want+want/10 {
+			t.Errorf("Backoff(%d) = %v, want %v plus up to 10%%", n, got, want)
+		}
+	}
+}
+
+// testDB opens the libsql database named by CORE_TEST_DATABASE_URL, e.g. a
+// local sqld at http://127.0.0.1:8080. The worker claims every due delivery
+// in the database, so it should be one used only by tests.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("libsql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := EnsureSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWorkerDelivers(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(testDB(t))
+	svc.AllowPrivateNetworks = true
+
+	rc := &receiver{t: t}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	orgID, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep, err := svc.CreateEndpoint(ctx, orgID, CreateEndpointRequest{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.secret = []byte(ep.Secret)
+
+	w := NewWorker(svc)
+	w.MaxAttempts = 2
+	w.BaseBackoff = time.Millisecond
+	w.MaxBackoff = time.Millisecond
+
+	run := func() {
+		t.Helper()
+		time.Sleep(5 * time.Millisecond)
+		if _, err := w.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest := func() Delivery {
+		t.Helper()
+		page, err := svc.ListDeliveries(ctx, orgID, ep.ID, client_identity.ListOptions{PageSize: 1})
+		if err != nil || len(page.Items) != 1 {
+			t.Fatalf("ListDeliveries = %+v, %v", page, err)
+		}
+		return page.Items[0]
+	}
+
+	// Two failures exhaust MaxAttempts and dead-letter the delivery.
+	rc.respond(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	if _, err := svc.Publish(ctx, orgID, "user.created", map[string]string{"id": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	run()
+	if d := latest(); d.Status != DeliveryPending || d.Attempts != 1 || d.LastStatusCode != 500 {
+		t.Fatalf("after first failure: %+v", d)
+	}
+	run()
+	d := latest()
+	if d.Status != DeliveryDead || d.Attempts != 2 || d.LastStatusCode != 503 {
+		t.Fatalf("after second failure: %+v", d)
+	}
+	attempts, err := svc.ListAttempts(ctx, orgID, d.ID)
+	if err != nil || len(attempts) != 2 {
+		t.Fatalf("ListAttempts = %d, %v; want 2", len(attempts), err)
+	}
+
+	// Redelivery resets the attempts and sends it again.
+	if _, err := svc.Redeliver(ctx, orgID, d.ID); err != nil {
+		t.Fatal(err)
+	}
+	run()
+	if d := latest(); d.Status != DeliverySucceeded || d.Attempts != 1 || d.DeliveredAt == nil {
+		t.Fatalf("after redelivery: %+v", d)
+	}
+	if n := rc.count(); n != 3 {
+		t.Fatalf("receiver got %d requests, want 3", n)
+	}
+
+	// 410 Gone dead-letters the delivery and disables the endpoint.
+	rc.respond(http.StatusGone)
+	if _, err := svc.Publish(ctx, orgID, "user.updated", map[string]string{"id": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	run()
+	if d = latest(); d.Status != DeliveryDead || d.LastStatusCode != http.StatusGone {
+		t.Fatalf("after 410: %+v", d)
+	}
+	got, err := svc.GetEndpoint(ctx, orgID, ep.ID)
+	if err != nil || got.Active {
+		t.Fatalf("endpoint after 410: %+v, %v; want inactive", got, err)
+	}
+	if _, err := svc.Redeliver(ctx, orgID, d.ID); !errors.Is(err, ErrEndpointDisabled) {
+		t.Fatalf("Redeliver to disabled endpoint = %v, want ErrEndpointDisabled", err)
+	}
+}
+
+func TestFinishRequiresLease(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(testDB(t))
+	svc.AllowPrivateNetworks = true
+	orgID, err := shared_helpers.GenerateCondensedUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateEndpoint(ctx, orgID, CreateEndpointRequest{URL: "http://127.0.0.1:1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Publish(ctx, orgID, "user.created", map[string]string{"id": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorker(svc)
+	time.Sleep(5 * time.Millisecond)
+	claimed, err := w.claim(ctx)
+	if err != nil || len(claimed) == 0 {
+		t.Fatalf("claim = %d, %v", len(claimed), err)
+	}
+	var d Delivery
+	for _, c := range claimed {
+		if c.OrgID == orgID {
+			d = c
+		}
+	}
+	if d.ID == "" {
+		t.Fatal("the published delivery was not claimed")
+	}
+
+	// The lease expires and another worker claims the delivery.
+	if _, err := svc.db.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`,
+		shared_helpers.FormatDBTime(time.Now().Add(time.Hour)), d.ID); err != nil {
+		t.Fatal(err)
+	}
+	d.Attempts = 1
+	if err := w.finish(ctx, d, DeliverySucceeded, http.StatusOK, "", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	if err := svc.db.QueryRowContext(ctx, `SELECT status FROM webhook_deliveries WHERE id = ?`, d.ID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != DeliveryPending {
+		t.Errorf("status = %s after finishing without the lease, want it left pending", status)
+	}
+}
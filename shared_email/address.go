@@ -0,0 +1,280 @@
+// Package shared_email validates and normalises email addresses so the same
+// mailbox is always stored, looked up and notified under one spelling.
+//
+// Normalise is the form to store and compare: surrounding whitespace is
+// trimmed, the address is checked against the RFC 5322 addr-spec grammar,
+// the domain is converted to lower-case ASCII (internationalised domains
+// become punycode "xn--" labels) and the local part is lower-cased.
+// RFC 5321 allows case-sensitive local parts, but no mainstream provider
+// treats them so, and "Foo@Example.com" and "foo@example.com" must not
+// become different users.
+//
+// Canonicalise goes further for duplicate detection, dropping "+tag"
+// sub-addresses and the dots Gmail ignores. It is not meant for storage:
+// mail should still go to the address the user gave.
+package shared_email
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Length limits from RFC 5321 section 4.5.3.1.
+const (
+	MaxLocalLength   = 64
+	MaxDomainLength  = 253
+	MaxLabelLength   = 63
+	MaxAddressLength = 254
+)
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Address is a parsed, normalised email address.
+type Address struct {
+	// Local is the part before the "@", lower-cased. A quoted local part
+	// keeps its quotes only when it needs them.
+	Local string
+	// Domain is the lower-case ASCII domain, with IDN labels in punycode.
+	Domain string
+}
+
+// String returns the address in its stored form, e.g. "foo@xn--bcher-kva.example".
+func (a Address) String() string {
+	return a.Local + "@" + a.Domain
+}
+
+// Unicode returns the address with its domain in Unicode for display,
+// e.g. "foo@bücher.example".
+func (a Address) Unicode() string {
+	domain, err := ToUnicode(a.Domain)
+	if err != nil {
+		return a.String()
+	}
+	return a.Local + "@" + domain
+}
+
+// Parse checks that s is a single addr-spec ("local@domain", without a
+// display name or angle brackets) and returns it normalised. The domain
+// must be a host name with at least two labels; IP address literals are
+// rejected. Errors wrap ErrInvalidAddress.
+func Parse(s string) (Address, error) {
+	s = strings.TrimFunc(s, unicode.IsSpace)
+	if s == "" {
+		return Address{}, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+	if !utf8.ValidString(s) {
+		return Address{}, fmt.Errorf("%w: not valid UTF-8", ErrInvalidAddress)
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return Address{}, fmt.Errorf("%w: missing @", ErrInvalidAddress)
+	}
+	local, err := parseLocal(s[:at])
+	if err != nil {
+		return Address{}, err
+	}
+	domain, err := parseDomain(s[at+1:])
+	if err != nil {
+		return Address{}, err
+	}
+	a := Address{Local: local, Domain: domain}
+	if len(a.String()) > MaxAddressLength {
+		return Address{}, fmt.Errorf("%w: longer than %d characters", ErrInvalidAddress, MaxAddressLength)
+	}
+	return a, nil
+}
+
+// Normalise returns s in its stored form, or an error wrapping
+// ErrInvalidAddress if it is not a valid address.
+func Normalise(s string) (string, error) {
+	a, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+// Valid reports whether s is a valid address.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// gmailDomains ignore dots in the local part.
+var gmailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// Canonicalise returns the normalised form of s with any "+tag" removed from
+// the local part and, for Gmail, its dots removed too, so that
+// "Jane.Doe+news@gmail.com" and "janedoe@googlemail.com" compare equal.
+// Quoted local parts are left as they are.
+func Canonicalise(s string) (string, error) {
+	a, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(a.Local, `"`) {
+		return a.String(), nil
+	}
+	if i := strings.IndexByte(a.Local, '+'); i > 0 {
+		a.Local = a.Local[:i]
+	}
+	if gmailDomains[a.Domain] {
+		a.Local = strings.ReplaceAll(a.Local, ".", "")
+		a.Domain = "gmail.com"
+	}
+	return a.String(), nil
+}
+
+// parseLocal checks a dot-atom or quoted-string local part and lower-cases
+// it. Non-ASCII characters are allowed as atext, as RFC 6532 permits.
+func parseLocal(local string) (string, error) {
+	if local == "" {
+		return "", fmt.Errorf("%w: empty local part", ErrInvalidAddress)
+	}
+	if strings.HasPrefix(local, `"`) {
+		return parseQuotedLocal(local)
+	}
+	if len(local) > MaxLocalLength {
+		return "", fmt.Errorf("%w: local part longer than %d characters", ErrInvalidAddress, MaxLocalLength)
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return "", fmt.Errorf("%w: misplaced dot in local part", ErrInvalidAddress)
+		}
+		for _, r := range atom {
+			if !isAtext(r) {
+				return "", fmt.Errorf("%w: invalid character %q in local part", ErrInvalidAddress, r)
+			}
+		}
+	}
+	return strings.ToLower(local), nil
+}
+
+// parseQuotedLocal unescapes a quoted-string local part and returns it as
+// a dot-atom when it is one, otherwise re-quoted with minimal escaping.
+func parseQuotedLocal(local string) (string, error) {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return "", fmt.Errorf("%w: unterminated quoted local part", ErrInvalidAddress)
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case escaped:
+			if r < ' ' && r != '\t' || r == 0x7f {
+				return "", fmt.Errorf("%w: invalid quoted character %q", ErrInvalidAddress, r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+			continue
+		case r == '"':
+			return "", fmt.Errorf("%w: unescaped quote in local part", ErrInvalidAddress)
+		case r < ' ' && r != '\t' || r == 0x7f:
+			return "", fmt.Errorf("%w: invalid quoted character %q", ErrInvalidAddress, r)
+		}
+		b.WriteRune(r)
+	}
+	if escaped {
+		return "", fmt.Errorf("%w: unterminated quoted local part", ErrInvalidAddress)
+	}
+	content := strings.ToLower(b.String())
+	if content == "" {
+		return "", fmt.Errorf("%w: empty local part", ErrInvalidAddress)
+	}
+	if isDotAtom(content) {
+		local = content
+	} else {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		local = `"` + r.Replace(content) + `"`
+	}
+	if len(local) > MaxLocalLength {
+		return "", fmt.Errorf("%w: local part longer than %d characters", ErrInvalidAddress, MaxLocalLength)
+	}
+	return local, nil
+}
+
+// parseDomain converts domain to lower-case ASCII and checks it is a host
+// name.
+func parseDomain(domain string) (string, error) {
+	if domain == "" {
+		return "", fmt.Errorf("%w: empty domain", ErrInvalidAddress)
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", fmt.Errorf("%w: address literals are not supported", ErrInvalidAddress)
+	}
+	// A trailing dot marks a fully qualified name; it is not part of the address.
+	domain = strings.TrimSuffix(domain, ".")
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	if len(ascii) > MaxDomainLength {
+		return "", fmt.Errorf("%w: domain longer than %d characters", ErrInvalidAddress, MaxDomainLength)
+	}
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("%w: domain %q has no top-level domain", ErrInvalidAddress, domain)
+	}
+	for _, label := range labels {
+		if err := checkLabel(label); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+		}
+	}
+	if tld := labels[len(labels)-1]; strings.Trim(tld, "0123456789") == "" {
+		return "", fmt.Errorf("%w: numeric top-level domain", ErrInvalidAddress)
+	}
+	return ascii, nil
+}
+
+// checkLabel checks an ASCII label is letters, digits and inner hyphens.
+func checkLabel(label string) error {
+	if label == "" {
+		return errors.New("empty domain label")
+	}
+	if len(label) > MaxLabelLength {
+		return fmt.Errorf("domain label longer than %d characters", MaxLabelLength)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("domain label %q starts or ends with a hyphen", label)
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("invalid character %q in domain", c)
+		}
+	}
+	return nil
+}
+
+// isAtext reports whether r may appear in an atom (RFC 5322 section 3.2.3,
+// extended to UTF-8 by RFC 6532).
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r >= utf8.RuneSelf:
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+func isDotAtom(s string) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if !isAtext(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package shared_email
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed disposable_domains.txt
+var disposableList string
+
+var (
+	disposableMu      sync.RWMutex
+	disposableDomains = parseDomainList(disposableList)
+)
+
+func parseDomainList(list string) map[string]bool {
+	domains := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if ascii, err := ToASCII(line); err == nil {
+			domains[ascii] = true
+		}
+	}
+	return domains
+}
+
+// IsDisposable reports whether s, an address or a bare domain, belongs to a
+// disposable email provider: a listed domain or one of its subdomains.
+// Invalid input is not disposable.
+func IsDisposable(s string) bool {
+	domain := strings.TrimFunc(s, unicode.IsSpace)
+	if at := strings.LastIndexByte(domain, '@'); at >= 0 {
+		domain = domain[at+1:]
+	}
+	domain, err := ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return false
+	}
+	disposableMu.RLock()
+	defer disposableMu.RUnlock()
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// AddDisposableDomains extends the embedded list, e.g. with domains an
+// operator has seen abused.
+func AddDisposableDomains(domains ...string) {
+	disposableMu.Lock()
+	defer disposableMu.Unlock()
+	for _, d := range domains {
+		if ascii, err := ToASCII(strings.TrimSpace(d)); err == nil && ascii != "" {
+			disposableDomains[ascii] = true
+		}
+	}
+}
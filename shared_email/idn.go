@@ -0,0 +1,232 @@
+package shared_email
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// acePrefix marks a punycode-encoded label.
+const acePrefix = "xn--"
+
+// ToASCII converts a domain to lower-case ASCII, encoding each label with
+// non-ASCII characters as punycode (RFC 3492). Labels that are already
+// punycode are checked to decode. Only lower-casing is applied before
+// encoding, not the full IDNA 2008 mapping.
+func ToASCII(domain string) (string, error) {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			if strings.HasPrefix(label, acePrefix) {
+				if _, err := decodePunycode(label[len(acePrefix):]); err != nil {
+					return "", fmt.Errorf("domain label %q: %w", label, err)
+				}
+			}
+			continue
+		}
+		if !utf8.ValidString(label) {
+			return "", fmt.Errorf("domain label %q is not valid UTF-8", label)
+		}
+		encoded, err := encodePunycode(label)
+		if err != nil {
+			return "", fmt.Errorf("domain label %q: %w", label, err)
+		}
+		labels[i] = acePrefix + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the punycode labels of domain back to Unicode.
+func ToUnicode(domain string) (string, error) {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(label, acePrefix) {
+			continue
+		}
+		decoded, err := decodePunycode(label[len(acePrefix):])
+		if err != nil {
+			return "", fmt.Errorf("domain label %q: %w", label, err)
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Bootstring parameters for punycode, from RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	// punyMaxInt keeps intermediate values well inside an int32, the bound
+	// RFC 3492 assumes, so overflow checks work on every platform.
+	punyMaxInt = 1<<31 - 1
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+// encodePunycode encodes a label per RFC 3492 section 6.3.
+func encodePunycode(label string) (string, error) {
+	input := []rune(label)
+	var out strings.Builder
+	for _, r := range input {
+		if r < utf8.RuneSelf {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for handled < len(input) {
+		m := punyMaxInt
+		for _, r := range input {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		if (m - n) > (punyMaxInt-delta)/(handled+1) {
+			return "", errPunycode
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+				if delta > punyMaxInt {
+					return "", errPunycode
+				}
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// decodePunycode decodes a label per RFC 3492 section 6.2.
+func decodePunycode(encoded string) (string, error) {
+	if encoded == "" {
+		return "", errPunycode
+	}
+	var output []rune
+	pos := 0
+	if b := strings.LastIndexByte(encoded, '-'); b > 0 {
+		for i := 0; i < b; i++ {
+			if encoded[i] >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+			output = append(output, rune(encoded[i]))
+		}
+		pos = b + 1
+	}
+
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos < len(encoded) {
+		oldI, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(encoded) {
+				return "", errPunycode
+			}
+			digit, ok := punyDigitValue(encoded[pos])
+			pos++
+			if !ok || digit > (punyMaxInt-i)/w {
+				return "", errPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w > punyMaxInt/(punyBase-t) {
+				return "", errPunycode
+			}
+			w *= punyBase - t
+		}
+		length := len(output) + 1
+		bias = punyAdapt(i-oldI, length, oldI == 0)
+		if i/length > punyMaxInt-n {
+			return "", errPunycode
+		}
+		n += i / length
+		i %= length
+		if n < punyInitialN || n > utf8.MaxRune || (n >= 0xd800 && n <= 0xdfff) {
+			return "", errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
+
+func punyThreshold(k, bias int) int {
+	return min(max(k-bias, punyTMin), punyTMax)
+}
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyDigitValue(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package shared_entitlements
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// Well-known numeric limits. Plans declare them in Plan.Features as "name=value".
+const (
+	LimitSeats           = "seats"
+	LimitStorageBytes    = "storage_bytes"
+	LimitAPICallsMonthly = "api_calls_monthly"
+)
+
+// Unlimited is the limit value used for "name=unlimited" features.
+const Unlimited int64 = -1
+
+// Entitlements is the effective set of features and limits for a user or organisation.
+type Entitlements struct {
+	SubjectID string           `json:"subject_id"`
+	OwnerID   string           `json:"owner_id,omitempty"` // the organisation owner whose subscription applies
+	PlanID    string           `json:"plan_id,omitempty"`
+	PlanName  string           `json:"plan_name,omitempty"`
+	Active    bool             `json:"active"` // true when backed by a subscription that grants access
+	Features  map[string]bool  `json:"features"`
+	Limits    map[string]int64 `json:"limits"`
+}
+
+// FromPlan builds entitlements from a plan's feature list.
+// Plain entries ("sso") are boolean features; "name=value" entries are numeric
+// limits and also enable the feature "name". A value of "unlimited" maps to Unlimited.
+func FromPlan(subjectID string, plan client_identity.Plan) Entitlements {
+	e := Entitlements{
+		SubjectID: subjectID,
+		PlanID:    plan.ID,
+		PlanName:  plan.Name,
+		Features:  make(map[string]bool),
+		Limits:    make(map[string]int64),
+	}
+	for _, raw := range plan.Features {
+		name, value, isLimit := strings.Cut(strings.TrimSpace(raw), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		e.Features[name] = true
+		if !isLimit {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(value, "unlimited") {
+			e.Limits[name] = Unlimited
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			e.Limits[name] = n
+		}
+	}
+	return e
+}
+
+// none returns entitlements with no features, used when there is no active subscription.
+func none(subjectID string) Entitlements {
+	return Entitlements{
+		SubjectID: subjectID,
+		Features:  make(map[string]bool),
+		Limits:    make(map[string]int64),
+	}
+}
+
+// Can reports whether feature is enabled. It is safe to call from templates.
+func (e Entitlements) Can(feature string) bool {
+	return e.Features[strings.ToLower(feature)]
+}
+
+// Limit returns the numeric limit for name and whether the plan declares one.
+func (e Entitlements) Limit(name string) (int64, bool) {
+	n, ok := e.Limits[strings.ToLower(name)]
+	return n, ok
+}
+
+// Within reports whether used is within the limit for name.
+// Undeclared limits are treated as zero, Unlimited always allows.
+func (e Entitlements) Within(name string, used int64) bool {
+	limit, ok := e.Limit(name)
+	if !ok {
+		return used <= 0
+	}
+	return limit == Unlimited || used <= limit
+}
@@ -0,0 +1,189 @@
+package shared_entitlements
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/shared_i18n"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// Context key for resolved entitlements
+type contextKey string
+
+const entitlementsContextKey = contextKey("entitlements")
+
+// SetEntitlementsInContext stores entitlements in context.
+func SetEntitlementsInContext(ctx context.Context, e Entitlements) context.Context {
+	return context.WithValue(ctx, entitlementsContextKey, e)
+}
+
+// GetEntitlementsFromContext retrieves entitlements stored by the middleware.
+func GetEntitlementsFromContext(ctx context.Context) (Entitlements, bool) {
+	e, ok := ctx.Value(entitlementsContextKey).(Entitlements)
+	return e, ok
+}
+
+// Can reports whether the entitlements resolved for this request include feature.
+func Can(r *http.Request, feature string) bool {
+	e, ok := GetEntitlementsFromContext(r.Context())
+	return ok && e.Can(feature)
+}
+
+// FuncMap returns template helpers bound to the request's entitlements,
+// e.g. {{if can "export"}}...{{end}} and {{limit "seats"}}.
+func FuncMap(r *http.Request) template.FuncMap {
+	e, _ := GetEntitlementsFromContext(r.Context())
+	return template.FuncMap{
+		"can": e.Can,
+		"limit": func(name string) int64 {
+			n, _ := e.Limit(name)
+			return n
+		},
+	}
+}
+
+// FeatureRequiredResponse is the JSON body returned when a gated feature is denied.
+type FeatureRequiredResponse struct {
+	Error      string `json:"error"`
+	Feature    string `json:"feature"`
+	Plan       string `json:"plan,omitempty"`
+	UpgradeURL string `json:"upgrade_url,omitempty"`
+}
+
+// subjectResolver picks the user or organisation entitlements for a request.
+type subjectResolver func(r *http.Request) (Entitlements, int, error)
+
+func (res *Resolver) forSessionUser(r *http.Request) (Entitlements, int, error) {
+	userID, ok := shared_utilities.GetUserIDFromContext(r)
+	if !ok {
+		return Entitlements{}, http.StatusUnauthorized, nil
+	}
+	e, err := res.ForUser(r.Context(), r.Cookies(), userID)
+	return e, http.StatusOK, err
+}
+
+func (res *Resolver) forRouteOrg(orgIDVar string) subjectResolver {
+	return func(r *http.Request) (Entitlements, int, error) {
+		orgID := mux.Vars(r)[orgIDVar]
+		if orgID == "" {
+			return Entitlements{}, http.StatusBadRequest, nil
+		}
+		e, err := res.ForOrganisation(r.Context(), r.Cookies(), orgID)
+		return e, http.StatusOK, err
+	}
+}
+
+// LoadEntitlements stores the session user's entitlements in context without
+// gating anything, so views can call Can or FuncMap.
+func (res *Resolver) LoadEntitlements() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			e, code, err := res.forSessionUser(r)
+			if err != nil || code != http.StatusOK {
+				if err != nil {
+					log.Printf("LoadEntitlements: %v", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(SetEntitlementsInContext(r.Context(), e)))
+		})
+	}
+}
+
+// RequireFeature returns middleware that only admits session users whose plan
+// includes feature. Users without an active subscription get 402 Payment
+// Required, users whose plan lacks the feature get 403 Forbidden.
+func (res *Resolver) RequireFeature(feature string) mux.MiddlewareFunc {
+	return res.gate(feature, res.forSessionUser)
+}
+
+// RequireOrgFeature is RequireFeature for the organisation named by the orgIDVar route variable.
+func (res *Resolver) RequireOrgFeature(feature, orgIDVar string) mux.MiddlewareFunc {
+	return res.gate(feature, res.forRouteOrg(orgIDVar))
+}
+
+func (res *Resolver) gate(feature string, resolve subjectResolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			e, code, err := resolve(r)
+			if err != nil {
+				log.Printf("Entitlement check for %q failed: %v", feature, err)
+				shared_i18n.Error(w, r, "errors.entitlement_check_failed", http.StatusBadGateway)
+				return
+			}
+			switch code {
+			case http.StatusUnauthorized:
+				shared_i18n.Error(w, r, "errors.unauthorized", http.StatusUnauthorized)
+				return
+			case http.StatusBadRequest:
+				shared_i18n.Error(w, r, "errors.org_id_required", http.StatusBadRequest)
+				return
+			}
+
+			if !e.Can(feature) {
+				res.writeFeatureRequired(w, r, e, feature)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(SetEntitlementsInContext(r.Context(), e)))
+		})
+	}
+}
+
+func (res *Resolver) writeFeatureRequired(w http.ResponseWriter, r *http.Request, e Entitlements, feature string) {
+	status := http.StatusForbidden
+	message := shared_i18n.FromRequest(r).T("errors.feature_not_in_plan")
+	if !e.Active {
+		status = http.StatusPaymentRequired
+		message = shared_i18n.FromRequest(r).T("errors.subscription_required")
+	}
+
+	if res.UpgradeURL != "" {
+		w.Header().Set("X-Upgrade-URL", res.UpgradeURL)
+		// HTMX requests follow the hint instead of swapping an error into the page.
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", res.UpgradeURL)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(FeatureRequiredResponse{
+		Error:      message,
+		Feature:    feature,
+		Plan:       e.PlanName,
+		UpgradeURL: res.UpgradeURL,
+	})
+}
+
+// RequireFeature wraps Resolver.RequireFeature on the default resolver.
+func RequireFeature(feature string) mux.MiddlewareFunc {
+	return defaultMiddleware(func(res *Resolver) mux.MiddlewareFunc { return res.RequireFeature(feature) })
+}
+
+// RequireOrgFeature wraps Resolver.RequireOrgFeature on the default resolver.
+func RequireOrgFeature(feature, orgIDVar string) mux.MiddlewareFunc {
+	return defaultMiddleware(func(res *Resolver) mux.MiddlewareFunc { return res.RequireOrgFeature(feature, orgIDVar) })
+}
+
+// LoadEntitlements wraps Resolver.LoadEntitlements on the default resolver.
+func LoadEntitlements() mux.MiddlewareFunc {
+	return defaultMiddleware(func(res *Resolver) mux.MiddlewareFunc { return res.LoadEntitlements() })
+}
+
+func defaultMiddleware(build func(*Resolver) mux.MiddlewareFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := ensure(); err != nil {
+				log.Printf("shared_entitlements: %v", err)
+				shared_i18n.Error(w, r, "errors.entitlements_unavailable", http.StatusInternalServerError)
+				return
+			}
+			build(Default)(next).ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package shared_entitlements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_rbac"
+	"github.com/hstles/go-sdk/shared_subscriptions"
+)
+
+// DefaultCacheTTL is how long resolved entitlements are reused.
+const DefaultCacheTTL = time.Minute
+
+var ErrNoOrganisationOwner = errors.New("organisation has no active owner")
+
+// Identity is the part of the identity client the Resolver uses. It is
+// satisfied by *client_identity.Client and *client_identity.CachedClient.
+type Identity interface {
+	GetActiveSubscription(ctx context.Context, cookies []*http.Cookie, userID string) (client_identity.Subscription, int, error)
+	GetPlan(ctx context.Context, planID string) (client_identity.Plan, int, error)
+	AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts client_identity.ListOptions) iter.Seq2[client_identity.Member, error]
+}
+
+// Resolver computes entitlements from active subscriptions via client_identity.
+type Resolver struct {
+	identity Identity
+	ttl      time.Duration
+
+	// UpgradeURL is returned to callers that hit a gated feature.
+	UpgradeURL string
+	// Policy decides whether a subscription still grants access, e.g. while
+	// trialing, past due within its grace period or cancelled before period end.
+	Policy shared_subscriptions.Policy
+	// Session returns the session cookies of the service account used to
+	// look up an organisation owner's subscription, which other members may
+	// not read. Without it the caller's cookies are used and the owner's
+	// entitlements are not cached under the owner.
+	Session func(ctx context.Context) ([]*http.Cookie, error)
+
+	mu        sync.Mutex
+	cache     map[string]cachedEntitlements
+	nextSweep time.Time
+}
+
+type cachedEntitlements struct {
+	entitlements Entitlements
+	expires      time.Time
+}
+
+// NewResolver creates a Resolver. A ttl of zero disables caching.
+func NewResolver(identity Identity, ttl time.Duration) *Resolver {
+	return &Resolver{
+		identity: identity,
+		ttl:      ttl,
+		Policy:   shared_subscriptions.DefaultPolicy(),
+		cache:    make(map[string]cachedEntitlements),
+	}
+}
+
+// Default is the package-level resolver used by the middleware wrappers.
+var Default *Resolver
+
+// Init sets up the Default resolver. upgradeURL may be empty.
+func Init(identityServiceURL, upgradeURL string) {
+	Default = NewResolver(client_identity.NewClient(identityServiceURL), DefaultCacheTTL)
+	Default.UpgradeURL = upgradeURL
+}
+
+// ensure checks that Default has been initialized.
+func ensure() error {
+	if Default == nil {
+		return errors.New("shared_entitlements: not initialized; call Init(identityServiceURL, upgradeURL) first")
+	}
+	return nil
+}
+
+func (r *Resolver) cached(key string) (Entitlements, bool) {
+	if r.ttl <= 0 {
+		return Entitlements{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return Entitlements{}, false
+	}
+	return entry.entitlements, true
+}
+
+func (r *Resolver) store(key string, e Entitlements) {
+	if r.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	r.sweepLocked(now)
+	r.cache[key] = cachedEntitlements{entitlements: e, expires: now.Add(r.ttl)}
+	r.mu.Unlock()
+}
+
+// sweepLocked drops expired entries at most once per ttl, so subjects that
+// are never looked up again do not stay cached forever. r.mu must be held.
+func (r *Resolver) sweepLocked(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	for key, entry := range r.cache {
+		if now.After(entry.expires) {
+			delete(r.cache, key)
+		}
+	}
+	r.nextSweep = now.Add(r.ttl)
+}
+
+// ForUser returns the entitlements granted by userID's active subscription.
+// A user without one, or whose subscription no longer grants access under
+// Policy, gets empty entitlements and no error.
+func (r *Resolver) ForUser(ctx context.Context, cookies []*http.Cookie, userID string) (Entitlements, error) {
+	key := "user:" + userID
+	if e, ok := r.cached(key); ok {
+		return e, nil
+	}
+	e, err := r.resolveUser(ctx, cookies, userID)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	r.store(key, e)
+	return e, nil
+}
+
+// resolveUser computes userID's entitlements without consulting the cache.
+func (r *Resolver) resolveUser(ctx context.Context, cookies []*http.Cookie, userID string) (Entitlements, error) {
+	sub, code, err := r.identity.GetActiveSubscription(ctx, cookies, userID)
+	if code == http.StatusNotFound {
+		return none(userID), nil
+	}
+	if err != nil {
+		return Entitlements{}, fmt.Errorf("get active subscription for %s: %w", userID, err)
+	}
+	if code != http.StatusOK {
+		return Entitlements{}, fmt.Errorf("get active subscription for %s: unexpected status %d", userID, code)
+	}
+
+	plan, err := r.planFor(ctx, sub)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	now := time.Now()
+	e := FromPlan(userID, plan)
+	lifecycle, err := shared_subscriptions.Evaluate(sub, &plan, r.Policy, now)
+	switch {
+	case err == nil:
+		e.Active = lifecycle.HasAccess(now)
+	case errors.Is(err, shared_subscriptions.ErrUnknownInterval), errors.Is(err, shared_subscriptions.ErrUnknownStatus):
+		// Free and lifetime plans have no billing period, and statuses the
+		// lifecycle does not know cannot be placed in one: go by the status.
+		e.Active = sub.Status == string(shared_subscriptions.StateActive)
+	default:
+		return Entitlements{}, fmt.Errorf("evaluate subscription for %s: %w", userID, err)
+	}
+	if !e.Active {
+		e = none(userID)
+	}
+	return e, nil
+}
+
+// ForOrganisation returns the entitlements of orgID, which are those of the
+// active subscription held by its owner. The owner's subscription is read
+// with the Session service account when one is set.
+func (r *Resolver) ForOrganisation(ctx context.Context, cookies []*http.Cookie, orgID string) (Entitlements, error) {
+	key := "org:" + orgID
+	if e, ok := r.cached(key); ok {
+		return e, nil
+	}
+
+	var ownerID string
+	for m, err := range r.identity.AllMembers(ctx, cookies, orgID, client_identity.ListOptions{}) {
+		if err != nil {
+			return Entitlements{}, fmt.Errorf("list members of %s: %w", orgID, err)
+		}
+		if role, _ := shared_rbac.ParseRole(m.Role); role == shared_rbac.RoleOwner && m.Status == shared_rbac.StatusActive {
+			ownerID = m.UserID
+			break
+		}
+	}
+	if ownerID == "" {
+		return Entitlements{}, ErrNoOrganisationOwner
+	}
+
+	var owner Entitlements
+	if r.Session != nil {
+		session, err := r.Session(ctx)
+		if err != nil {
+			return Entitlements{}, fmt.Errorf("service session: %w", err)
+		}
+		owner, err = r.ForUser(ctx, session, ownerID)
+		if err != nil {
+			return Entitlements{}, err
+		}
+	} else {
+		// The caller's cookies may not be able to read the owner's
+		// subscription, so the result is not cached as the owner's.
+		var err error
+		owner, err = r.resolveUser(ctx, cookies, ownerID)
+		if err != nil {
+			return Entitlements{}, err
+		}
+	}
+	e := owner
+	e.SubjectID = orgID
+	e.OwnerID = ownerID
+	r.store(key, e)
+	return e, nil
+}
+
+func (r *Resolver) planFor(ctx context.Context, sub client_identity.Subscription) (client_identity.Plan, error) {
+	if sub.Plan != nil {
+		return *sub.Plan, nil
+	}
+	plan, code, err := r.identity.GetPlan(ctx, sub.PlanID)
+	if err != nil {
+		return client_identity.Plan{}, fmt.Errorf("get plan %s: %w", sub.PlanID, err)
+	}
+	if code != http.StatusOK {
+		return client_identity.Plan{}, fmt.Errorf("get plan %s: unexpected status %d", sub.PlanID, code)
+	}
+	return plan, nil
+}
+
+// InvalidateUser drops cached entitlements for userID.
+func (r *Resolver) InvalidateUser(userID string) {
+	r.mu.Lock()
+	delete(r.cache, "user:"+userID)
+	r.mu.Unlock()
+}
+
+// InvalidateOrganisation drops cached entitlements for orgID.
+func (r *Resolver) InvalidateOrganisation(orgID string) {
+	r.mu.Lock()
+	delete(r.cache, "org:"+orgID)
+	r.mu.Unlock()
+}
@@ -0,0 +1,155 @@
+package shared_entitlements
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// fakeIdentity serves subscriptions by user ID. When readers is set, only
+// the named session cookies may read subscriptions.
+type fakeIdentity struct {
+	subs    map[string]client_identity.Subscription
+	members map[string][]client_identity.Member
+	readers map[string]bool
+}
+
+func (f *fakeIdentity) GetActiveSubscription(_ context.Context, cookies []*http.Cookie, userID string) (client_identity.Subscription, int, error) {
+	if f.readers != nil && (len(cookies) == 0 || !f.readers[cookies[0].Value]) {
+		return client_identity.Subscription{}, http.StatusForbidden, errors.New("forbidden")
+	}
+	sub, ok := f.subs[userID]
+	if !ok {
+		return client_identity.Subscription{}, http.StatusNotFound, errors.New("not found")
+	}
+	return sub, http.StatusOK, nil
+}
+
+func (f *fakeIdentity) GetPlan(context.Context, string) (client_identity.Plan, int, error) {
+	return client_identity.Plan{}, http.StatusNotFound, errors.New("not found")
+}
+
+func (f *fakeIdentity) AllMembers(_ context.Context, _ []*http.Cookie, orgID string, _ client_identity.ListOptions) iter.Seq2[client_identity.Member, error] {
+	return func(yield func(client_identity.Member, error) bool) {
+		for _, m := range f.members[orgID] {
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+func session(value string) []*http.Cookie {
+	return []*http.Cookie{{Name: "session", Value: value}}
+}
+
+var proPlan = &client_identity.Plan{ID: "pro", Name: "Pro", Interval: "monthly", Features: []string{"sso", "seats=10"}}
+
+func TestResolverForOrganisationUsesServiceSession(t *testing.T) {
+	identity := &fakeIdentity{
+		subs: map[string]client_identity.Subscription{
+			"owner": {ID: "sub_1", UserID: "owner", PlanID: "pro", Plan: proPlan, Status: "active", StartDate: time.Now().Add(-24 * time.Hour)},
+		},
+		members: map[string][]client_identity.Member{
+			"org_1": {{UserID: "member", Role: "member", Status: "active"}, {UserID: "owner", Role: "owner", Status: "active"}},
+		},
+		readers: map[string]bool{"service": true},
+	}
+	r := NewResolver(identity, time.Minute)
+
+	// Without a service session, a member cannot read the owner's subscription.
+	if _, err := r.ForOrganisation(context.Background(), session("member"), "org_1"); err == nil {
+		t.Fatal("ForOrganisation with the member's cookies succeeded")
+	}
+
+	r.Session = func(context.Context) ([]*http.Cookie, error) { return session("service"), nil }
+	e, err := r.ForOrganisation(context.Background(), session("member"), "org_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.SubjectID != "org_1" || !e.Active || !e.Can("sso") {
+		t.Errorf("ForOrganisation = %+v, want the owner's Pro entitlements", e)
+	}
+	if _, ok := r.cached("user:owner"); !ok {
+		t.Error("owner's entitlements read with the service session were not cached")
+	}
+}
+
+func TestResolverCacheSweep(t *testing.T) {
+	identity := &fakeIdentity{}
+	r := NewResolver(identity, 10*time.Millisecond)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := r.ForUser(context.Background(), nil, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := r.ForUser(context.Background(), nil, "d"); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	n := len(r.cache)
+	r.mu.Unlock()
+	if n != 1 {
+		t.Errorf("cache holds %d entries after the sweep, want 1", n)
+	}
+}
+
+func TestResolverNonRecurringPlans(t *testing.T) {
+	start := time.Now().Add(-400 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		interval string
+		status   string
+		active   bool
+	}{
+		{"free plan", "", "active", true},
+		{"lifetime plan", "lifetime", "active", true},
+		{"cancelled lifetime plan", "lifetime", "cancelled", false},
+		{"unknown status", "monthly", "paused", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &client_identity.Plan{ID: "p", Interval: tt.interval, Features: []string{"sso"}}
+			identity := &fakeIdentity{subs: map[string]client_identity.Subscription{
+				"usr_1": {ID: "sub_1", UserID: "usr_1", PlanID: "p", Plan: plan, Status: tt.status, StartDate: start},
+			}}
+			e, err := NewResolver(identity, 0).ForUser(context.Background(), nil, "usr_1")
+			if err != nil {
+				t.Fatalf("ForUser = %v, want the status to decide", err)
+			}
+			if e.Active != tt.active || e.Can("sso") != tt.active {
+				t.Errorf("ForUser = %+v, want active %v", e, tt.active)
+			}
+		})
+	}
+}
+
+func TestResolverTrialExpiry(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		started time.Duration
+		active  bool
+	}{
+		{"in trial", 10 * 24 * time.Hour, true},
+		{"trial ended", 20 * 24 * time.Hour, false},
+	} {
+		identity := &fakeIdentity{subs: map[string]client_identity.Subscription{
+			"usr_1": {ID: "sub_1", UserID: "usr_1", PlanID: "pro", Plan: proPlan, Status: "trialing", StartDate: time.Now().Add(-tt.started)},
+		}}
+		r := NewResolver(identity, 0)
+		r.Policy.TrialPeriod = 14 * 24 * time.Hour
+		e, err := r.ForUser(context.Background(), nil, "usr_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Active != tt.active || e.Can("sso") != tt.active {
+			t.Errorf("%s: ForUser = %+v, want active %v", tt.name, e, tt.active)
+		}
+	}
+}
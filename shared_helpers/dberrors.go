@@ -0,0 +1,9 @@
+package shared_helpers
+
+import "strings"
+
+// IsUniqueViolation reports whether err is CoreDB rejecting a write that
+// breaks a UNIQUE constraint or index.
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
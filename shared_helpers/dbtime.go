@@ -0,0 +1,42 @@
+package shared_helpers
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DBTimeLayout is the fixed-width layout the SDK writes timestamps in, so that
+// stored values compare correctly as strings in SQL.
+const DBTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// dbTimeLayouts are the layouts CoreDB timestamps are stored in: RFC 3339 when
+// written by the SDK and SQLite's CURRENT_TIMESTAMP format for column defaults.
+var dbTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999-07:00",
+}
+
+// FormatDBTime formats t for storage in a CoreDB TIMESTAMP column.
+func FormatDBTime(t time.Time) string {
+	return t.UTC().Format(DBTimeLayout)
+}
+
+// ParseDBTime parses a CoreDB timestamp, returning the zero time if it is empty or malformed.
+func ParseDBTime(s string) time.Time {
+	for _, layout := range dbTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// ParseNullDBTime parses a nullable CoreDB timestamp, returning nil for NULL.
+func ParseNullDBTime(s sql.NullString) *time.Time {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	t := ParseDBTime(s.String)
+	return &t
+}
@@ -0,0 +1,195 @@
+// Package shared_i18n localises user-facing text: message catalogs per
+// locale embedded in the binary, locale negotiation, plural rules and
+// date and number formatting.
+//
+// A catalog is a JSON file named after its locale, e.g. "de.json" or
+// "en-US.json", mapping message keys to text. Text may contain {name}
+// placeholders, and a message with plural forms is an object keyed by
+// CLDR plural category:
+//
+//	{
+//	  "errors.unauthorized": "Unauthorized",
+//	  "duration.minutes": {"one": "{count} minute", "other": "{count} minutes"}
+//	}
+//
+// A regional catalog only needs the keys that differ from its language:
+// lookups for "en-US" fall back to "en" and then to the default locale.
+package shared_i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+//go:embed locales/*.json
+var embedded embed.FS
+
+// message is a catalog entry: plain text or plural forms.
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+func (m *message) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &m.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, &m.plural); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms")
+	}
+	if _, ok := m.plural[PluralOther]; !ok {
+		return fmt.Errorf("plural message has no %q form", PluralOther)
+	}
+	return nil
+}
+
+// Catalog holds the messages of every locale. It is safe for concurrent use
+// once loaded.
+type Catalog struct {
+	// DefaultLocale is used when negotiation finds no supported locale and
+	// for keys missing from the negotiated one.
+	DefaultLocale string
+
+	messages map[string]map[string]message
+}
+
+// Default is the package-level catalog used by FromRequest, Middleware and
+// Error. It holds the embedded catalogs with English as the default.
+var Default = mustLoad("en")
+
+// Init replaces Default with the embedded catalogs overlaid with layers; see Load.
+func Init(defaultLocale string, layers ...fs.FS) error {
+	c, err := Load(defaultLocale, layers...)
+	if err != nil {
+		return err
+	}
+	Default = c
+	return nil
+}
+
+// Load reads the embedded catalogs overlaid with the *.json files at the
+// root of each layer: keys in a later layer replace the same keys of the
+// same locale, and new files add locales.
+func Load(defaultLocale string, layers ...fs.FS) (*Catalog, error) {
+	sub, err := fs.Sub(embedded, "locales")
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{
+		DefaultLocale: Canonical(defaultLocale),
+		messages:      make(map[string]map[string]message),
+	}
+	for _, layer := range append([]fs.FS{sub}, layers...) {
+		files, err := fs.Glob(layer, "*.json")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			b, err := fs.ReadFile(layer, file)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", file, err)
+			}
+			var entries map[string]message
+			if err := json.Unmarshal(b, &entries); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", file, err)
+			}
+			locale := Canonical(strings.TrimSuffix(path.Base(file), ".json"))
+			if c.messages[locale] == nil {
+				c.messages[locale] = make(map[string]message, len(entries))
+			}
+			for key, m := range entries {
+				c.messages[locale][key] = m
+			}
+		}
+	}
+	if _, ok := c.messages[c.DefaultLocale]; !ok {
+		return nil, fmt.Errorf("no catalog for default locale %q", defaultLocale)
+	}
+	return c, nil
+}
+
+func mustLoad(defaultLocale string) *Catalog {
+	c, err := Load(defaultLocale)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Locales lists the locales that have a catalog.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for l := range c.messages {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supports reports whether locale or its language has a catalog.
+func (c *Catalog) Supports(locale string) bool {
+	locale = Canonical(locale)
+	if _, ok := c.messages[locale]; ok {
+		return true
+	}
+	lang, _ := split(locale)
+	_, ok := c.messages[lang]
+	return ok
+}
+
+// Localizer returns a Localizer for locale. Locales without a catalog of
+// their own use their language's catalog and then the default locale's.
+func (c *Catalog) Localizer(locale string) *Localizer {
+	locale = Canonical(locale)
+	if locale == "" {
+		locale = c.DefaultLocale
+	}
+	l := &Localizer{Locale: locale, catalog: c}
+	lang, _ := split(locale)
+	defaultLang, _ := split(c.DefaultLocale)
+	for _, candidate := range []string{locale, lang, c.DefaultLocale, defaultLang} {
+		if _, ok := c.messages[candidate]; ok && !slices.Contains(l.chain, candidate) {
+			l.chain = append(l.chain, candidate)
+		}
+	}
+	return l
+}
+
+// lookup finds key along the fallback chain and returns the catalog locale
+// it was found in.
+func (l *Localizer) lookup(key string) (message, string, bool) {
+	for _, locale := range l.chain {
+		if m, ok := l.catalog.messages[locale][key]; ok {
+			return m, locale, true
+		}
+	}
+	return message{}, "", false
+}
+
+// Canonical normalises a BCP 47 tag to a lowercase language and an
+// uppercase region, e.g. "en_gb" to "en-GB". Scripts and variants are dropped.
+func Canonical(locale string) string {
+	lang, region := split(locale)
+	if region == "" {
+		return lang
+	}
+	return lang + "-" + region
+}
+
+func split(locale string) (lang, region string) {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	lang, rest, _ := strings.Cut(locale, "-")
+	for _, part := range strings.Split(rest, "-") {
+		if len(part) == 2 || len(part) == 3 && part[0] >= '0' && part[0] <= '9' {
+			region = strings.ToUpper(part)
+			break
+		}
+	}
+	return strings.ToLower(lang), region
+}
@@ -0,0 +1,175 @@
+package shared_i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+// Localizer translates and formats for one locale. Build it with
+// Catalog.Localizer, FromRequest or FromContext.
+//
+// Arguments are name/value pairs filling the {name} placeholders of a
+// message, e.g. T("email.welcome.heading", "name", user.Name). Missing
+// messages render as their key so they are easy to spot.
+type Localizer struct {
+	// Locale is the negotiated tag, e.g. "de-AT", which may be more specific
+	// than the catalogs it reads from; formatting uses its region.
+	Locale string
+
+	catalog *Catalog
+	chain   []string
+}
+
+// Lang is the language of Locale, e.g. "de" for "de-AT".
+func (l *Localizer) Lang() string {
+	lang, _ := split(l.Locale)
+	return lang
+}
+
+// T returns the message for key. A plural message uses its "other" form.
+func (l *Localizer) T(key string, args ...any) string {
+	m, _, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		text = m.plural[PluralOther]
+	}
+	return interpolate(text, args)
+}
+
+// N returns the plural form of key for n, whose formatted value fills the
+// {count} placeholder.
+func (l *Localizer) N(key string, n int, args ...any) string {
+	m, locale, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		// The rules of the catalog the text came from, not of the requested
+		// locale, decide the form when a key falls back to another language.
+		if text, ok = m.plural[PluralCategory(locale, n)]; !ok {
+			text = m.plural[PluralOther]
+		}
+	}
+	return interpolate(text, append([]any{"count", l.Number(n)}, args...))
+}
+
+// Has reports whether key has a message in the fallback chain.
+func (l *Localizer) Has(key string) bool {
+	_, _, ok := l.lookup(key)
+	return ok
+}
+
+// Number formats an integer, float or decimal string with the locale's
+// digit grouping and decimal separator.
+func (l *Localizer) Number(v any) string {
+	var s string
+	switch n := v.(type) {
+	case int:
+		s = strconv.Itoa(n)
+	case int32:
+		s = strconv.FormatInt(int64(n), 10)
+	case int64:
+		s = strconv.FormatInt(n, 10)
+	case uint:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(n), 10)
+	case uint64:
+		s = strconv.FormatUint(n, 10)
+	case float32:
+		s = strconv.FormatFloat(float64(n), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case string:
+		s = n
+	default:
+		return fmt.Sprint(v)
+	}
+	return shared_money.FormatNumber(s, l.Locale)
+}
+
+// Money formats an amount with the locale's conventions.
+func (l *Localizer) Money(m shared_money.Money) string {
+	return m.Format(l.Locale)
+}
+
+// Date formats t as a long date, e.g. "2 March 2026" or "2. März 2026".
+func (l *Localizer) Date(t time.Time) string {
+	return l.T("date.long",
+		"day", t.Day(),
+		"month", l.T("date.month."+strconv.Itoa(int(t.Month()))),
+		"year", t.Year(),
+		"weekday", l.T("date.weekday."+strconv.Itoa(int(t.Weekday()))))
+}
+
+// ShortDate formats t numerically, e.g. "02/03/2026" or "02.03.2026".
+func (l *Localizer) ShortDate(t time.Time) string {
+	return l.T("date.short",
+		"day", fmt.Sprintf("%02d", t.Day()),
+		"month", fmt.Sprintf("%02d", int(t.Month())),
+		"year", t.Year())
+}
+
+// Time formats the time of day of t, e.g. "14:05" or "2:05 PM".
+func (l *Localizer) Time(t time.Time) string {
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	ampm := l.T("time.am")
+	if t.Hour() >= 12 {
+		ampm = l.T("time.pm")
+	}
+	return l.T("time.short",
+		"hour", fmt.Sprintf("%02d", t.Hour()),
+		"hour12", hour12,
+		"minute", fmt.Sprintf("%02d", t.Minute()),
+		"ampm", ampm)
+}
+
+// DateTime formats t as a long date followed by the time of day.
+func (l *Localizer) DateTime(t time.Time) string {
+	return l.T("datetime.long", "date", l.Date(t), "time", l.Time(t))
+}
+
+// Duration formats d in its largest whole unit, e.g. "10 minutes" or
+// "2 Stunden". Durations under a second format as seconds.
+func (l *Localizer) Duration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	for _, u := range []struct {
+		key  string
+		size time.Duration
+	}{
+		{"duration.days", 24 * time.Hour},
+		{"duration.hours", time.Hour},
+		{"duration.minutes", time.Minute},
+	} {
+		if d >= u.size {
+			return l.N(u.key, int(d/u.size))
+		}
+	}
+	return l.N("duration.seconds", int(d/time.Second))
+}
+
+// interpolate fills {name} placeholders from name/value pairs. Unknown
+// placeholders are left as they are.
+func interpolate(text string, args []any) string {
+	if len(args) < 2 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -0,0 +1,119 @@
+package shared_i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Negotiate picks the locale to use: preferred, typically the user's saved
+// setting, if it is supported; otherwise the best supported language of an
+// Accept-Language header; otherwise the default locale. The result keeps
+// the requested region, e.g. "en-US" when only "en" has a catalog, so that
+// dates and numbers follow it.
+func (c *Catalog) Negotiate(preferred, acceptLanguage string) string {
+	if preferred != "" && c.Supports(preferred) {
+		return Canonical(preferred)
+	}
+	for _, tag := range ParseAcceptLanguage(acceptLanguage) {
+		if c.Supports(tag) {
+			return Canonical(tag)
+		}
+	}
+	return c.DefaultLocale
+}
+
+// ParseAcceptLanguage returns the tags of an Accept-Language header from
+// most to least preferred, dropping "*" and tags with q=0.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(name, "q") {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = t.tag
+	}
+	return out
+}
+
+// Context key for the request's localizer
+type contextKey string
+
+const localizerContextKey = contextKey("localizer")
+
+// SetLocalizerInContext stores l in context.
+func SetLocalizerInContext(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerContextKey, l)
+}
+
+// GetLocalizerFromContext retrieves the localizer stored by Middleware.
+func GetLocalizerFromContext(ctx context.Context) (*Localizer, bool) {
+	l, ok := ctx.Value(localizerContextKey).(*Localizer)
+	return l, ok
+}
+
+// Middleware negotiates each request's locale and stores its Localizer in
+// context. preference, which may be nil, returns the user's saved locale,
+// e.g. from their profile; it runs after authentication middleware if it
+// is mounted after it.
+func (c *Catalog) Middleware(preference func(*http.Request) string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var preferred string
+			if preference != nil {
+				preferred = preference(r)
+			}
+			l := c.Localizer(c.Negotiate(preferred, r.Header.Get("Accept-Language")))
+			w.Header().Set("Content-Language", l.Locale)
+			w.Header().Add("Vary", "Accept-Language")
+			next.ServeHTTP(w, r.WithContext(SetLocalizerInContext(r.Context(), l)))
+		})
+	}
+}
+
+// Middleware wraps Catalog.Middleware on the Default catalog.
+func Middleware(preference func(*http.Request) string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return Default.Middleware(preference)(next)
+	}
+}
+
+// FromRequest returns the localizer stored by Middleware, or negotiates one
+// from the Accept-Language header against the Default catalog.
+func FromRequest(r *http.Request) *Localizer {
+	if l, ok := GetLocalizerFromContext(r.Context()); ok {
+		return l
+	}
+	return Default.Localizer(Default.Negotiate("", r.Header.Get("Accept-Language")))
+}
+
+// Error replies with the request's translation of the message key, like http.Error.
+func Error(w http.ResponseWriter, r *http.Request, key string, code int) {
+	http.Error(w, FromRequest(r).T(key), code)
+}
@@ -0,0 +1,77 @@
+package shared_i18n
+
+// CLDR plural categories, the keys of a plural message.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralCategory returns the CLDR cardinal plural category of the integer n
+// in locale's language. Languages without a rule here use the English one.
+func PluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	lang, region := split(locale)
+	mod10, mod100 := n%10, n%100
+	switch lang {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "tr":
+		return PluralOther
+	case "fr", "hi":
+		if n <= 1 {
+			return PluralOne
+		}
+	case "pt":
+		// Portuguese in Brazil treats 0 as singular, in Portugal it doesn't.
+		if n == 1 || n == 0 && region != "PT" {
+			return PluralOne
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "ru", "uk":
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return PluralOne
+		case n >= 2 && n <= 4:
+			return PluralFew
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return PluralZero
+		case n == 1:
+			return PluralOne
+		case n == 2:
+			return PluralTwo
+		case mod100 >= 3 && mod100 <= 10:
+			return PluralFew
+		case mod100 >= 11:
+			return PluralMany
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+	}
+	return PluralOther
+}
@@ -0,0 +1,81 @@
+package shared_money
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownCurrency = errors.New("unknown ISO 4217 currency")
+
+// Currency describes an ISO 4217 currency.
+type Currency struct {
+	Code string
+	// Digits is the number of minor-unit decimal places, e.g. 2 for GBP, 0 for JPY.
+	Digits int
+	// Symbol is the display symbol; empty means the code is shown instead.
+	// Shared symbols are stored qualified, e.g. "US$", so they are not
+	// ambiguous outside their Home region.
+	Symbol string
+	// Home is the region whose locales show Symbol unqualified, i.e. without
+	// its leading capital letters, for currencies that share a symbol such as
+	// the dollar.
+	Home string
+}
+
+var currencies = map[string]Currency{}
+
+func init() {
+	for _, c := range []Currency{
+		{"AED", 2, "AED", ""}, {"ARS", 2, "AR$", "AR"}, {"AUD", 2, "A$", "AU"}, {"BGN", 2, "лв", ""},
+		{"BHD", 3, "BHD", ""}, {"BRL", 2, "R$", ""}, {"CAD", 2, "CA$", "CA"}, {"CHF", 2, "CHF", ""},
+		{"CLP", 0, "CLP$", "CL"}, {"CNY", 2, "CN¥", "CN"}, {"COP", 2, "COL$", "CO"}, {"CZK", 2, "Kč", ""},
+		{"DKK", 2, "kr.", ""}, {"EGP", 2, "E£", ""}, {"EUR", 2, "€", ""}, {"GBP", 2, "£", ""},
+		{"HKD", 2, "HK$", "HK"}, {"HUF", 2, "Ft", ""}, {"IDR", 2, "Rp", ""}, {"ILS", 2, "₪", ""},
+		{"INR", 2, "₹", ""}, {"IQD", 3, "IQD", ""}, {"ISK", 0, "kr", ""}, {"JOD", 3, "JOD", ""},
+		{"JPY", 0, "¥", "JP"}, {"KES", 2, "KSh", ""}, {"KRW", 0, "₩", ""}, {"KWD", 3, "KWD", ""},
+		{"LYD", 3, "LYD", ""}, {"MXN", 2, "MX$", "MX"}, {"MYR", 2, "RM", ""}, {"NGN", 2, "₦", ""},
+		{"NOK", 2, "kr", ""}, {"NZD", 2, "NZ$", "NZ"}, {"OMR", 3, "OMR", ""}, {"PHP", 2, "₱", ""},
+		{"PKR", 2, "Rs", ""}, {"PLN", 2, "zł", ""}, {"PYG", 0, "₲", ""}, {"QAR", 2, "QAR", ""},
+		{"RON", 2, "lei", ""}, {"SAR", 2, "SAR", ""}, {"SEK", 2, "kr", ""}, {"SGD", 2, "S$", "SG"},
+		{"THB", 2, "฿", ""}, {"TND", 3, "TND", ""}, {"TRY", 2, "₺", ""}, {"TWD", 2, "NT$", "TW"},
+		{"UAH", 2, "₴", ""}, {"UGX", 0, "USh", ""}, {"USD", 2, "US$", "US"}, {"VND", 0, "₫", ""},
+		{"XAF", 0, "FCFA", ""}, {"XOF", 0, "F CFA", ""}, {"ZAR", 2, "R", ""},
+	} {
+		currencies[c.Code] = c
+	}
+}
+
+// LookupCurrency returns the currency for an ISO 4217 code, case-insensitively.
+func LookupCurrency(code string) (Currency, error) {
+	c, ok := currencies[normaliseCode(code)]
+	if !ok {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return c, nil
+}
+
+// Digits returns the minor-unit decimal places of code. Codes not in the
+// table are assumed to have two, which is by far the most common.
+func Digits(code string) int {
+	if c, ok := currencies[normaliseCode(code)]; ok {
+		return c.Digits
+	}
+	return 2
+}
+
+// validCode reports whether code has the shape of an ISO 4217 code.
+func validCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func normaliseCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
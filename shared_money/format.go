@@ -0,0 +1,144 @@
+package shared_money
+
+import (
+	"strings"
+)
+
+// localeFormat is how a locale writes amounts.
+type localeFormat struct {
+	group   string
+	decimal string
+	// symbolAfter places the symbol after the number, e.g. "12,50 €".
+	symbolAfter bool
+	// space separates symbol and number.
+	space string
+}
+
+// localeFormats is keyed by language or language-REGION; the most specific
+// match wins.
+var localeFormats = map[string]localeFormat{
+	"en":    {group: ",", decimal: "."},
+	"en-IE": {group: ",", decimal: "."},
+	"en-ZA": {group: " ", decimal: ","},
+	"de":    {group: ".", decimal: ",", symbolAfter: true, space: " "},
+	"de-CH": {group: "’", decimal: ".", space: " "},
+	"de-AT": {group: " ", decimal: ",", space: " "},
+	"fr":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"fr-CH": {group: " ", decimal: ".", symbolAfter: true, space: " "},
+	"es":    {group: ".", decimal: ",", symbolAfter: true, space: " "},
+	"es-MX": {group: ",", decimal: "."},
+	"it":    {group: ".", decimal: ",", symbolAfter: true, space: " "},
+	"nl":    {group: ".", decimal: ",", space: " "},
+	"pt":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"pt-BR": {group: ".", decimal: ",", space: " "},
+	"sv":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"da":    {group: ".", decimal: ",", symbolAfter: true, space: " "},
+	"nb":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"fi":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"pl":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"cs":    {group: " ", decimal: ",", symbolAfter: true, space: " "},
+	"ja":    {group: ",", decimal: "."},
+	"zh":    {group: ",", decimal: "."},
+	"ko":    {group: ",", decimal: "."},
+	"hi":    {group: ",", decimal: "."},
+}
+
+// Format renders m for display in locale, a BCP 47 tag such as "en-GB" or
+// "de_DE". Unknown locales format like English. Currencies whose symbol is
+// shared, such as the dollar, show the bare symbol only in their home region.
+func (m Money) Format(locale string) string {
+	f, region := lookupFormat(locale)
+	symbol := m.Currency
+	if c, ok := currencies[m.Currency]; ok && c.Symbol != "" {
+		symbol = c.Symbol
+		if c.Home != "" && c.Home == region {
+			symbol = strings.TrimLeft(symbol, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		}
+	}
+	space := f.space
+	if symbol == m.Currency {
+		space = " " // ISO codes never touch the digits
+	}
+
+	decimal := m.Abs().Decimal()
+	if m.Amount < 0 && decimal[0] == '-' { // math.MinInt64
+		decimal = decimal[1:]
+	}
+	whole, frac, _ := strings.Cut(decimal, ".")
+	number := groupDigits(whole, f.group)
+	if frac != "" {
+		number += f.decimal + frac
+	}
+
+	var b strings.Builder
+	if m.Amount < 0 {
+		b.WriteString("-")
+	}
+	if f.symbolAfter {
+		b.WriteString(number + space + symbol)
+	} else {
+		b.WriteString(symbol + space + number)
+	}
+	return b.String()
+}
+
+// FormatNumber renders a plain decimal such as "-1234.5" with the digit
+// grouping and decimal separator of locale, e.g. "-1.234,5" for "de".
+// Input that is not a decimal is returned unchanged.
+func FormatNumber(decimal, locale string) string {
+	f, _ := lookupFormat(locale)
+	sign, digits := "", decimal
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	if whole == "" || !allDigits(whole) || hasFrac && (frac == "" || !allDigits(frac)) {
+		return decimal
+	}
+	number := sign + groupDigits(whole, f.group)
+	if frac != "" {
+		number += f.decimal + frac
+	}
+	return number
+}
+
+func lookupFormat(locale string) (localeFormat, string) {
+	lang, region := splitLocale(locale)
+	f, ok := localeFormats[lang+"-"+region]
+	if !ok {
+		if f, ok = localeFormats[lang]; !ok {
+			f = localeFormats["en"]
+		}
+	}
+	return f, region
+}
+
+func splitLocale(locale string) (lang, region string) {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	lang, rest, _ := strings.Cut(locale, "-")
+	for _, part := range strings.Split(rest, "-") {
+		if len(part) == 2 {
+			region = strings.ToUpper(part)
+			break
+		}
+	}
+	return strings.ToLower(lang), region
+}
+
+func groupDigits(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
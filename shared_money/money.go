@@ -0,0 +1,238 @@
+// Package shared_money represents monetary amounts exactly, as integer minor
+// units of an ISO 4217 currency, so that billing arithmetic never goes
+// through floating point.
+package shared_money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrInvalidAmount    = errors.New("invalid monetary amount")
+	ErrOverflow         = errors.New("monetary amount overflows")
+)
+
+// Money is an amount in minor units (pence, cents, yen) of Currency.
+// The zero value has no currency and only combines with other zero values.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns minor units of currency.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: normaliseCode(currency)}
+}
+
+// Zero returns nothing of currency.
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// Parse reads a decimal amount in major units, such as "19.99" or "-5",
+// exactly. It rejects more decimal places than the currency has, unless the
+// excess digits are zeros. Well-formed codes missing from the currency table
+// are accepted with two decimal places.
+func Parse(amount, currency string) (Money, error) {
+	code := normaliseCode(currency)
+	if !validCode(code) {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	digits := Digits(code)
+
+	s := strings.TrimSpace(amount)
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !allDigits(whole) || !allDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if len(frac) > digits {
+		if strings.Trim(frac[digits:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, amount, digits, code)
+		}
+		frac = frac[:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+
+	digitsOnly := strings.TrimLeft(whole+frac, "0")
+	if digitsOnly == "" {
+		digitsOnly = "0"
+	}
+	minor, err := strconv.ParseInt(digitsOnly, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+	if neg {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: code}, nil
+}
+
+// MustParse is Parse for constants; it panics on error.
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// FromMajor converts a floating-point amount in major units, rounding half
+// away from zero. It exists for values that arrive as floats from elsewhere;
+// prefer Parse.
+func FromMajor(amount float64, currency string) Money {
+	return New(int64(math.Round(amount*math.Pow10(Digits(currency)))), currency)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether the amount is zero, in any currency.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// IsPositive reports whether the amount is above zero.
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// Neg returns -m.
+func (m Money) Neg() Money { return Money{Amount: -m.Amount, Currency: m.Currency} }
+
+// Abs returns |m|.
+func (m Money) Abs() Money {
+	if m.Amount < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// SameCurrency reports whether m and o can be combined. A zero amount with
+// no currency is compatible with every currency.
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == o.Currency ||
+		m.Currency == "" && m.Amount == 0 ||
+		o.Currency == "" && o.Amount == 0
+}
+
+func (m Money) currencyWith(o Money) string {
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return o.Currency
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	if !m.SameCurrency(o) {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: m.currencyWith(o)}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Mul returns m multiplied by a whole quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && (m.Amount*n/n != m.Amount || m.Amount == math.MinInt64 && n == -1) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount * n, Currency: m.Currency}, nil
+}
+
+// Cmp compares m and o, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if !m.SameCurrency(o) {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Equal reports whether m and o are the same amount of the same currency.
+func (m Money) Equal(o Money) bool {
+	c, err := m.Cmp(o)
+	return err == nil && c == 0
+}
+
+// Sum adds amounts that must all share a currency. The sum of nothing is
+// the zero Money.
+func Sum(amounts ...Money) (Money, error) {
+	var total Money
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Decimal formats the amount in major units with the currency's decimal
+// places and no grouping, e.g. "-1234.50". It round-trips through Parse.
+func (m Money) Decimal() string {
+	digits := Digits(m.Currency)
+	sign := ""
+	u := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, u = "-", uint64(-(m.Amount+1))+1
+	}
+	s := strconv.FormatUint(u, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+// String formats m as code and decimal, e.g. "GBP 19.99". Use Format for display to users.
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Currency + " " + m.Decimal()
+}
+
+// UnmarshalJSON decodes {"amount": minor units, "currency": code},
+// normalising the code.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	type plain Money
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = New(p.Amount, p.Currency)
+	return nil
+}
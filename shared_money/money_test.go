@@ -0,0 +1,148 @@
+package shared_money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             Money
+		err              error
+	}{
+		{"19.99", "gbp", New(1999, "GBP"), nil},
+		{"-5", "EUR", New(-500, "EUR"), nil},
+		{"1000", "JPY", New(1000, "JPY"), nil},
+		{"1.250", "BHD", New(1250, "BHD"), nil},
+		{"2.500", "USD", New(250, "USD"), nil},
+		{"2.505", "USD", Money{}, ErrInvalidAmount},
+		{"1.5", "JPY", Money{}, ErrInvalidAmount},
+		{"abc", "USD", Money{}, ErrInvalidAmount},
+		{"1", "US", Money{}, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Parse(%q, %q) = %+v, %v; want %+v, %v", tt.amount, tt.currency, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMulDivRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		num, den int64
+		mode     RoundingMode
+		want     int64
+	}{
+		{"half even rounds down to even", 5, 1, 2, RoundHalfEven, 2},
+		{"half even rounds up to even", 7, 1, 2, RoundHalfEven, 4},
+		{"half even negative", -5, 1, 2, RoundHalfEven, -2},
+		{"half up", 5, 1, 2, RoundHalfUp, 3},
+		{"half up negative", -5, 1, 2, RoundHalfUp, -3},
+		{"down", 999, 1, 10, RoundDown, 99},
+		{"down negative", -999, 1, 10, RoundDown, -99},
+		{"up", 991, 1, 10, RoundUp, 100},
+		{"up negative", -991, 1, 10, RoundUp, -100},
+		{"nearest", 1000, 2, 3, RoundHalfEven, 667},
+		{"negative denominator", 1000, 1, -4, RoundHalfEven, -250},
+		{"exact", 1000, 3, 4, RoundDown, 750},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.amount, "USD").MulDiv(tt.num, tt.den, tt.mode)
+		if err != nil || got.Amount != tt.want {
+			t.Errorf("%s: %d × %d/%d = %d, %v; want %d", tt.name, tt.amount, tt.num, tt.den, got.Amount, err, tt.want)
+		}
+	}
+
+	if _, err := New(1, "USD").MulDiv(1, 0, RoundDown); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("MulDiv by zero = %v, want ErrInvalidAmount", err)
+	}
+	if _, err := New(1<<62, "USD").MulDiv(4, 1, RoundDown); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MulDiv overflowing = %v, want ErrOverflow", err)
+	}
+	// 20% VAT on 19.99 is 3.998.
+	if vat, err := New(1999, "GBP").Percent(2000, RoundHalfUp); err != nil || vat.Amount != 400 {
+		t.Errorf("Percent = %+v, %v; want 400", vat, err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		ratios []int64
+		want   []int64
+	}{
+		{"even", 100, []int64{1, 1}, []int64{50, 50}},
+		{"leftover to the first parts", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"weighted", 1000, []int64{70, 20, 10}, []int64{700, 200, 100}},
+		{"weighted with leftover", 5, []int64{3, 7}, []int64{2, 3}},
+		{"zero ratios get nothing", 10, []int64{0, 1, 0, 2}, []int64{0, 4, 0, 6}},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+	}
+	for _, tt := range tests {
+		parts, err := New(tt.amount, "EUR").Allocate(tt.ratios...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var sum int64
+		for i, p := range parts {
+			sum += p.Amount
+			if p.Amount != tt.want[i] || p.Currency != "EUR" {
+				t.Errorf("%s: part %d = %+v, want %d EUR", tt.name, i, p, tt.want[i])
+			}
+		}
+		if sum != tt.amount {
+			t.Errorf("%s: parts sum to %d, want %d", tt.name, sum, tt.amount)
+		}
+	}
+
+	for _, ratios := range [][]int64{nil, {0, 0}, {1, -1}} {
+		if _, err := New(100, "EUR").Allocate(ratios...); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("Allocate(%v) = %v, want ErrInvalidRatio", ratios, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	parts, err := New(1000, "JPY").Split(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 || parts[0].Amount != 334 || parts[1].Amount != 333 || parts[2].Amount != 333 {
+		t.Errorf("Split(3) = %+v", parts)
+	}
+	for _, n := range []int{0, -1} {
+		if _, err := New(1000, "JPY").Split(n); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("Split(%d) = %v, want ErrInvalidRatio", n, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m      Money
+		locale string
+		want   string
+	}{
+		{New(123456, "USD"), "en-US", "$1,234.56"},
+		{New(123456, "USD"), "en-GB", "US$1,234.56"},
+		{New(123456, "USD"), "es-AR", "1.234,56\u00a0US$"},
+		{New(123456, "ARS"), "es-AR", "1.234,56\u00a0$"},
+		{New(123456, "ARS"), "en-US", "AR$1,234.56"},
+		{New(1234, "CLP"), "es-CL", "1.234\u00a0$"},
+		{New(1234, "CLP"), "en", "CLP$1,234"},
+		{New(123456, "COP"), "es-MX", "COL$1,234.56"},
+		{New(123456, "CAD"), "en-CA", "$1,234.56"},
+		{New(-123456, "EUR"), "de-DE", "-1.234,56\u00a0€"},
+		{New(500, "XYZ"), "en", "XYZ\u00a05.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Format(tt.locale); got != tt.want {
+			t.Errorf("%+v.Format(%q) = %q, want %q", tt.m, tt.locale, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package shared_money
+
+import (
+	"errors"
+	"math/big"
+)
+
+// RoundingMode says what to do with a fraction of a minor unit.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest unit, ties to even (banker's rounding).
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest unit, ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates toward zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+var ErrInvalidRatio = errors.New("allocation ratios must be non-negative and not all zero")
+
+// MulDiv returns m * num / den rounded with mode, computed exactly. It is
+// how fractions of a price are taken, e.g. the unused part of a period as
+// remaining/total nanoseconds.
+func (m Money) MulDiv(num, den int64, mode RoundingMode) (Money, error) {
+	if den == 0 {
+		return Money{}, ErrInvalidAmount
+	}
+	n := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int)) // truncated toward zero
+	if r.Sign() != 0 && roundAway(q, r, d, mode) {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: q.Int64(), Currency: m.Currency}, nil
+}
+
+// roundAway reports whether a truncated quotient q with non-zero remainder r
+// over d should move one unit away from zero.
+func roundAway(q, r, d *big.Int, mode RoundingMode) bool {
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	}
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(d) {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	if mode == RoundHalfUp {
+		return true
+	}
+	return q.Bit(0) == 1 // odd magnitude: round to even
+}
+
+// Percent returns basisPoints/10000 of m, e.g. Percent(2000, …) for 20%.
+func (m Money) Percent(basisPoints int64, mode RoundingMode) (Money, error) {
+	return m.MulDiv(basisPoints, 10000, mode)
+}
+
+// Allocate splits m in proportion to ratios without losing or inventing a
+// minor unit: parts are rounded toward zero and the leftover units go one
+// at a time to the earliest parts.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, ErrInvalidRatio
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, ErrInvalidRatio
+	}
+
+	parts := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, r := range ratios {
+		p, err := m.MulDiv(r, total, RoundDown)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = p
+		remainder -= p.Amount
+	}
+	unit := int64(1)
+	if remainder < 0 {
+		unit = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Amount += unit
+		remainder -= unit
+	}
+	return parts, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrInvalidRatio
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
@@ -0,0 +1,204 @@
+package shared_rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// DefaultCacheTTL is how long a resolved membership is reused before it is fetched again.
+const DefaultCacheTTL = 30 * time.Second
+
+var (
+	ErrNotMember        = errors.New("user is not a member of the organisation")
+	ErrMemberPending    = errors.New("organisation membership is pending")
+	ErrMemberInactive   = errors.New("organisation membership is inactive")
+	ErrUnknownRole      = errors.New("organisation membership has an unknown role")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Membership is the caller's resolved membership of a single organisation.
+type Membership struct {
+	OrgID  string
+	UserID string
+	Role   Role
+	Status string
+}
+
+// Can reports whether the membership is active and its role grants perm.
+func (m Membership) Can(perm Permission) bool {
+	return m.Status == StatusActive && m.Role.Has(perm)
+}
+
+// Identity is the part of the identity client the Authorizer uses. It is
+// satisfied by *client_identity.Client and *client_identity.CachedClient.
+type Identity interface {
+	AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts client_identity.ListOptions) iter.Seq2[client_identity.Member, error]
+}
+
+// Authorizer resolves organisation memberships through client_identity
+// and checks them against the role permission table.
+type Authorizer struct {
+	identity Identity
+	ttl      time.Duration
+
+	// OrgIDVar is the mux route variable holding the organisation ID.
+	OrgIDVar string
+
+	mu        sync.Mutex
+	cache     map[string]cachedMembership
+	nextSweep time.Time
+}
+
+type cachedMembership struct {
+	membership Membership
+	err        error
+	expires    time.Time
+}
+
+// NewAuthorizer creates an Authorizer backed by the given identity client.
+// A ttl of zero disables caching.
+func NewAuthorizer(identity Identity, ttl time.Duration) *Authorizer {
+	return &Authorizer{
+		identity: identity,
+		ttl:      ttl,
+		OrgIDVar: "id",
+		cache:    make(map[string]cachedMembership),
+	}
+}
+
+// Default is the package-level authorizer used by RequireOrgPermission.
+var Default *Authorizer
+
+// Init sets up the Default authorizer against the identity service.
+func Init(identityServiceURL string) {
+	Default = NewAuthorizer(client_identity.NewClient(identityServiceURL), DefaultCacheTTL)
+}
+
+// ensure checks that Default has been initialized.
+func ensure() error {
+	if Default == nil {
+		return errors.New("shared_rbac: not initialized; call Init(identityServiceURL) first")
+	}
+	return nil
+}
+
+func cacheKey(orgID, userID string) string {
+	return orgID + "\x00" + userID
+}
+
+// ResolveMembership returns userID's membership of orgID, fetching it with the
+// caller's cookies when it is not cached. Inactive and pending members are
+// returned together with ErrMemberInactive or ErrMemberPending.
+func (a *Authorizer) ResolveMembership(ctx context.Context, cookies []*http.Cookie, orgID, userID string) (Membership, error) {
+	key := cacheKey(orgID, userID)
+	if a.ttl > 0 {
+		a.mu.Lock()
+		entry, ok := a.cache[key]
+		a.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.membership, entry.err
+		}
+	}
+
+	membership, err := a.fetchMembership(ctx, cookies, orgID, userID)
+	// Only cache definitive answers; transport failures are retried next time.
+	if a.ttl > 0 && (err == nil || isMembershipError(err)) {
+		now := time.Now()
+		a.mu.Lock()
+		a.sweepLocked(now)
+		a.cache[key] = cachedMembership{membership: membership, err: err, expires: now.Add(a.ttl)}
+		a.mu.Unlock()
+	}
+	return membership, err
+}
+
+// sweepLocked drops expired entries at most once per ttl, so the cache only
+// holds memberships resolved within the last two ttls. a.mu must be held.
+func (a *Authorizer) sweepLocked(now time.Time) {
+	if now.Before(a.nextSweep) {
+		return
+	}
+	for key, entry := range a.cache {
+		if !now.Before(entry.expires) {
+			delete(a.cache, key)
+		}
+	}
+	a.nextSweep = now.Add(a.ttl)
+}
+
+func (a *Authorizer) fetchMembership(ctx context.Context, cookies []*http.Cookie, orgID, userID string) (Membership, error) {
+	for m, err := range a.identity.AllMembers(ctx, cookies, orgID, client_identity.ListOptions{}) {
+		var status *client_identity.StatusError
+		if errors.As(err, &status) && (status.StatusCode == http.StatusNotFound || status.StatusCode == http.StatusForbidden) {
+			return Membership{}, ErrNotMember
+		}
+		if err != nil {
+			return Membership{}, fmt.Errorf("list members of %s: %w", orgID, err)
+		}
+		if m.UserID != userID {
+			continue
+		}
+		role, _ := ParseRole(m.Role)
+		membership := Membership{OrgID: orgID, UserID: userID, Role: role, Status: m.Status}
+		switch m.Status {
+		case StatusActive:
+		case StatusPending:
+			return membership, ErrMemberPending
+		default:
+			return membership, ErrMemberInactive
+		}
+		if !role.Valid() {
+			return membership, ErrUnknownRole
+		}
+		return membership, nil
+	}
+	return Membership{}, ErrNotMember
+}
+
+func isMembershipError(err error) bool {
+	return errors.Is(err, ErrNotMember) ||
+		errors.Is(err, ErrMemberPending) ||
+		errors.Is(err, ErrMemberInactive) ||
+		errors.Is(err, ErrUnknownRole)
+}
+
+// CheckPermission resolves the membership and returns ErrPermissionDenied
+// if its role does not grant perm.
+func (a *Authorizer) CheckPermission(ctx context.Context, cookies []*http.Cookie, orgID, userID string, perm Permission) (Membership, error) {
+	membership, err := a.ResolveMembership(ctx, cookies, orgID, userID)
+	if err != nil {
+		return membership, err
+	}
+	if !membership.Role.Has(perm) {
+		return membership, ErrPermissionDenied
+	}
+	return membership, nil
+}
+
+// Invalidate drops the cached membership for userID in orgID.
+// Call it after changing a member's role or status.
+func (a *Authorizer) Invalidate(orgID, userID string) {
+	a.mu.Lock()
+	delete(a.cache, cacheKey(orgID, userID))
+	a.mu.Unlock()
+}
+
+// InvalidateOrg drops every cached membership for orgID.
+func (a *Authorizer) InvalidateOrg(orgID string) {
+	prefix := orgID + "\x00"
+	a.mu.Lock()
+	for key := range a.cache {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.cache, key)
+		}
+	}
+	a.mu.Unlock()
+}
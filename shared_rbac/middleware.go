@@ -0,0 +1,107 @@
+package shared_rbac
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/shared_i18n"
+	"github.com/hstles/go-sdk/shared_utilities"
+)
+
+// Context key for the resolved membership
+type contextKey string
+
+const membershipContextKey = contextKey("org_membership")
+
+// SetMembershipInContext stores the resolved membership in context.
+func SetMembershipInContext(ctx context.Context, membership Membership) context.Context {
+	return context.WithValue(ctx, membershipContextKey, membership)
+}
+
+// GetMembershipFromContext retrieves the membership stored by RequireOrgPermission.
+func GetMembershipFromContext(ctx context.Context) (Membership, bool) {
+	membership, ok := ctx.Value(membershipContextKey).(Membership)
+	return membership, ok
+}
+
+// HasPermission reports whether the membership resolved for this request grants perm.
+// It returns false when no RequireOrgPermission middleware ran for the request.
+func HasPermission(r *http.Request, perm Permission) bool {
+	membership, ok := GetMembershipFromContext(r.Context())
+	return ok && membership.Can(perm)
+}
+
+// RequireOrgPermission returns middleware that only lets active members whose
+// role grants perm through. The organisation ID is read from the OrgIDVar route
+// variable and the user from the session stored by SessionValidationMiddleware.
+func (a *Authorizer) RequireOrgPermission(perm Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := shared_utilities.GetUserIDFromContext(r)
+			if !ok {
+				shared_i18n.Error(w, r, "errors.unauthorized", http.StatusUnauthorized)
+				return
+			}
+			orgID := mux.Vars(r)[a.OrgIDVar]
+			if orgID == "" {
+				shared_i18n.Error(w, r, "errors.org_id_required", http.StatusBadRequest)
+				return
+			}
+
+			membership, err := a.CheckPermission(r.Context(), r.Cookies(), orgID, userID, perm)
+			if err != nil {
+				writeAuthorizationError(w, r, err)
+				return
+			}
+
+			ctx := SetMembershipInContext(r.Context(), membership)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireOrgPermission wraps Authorizer.RequireOrgPermission on the default authorizer.
+func RequireOrgPermission(perm Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := ensure(); err != nil {
+				log.Printf("RequireOrgPermission: %v", err)
+				shared_i18n.Error(w, r, "errors.authorization_unavailable", http.StatusInternalServerError)
+				return
+			}
+			Default.RequireOrgPermission(perm)(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckPermission wraps Authorizer.CheckPermission on the default authorizer
+// for the session user of r.
+func CheckPermission(r *http.Request, orgID string, perm Permission) (Membership, error) {
+	if err := ensure(); err != nil {
+		return Membership{}, err
+	}
+	userID, err := shared_utilities.RequireSessionUser(r)
+	if err != nil {
+		return Membership{}, err
+	}
+	return Default.CheckPermission(r.Context(), r.Cookies(), orgID, userID, perm)
+}
+
+func writeAuthorizationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		shared_i18n.Error(w, r, "errors.forbidden", http.StatusForbidden)
+	case errors.Is(err, ErrMemberPending):
+		shared_i18n.Error(w, r, "errors.membership_pending", http.StatusForbidden)
+	case errors.Is(err, ErrMemberInactive):
+		shared_i18n.Error(w, r, "errors.membership_inactive", http.StatusForbidden)
+	case errors.Is(err, ErrNotMember), errors.Is(err, ErrUnknownRole):
+		shared_i18n.Error(w, r, "errors.forbidden", http.StatusForbidden)
+	default:
+		log.Printf("Organisation permission check error: %v", err)
+		shared_i18n.Error(w, r, "errors.authorization_failed", http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,103 @@
+package shared_rbac
+
+import "strings"
+
+// Role is an organisation membership role as stored in client_identity.Member.Role.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleAdmin   Role = "admin"
+	RoleMember  Role = "member"
+	RoleBilling Role = "billing"
+	RoleViewer  Role = "viewer"
+)
+
+// Permission is a single action a member may perform within an organisation.
+type Permission string
+
+const (
+	PermOrgRead       Permission = "org:read"
+	PermOrgUpdate     Permission = "org:update"
+	PermOrgDelete     Permission = "org:delete"
+	PermMembersRead   Permission = "members:read"
+	PermMembersInvite Permission = "members:invite"
+	PermMembersManage Permission = "members:manage"
+	PermBillingRead   Permission = "billing:read"
+	PermBillingManage Permission = "billing:manage"
+	PermContentRead   Permission = "content:read"
+	PermContentWrite  Permission = "content:write"
+)
+
+// Member status values as stored in client_identity.Member.Status.
+const (
+	StatusActive   = "active"
+	StatusPending  = "pending"
+	StatusInactive = "inactive"
+)
+
+// rolePermissions maps each role to the permissions it grants.
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermOrgRead, PermOrgUpdate, PermOrgDelete,
+		PermMembersRead, PermMembersInvite, PermMembersManage,
+		PermBillingRead, PermBillingManage,
+		PermContentRead, PermContentWrite,
+	},
+	RoleAdmin: {
+		PermOrgRead, PermOrgUpdate,
+		PermMembersRead, PermMembersInvite, PermMembersManage,
+		PermBillingRead,
+		PermContentRead, PermContentWrite,
+	},
+	RoleMember: {
+		PermOrgRead,
+		PermMembersRead,
+		PermContentRead, PermContentWrite,
+	},
+	RoleBilling: {
+		PermOrgRead,
+		PermBillingRead, PermBillingManage,
+	},
+	RoleViewer: {
+		PermOrgRead,
+		PermMembersRead,
+		PermContentRead,
+	},
+}
+
+// ParseRole normalises a free-text role and reports whether it is a known role.
+func ParseRole(s string) (Role, bool) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := rolePermissions[role]
+	return role, ok
+}
+
+// Roles returns every known role, most privileged first.
+func Roles() []Role {
+	return []Role{RoleOwner, RoleAdmin, RoleMember, RoleBilling, RoleViewer}
+}
+
+// Permissions returns the permissions granted to the role.
+func (r Role) Permissions() []Permission {
+	perms := rolePermissions[r]
+	out := make([]Permission, len(perms))
+	copy(out, perms)
+	return out
+}
+
+// Has reports whether the role grants perm. Unknown roles grant nothing.
+func (r Role) Has(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether the role is one of the known roles.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
@@ -0,0 +1,83 @@
+package shared_seats
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// SeatUsageHandler serves GET /api/organisations/{id}/seats.
+func SeatUsageHandler(e *Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		usage, _, err := e.Report(r.Context(), r.Cookies(), orgID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(usage)
+	}
+}
+
+// AddMemberHandler proxies POST /api/organisations/{id}/members with seat enforcement.
+func AddMemberHandler(e *Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		var req client_identity.AddMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, code, err := e.AddMember(r.Context(), r.Cookies(), orgID, req)
+		if err != nil {
+			writeSeatError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// UpdateMemberStatusHandler proxies PUT /api/organisations/{id}/members/{user_id} with seat enforcement.
+func UpdateMemberStatusHandler(e *Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orgID := vars["id"]
+		userID := vars["user_id"]
+		var req client_identity.UpdateMemberStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		resp, code, err := e.UpdateMemberStatus(r.Context(), r.Cookies(), orgID, userID, req)
+		if err != nil {
+			writeSeatError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func writeSeatError(w http.ResponseWriter, err error) {
+	var seatErr *SeatLimitError
+	if !errors.As(err, &seatErr) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPaymentRequired)
+	json.NewEncoder(w).Encode(client_identity.ErrorResponse{
+		Error:   "seat_limit_reached",
+		Message: seatErr.Error(),
+	})
+}
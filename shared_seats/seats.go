@@ -0,0 +1,236 @@
+package shared_seats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+	"github.com/hstles/go-sdk/shared_entitlements"
+	"github.com/hstles/go-sdk/shared_rbac"
+)
+
+// DefaultNearLimitRatio is the share of seats in use at which the near-limit hook fires.
+const DefaultNearLimitRatio = 0.8
+
+// ErrSeatLimitReached is matched by errors.Is for every *SeatLimitError.
+var ErrSeatLimitReached = errors.New("organisation seat limit reached")
+
+// SeatLimitError is returned when adding or activating a member would exceed the plan's seats.
+type SeatLimitError struct {
+	OrgID string
+	Limit int64
+	Used  int64
+}
+
+func (e *SeatLimitError) Error() string {
+	return fmt.Sprintf("organisation %s has used %d of %d seats", e.OrgID, e.Used, e.Limit)
+}
+
+// Is lets errors.Is(err, ErrSeatLimitReached) match.
+func (e *SeatLimitError) Is(target error) bool {
+	return target == ErrSeatLimitReached
+}
+
+// Usage reports seat usage for an organisation. Active and pending members occupy seats.
+type Usage struct {
+	OrgID     string `json:"org_id"`
+	Limit     int64  `json:"limit"`
+	Unlimited bool   `json:"unlimited"`
+	Active    int    `json:"active"`
+	Pending   int    `json:"pending"`
+	Inactive  int    `json:"inactive"`
+	Available int64  `json:"available"`
+}
+
+// Used returns the number of occupied seats.
+func (u Usage) Used() int64 {
+	return int64(u.Active + u.Pending)
+}
+
+// NearLimitHook is called when adding or activating a member takes usage
+// across the enforcer's NearLimitRatio.
+type NearLimitHook func(ctx context.Context, usage Usage, owners []client_identity.Member)
+
+// Identity is the part of the identity client the Enforcer uses. It is
+// satisfied by *client_identity.Client and *client_identity.CachedClient.
+type Identity interface {
+	AllMembers(ctx context.Context, cookies []*http.Cookie, orgID string, opts client_identity.ListOptions) iter.Seq2[client_identity.Member, error]
+	AddMember(ctx context.Context, cookies []*http.Cookie, orgID string, req client_identity.AddMemberRequest) (client_identity.Member, int, error)
+	UpdateMemberStatus(ctx context.Context, cookies []*http.Cookie, orgID, userID string, req client_identity.UpdateMemberStatusRequest) (client_identity.Member, int, error)
+}
+
+// Enforcer applies plan seat limits to organisation membership changes.
+type Enforcer struct {
+	identity     Identity
+	entitlements *shared_entitlements.Resolver
+
+	NearLimitRatio float64
+	OnNearLimit    NearLimitHook
+	// DefaultLimit applies to organisations whose plan declares no "seats"
+	// limit, and to new organisations that have no active owner yet.
+	DefaultLimit int64
+}
+
+// NewEnforcer creates an Enforcer. Seat limits come from the "seats" limit of
+// the organisation's plan; organisations without one get DefaultLimit, which
+// starts as shared_entitlements.Unlimited.
+func NewEnforcer(identity Identity, entitlements *shared_entitlements.Resolver) *Enforcer {
+	return &Enforcer{
+		identity:       identity,
+		entitlements:   entitlements,
+		NearLimitRatio: DefaultNearLimitRatio,
+		DefaultLimit:   shared_entitlements.Unlimited,
+	}
+}
+
+// Report returns the seat usage of orgID together with its member list.
+func (e *Enforcer) Report(ctx context.Context, cookies []*http.Cookie, orgID string) (Usage, []client_identity.Member, error) {
+	members, err := client_identity.Collect(e.identity.AllMembers(ctx, cookies, orgID, client_identity.ListOptions{}))
+	if err != nil {
+		return Usage{}, nil, fmt.Errorf("list members of %s: %w", orgID, err)
+	}
+
+	usage := Usage{OrgID: orgID, Limit: e.DefaultLimit}
+	ent, err := e.entitlements.ForOrganisation(ctx, cookies, orgID)
+	switch {
+	case errors.Is(err, shared_entitlements.ErrNoOrganisationOwner):
+		// A new organisation gets its owner as its first member.
+	case err != nil:
+		return Usage{}, nil, fmt.Errorf("resolve entitlements for %s: %w", orgID, err)
+	default:
+		if limit, ok := ent.Limit(shared_entitlements.LimitSeats); ok {
+			usage.Limit = limit
+		}
+	}
+	usage.Unlimited = usage.Limit == shared_entitlements.Unlimited
+	for _, m := range members {
+		switch m.Status {
+		case shared_rbac.StatusActive:
+			usage.Active++
+		case shared_rbac.StatusPending:
+			usage.Pending++
+		default:
+			usage.Inactive++
+		}
+	}
+	if !usage.Unlimited {
+		usage.Available = max(usage.Limit-usage.Used(), 0)
+	}
+	return usage, members, nil
+}
+
+// CheckAvailable returns a *SeatLimitError if orgID has no free seat.
+func (e *Enforcer) CheckAvailable(ctx context.Context, cookies []*http.Cookie, orgID string) (Usage, error) {
+	usage, _, err := e.Report(ctx, cookies, orgID)
+	if err != nil {
+		return usage, err
+	}
+	if !usage.Unlimited && usage.Available <= 0 {
+		return usage, &SeatLimitError{OrgID: orgID, Limit: usage.Limit, Used: usage.Used()}
+	}
+	return usage, nil
+}
+
+// AddMember adds a member through client_identity after checking a seat is free.
+func (e *Enforcer) AddMember(ctx context.Context, cookies []*http.Cookie, orgID string, req client_identity.AddMemberRequest) (client_identity.Member, int, error) {
+	before, err := e.CheckAvailable(ctx, cookies, orgID)
+	if err != nil {
+		return client_identity.Member{}, 0, err
+	}
+	member, code, err := e.identity.AddMember(ctx, cookies, orgID, req)
+	if err == nil && code < 300 {
+		e.afterChange(ctx, cookies, orgID, before.Used())
+	}
+	return member, code, err
+}
+
+// UpdateMemberStatus updates a member through client_identity. Moving an
+// inactive member to active or pending requires a free seat.
+func (e *Enforcer) UpdateMemberStatus(ctx context.Context, cookies []*http.Cookie, orgID, userID string, req client_identity.UpdateMemberStatusRequest) (client_identity.Member, int, error) {
+	takesSeat := req.Status == shared_rbac.StatusActive || req.Status == shared_rbac.StatusPending
+	var before Usage
+	if takesSeat {
+		usage, members, err := e.Report(ctx, cookies, orgID)
+		if err != nil {
+			return client_identity.Member{}, 0, err
+		}
+		// Members already holding a seat (pending -> active) need no new one.
+		if !occupiesSeat(members, userID) && !usage.Unlimited && usage.Available <= 0 {
+			return client_identity.Member{}, 0, &SeatLimitError{OrgID: orgID, Limit: usage.Limit, Used: usage.Used()}
+		}
+		before = usage
+	}
+	member, code, err := e.identity.UpdateMemberStatus(ctx, cookies, orgID, userID, req)
+	if err == nil && code < 300 && takesSeat {
+		e.afterChange(ctx, cookies, orgID, before.Used())
+	}
+	return member, code, err
+}
+
+func occupiesSeat(members []client_identity.Member, userID string) bool {
+	for _, m := range members {
+		if m.UserID == userID {
+			return m.Status == shared_rbac.StatusActive || m.Status == shared_rbac.StatusPending
+		}
+	}
+	return false
+}
+
+// afterChange calls OnNearLimit when a change took usage from below the
+// near-limit threshold, with usedBefore seats occupied, to at or above it.
+func (e *Enforcer) afterChange(ctx context.Context, cookies []*http.Cookie, orgID string, usedBefore int64) {
+	if e.OnNearLimit == nil {
+		return
+	}
+	usage, members, err := e.Report(ctx, cookies, orgID)
+	if err != nil {
+		log.Printf("shared_seats: seat report after change for %s failed: %v", orgID, err)
+		return
+	}
+	if usage.Unlimited || usage.Limit <= 0 {
+		return
+	}
+	threshold := float64(usage.Limit) * e.NearLimitRatio
+	if float64(usage.Used()) < threshold || float64(usedBefore) >= threshold {
+		return
+	}
+
+	var owners []client_identity.Member
+	for _, m := range members {
+		if role, _ := shared_rbac.ParseRole(m.Role); role == shared_rbac.RoleOwner && m.Status == shared_rbac.StatusActive {
+			owners = append(owners, m)
+		}
+	}
+	e.OnNearLimit(ctx, usage, owners)
+}
+
+// NotifyOwnersHook returns a NearLimitHook that sends each owner a billing
+// notification through notifier, so their notification preferences apply.
+// Owners whose Member.User is not populated have no email address and are
+// only reached on channels that do not need one, such as in-app.
+func NotifyOwnersHook(notifier client_notify.Notifier) NearLimitHook {
+	return func(ctx context.Context, usage Usage, owners []client_identity.Member) {
+		msg := client_notify.Message{
+			Category: client_notify.CategoryBilling,
+			Subject:  "Your organisation is running out of seats",
+			Body: fmt.Sprintf("Your organisation is using %d of %d seats (%d pending). Upgrade your plan to add more members.",
+				usage.Used(), usage.Limit, usage.Pending),
+		}
+		for _, owner := range owners {
+			to := client_notify.Recipient{UserID: owner.UserID}
+			if owner.User != nil {
+				to.Email = owner.User.Email
+				to.Name = strings.TrimSpace(owner.User.FirstName + " " + owner.User.LastName)
+			}
+			if _, err := notifier.Notify(ctx, to, msg); err != nil && !errors.Is(err, client_notify.ErrOptedOut) {
+				log.Printf("shared_seats: notify owner %s: %v", owner.UserID, err)
+			}
+		}
+	}
+}
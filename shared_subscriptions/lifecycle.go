@@ -0,0 +1,196 @@
+package shared_subscriptions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// State is a subscription lifecycle state, stored in client_identity.Subscription.Status.
+type State string
+
+const (
+	StateTrialing State = "trialing"
+	StateActive   State = "active"
+	StatePastDue  State = "past_due"
+	// StateCancelled is cancelled at period end: access continues until EndDate.
+	StateCancelled State = "cancelled"
+	StateExpired   State = "expired"
+)
+
+// ParseState maps a Subscription.Status to a State. The legacy "inactive"
+// status is treated as expired.
+func ParseState(status string) (State, bool) {
+	switch State(status) {
+	case StateTrialing, StateActive, StatePastDue, StateCancelled, StateExpired:
+		return State(status), true
+	case "inactive":
+		return StateExpired, true
+	}
+	return "", false
+}
+
+// Trigger is something that happens to a subscription and may change its state.
+type Trigger string
+
+const (
+	TriggerTrialConverted   Trigger = "trial_converted"
+	TriggerTrialExpired     Trigger = "trial_expired"
+	TriggerRenewed          Trigger = "renewed"
+	TriggerPaymentFailed    Trigger = "payment_failed"
+	TriggerPaymentRecovered Trigger = "payment_recovered"
+	TriggerCancel           Trigger = "cancel"
+	TriggerResume           Trigger = "resume"
+	TriggerPeriodEnded      Trigger = "period_ended"
+	TriggerGraceExpired     Trigger = "grace_expired"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid subscription state transition")
+	ErrUnknownStatus     = errors.New("unknown subscription status")
+)
+
+var transitions = map[State]map[Trigger]State{
+	StateTrialing: {
+		TriggerTrialConverted: StateActive,
+		TriggerTrialExpired:   StateExpired,
+		TriggerPaymentFailed:  StatePastDue,
+		TriggerCancel:         StateCancelled,
+	},
+	StateActive: {
+		TriggerRenewed:       StateActive,
+		TriggerPaymentFailed: StatePastDue,
+		TriggerCancel:        StateCancelled,
+	},
+	StatePastDue: {
+		TriggerPaymentRecovered: StateActive,
+		TriggerRenewed:          StateActive,
+		TriggerPaymentFailed:    StatePastDue,
+		TriggerCancel:           StateCancelled,
+		TriggerGraceExpired:     StateExpired,
+	},
+	StateCancelled: {
+		TriggerResume:      StateActive,
+		TriggerPeriodEnded: StateExpired,
+	},
+	StateExpired: {},
+}
+
+// Transition returns the state reached from from when trigger happens.
+func Transition(from State, trigger Trigger) (State, error) {
+	to, ok := transitions[from][trigger]
+	if !ok {
+		return from, fmt.Errorf("%w: %s on %s", ErrInvalidTransition, trigger, from)
+	}
+	return to, nil
+}
+
+// Policy configures trials, grace periods and how early renewals are flagged.
+type Policy struct {
+	// TrialPeriod is the length of the free trial that starts at StartDate.
+	// Billing periods are anchored at the end of the trial. Zero disables trials.
+	TrialPeriod time.Duration
+	// GracePeriod is how long a past_due subscription keeps access after its
+	// paid-through date before it expires.
+	GracePeriod time.Duration
+	// RenewalLead is how long before the end of a period a renewal is flagged as due.
+	RenewalLead time.Duration
+}
+
+// DefaultPolicy has no trial, a seven day grace period and flags renewals a day early.
+func DefaultPolicy() Policy {
+	return Policy{
+		GracePeriod: 7 * 24 * time.Hour,
+		RenewalLead: 24 * time.Hour,
+	}
+}
+
+// Lifecycle is the evaluated lifecycle of a subscription at a point in time.
+type Lifecycle struct {
+	State    State  `json:"state"`
+	Interval string `json:"interval"`
+	// Anchor is the start of the first billing period.
+	Anchor time.Time `json:"anchor"`
+	// Period is the current billing period, or the trial while trialing.
+	Period   Period     `json:"period"`
+	TrialEnd *time.Time `json:"trial_end,omitempty"`
+	// PaidThrough is Subscription.EndDate when set, otherwise the end of Period.
+	PaidThrough time.Time `json:"paid_through"`
+	// AccessUntil is when access ends if nothing else happens: PaidThrough,
+	// plus the grace period when past due, or the trial end while trialing.
+	// Zero for expired subscriptions.
+	AccessUntil time.Time `json:"access_until"`
+}
+
+// InTrial reports whether the subscription is still inside its trial at t.
+func (l Lifecycle) InTrial(t time.Time) bool {
+	return l.TrialEnd != nil && t.Before(*l.TrialEnd)
+}
+
+// HasAccess reports whether the subscriber should have access to the plan at t.
+func (l Lifecycle) HasAccess(t time.Time) bool {
+	return l.State != StateExpired && t.Before(l.AccessUntil)
+}
+
+// Evaluate computes the lifecycle of sub at now. plan supplies the billing
+// interval and may be nil when sub.Plan is populated.
+func Evaluate(sub client_identity.Subscription, plan *client_identity.Plan, policy Policy, now time.Time) (Lifecycle, error) {
+	if plan == nil {
+		plan = sub.Plan
+	}
+	if plan == nil {
+		return Lifecycle{}, fmt.Errorf("subscription %s: plan %s not loaded", sub.ID, sub.PlanID)
+	}
+	state, ok := ParseState(sub.Status)
+	if !ok {
+		return Lifecycle{}, fmt.Errorf("subscription %s: %w %q", sub.ID, ErrUnknownStatus, sub.Status)
+	}
+	interval, err := NormaliseInterval(plan.Interval)
+	if err != nil {
+		return Lifecycle{}, fmt.Errorf("plan %s: %w: %q", plan.ID, err, plan.Interval)
+	}
+
+	l := Lifecycle{State: state, Interval: interval, Anchor: sub.StartDate}
+	if policy.TrialPeriod > 0 {
+		trialEnd := sub.StartDate.Add(policy.TrialPeriod)
+		l.TrialEnd = &trialEnd
+		l.Anchor = trialEnd
+	}
+
+	if l.InTrial(now) {
+		l.Period = Period{Index: -1, Start: sub.StartDate, End: *l.TrialEnd}
+	} else if l.Period, err = PeriodAt(l.Anchor, interval, now); err != nil {
+		return Lifecycle{}, err
+	}
+
+	l.PaidThrough = l.Period.End
+	if sub.EndDate != nil {
+		l.PaidThrough = *sub.EndDate
+	}
+	switch state {
+	case StateExpired:
+	case StateTrialing:
+		// A trial that ended without converting grants no access past its end.
+		l.AccessUntil = l.PaidThrough
+		if l.TrialEnd != nil && l.TrialEnd.Before(l.AccessUntil) {
+			l.AccessUntil = *l.TrialEnd
+		}
+	case StatePastDue:
+		l.AccessUntil = l.PaidThrough.Add(policy.GracePeriod)
+	default:
+		l.AccessUntil = l.PaidThrough
+	}
+	return l, nil
+}
+
+// NextPaidThrough returns the paid-through date after renewing for one more
+// interval from l.PaidThrough, keeping the anchor's day of month.
+func (l Lifecycle) NextPaidThrough() (time.Time, error) {
+	p, err := PeriodAt(l.Anchor, l.Interval, l.PaidThrough)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return p.End, nil
+}
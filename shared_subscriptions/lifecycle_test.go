@@ -0,0 +1,67 @@
+package shared_subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+var monthly = &client_identity.Plan{ID: "pro", Interval: "monthly"}
+
+func TestEvaluateTrialExpiry(t *testing.T) {
+	start := date(2025, time.March, 1)
+	policy := Policy{TrialPeriod: 14 * 24 * time.Hour, GracePeriod: 7 * 24 * time.Hour}
+	trialEnd := start.Add(policy.TrialPeriod)
+
+	tests := []struct {
+		name   string
+		status string
+		at     time.Time
+		access bool
+	}{
+		{"during the trial", "trialing", start.Add(24 * time.Hour), true},
+		{"just before the trial ends", "trialing", trialEnd.Add(-time.Second), true},
+		{"trial ended without converting", "trialing", trialEnd.Add(time.Hour), false},
+		{"trial converted", "active", trialEnd.Add(time.Hour), true},
+		{"converted and renewed", "active", trialEnd.AddDate(0, 1, 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := client_identity.Subscription{ID: "sub_1", Status: tt.status, StartDate: start}
+			l, err := Evaluate(sub, monthly, policy, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := l.HasAccess(tt.at); got != tt.access {
+				t.Errorf("HasAccess = %v, want %v (lifecycle %+v)", got, tt.access, l)
+			}
+			if l.InTrial(tt.at) != (tt.status == "trialing" && tt.at.Before(trialEnd)) {
+				t.Errorf("InTrial = %v at %s", l.InTrial(tt.at), tt.at)
+			}
+		})
+	}
+
+	// The scheduler flags the end of the trial.
+	sub := client_identity.Subscription{ID: "sub_1", Status: "trialing", StartDate: start}
+	due, ok, err := Check(sub, monthly, policy, trialEnd.Add(time.Hour))
+	if err != nil || !ok || due.Action != ActionEndTrial || !due.At.Equal(trialEnd) {
+		t.Errorf("Check = %+v, %v, %v; want end_trial at %s", due, ok, err, trialEnd)
+	}
+}
+
+func TestEvaluateRejectsUnknownStatusAndInterval(t *testing.T) {
+	now := date(2025, time.March, 1)
+	sub := client_identity.Subscription{ID: "sub_1", Status: "paused", StartDate: now}
+	if _, err := Evaluate(sub, monthly, DefaultPolicy(), now); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Evaluate with status paused = %v, want ErrUnknownStatus", err)
+	}
+	sub.Status = "active"
+	for _, interval := range []string{"", "lifetime"} {
+		plan := &client_identity.Plan{ID: "free", Interval: interval}
+		if _, err := Evaluate(sub, plan, DefaultPolicy(), now); !errors.Is(err, ErrUnknownInterval) {
+			t.Errorf("Evaluate with interval %q = %v, want ErrUnknownInterval", interval, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package shared_subscriptions
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Billing intervals, as stored in client_identity.Plan.Interval.
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+	IntervalYearly  = "yearly"
+)
+
+var ErrUnknownInterval = errors.New("unknown billing interval")
+
+// NormaliseInterval maps the spellings found in plan data ("month", "annual",
+// "year", ...) to one of the Interval constants.
+func NormaliseInterval(interval string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(interval)) {
+	case "daily", "day":
+		return IntervalDaily, nil
+	case "weekly", "week":
+		return IntervalWeekly, nil
+	case "monthly", "month":
+		return IntervalMonthly, nil
+	case "yearly", "year", "annual", "annually":
+		return IntervalYearly, nil
+	}
+	return "", ErrUnknownInterval
+}
+
+// AddIntervals returns the boundary n intervals after anchor. Monthly and
+// yearly boundaries keep the anchor's day of month, clamped to the length of
+// shorter months: a subscription started on 31 January renews on 28 (or 29)
+// February and 31 March, and one started on 29 February renews on 28 February
+// in non-leap years. The time of day is preserved in the anchor's location.
+func AddIntervals(anchor time.Time, interval string, n int) (time.Time, error) {
+	interval, err := NormaliseInterval(interval)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch interval {
+	case IntervalDaily:
+		return anchor.AddDate(0, 0, n), nil
+	case IntervalWeekly:
+		return anchor.AddDate(0, 0, 7*n), nil
+	case IntervalYearly:
+		return addMonthsClamped(anchor, 12*n), nil
+	default:
+		return addMonthsClamped(anchor, n), nil
+	}
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	hh, mm, ss := t.Clock()
+	// Day 0 of the following month is the last day of the target month.
+	lastDay := time.Date(y, m+time.Month(months)+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	return time.Date(y, m+time.Month(months), min(d, lastDay), hh, mm, ss, t.Nanosecond(), t.Location())
+}
+
+// Period is a half-open billing period [Start, End).
+type Period struct {
+	Index int       `json:"index"` // 0 for the first period after the anchor
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains reports whether t falls inside the period.
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// Duration returns the length of the period.
+func (p Period) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// Remaining returns the fraction of the period left at t, between 0 and 1.
+func (p Period) Remaining(t time.Time) float64 {
+	switch {
+	case !t.After(p.Start):
+		return 1
+	case !t.Before(p.End):
+		return 0
+	}
+	return float64(p.End.Sub(t)) / float64(p.Duration())
+}
+
+// remainingRatio returns Remaining(t) as an exact fraction of nanoseconds.
+func (p Period) remainingRatio(t time.Time) (num, den int64) {
+	switch {
+	case p.Duration() <= 0, !t.After(p.Start):
+		return 1, 1
+	case !t.Before(p.End):
+		return 0, 1
+	}
+	return int64(p.End.Sub(t)), int64(p.Duration())
+}
+
+// PeriodAt returns the billing period anchored at anchor that contains t.
+// Times before anchor return the first period.
+func PeriodAt(anchor time.Time, interval string, t time.Time) (Period, error) {
+	if _, err := NormaliseInterval(interval); err != nil {
+		return Period{}, err
+	}
+	n := estimateIntervals(anchor, interval, t)
+	for {
+		start, _ := AddIntervals(anchor, interval, n)
+		if n > 0 && start.After(t) {
+			n--
+			continue
+		}
+		end, _ := AddIntervals(anchor, interval, n+1)
+		if !end.After(t) {
+			n++
+			continue
+		}
+		return Period{Index: n, Start: start, End: end}, nil
+	}
+}
+
+// estimateIntervals gives a starting guess for the number of whole intervals
+// between anchor and t, so PeriodAt only has to correct by a step or two.
+func estimateIntervals(anchor time.Time, interval string, t time.Time) int {
+	if !t.After(anchor) {
+		return 0
+	}
+	interval, _ = NormaliseInterval(interval)
+	switch interval {
+	case IntervalDaily:
+		return int(t.Sub(anchor) / (24 * time.Hour))
+	case IntervalWeekly:
+		return int(t.Sub(anchor) / (7 * 24 * time.Hour))
+	}
+	ay, am, _ := anchor.Date()
+	ty, tm, _ := t.In(anchor.Location()).Date()
+	months := (ty-ay)*12 + int(tm-am)
+	if interval == IntervalYearly {
+		return months / 12
+	}
+	return months
+}
@@ -0,0 +1,71 @@
+package shared_subscriptions
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 9, 30, 0, 0, time.UTC)
+}
+
+func TestAddIntervalsClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		anchor   time.Time
+		interval string
+		n        int
+		want     time.Time
+	}{
+		{"31 Jan to leap February", date(2024, time.January, 31), "monthly", 1, date(2024, time.February, 29)},
+		{"31 Jan to February", date(2025, time.January, 31), "monthly", 1, date(2025, time.February, 28)},
+		{"31 Jan keeps its day in March", date(2025, time.January, 31), "month", 2, date(2025, time.March, 31)},
+		{"31 Jan to April", date(2025, time.January, 31), "monthly", 3, date(2025, time.April, 30)},
+		{"31 Jan across a year", date(2025, time.January, 31), "monthly", 13, date(2026, time.February, 28)},
+		{"29 Feb yearly", date(2024, time.February, 29), "yearly", 1, date(2025, time.February, 28)},
+		{"29 Feb back in a leap year", date(2024, time.February, 29), "annual", 4, date(2028, time.February, 29)},
+		{"29 Feb monthly", date(2024, time.February, 29), "monthly", 1, date(2024, time.March, 29)},
+		{"weekly", date(2024, time.February, 29), "weekly", 1, date(2024, time.March, 7)},
+		{"daily", date(2024, time.February, 28), "day", 2, date(2024, time.March, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AddIntervals(tt.anchor, tt.interval, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("AddIntervals(%s, %s, %d) = %s, want %s", tt.anchor.Format(time.DateOnly), tt.interval, tt.n, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := AddIntervals(date(2024, time.January, 1), "lifetime", 1); err != ErrUnknownInterval {
+		t.Errorf("AddIntervals with a lifetime interval = %v, want ErrUnknownInterval", err)
+	}
+}
+
+func TestPeriodAt(t *testing.T) {
+	anchor := date(2025, time.January, 31)
+	tests := []struct {
+		at         time.Time
+		index      int
+		start, end time.Time
+	}{
+		{date(2025, time.January, 1), 0, anchor, date(2025, time.February, 28)},
+		{date(2025, time.February, 28), 1, date(2025, time.February, 28), date(2025, time.March, 31)},
+		{date(2025, time.April, 15), 2, date(2025, time.March, 31), date(2025, time.April, 30)},
+		{date(2026, time.February, 1), 12, date(2026, time.January, 31), date(2026, time.February, 28)},
+	}
+	for _, tt := range tests {
+		p, err := PeriodAt(anchor, IntervalMonthly, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Index != tt.index || !p.Start.Equal(tt.start) || !p.End.Equal(tt.end) {
+			t.Errorf("PeriodAt(%s) = %d [%s, %s), want %d [%s, %s)", tt.at.Format(time.DateOnly),
+				p.Index, p.Start.Format(time.DateOnly), p.End.Format(time.DateOnly),
+				tt.index, tt.start.Format(time.DateOnly), tt.end.Format(time.DateOnly))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package shared_subscriptions
+
+import (
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+var ErrCurrencyMismatch = shared_money.ErrCurrencyMismatch
+
+// Proration is the adjustment owed when a subscription changes plan mid-period.
+type Proration struct {
+	// Credit is the unused part of the old plan's price for the current period.
+	Credit shared_money.Money `json:"credit"`
+	// Charge is the price of the new plan for the rest of the period, or the
+	// full new price when the period resets.
+	Charge shared_money.Money `json:"charge"`
+	// Net is Charge minus Credit; negative values are owed to the subscriber.
+	Net shared_money.Money `json:"net"`
+	// Remaining is the fraction of the current period left at the change.
+	Remaining float64 `json:"remaining"`
+	// ResetPeriod is true when the intervals differ, so a new billing period
+	// anchored at EffectiveAt starts with the new plan.
+	ResetPeriod bool      `json:"reset_period"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// Prorate computes the adjustment for moving from one plan to another at t,
+// during period. Switching between plans of the same interval charges the
+// price difference for the time left; switching interval credits the unused
+// time and starts a new period on the new plan. Partial amounts are exact
+// fractions of the prices, rounded half to even in the minor unit.
+func Prorate(from, to client_identity.Plan, period Period, t time.Time) (Proration, error) {
+	if from.Price.Currency != to.Price.Currency {
+		return Proration{}, ErrCurrencyMismatch
+	}
+	fromInterval, err := NormaliseInterval(from.Interval)
+	if err != nil {
+		return Proration{}, err
+	}
+	toInterval, err := NormaliseInterval(to.Interval)
+	if err != nil {
+		return Proration{}, err
+	}
+
+	num, den := period.remainingRatio(t)
+	p := Proration{
+		Charge:      to.Price,
+		Remaining:   period.Remaining(t),
+		ResetPeriod: fromInterval != toInterval,
+		EffectiveAt: t,
+	}
+	if p.Credit, err = from.Price.MulDiv(num, den, shared_money.RoundHalfEven); err != nil {
+		return Proration{}, err
+	}
+	if !p.ResetPeriod {
+		if p.Charge, err = to.Price.MulDiv(num, den, shared_money.RoundHalfEven); err != nil {
+			return Proration{}, err
+		}
+	}
+	if p.Net, err = p.Charge.Sub(p.Credit); err != nil {
+		return Proration{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,49 @@
+package shared_subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/shared_money"
+)
+
+func TestProrateRounding(t *testing.T) {
+	period := Period{Start: date(2025, time.April, 1), End: date(2025, time.April, 4)}
+	plan := func(minor int64, interval string) client_identity.Plan {
+		return client_identity.Plan{Price: shared_money.New(minor, "GBP"), Interval: interval}
+	}
+	tests := []struct {
+		name                string
+		from, to            client_identity.Plan
+		at                  time.Time
+		credit, charge, net int64
+		reset               bool
+	}{
+		// Two thirds left: 666.67 rounds up, 1333.33 rounds down.
+		{"upgrade", plan(1000, "monthly"), plan(2000, "monthly"), date(2025, time.April, 2), 667, 1333, 666, false},
+		{"downgrade", plan(2000, "monthly"), plan(1000, "monthly"), date(2025, time.April, 2), 1333, 667, -666, false},
+		// Half left: 500.5 rounds to the even 500 and 501.5 to 502.
+		{"half to even", plan(1001, "monthly"), plan(1003, "monthly"), period.Start.Add(36 * time.Hour), 500, 502, 2, false},
+		{"interval change charges the full price", plan(1000, "monthly"), plan(10000, "yearly"), date(2025, time.April, 2), 667, 10000, 9333, true},
+		{"at the start", plan(1000, "monthly"), plan(2000, "monthly"), period.Start, 1000, 2000, 1000, false},
+		{"at the end", plan(1000, "monthly"), plan(2000, "monthly"), period.End, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Prorate(tt.from, tt.to, period, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Credit.Amount != tt.credit || p.Charge.Amount != tt.charge || p.Net.Amount != tt.net || p.ResetPeriod != tt.reset {
+				t.Errorf("Prorate = credit %d, charge %d, net %d, reset %v; want %d, %d, %d, %v",
+					p.Credit.Amount, p.Charge.Amount, p.Net.Amount, p.ResetPeriod, tt.credit, tt.charge, tt.net, tt.reset)
+			}
+		})
+	}
+
+	if _, err := Prorate(plan(1000, "monthly"), client_identity.Plan{Price: shared_money.New(1000, "EUR"), Interval: "monthly"}, period, period.Start); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Prorate across currencies = %v, want ErrCurrencyMismatch", err)
+	}
+}
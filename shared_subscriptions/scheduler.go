@@ -0,0 +1,193 @@
+package shared_subscriptions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// Action is what a due subscription needs.
+type Action string
+
+const (
+	// ActionRenew: an active subscription reaches the end of its period within RenewalLead.
+	ActionRenew Action = "renew"
+	// ActionEndTrial: a trial ends within RenewalLead and should convert or expire.
+	ActionEndTrial Action = "end_trial"
+	// ActionRetryPayment: a past_due subscription is inside its grace period.
+	ActionRetryPayment Action = "retry_payment"
+	// ActionExpire: a cancelled subscription passed its end date, or a past_due one its grace period.
+	ActionExpire Action = "expire"
+)
+
+// Due flags a subscription that needs an action.
+type Due struct {
+	Subscription client_identity.Subscription `json:"subscription"`
+	Lifecycle    Lifecycle                    `json:"lifecycle"`
+	Action       Action                       `json:"action"`
+	// At is when the action falls due; it may be in the past.
+	At time.Time `json:"at"`
+}
+
+// Check evaluates sub at now and reports the action it needs, if any.
+func Check(sub client_identity.Subscription, plan *client_identity.Plan, policy Policy, now time.Time) (Due, bool, error) {
+	l, err := Evaluate(sub, plan, policy, now)
+	if err != nil {
+		return Due{}, false, err
+	}
+	due := Due{Subscription: sub, Lifecycle: l}
+	switch l.State {
+	case StateTrialing:
+		if l.TrialEnd != nil && !now.Before(l.TrialEnd.Add(-policy.RenewalLead)) {
+			due.Action, due.At = ActionEndTrial, *l.TrialEnd
+		}
+	case StateActive:
+		if !now.Before(l.PaidThrough.Add(-policy.RenewalLead)) {
+			due.Action, due.At = ActionRenew, l.PaidThrough
+		}
+	case StatePastDue:
+		if !now.Before(l.AccessUntil) {
+			due.Action, due.At = ActionExpire, l.AccessUntil
+		} else {
+			due.Action, due.At = ActionRetryPayment, l.PaidThrough
+		}
+	case StateCancelled:
+		if !now.Before(l.PaidThrough) {
+			due.Action, due.At = ActionExpire, l.PaidThrough
+		}
+	}
+	return due, due.Action != "", nil
+}
+
+// Hook handles a due subscription, e.g. charging a renewal or expiring it.
+type Hook func(ctx context.Context, due Due) error
+
+// Scheduler periodically checks subscriptions and passes due ones to a Hook.
+type Scheduler struct {
+	Policy Policy
+	// Source lists the subscriptions to check.
+	Source func(ctx context.Context) ([]client_identity.Subscription, error)
+	// Plan resolves a plan for subscriptions loaded without one. Optional.
+	Plan func(ctx context.Context, planID string) (client_identity.Plan, error)
+	// Hook receives each due subscription.
+	Hook Hook
+	// Interval is how often Start runs a check.
+	Interval time.Duration
+}
+
+// NewScheduler creates a Scheduler that checks the subscriptions from source
+// every hour and hands due ones to hook.
+func NewScheduler(policy Policy, source func(ctx context.Context) ([]client_identity.Subscription, error), hook Hook) *Scheduler {
+	return &Scheduler{
+		Policy:   policy,
+		Source:   source,
+		Hook:     hook,
+		Interval: time.Hour,
+	}
+}
+
+// Due returns the subscriptions needing action at now without running the hook.
+func (s *Scheduler) Due(ctx context.Context, now time.Time) ([]Due, error) {
+	subs, err := s.Source(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+	plans := map[string]*client_identity.Plan{}
+	var due []Due
+	for _, sub := range subs {
+		plan := sub.Plan
+		if plan == nil && s.Plan != nil {
+			if cached, ok := plans[sub.PlanID]; ok {
+				plan = cached
+			} else {
+				p, err := s.Plan(ctx, sub.PlanID)
+				if err != nil {
+					log.Printf("shared_subscriptions: load plan %s: %v", sub.PlanID, err)
+					continue
+				}
+				plan = &p
+				plans[sub.PlanID] = plan
+			}
+		}
+		d, ok, err := Check(sub, plan, s.Policy, now)
+		if err != nil {
+			log.Printf("shared_subscriptions: check %s: %v", sub.ID, err)
+			continue
+		}
+		if ok {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+// RunOnce passes every due subscription to the hook and returns how many were
+// handled successfully. Hook errors are logged and do not stop the run.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.Due(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	handled := 0
+	for _, d := range due {
+		if err := s.Hook(ctx, d); err != nil {
+			log.Printf("shared_subscriptions: %s %s: %v", d.Action, d.Subscription.ID, err)
+			continue
+		}
+		handled++
+	}
+	return handled, nil
+}
+
+// Start runs RunOnce every Interval until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("shared_subscriptions: scheduler run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyTrigger moves sub through the state machine and persists the new
+// status with UpdateSubscription. Renewals, trial conversions and recovered
+// payments extend the end date by one period; cancellation fixes it at the
+// current paid-through date.
+func ApplyTrigger(ctx context.Context, c *client_identity.Client, cookies []*http.Cookie, sub client_identity.Subscription, l Lifecycle, trigger Trigger) (client_identity.Subscription, error) {
+	next, err := Transition(l.State, trigger)
+	if err != nil {
+		return sub, err
+	}
+	status := string(next)
+	req := client_identity.UpdateSubscriptionRequest{Status: &status}
+	switch trigger {
+	case TriggerRenewed, TriggerTrialConverted, TriggerPaymentRecovered:
+		end, err := l.NextPaidThrough()
+		if err != nil {
+			return sub, err
+		}
+		req.EndDate = &end
+	case TriggerCancel:
+		end := l.PaidThrough
+		req.EndDate = &end
+	}
+
+	updated, code, err := c.UpdateSubscription(ctx, cookies, sub.ID, req)
+	if err != nil {
+		return sub, err
+	}
+	if code >= 300 {
+		return sub, fmt.Errorf("update subscription %s: unexpected status %d", sub.ID, code)
+	}
+	return updated, nil
+}
@@ -0,0 +1,96 @@
+package shared_templates
+
+import "time"
+
+// Data is the typed input of one template. TemplateName is the file name
+// under emails/ without extension.
+type Data interface {
+	TemplateName() string
+}
+
+// Template names of the embedded defaults.
+const (
+	TemplateWelcome      = "welcome"
+	TemplateSecurityCode = "security_code"
+	TemplateRecoveryCode = "recovery_code"
+	TemplateServiceAlert = "service_alert"
+	TemplateLoginLink    = "login_link"
+	TemplateGeneric      = "generic"
+	TemplateInvite       = "invite"
+)
+
+type WelcomeData struct {
+	UserName string
+	LoginURL string
+}
+
+func (WelcomeData) TemplateName() string { return TemplateWelcome }
+
+type SecurityCodeData struct {
+	Code string
+	// ExpiresIn is shown in its largest whole unit, e.g. "10 minutes".
+	ExpiresIn time.Duration
+}
+
+func (SecurityCodeData) TemplateName() string { return TemplateSecurityCode }
+
+type RecoveryCodeData struct {
+	UserName string
+	Code     string
+}
+
+func (RecoveryCodeData) TemplateName() string { return TemplateRecoveryCode }
+
+type ServiceAlertData struct {
+	Title   string
+	Message string
+	// ActionURL optionally adds a button labelled ActionLabel.
+	ActionURL   string
+	ActionLabel string
+}
+
+func (ServiceAlertData) TemplateName() string { return TemplateServiceAlert }
+
+type LoginLinkData struct {
+	UserName  string
+	Link      string
+	ExpiresIn time.Duration
+}
+
+func (LoginLinkData) TemplateName() string { return TemplateLoginLink }
+
+// GenericData renders Message as paragraphs split on blank lines.
+type GenericData struct {
+	Subject string
+	Message string
+}
+
+func (GenericData) TemplateName() string { return TemplateGeneric }
+
+type InviteData struct {
+	OrganisationName string
+	InviterName      string
+	Role             string
+	Link             string
+}
+
+func (InviteData) TemplateName() string { return TemplateInvite }
+
+// Samples returns example data for every default template, used by the
+// preview handler.
+func Samples() []Data {
+	return []Data{
+		WelcomeData{UserName: "Alice", LoginURL: "https://app.example.com/login"},
+		SecurityCodeData{Code: "482913", ExpiresIn: 10 * time.Minute},
+		RecoveryCodeData{UserName: "Alice", Code: "R7K2-94QF"},
+		ServiceAlertData{
+			Title:       "Your organisation is running out of seats",
+			Message:     "Your organisation is using 9 of 10 seats. Upgrade your plan to add more members.",
+			ActionURL:   "https://app.example.com/billing",
+			ActionLabel: "Upgrade plan",
+		},
+		LoginLinkData{UserName: "Alice", Link: "https://app.example.com/login?token=abc123", ExpiresIn: 15 * time.Minute},
+		GenericData{Subject: "Scheduled maintenance", Message: "We will be performing maintenance on Sunday.\n\nThe service may be unavailable for up to an hour."},
+		InviteData{OrganisationName: "Acme Ltd", InviterName: "Bob", Role: "member", Link: "https://app.example.com/invites/accept?token=xyz"},
+	}
+}
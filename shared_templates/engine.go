@@ -0,0 +1,288 @@
+// Package shared_templates renders notification emails locally: an HTML
+// and a plain-text template per email, wrapped in shared layouts and
+// partials, with CSS inlined for email clients. Defaults are embedded and
+// can be overridden file by file. Text comes from shared_i18n catalogs under
+// "email.*" keys, so each email renders in the recipient's locale.
+package shared_templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/hstles/go-sdk/shared_i18n"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Brand is shown in every email's header and footer.
+type Brand struct {
+	Name         string
+	URL          string
+	SupportEmail string
+	// Address is a postal address, which some jurisdictions require in bulk email.
+	Address string
+}
+
+// Rendered is a ready-to-send email.
+type Rendered struct {
+	// Locale is the locale the email was rendered in.
+	Locale  string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Link is the argument of the "button" partial; build it with .Button.
+type Link struct {
+	Label string
+	URL   string
+	// Hint introduces the plain URL shown under the button.
+	Hint string
+}
+
+// view is what templates see: .Data is the typed template data, and the
+// embedded Localizer provides .T, .N, .Date, .Number, .Money, .Duration and
+// the other formatting methods.
+type view struct {
+	*shared_i18n.Localizer
+	Data    any
+	Brand   Brand
+	Subject string
+	Lang    string
+}
+
+// Button builds the argument of the "button" partial.
+func (v view) Button(label, url string) Link {
+	return Link{Label: label, URL: url, Hint: v.T("email.button.hint")}
+}
+
+// Role names an organisation role, falling back to the role itself.
+func (v view) Role(role string) string {
+	if key := "email.invite.role." + role; v.Has(key) {
+		return v.T(key)
+	}
+	return role
+}
+
+// Engine holds parsed templates. It is safe for concurrent use.
+type Engine struct {
+	Brand Brand
+	// InlineCSS copies the layout's <style> rules into style attributes,
+	// which many email clients require. It is on by default.
+	InlineCSS bool
+	// Catalog supplies translated text; nil means shared_i18n.Default.
+	Catalog *shared_i18n.Catalog
+
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// New parses the embedded templates overlaid with layers: a file at the
+// same path in a later layer (e.g. "emails/welcome.html" or
+// "layouts/base.html") replaces the earlier one, and new files under
+// emails/ add templates.
+func New(brand Brand, layers ...fs.FS) (*Engine, error) {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	files := &overlay{layers: append([]fs.FS{sub}, layers...)}
+
+	funcs := map[string]any{
+		"paragraphs": paragraphs,
+	}
+	htmlBase := htmltemplate.New("base").Funcs(funcs)
+	textBase := texttemplate.New("base").Funcs(funcs)
+	for _, p := range []string{"layouts/base.html", "partials/*.html"} {
+		if err := files.parseInto(p, func(name, src string) error {
+			_, err := htmlBase.New(name).Parse(src)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range []string{"layouts/base.txt", "partials/*.txt"} {
+		if err := files.parseInto(p, func(name, src string) error {
+			_, err := textBase.New(name).Parse(src)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &Engine{
+		Brand:     brand,
+		InlineCSS: true,
+		html:      make(map[string]*htmltemplate.Template),
+		text:      make(map[string]*texttemplate.Template),
+	}
+	names, err := files.glob("emails/*.html")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range names {
+		name := strings.TrimSuffix(path.Base(file), ".html")
+		h, err := htmlBase.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := files.parseInto(file, func(n, src string) error {
+			_, err := h.New(n).Parse(src)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		t, err := textBase.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := files.parseInto("emails/"+name+".txt", func(n, src string) error {
+			_, err := t.New(n).Parse(src)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		if t.Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s: emails/%s.txt must define \"subject\"", name, name)
+		}
+		e.html[name], e.text[name] = h, t
+	}
+	return e, nil
+}
+
+// Default parses only the embedded templates; it panics if they are broken.
+func Default(brand Brand) *Engine {
+	e, err := New(brand)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Names lists the available templates.
+func (e *Engine) Names() []string {
+	names := make([]string, 0, len(e.html))
+	for name := range e.html {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders the template named by data.TemplateName in the catalog's
+// default locale.
+func (e *Engine) Render(data Data) (Rendered, error) {
+	return e.RenderLocale(data, "")
+}
+
+// RenderLocale renders the template named by data.TemplateName in locale,
+// e.g. a recipient's saved preference. Unsupported locales fall back to the
+// catalog's default; use Catalog.Negotiate to choose from several sources.
+func (e *Engine) RenderLocale(data Data, locale string) (Rendered, error) {
+	name := data.TemplateName()
+	h, t := e.html[name], e.text[name]
+	if h == nil || t == nil {
+		return Rendered{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	catalog := e.catalog()
+	l := catalog.Localizer(catalog.Negotiate(locale, ""))
+	v := view{Localizer: l, Data: data, Brand: e.Brand, Lang: l.Lang()}
+
+	var subject, text, html bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", v); err != nil {
+		return Rendered{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	v.Subject = strings.TrimSpace(subject.String())
+	if err := t.ExecuteTemplate(&text, "layout", v); err != nil {
+		return Rendered{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := h.ExecuteTemplate(&html, "layout", v); err != nil {
+		return Rendered{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	out := Rendered{Locale: l.Locale, Subject: v.Subject, HTML: html.String(), Text: strings.TrimSpace(text.String()) + "\n"}
+	if e.InlineCSS {
+		out.HTML = InlineCSS(out.HTML)
+	}
+	return out, nil
+}
+
+func (e *Engine) catalog() *shared_i18n.Catalog {
+	if e.Catalog != nil {
+		return e.Catalog
+	}
+	return shared_i18n.Default
+}
+
+// paragraphs splits text on blank lines.
+func paragraphs(s string) []string {
+	var out []string
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// overlay resolves template files across layers, later layers first.
+type overlay struct {
+	layers []fs.FS
+}
+
+// glob returns the distinct paths matching pattern in any layer.
+func (o *overlay) glob(pattern string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, l := range o.layers {
+		matches, err := fs.Glob(l, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				out = append(out, m)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// parseInto passes the winning version of each file matching pattern to parse.
+func (o *overlay) parseInto(pattern string, parse func(name, src string) error) error {
+	files, err := o.glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && !strings.Contains(pattern, "*") {
+		return fmt.Errorf("template file %s not found", pattern)
+	}
+	for _, file := range files {
+		var src []byte
+		for i := len(o.layers) - 1; i >= 0; i-- {
+			if src, err = fs.ReadFile(o.layers[i], file); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file, err)
+		}
+		if err := parse(file, string(src)); err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+	}
+	return nil
+}
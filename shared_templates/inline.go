@@ -0,0 +1,244 @@
+package shared_templates
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	styleBlockRe = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	commentRe    = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	startTagRe   = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)(\s[^<>]*?)?(/?)>`)
+	attrRe       = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	styleAttrRe  = regexp.MustCompile(`(?i)\sstyle\s*=\s*(?:"[^"]*"|'[^']*')`)
+	// simpleSelectorRe matches what the inliner understands: an optional
+	// tag, an optional id and any number of classes, with no combinators.
+	simpleSelectorRe = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9]*)?(#[-_a-zA-Z0-9]+)?((?:\.[-_a-zA-Z0-9]+)*)$`)
+)
+
+type cssRule struct {
+	selectors []selector
+	decls     []declaration
+}
+
+type selector struct {
+	tag         string
+	id          string
+	classes     []string
+	specificity int
+}
+
+type declaration struct {
+	property string
+	value    string
+}
+
+type match struct {
+	specificity int
+	order       int
+	decls       []declaration
+}
+
+// InlineCSS moves the rules of html's <style> blocks into the style
+// attributes of the elements they select, as most email clients ignore
+// stylesheets. Only simple selectors (tag, .class, #id and combinations
+// such as a.button) are inlined; at-rules such as @media and rules with
+// other selectors stay in the <style> block for clients that support them.
+// Existing style attributes take precedence over stylesheet rules.
+func InlineCSS(html string) string {
+	var rules []cssRule
+	html = styleBlockRe.ReplaceAllStringFunc(html, func(block string) string {
+		css := styleBlockRe.FindStringSubmatch(block)[1]
+		inlined, kept := parseCSS(css)
+		rules = append(rules, inlined...)
+		if strings.TrimSpace(kept) == "" {
+			return ""
+		}
+		return "<style>\n" + kept + "\n</style>"
+	})
+	if len(rules) == 0 {
+		return html
+	}
+
+	return startTagRe.ReplaceAllStringFunc(html, func(tag string) string {
+		m := startTagRe.FindStringSubmatch(tag)
+		name, attrs, selfClose := strings.ToLower(m[1]), m[2], m[3]
+		var id, style string
+		var classes []string
+		for _, a := range attrRe.FindAllStringSubmatch(attrs, -1) {
+			value := a[2] + a[3]
+			switch strings.ToLower(a[1]) {
+			case "id":
+				id = value
+			case "class":
+				classes = strings.Fields(value)
+			case "style":
+				style = value
+			}
+		}
+
+		var matches []match
+		order := 0
+		for _, r := range rules {
+			for _, sel := range r.selectors {
+				if sel.matches(name, id, classes) {
+					matches = append(matches, match{specificity: sel.specificity, order: order, decls: r.decls})
+				}
+				order++
+			}
+		}
+		if len(matches) == 0 {
+			return tag
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].specificity != matches[j].specificity {
+				return matches[i].specificity < matches[j].specificity
+			}
+			return matches[i].order < matches[j].order
+		})
+
+		var merged []declaration
+		for _, mt := range matches {
+			merged = mergeDecls(merged, mt.decls)
+		}
+		merged = mergeDecls(merged, parseDecls(style))
+		parts := make([]string, len(merged))
+		for i, d := range merged {
+			parts[i] = d.property + ": " + strings.ReplaceAll(d.value, `"`, "'")
+		}
+		styleAttr := `style="` + strings.Join(parts, "; ") + `"`
+
+		loc := styleAttrRe.FindStringIndex(attrs)
+		if loc != nil {
+			attrs = attrs[:loc[0]] + " " + styleAttr + attrs[loc[1]:]
+		} else {
+			attrs = strings.TrimRight(attrs, " ") + " " + styleAttr
+		}
+		return "<" + m[1] + attrs + selfClose + ">"
+	})
+}
+
+// parseCSS splits a stylesheet into inlinable rules and the text to keep.
+func parseCSS(css string) ([]cssRule, string) {
+	css = commentRe.ReplaceAllString(css, "")
+	var rules []cssRule
+	var kept strings.Builder
+	for len(strings.TrimSpace(css)) > 0 {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		// Find the matching brace so nested blocks such as @media stay whole.
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				if depth--; depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		head := strings.TrimSpace(css[:open])
+		body := css[open+1 : end]
+		raw := strings.TrimSpace(css[:end+1])
+		css = css[end+1:]
+
+		if strings.HasPrefix(head, "@") {
+			kept.WriteString(raw + "\n")
+			continue
+		}
+		rule := cssRule{decls: parseDecls(body)}
+		simple := true
+		for _, s := range strings.Split(head, ",") {
+			sel, ok := parseSelector(strings.TrimSpace(s))
+			if !ok {
+				simple = false
+				break
+			}
+			rule.selectors = append(rule.selectors, sel)
+		}
+		if simple {
+			rules = append(rules, rule)
+		} else {
+			kept.WriteString(raw + "\n")
+		}
+	}
+	return rules, kept.String()
+}
+
+func parseSelector(s string) (selector, bool) {
+	m := simpleSelectorRe.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return selector{}, false
+	}
+	sel := selector{tag: strings.ToLower(m[1]), id: strings.TrimPrefix(m[2], "#")}
+	if m[3] != "" {
+		sel.classes = strings.Split(strings.TrimPrefix(m[3], "."), ".")
+	}
+	if sel.id != "" {
+		sel.specificity += 100
+	}
+	sel.specificity += 10 * len(sel.classes)
+	if sel.tag != "" {
+		sel.specificity++
+	}
+	return sel, true
+}
+
+func (s selector) matches(tag, id string, classes []string) bool {
+	if s.tag != "" && s.tag != tag {
+		return false
+	}
+	if s.id != "" && s.id != id {
+		return false
+	}
+	for _, want := range s.classes {
+		found := false
+		for _, c := range classes {
+			if c == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func parseDecls(s string) []declaration {
+	var out []declaration
+	for _, part := range strings.Split(s, ";") {
+		prop, value, ok := strings.Cut(part, ":")
+		prop, value = strings.ToLower(strings.TrimSpace(prop)), strings.TrimSpace(value)
+		if ok && prop != "" && value != "" {
+			out = append(out, declaration{property: prop, value: value})
+		}
+	}
+	return out
+}
+
+// mergeDecls overrides base with later declarations, keeping first-seen order.
+func mergeDecls(base, later []declaration) []declaration {
+	for _, d := range later {
+		replaced := false
+		for i := range base {
+			if base[i].property == d.property {
+				base[i].value = d.value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			base = append(base, d)
+		}
+	}
+	return base
+}
@@ -0,0 +1,93 @@
+package shared_templates
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PreviewHandler serves a development preview of every template rendered
+// with samples, which defaults to Samples(). Do not mount it in production.
+//
+//	GET /            lists templates
+//	GET /?name=welcome             renders the HTML part
+//	GET /?name=welcome&format=text renders the plain-text part
+//
+// Add locale=de to any of them to preview a translation; without it the
+// Accept-Language header decides.
+func PreviewHandler(e *Engine, samples ...Data) http.Handler {
+	if len(samples) == 0 {
+		samples = Samples()
+	}
+	byName := make(map[string]Data, len(samples))
+	for _, s := range samples {
+		byName[s.TemplateName()] = s
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		locale := e.catalog().Negotiate(r.URL.Query().Get("locale"), r.Header.Get("Accept-Language"))
+		if name == "" {
+			writeIndex(w, r, e, byName, locale)
+			return
+		}
+		data, ok := byName[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("no sample data for template %q", name), http.StatusNotFound)
+			return
+		}
+		out, err := e.RenderLocale(data, locale)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Email-Subject", out.Subject)
+		w.Header().Set("Content-Language", out.Locale)
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintf(w, "Subject: %s\n\n%s", out.Subject, out.Text)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, out.HTML)
+	})
+}
+
+func writeIndex(w http.ResponseWriter, r *http.Request, e *Engine, samples map[string]Data, locale string) {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Email templates</title></head>")
+	b.WriteString("<body style=\"font-family: sans-serif; margin: 32px\"><h1>Email templates</h1>")
+	base := html.EscapeString(r.URL.Path)
+	loc := html.EscapeString(url.QueryEscape(locale))
+	b.WriteString("<p>Locale:")
+	for _, l := range e.catalog().Locales() {
+		if l == locale {
+			fmt.Fprintf(&b, " <strong>%s</strong>", html.EscapeString(l))
+		} else {
+			fmt.Fprintf(&b, " <a href=\"%s?locale=%s\">%s</a>", base, html.EscapeString(url.QueryEscape(l)), html.EscapeString(l))
+		}
+	}
+	b.WriteString("</p><table cellpadding=\"6\">")
+	b.WriteString("<tr><th align=\"left\">Template</th><th align=\"left\">Subject</th><th></th></tr>")
+	for _, name := range e.Names() {
+		data, ok := samples[name]
+		if !ok {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td><em>no sample data</em></td><td></td></tr>", html.EscapeString(name))
+			continue
+		}
+		subject := ""
+		if out, err := e.RenderLocale(data, locale); err != nil {
+			subject = "<strong>error:</strong> " + html.EscapeString(err.Error())
+		} else {
+			subject = html.EscapeString(out.Subject)
+		}
+		n := html.EscapeString(name)
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td><a href=\"%s?name=%s&amp;locale=%s\">HTML</a> · <a href=\"%s?name=%s&amp;locale=%s&amp;format=text\">Text</a></td></tr>",
+			n, subject, base, n, loc, base, n, loc)
+	}
+	b.WriteString("</table></body></html>")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/hstles/go-sdk/client_auth"
+	"github.com/hstles/go-sdk/shared_i18n"
 )
 
 // SecurityConfig holds configuration for route-based security
@@ -116,12 +117,12 @@ func SessionValidationMiddleware(authServiceURL string) mux.MiddlewareFunc {
 			resp, code, err := authClient.ValidateSession(r.Context(), r.Cookies())
 			if err != nil {
 				log.Printf("Session validation error: %v", err)
-				http.Error(w, "Session validation failed", code)
+				shared_i18n.Error(w, r, "errors.session_validation_failed", code)
 				return
 			}
 
 			if !resp.Valid {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				shared_i18n.Error(w, r, "errors.unauthorized", http.StatusUnauthorized)
 				return
 			}
 
@@ -143,7 +144,7 @@ func ServiceAuthMiddleware(validAPIKeys map[string]string) mux.MiddlewareFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := r.Header.Get("X-API-Key")
 			if apiKey == "" {
-				http.Error(w, "API key required", http.StatusUnauthorized)
+				shared_i18n.Error(w, r, "errors.api_key_required", http.StatusUnauthorized)
 				return
 			}
 
@@ -158,7 +159,7 @@ func ServiceAuthMiddleware(validAPIKeys map[string]string) mux.MiddlewareFunc {
 
 			if serviceName == "" {
 				log.Printf("Invalid API key attempt from %s", r.RemoteAddr)
-				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				shared_i18n.Error(w, r, "errors.invalid_api_key", http.StatusUnauthorized)
 				return
 			}
 
@@ -185,7 +186,7 @@ func MixedAuthMiddleware(authServiceURL string, validAPIKeys map[string]string)
 						return
 					}
 				}
-				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				shared_i18n.Error(w, r, "errors.invalid_api_key", http.StatusUnauthorized)
 				return
 			}
 
@@ -203,7 +204,7 @@ func MixedAuthMiddleware(authServiceURL string, validAPIKeys map[string]string)
 				return
 			}
 
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			shared_i18n.Error(w, r, "errors.unauthorized", http.StatusUnauthorized)
 		})
 	}
 }
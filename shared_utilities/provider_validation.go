@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/hstles/go-sdk/core_config"
+	"github.com/hstles/go-sdk/shared_i18n"
 )
 
 // ProviderValidationError represents an error when provider validation fails
@@ -114,14 +115,14 @@ func ProviderValidationMiddleware(appName, appDomains string) func(http.Handler)
 			// Get session data from context (should be set by previous middleware)
 			sessionData, ok := r.Context().Value(GetSessionContextKey()).(UserSessionData)
 			if !ok {
-				http.Error(w, "Session data not found in context", http.StatusInternalServerError)
+				shared_i18n.Error(w, r, "errors.session_data_missing", http.StatusInternalServerError)
 				return
 			}
 
 			// Validate provider
 			provider := sessionData.Provider
 			if provider == "" {
-				http.Error(w, "Provider information not available", http.StatusUnauthorized)
+				shared_i18n.Error(w, r, "errors.provider_unavailable", http.StatusUnauthorized)
 				return
 			}
 
@@ -129,7 +130,7 @@ func ProviderValidationMiddleware(appName, appDomains string) func(http.Handler)
 				if pvErr, ok := err.(*ProviderValidationError); ok {
 					http.Error(w, pvErr.Message, http.StatusForbidden)
 				} else {
-					http.Error(w, "Provider validation failed", http.StatusInternalServerError)
+					shared_i18n.Error(w, r, "errors.provider_validation_failed", http.StatusInternalServerError)
 				}
 				return
 			}
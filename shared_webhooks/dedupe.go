@@ -0,0 +1,69 @@
+package shared_webhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DeliveryStore records which event IDs have been processed so retried
+// deliveries are acknowledged without running handlers twice.
+type DeliveryStore interface {
+	// Claim reserves id for processing and reports false if it was already
+	// processed or is being processed.
+	Claim(ctx context.Context, id string) (bool, error)
+	// Complete marks a claimed id as processed.
+	Complete(ctx context.Context, id string) error
+	// Release gives up a claim after a failure so a retry can process it.
+	Release(ctx context.Context, id string) error
+}
+
+// MemoryDeliveryStore is an in-process DeliveryStore that remembers IDs for a fixed retention.
+type MemoryDeliveryStore struct {
+	retention time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // id -> expiry
+	lastSweep time.Time
+}
+
+// NewMemoryDeliveryStore remembers processed IDs for retention.
+func NewMemoryDeliveryStore(retention time.Duration) *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{
+		retention: retention,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryDeliveryStore) Claim(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, exp := range s.seen {
+			if now.After(exp) {
+				delete(s.seen, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if exp, ok := s.seen[id]; ok && now.Before(exp) {
+		return false, nil
+	}
+	s.seen[id] = now.Add(s.retention)
+	return true, nil
+}
+
+func (s *MemoryDeliveryStore) Complete(_ context.Context, id string) error {
+	s.mu.Lock()
+	s.seen[id] = time.Now().Add(s.retention)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryDeliveryStore) Release(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.seen, id)
+	s.mu.Unlock()
+	return nil
+}
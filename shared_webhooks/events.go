@@ -0,0 +1,109 @@
+package shared_webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+	"github.com/hstles/go-sdk/client_notify"
+)
+
+// Identity and auth event types delivered by webhook. They share the
+// client_identity event taxonomy.
+const (
+	EventUserCreated = client_identity.EventUserCreated
+	EventUserUpdated = client_identity.EventUserUpdated
+	EventUserDeleted = client_identity.EventUserDeleted
+
+	EventOrganisationCreated = client_identity.EventOrganisationCreated
+	EventOrganisationUpdated = client_identity.EventOrganisationUpdated
+	EventOrganisationDeleted = client_identity.EventOrganisationDeleted
+
+	EventMemberAdded   = client_identity.EventMemberAdded
+	EventMemberUpdated = client_identity.EventMemberUpdated
+	EventMemberRemoved = client_identity.EventMemberRemoved
+
+	EventSubscriptionCreated   = client_identity.EventSubscriptionCreated
+	EventSubscriptionUpdated   = client_identity.EventSubscriptionUpdated
+	EventSubscriptionCancelled = client_identity.EventSubscriptionCancelled
+	EventSubscriptionExpired   = client_identity.EventSubscriptionExpired
+
+	EventSessionRevoked = client_identity.EventSessionRevoked
+	EventTwoFAEnabled   = client_identity.EventTwoFAEnabled
+	EventTwoFADisabled  = client_identity.EventTwoFADisabled
+	EventUserLockedOut  = client_identity.EventUserLockedOut
+)
+
+// Email delivery event types sent by the notify service.
+const (
+	EventEmailDelivered  = "email.delivered"
+	EventEmailBounced    = "email.bounced"
+	EventEmailComplained = "email.complained"
+)
+
+// Envelope is the JSON body of every delivery. For identity events Data is
+// the payload registered for Type in client_identity, the same metadata
+// carried by client_identity.Event, and UserID is the user the event is
+// about; decode it with Payload or DecodePayload.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	UserID    string          `json:"user_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Event returns the envelope as a client_identity.Event with Data as its Metadata.
+func (e Envelope) Event() client_identity.Event {
+	return client_identity.Event{
+		ID:        e.ID,
+		UserID:    e.UserID,
+		Type:      e.Type,
+		Metadata:  string(e.Data),
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// Payload decodes Data into the payload struct client_identity registers for
+// Type. It fails with client_identity.ErrUnknownEventType for other types,
+// such as the email.* events.
+func (e Envelope) Payload() (client_identity.EventPayload, error) {
+	return e.Event().Payload()
+}
+
+// DecodePayload decodes Data into T, which must be the payload type
+// client_identity registers for the envelope's Type, e.g.
+//
+//	data, err := DecodePayload[client_identity.MemberEventData](env)
+func DecodePayload[T client_identity.EventPayload](e Envelope) (T, error) {
+	return client_identity.DecodeEventMetadata[T](e.Event())
+}
+
+// EmailEvent is the payload of email.* events. MessageID is the ID
+// returned when the email was sent.
+type EmailEvent struct {
+	MessageID  string                   `json:"message_id"`
+	Recipient  string                   `json:"recipient"`
+	Bounce     client_notify.BounceType `json:"bounce,omitempty"`
+	Reason     string                   `json:"reason,omitempty"`
+	OccurredAt time.Time                `json:"occurred_at"`
+}
+
+// Subjects returns the user and organisation IDs an envelope refers to,
+// taken from UserID and the registered payload of identity events.
+func (e Envelope) Subjects() (userID, orgID string) {
+	userID = e.UserID
+	payload, err := e.Payload()
+	if err != nil {
+		return userID, ""
+	}
+	switch p := payload.(type) {
+	case *client_identity.MemberEventData:
+		userID, orgID = p.MemberUserID, p.OrgID
+	case *client_identity.OrganisationEventData:
+		orgID = p.OrgID
+	case *client_identity.InviteEventData:
+		orgID = p.OrgID
+	}
+	return userID, orgID
+}
@@ -0,0 +1,198 @@
+package shared_webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/hstles/go-sdk/client_identity"
+)
+
+// MaxBodyBytes is the largest delivery body the receiver reads.
+const MaxBodyBytes = 1 << 20
+
+// HandlerFunc processes one verified, de-duplicated delivery. Returning an
+// error makes the receiver answer 500 so the sender retries.
+type HandlerFunc func(ctx context.Context, env Envelope) error
+
+// Receiver is an http.Handler for signed webhook deliveries.
+type Receiver struct {
+	secrets   [][]byte
+	store     DeliveryStore
+	Tolerance time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string][]HandlerFunc
+	any      []HandlerFunc
+}
+
+// NewReceiver creates a Receiver accepting deliveries signed with any of
+// secrets and de-duplicated through store. A nil store keeps IDs in memory for 24 hours.
+func NewReceiver(store DeliveryStore, secrets ...[]byte) *Receiver {
+	if store == nil {
+		store = NewMemoryDeliveryStore(24 * time.Hour)
+	}
+	return &Receiver{
+		secrets:   secrets,
+		store:     store,
+		Tolerance: DefaultTolerance,
+		handlers:  make(map[string][]HandlerFunc),
+	}
+}
+
+// On registers fn for eventType. Several handlers may be registered per type.
+func (rc *Receiver) On(eventType string, fn HandlerFunc) {
+	rc.mu.Lock()
+	rc.handlers[eventType] = append(rc.handlers[eventType], fn)
+	rc.mu.Unlock()
+}
+
+// OnAny registers fn for every event type, after type-specific handlers.
+func (rc *Receiver) OnAny(fn HandlerFunc) {
+	rc.mu.Lock()
+	rc.any = append(rc.any, fn)
+	rc.mu.Unlock()
+}
+
+// Handle registers a handler that receives the payload decoded into T.
+func Handle[T any](rc *Receiver, eventType string, fn func(ctx context.Context, env Envelope, payload T) error) {
+	rc.On(eventType, func(ctx context.Context, env Envelope) error {
+		var payload T
+		if err := json.Unmarshal(env.Data, &payload); err != nil {
+			return fmt.Errorf("decode %s payload: %w", env.Type, err)
+		}
+		return fn(ctx, env, payload)
+	})
+}
+
+// HandleEvent registers a handler for an identity event type that receives
+// the client_identity payload registered for it, decoded with DecodePayload.
+func HandleEvent[T client_identity.EventPayload](rc *Receiver, eventType string, fn func(ctx context.Context, env Envelope, payload T) error) {
+	rc.On(eventType, func(ctx context.Context, env Envelope) error {
+		payload, err := DecodePayload[T](env)
+		if err != nil {
+			return fmt.Errorf("decode %s payload: %w", env.Type, err)
+		}
+		return fn(ctx, env, payload)
+	})
+}
+
+// OnUser registers fn for a user.* event.
+func (rc *Receiver) OnUser(eventType string, fn func(ctx context.Context, env Envelope, payload client_identity.UserEventData) error) {
+	HandleEvent(rc, eventType, fn)
+}
+
+// OnOrganisation registers fn for an organisation.* event.
+func (rc *Receiver) OnOrganisation(eventType string, fn func(ctx context.Context, env Envelope, payload client_identity.OrganisationEventData) error) {
+	HandleEvent(rc, eventType, fn)
+}
+
+// OnMember registers fn for a member.* event.
+func (rc *Receiver) OnMember(eventType string, fn func(ctx context.Context, env Envelope, payload client_identity.MemberEventData) error) {
+	HandleEvent(rc, eventType, fn)
+}
+
+// OnSubscription registers fn for a subscription.* event.
+func (rc *Receiver) OnSubscription(eventType string, fn func(ctx context.Context, env Envelope, payload client_identity.SubscriptionEventData) error) {
+	HandleEvent(rc, eventType, fn)
+}
+
+// OnAuth registers fn for an auth.* event.
+func (rc *Receiver) OnAuth(eventType string, fn func(ctx context.Context, env Envelope, payload client_identity.SecurityEventData) error) {
+	HandleEvent(rc, eventType, fn)
+}
+
+// OnEmail registers fn for an email.* event.
+func (rc *Receiver) OnEmail(eventType string, fn func(ctx context.Context, env Envelope, payload EmailEvent) error) {
+	Handle(rc, eventType, fn)
+}
+
+// InvalidateCache drops cached identity data affected by each delivery.
+func (rc *Receiver) InvalidateCache(cc *client_identity.CachedClient) {
+	rc.OnAny(func(_ context.Context, env Envelope) error {
+		userID, orgID := env.Subjects()
+		cc.InvalidateForEvent(env.Type, userID, orgID)
+		return nil
+	})
+}
+
+// ServeHTTP verifies, de-duplicates and dispatches a delivery.
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := Verify(rc.secrets, r.Header.Get(SignatureHeader), body, rc.Tolerance, time.Now()); err != nil {
+		log.Printf("Webhook rejected from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil || env.ID == "" || env.Type == "" {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	claimed, err := rc.store.Claim(r.Context(), env.ID)
+	if err != nil {
+		log.Printf("Webhook %s: claim failed: %v", env.ID, err)
+		http.Error(w, "Delivery store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !claimed {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := rc.dispatch(r.Context(), env); err != nil {
+		log.Printf("Webhook %s (%s) failed: %v", env.ID, env.Type, err)
+		if relErr := rc.store.Release(r.Context(), env.ID); relErr != nil {
+			log.Printf("Webhook %s: release failed: %v", env.ID, relErr)
+		}
+		http.Error(w, "Webhook handler failed", http.StatusInternalServerError)
+		return
+	}
+	if err := rc.store.Complete(r.Context(), env.ID); err != nil {
+		log.Printf("Webhook %s: complete failed: %v", env.ID, err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rc *Receiver) dispatch(ctx context.Context, env Envelope) error {
+	rc.mu.RLock()
+	handlers := append(append([]HandlerFunc(nil), rc.handlers[env.Type]...), rc.any...)
+	rc.mu.RUnlock()
+
+	var errs []error
+	for _, fn := range handlers {
+		if err := safeCall(ctx, fn, env); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// safeCall runs fn, turning a panic into an error so one bad handler cannot
+// take down the server or skip the remaining handlers.
+func safeCall(ctx context.Context, fn HandlerFunc, env Envelope) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Webhook handler panic for %s: %v\n%s", env.Type, p, debug.Stack())
+			err = fmt.Errorf("handler panic: %v", p)
+		}
+	}()
+	return fn(ctx, env)
+}